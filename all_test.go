@@ -17,18 +17,32 @@
 package main
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"go/ast"
 	"go/build"
 	"go/constant"
 	"go/token"
+	"io"
+	"io/ioutil"
+	"math"
 	"math/big"
+	"os"
+	"path/filepath"
 	r "reflect"
+	"strings"
 	"sync"
 	"testing"
 	"time"
+	"unsafe"
 
 	. "github.com/cosmos72/gomacro/ast2"
 	. "github.com/cosmos72/gomacro/base"
+	"github.com/cosmos72/gomacro/base/output"
 	"github.com/cosmos72/gomacro/base/reflect"
 	"github.com/cosmos72/gomacro/base/untyped"
 	"github.com/cosmos72/gomacro/classic"
@@ -44,6 +58,21 @@ var enable_generics_v2_cti = func() bool {
 	return true
 }()
 
+// compiled counterparts of interpreted types used in unsafe_* tests
+type unsafeS struct {
+	A int8
+	B int64
+	C struct {
+		D int16
+		E int32
+	}
+}
+
+type unsafeT struct {
+	X int8
+	unsafeS
+}
+
 type TestFor int
 
 const (
@@ -52,7 +81,9 @@ const (
 	F                          // test for fast interpreter
 	G1                         // test requires generics v1 (C++-style)
 	G2                         // test requires generics v2 "contracts are interfaces"
+	G3                         // test requires generics v3 (standard Go 1.18+ type parameters)
 	U                          // test returns untyped constant (relevant only for fast interpreter)
+	X                          // set option OptUnsafe
 	Go1_13                     // test for Go 1.3 number literals: 0b... binary, 0o... octal, 1.2p3 hex floating point, 1_23 underscore digit separator
 	Z                          // temporary override: run only these tests, on fast interpreter only
 	A      = C | F             // test for both interpreters
@@ -89,7 +120,10 @@ func (tc *TestCase) shouldRun(interp TestFor) bool {
 	if tc.testfor&G2 != 0 && etoken.GENERICS.V2_CTI() {
 		return true
 	}
-	return tc.testfor&(G1|G2) == 0
+	if tc.testfor&G3 != 0 && etoken.GENERICS.V3_GO() {
+		return true
+	}
+	return tc.testfor&(G1|G2|G3) == 0
 }
 
 var foundZ bool
@@ -126,6 +160,1386 @@ func TestFast(t *testing.T) {
 	}
 }
 
+// generics v3 use a different syntax: run their tests separately
+func TestFastGenericsGo(t *testing.T) {
+	saved := etoken.GENERICS
+	etoken.GENERICS = etoken.GENERICS_V3_GO
+	defer func() {
+		etoken.GENERICS = saved
+	}()
+	ir := fast.New()
+	for i := range testcases {
+		test := &testcases[i]
+		if (!foundZ || test.testfor&Z != 0) && test.testfor&G3 != 0 && test.shouldRun(F) {
+			t.Run(test.name, func(t *testing.T) { test.fast(t, ir) })
+		}
+	}
+}
+
+// records the value of local variable n at each breakpoint
+type breakpointRecorder []int64
+
+func (d *breakpointRecorder) Breakpoint(ir *fast.Interp, env *fast.Env) fast.DebugOp {
+	vals, _ := fast.NewInnerInterp(ir, "breakpoint", "breakpoint").Eval("n")
+	*d = append(*d, vals[0].Int())
+	return fast.DebugOpContinue
+}
+
+func (d *breakpointRecorder) At(ir *fast.Interp, env *fast.Env) fast.DebugOp {
+	return fast.DebugOpContinue
+}
+
+func TestFastBreakpoints(t *testing.T) {
+	if foundZ {
+		t.Skip("one or more tests marked with 'Z' i.e. run only those")
+	}
+	var stops breakpointRecorder
+	ir := fast.New()
+	ir.Comp.Options |= OptDebugger
+	ir.SetDebugger(&stops)
+	ir.Eval(`func bp_fact(n int) int {
+		if n <= 1 {
+			return 1
+		}
+		return n * bp_fact(n-1)
+	}`)
+	check := func(expr string, expected ...int64) {
+		stops = nil
+		vals, _ := ir.Eval(expr)
+		if vals[0].Int() != 120 {
+			t.Errorf("%s returned %v, expecting 120", expr, vals[0])
+		}
+		if !r.DeepEqual([]int64(stops), expected) {
+			t.Errorf("%s stopped at breakpoints with n = %v, expecting %v", expr, stops, expected)
+		}
+	}
+	bp, err := ir.AddBreakpoint("bp_fact", "n%2 == 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("bp_fact(5)", 5, 3, 1)
+
+	ir.EnableBreakpoint(bp.ID, false)
+	if _, err = ir.AddBreakpoint("5", ""); err != nil {
+		t.Fatal(err)
+	}
+	check("bp_fact(5)", 5, 4, 3, 2)
+
+	ir.DeleteBreakpoint(bp.ID)
+	if list := ir.ListBreakpoints(); len(list) != 1 || list[0].Line != 5 || list[0].Hits != 4 {
+		t.Errorf("unexpected breakpoints %v", list)
+	}
+	// top-level statements must terminate while breakpoints are enabled
+	ir.Eval("var bp_x = 1")
+	ir.Eval("for i := 0; i < 3; i++ { bp_x++ }")
+	if vals, _ := ir.Eval("bp_x"); vals[0].Int() != 4 {
+		t.Errorf("bp_x = %v, expecting 4", vals[0])
+	}
+
+	// stop at each call, even if the function Env is reused
+	ir.Eval("func bp_id(n int) int { return n }")
+	ir.AddBreakpoint("bp_id", "")
+	stops = nil
+	ir.Eval("bp_id(1)")
+	ir.Eval("bp_id(2)")
+	if !r.DeepEqual([]int64(stops), []int64{1, 2}) {
+		t.Errorf("bp_id stopped at breakpoints with n = %v, expecting [1 2]", stops)
+	}
+
+	for _, loc := range []string{"", ":5", "file:", "1+1"} {
+		if _, err = ir.AddBreakpoint(loc, ""); err == nil {
+			t.Errorf("invalid breakpoint location %q accepted", loc)
+		}
+	}
+}
+
+// compiled functions used by TestFastRecover
+var compiledRecovered interface{}
+
+func compiledRecover() {
+	compiledRecovered = recover()
+}
+
+// compiled code cannot recover() if deferred by interpreted code with arguments:
+// the interpreter invokes it with reflect.Value.Call, i.e. not directly as defer
+func compiledRecover1(x int) {
+	compiledRecovered = recover()
+}
+
+func compiledRecover2(x int, s string) {
+	compiledRecovered = recover()
+}
+
+func compiledRecoverer() func() {
+	return func() {
+		compiledRecovered = recover()
+	}
+}
+
+func compiledDeferPanic(f func(), panick interface{}) {
+	defer f()
+	panic(panick)
+}
+
+func compiledDeferPanic1(f func(int), panick interface{}) {
+	defer f(1)
+	panic(panick)
+}
+
+func compiledDeferPanic1Ret1(f func(int) int, panick interface{}) {
+	defer f(1)
+	panic(panick)
+}
+
+func compiledDeferPanic2(f func(int, string), panick interface{}) {
+	defer f(1, "a")
+	panic(panick)
+}
+
+func compiledDeferPanic2Ret2(f func(int, string) (string, error), panick interface{}) {
+	defer f(1, "a")
+	panic(panick)
+}
+
+func compiledDeferPanicVariadic(f func(...int), panick interface{}) {
+	defer f(1, 2)
+	panic(panick)
+}
+
+func compiledCallRecover(f func()) (rec interface{}) {
+	defer func() {
+		rec = recover()
+	}()
+	f()
+	return nil
+}
+
+// recover() across defers that mix interpreted and compiled functions
+func TestFastRecover(t *testing.T) {
+	if foundZ {
+		t.Skip("one or more tests marked with 'Z' i.e. run only those")
+	}
+	ir := fast.New()
+	ir.DeclFunc("compiled_recover", compiledRecover)
+	ir.DeclFunc("compiled_defer_panic", compiledDeferPanic)
+	ir.DeclFunc("compiled_call_recover", compiledCallRecover)
+	ir.DeclFunc("compiled_recover1", compiledRecover1)
+	ir.DeclFunc("compiled_recover2", compiledRecover2)
+	ir.DeclFunc("compiled_recoverer", compiledRecoverer)
+	ir.DeclFunc("compiled_defer_panic1", compiledDeferPanic1)
+	ir.DeclFunc("compiled_defer_panic1_ret1", compiledDeferPanic1Ret1)
+	ir.DeclFunc("compiled_defer_panic2", compiledDeferPanic2)
+	ir.DeclFunc("compiled_defer_panic2_ret2", compiledDeferPanic2Ret2)
+	ir.DeclFunc("compiled_defer_panic_variadic", compiledDeferPanicVariadic)
+	ir.Eval(`import "fmt"
+		var recovered interface{}
+
+		// interpreted function defers a compiled function that recovers
+		func defer_compiled(panick interface{}) {
+			defer func() {
+				recovered = recover() // nothing to recover
+			}()
+			defer compiled_recover()
+			panic(panick)
+		}
+
+		// interpreted function defers a compiled function that does not recover
+		func defer_compiled_norecover(panick interface{}) {
+			defer func() {
+				recovered = recover()
+			}()
+			defer compiled_recover()
+			defer compiled_recover() // consumes the panic
+			defer func() {
+				panic(panick)
+			}()
+		}
+
+		// a nested panic is recovered while the outer one is in progress
+		func recover_nested() {
+			defer func() {
+				recover()
+			}()
+			panic("nested")
+		}
+		func defer_nested(panick interface{}) {
+			defer func() {
+				recovered = recover()
+			}()
+			defer func() {
+				recover_nested()
+			}()
+			panic(panick)
+		}
+		func defer_compiled_nested(panick interface{}) {
+			defer func() {
+				recovered = recover()
+			}()
+			compiled_defer_panic(func() {
+				recover_nested()
+			}, panick)
+		}
+
+		// interpreted function defers a compiled function with arguments:
+		// its recover() returns nil
+		func defer_compiled1(panick interface{}) {
+			defer func() {
+				recovered = recover()
+			}()
+			defer compiled_recover1(1)
+			panic(panick)
+		}
+		func defer_compiled2(panick interface{}) {
+			defer func() {
+				recovered = recover()
+			}()
+			defer compiled_recover2(1, "a")
+			panic(panick)
+		}
+
+		// interpreted function defers a compiled closure that recovers
+		func defer_compiled_closure(panick interface{}) {
+			defer func() {
+				recovered = recover() // nothing to recover
+			}()
+			defer compiled_recoverer()()
+			panic(panick)
+		}
+
+		// interpreted function defers interpreted functions without arguments
+		func recover0() {
+			recovered = recover()
+		}
+		func defer_interpreted(panick interface{}) {
+			defer recover0()
+			panic(panick)
+		}
+		type recoverer struct{}
+		func (recoverer) Recover() {
+			recovered = recover()
+		}
+		func defer_method_value(panick interface{}) {
+			m := recoverer{}.Recover
+			defer m()
+			panic(panick)
+		}
+
+		func recover1(x int) {
+			recovered = fmt.Sprint(x, " ", recover())
+		}`)
+
+	check := func(expr string, expectRecovered interface{}, expectCompiled interface{}) {
+		t.Helper()
+		ir.Eval("recovered = nil")
+		compiledRecovered = nil
+		ir.Eval(expr)
+		vals, _ := ir.Eval("recovered")
+		if rec := vals[0].Interface(); rec != expectRecovered {
+			t.Errorf("%s: interpreted recover() returned %v, expecting %v", expr, rec, expectRecovered)
+		}
+		if compiledRecovered != expectCompiled {
+			t.Errorf("%s: compiled recover() returned %v, expecting %v", expr, compiledRecovered, expectCompiled)
+		}
+	}
+	// interpreted -> compiled
+	check("defer_compiled(1)", nil, 1)
+	check("defer_compiled_norecover(2)", nil, nil)
+	// interpreted -> interpreted
+	check("defer_nested(3)", 3, nil)
+	// compiled -> interpreted
+	check("compiled_defer_panic(func() { recovered = recover() }, 4)", 4, nil)
+	check(`recovered = compiled_call_recover(func() {
+		compiled_defer_panic(func() { }, 5)
+	})`, 5, nil)
+	check("defer_compiled_nested(6)", 6, nil)
+
+	// interpreted -> compiled, with arguments
+	check("defer_compiled1(7)", 7, nil)
+	check("defer_compiled2(8)", 8, nil)
+	// interpreted -> compiled closure
+	check("defer_compiled_closure(9)", nil, 9)
+	// interpreted -> interpreted, without arguments
+	check("defer_interpreted(10)", 10, nil)
+	check("defer_method_value(11)", 11, nil)
+	// compiled -> interpreted, with parameters and results
+	check(`compiled_defer_panic1(func(x int) {
+		recovered = fmt.Sprint(x, " ", recover())
+	}, 12)`, "1 12", nil)
+	check(`compiled_defer_panic1_ret1(func(x int) int {
+		recovered = fmt.Sprint(x, " ", recover())
+		return x
+	}, 13)`, "1 13", nil)
+	check(`compiled_defer_panic2(func(x int, s string) {
+		recovered = fmt.Sprint(x, " ", s, " ", recover())
+	}, 14)`, "1 a 14", nil)
+	check(`compiled_defer_panic2_ret2(func(x int, s string) (string, error) {
+		recovered = fmt.Sprint(x, " ", s, " ", recover())
+		return s, nil
+	}, 15)`, "1 a 15", nil)
+	check(`compiled_defer_panic_variadic(func(xs ...int) {
+		recovered = fmt.Sprint(xs, " ", recover())
+	}, 16)`, "[1 2] 16", nil)
+	check("compiled_defer_panic1(recover1, 17)", "1 17", nil)
+	// compiled -> interpreted with parameters, not recovering
+	check(`recovered = compiled_call_recover(func() {
+		compiled_defer_panic2(func(x int, s string) { }, 18)
+	})`, 18, nil)
+}
+
+// writeFiles creates the given files and their parent directories inside dir.
+// File names use '/' as separator
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// source of third-party packages imported by TestFastImportSource
+var importSourceFiles = map[string]string{
+	"example.com/greet@v0.9.0/greet.go": `package greet
+
+	this version is older and not valid Go code
+`,
+	"example.com/greet@v1.0.0/greet.go": `package greet
+
+import (
+	"strings"
+
+	"example.com/util/text"
+)
+
+var prefix = text.Title(word) + ", "
+
+var greetings []string
+
+func init() {
+	greetings = append(greetings, "hello")
+}
+
+func Greet(name string) string {
+	return prefix + text.Upper(name) + strings.Repeat("!", exclamations)
+}
+
+func (g Greeter) Greet() string {
+	return Greet(g.Name)
+}
+`,
+	"example.com/greet@v1.0.0/types.go": `package greet
+
+import "example.com/util/text"
+
+const exclamations = 2
+
+var word = "hello"
+
+type Greeter struct {
+	Name string
+}
+
+func init() {
+	greetings = append(greetings, "bye")
+}
+
+func Count() int {
+	return len(greetings)
+}
+`,
+	"example.com/greet@v1.0.0/greet_test.go": `package greet
+
+	tests are not imported
+`,
+	"example.com/util@v1.2.0/text/text.go": `package text
+
+import "strings"
+
+func Upper(s string) string {
+	return strings.ToUpper(s)
+}
+
+func Title(s string) string {
+	return Upper(s[:1]) + s[1:]
+}
+`,
+}
+
+// interpret from source a third-party package and its dependencies
+func TestFastImportSource(t *testing.T) {
+	if foundZ {
+		t.Skip("one or more tests marked with 'Z' i.e. run only those")
+	}
+	modcache := t.TempDir()
+	writeFiles(t, modcache, importSourceFiles)
+	t.Setenv("GOMODCACHE", modcache)
+	t.Setenv("GOPATH", t.TempDir())
+	t.Setenv("GOPROXY", "off")
+
+	ir := fast.New()
+	ir.Comp.Options |= OptModuleImport
+	if _, err := ir.ImportPackageOrError("_s", "example.com/greet"); err != nil {
+		t.Fatal(err)
+	}
+	check := func(expr string, expected interface{}) {
+		t.Helper()
+		vals, _ := ir.Eval(expr)
+		if actual := vals[0].Interface(); actual != expected {
+			t.Errorf("%s returned %v, expecting %v", expr, actual, expected)
+		}
+	}
+	check(`greet.Greet("world")`, "Hello, WORLD!!")
+	check(`greet.Greeter{"gopher"}.Greet()`, "Hello, GOPHER!!")
+	check(`greet.Count()`, 2)
+
+	if _, err := ir.ImportPackageOrError("_s", "example.com/missing"); err == nil {
+		t.Errorf("importing a missing package from source succeeded")
+	}
+}
+
+var runPackageFiles = map[string]string{
+	"a.go": `package main
+
+import (
+	"os"
+	"strings"
+)
+
+var order = []string{"var " + helper()}
+
+func init() {
+	order = append(order, "init a")
+}
+
+func main() {
+	order = append(order, "main")
+	os.Setenv("GOMACRO_TEST_RUN_PACKAGE", strings.Join(append(order, os.Args[1:]...), ", "))
+}
+`,
+	"b.go": `package main
+
+func init() {
+	order = append(order, "init b")
+}
+
+func helper() string {
+	return "b"
+}
+`,
+	"ignored.go": `//go:build ignore
+
+package main
+
+func init() {
+	order = append(order, "ignored")
+}
+`,
+	"main_test.go": `package main
+
+	tests are not executed
+`,
+}
+
+// interpret a whole main package directory, as "gomacro run" does
+func TestFastRunPackage(t *testing.T) {
+	if foundZ {
+		t.Skip("one or more tests marked with 'Z' i.e. run only those")
+	}
+	dir := t.TempDir()
+	writeFiles(t, dir, runPackageFiles)
+	t.Setenv("GOMACRO_TEST_RUN_PACKAGE", "")
+	saveArgs := os.Args
+	defer func() {
+		os.Args = saveArgs
+	}()
+	os.Args = []string{dir, "arg"}
+
+	ir := fast.New()
+	if err := ir.RunPackage(dir); err != nil {
+		t.Fatal(err)
+	}
+	const expected = "var b, init a, init b, main, arg"
+	if actual := os.Getenv("GOMACRO_TEST_RUN_PACKAGE"); actual != expected {
+		t.Errorf("RunPackage executed %q, expecting %q", actual, expected)
+	}
+	if err := ir.RunPackage(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("running a missing directory succeeded")
+	}
+}
+
+// Go language version option: go1.21 and older share loop variables among iterations, and are the default
+func TestFastLoopVarLang(t *testing.T) {
+	if foundZ {
+		t.Skip("one or more tests marked with 'Z' i.e. run only those")
+	}
+	const src = `var funs []func() int
+		for i := 0; i < 3; i++ { funs = append(funs, func() int { return i }) }
+		for _, e := range []int{4, 5, 6} { funs = append(funs, func() int { return e }) }
+		sum := 0
+		for j, f := range funs { sum += f() << uint(4*j) }
+		sum`
+	for _, test := range []struct {
+		name   string
+		lang   string
+		src    string
+		expect interface{}
+	}{
+		{"loopvar_default", "", src, 0x666333},
+		{"loopvar_go1.21", "go1.21", src, 0x666333},
+		{"loopvar_go1.22", "go1.22", src, 0x654210},
+		{"for_loopvar_closure", "go1.22", `var vfuns []func() int
+			for i := 0; i < 3; i++ { vfuns = append(vfuns, func() int { return i }) }
+			vfuns[0]() + 10*vfuns[1]() + 100*vfuns[2]()`, 210},
+		{"for_loopvar_address", "go1.22", `var vptrs []*string
+			for s := "a"; len(s) < 4; s += "b" { if len(s) == 2 { continue }; vptrs = append(vptrs, &s) }
+			*vptrs[0] + *vptrs[1]`, "aabb"},
+		{"for_loopvar_post", "go1.22", `var vfuns []func() int
+			for i := 0; i < 4; i++ { vfuns = append(vfuns, func() int { return i }); i++ }
+			vfuns[0]() + 10*vfuns[1]()`, 31},
+		{"for_range_loopvar_slice", "go1.22", `var vfuns []func() int
+			for _, e := range []int{4, 5, 6} { vfuns = append(vfuns, func() int { return e }) }
+			vfuns[0]() + 10*vfuns[1]() + 100*vfuns[2]()`, 654},
+		{"for_range_loopvar_string", "go1.22", `var vfuns []func() int
+			for i := range "xyz" { vfuns = append(vfuns, func() int { return i }) }
+			vfuns[0]() + 10*vfuns[1]() + 100*vfuns[2]()`, 210},
+		{"for_range_int_loopvar", "go1.22", `var vfuns []func() int
+			for i := range 3 { vfuns = append(vfuns, func() int { return i }) }
+			vfuns[0]() + 10*vfuns[1]() + 100*vfuns[2]()`, 210},
+	} {
+		ir := fast.New()
+		if test.lang == "" {
+			// keep the default
+		} else if err := ir.Comp.SetLangVersion(test.lang); err != nil {
+			t.Fatal(err)
+		}
+		vals, _ := ir.Eval(test.src)
+		if len(vals) != 1 || vals[0].Interface() != test.expect {
+			t.Errorf("%s: expecting %v, found %v", test.name, test.expect, vals)
+		}
+	}
+}
+
+// compiledSeq returns a compiled iterator function over 0 ... n-1
+func compiledSeq(n int) func(func(int) bool) {
+	return func(yield func(int) bool) {
+		for i := 0; i < n; i++ {
+			if !yield(i) {
+				return
+			}
+		}
+	}
+}
+
+// compiledIgnoreFalse is a compiled iterator that keeps calling yield after it returned false
+func compiledIgnoreFalse(yield func(int) bool) {
+	yield(0)
+	yield(1)
+}
+
+func TestFastRangeFunc(t *testing.T) {
+	if foundZ {
+		t.Skip("one or more tests marked with 'Z' i.e. run only those")
+	}
+	ir := fast.New()
+	ir.DeclFunc("seq", compiledSeq)
+	ir.DeclFunc("ignore_false", compiledIgnoreFalse)
+	ir.Eval(`import "fmt"
+		func iseq(n int) func(func(int) bool) {
+			return func(yield func(int) bool) { for i := 0; i < n; i++ { if !yield(i) { return } } }
+		}
+		func labels(seq func(int) func(func(int) bool)) string {
+			s := ""
+		outer:
+			for i := range seq(4) {
+				for j := range seq(4) {
+					if j > i {
+						continue outer
+					} else if i == 3 {
+						break outer
+					}
+					s += fmt.Sprint(i*10+j, " ")
+				}
+			}
+			return s
+		}
+		func find(seq func(int) func(func(int) bool), x int) (int, bool) {
+			for i := range seq(10) {
+				for j := range seq(10) {
+					if i*j == x {
+						return i*10 + j, true
+					}
+				}
+			}
+			return -1, false
+		}
+		func gotos(seq func(int) func(func(int) bool)) int {
+			for i := range seq(10) {
+				if i == 4 {
+					goto done
+				}
+			}
+			return -1
+		done:
+			return 4
+		}
+		func defers(seq func(int) func(func(int) bool)) (s string) {
+			defer func() { s += "!" }()
+			for i := range seq(3) {
+				defer func() { s += fmt.Sprint(i) }()
+			}
+			s = "x"
+			return
+		}
+		func panics(seq func(int) func(func(int) bool)) (s string) {
+			defer func() { s += fmt.Sprint(recover()) }()
+			for i := range seq(3) {
+				defer func() { s += fmt.Sprint(i) }()
+				if i == 1 {
+					panic("boom")
+				}
+			}
+			return
+		}`)
+
+	for _, seq := range []string{"seq", "iseq"} {
+		for _, test := range []struct {
+			expr   string
+			expect interface{}
+		}{
+			{"labels(%s)", "0 10 11 20 21 22 "},
+			{"fmt.Sprint(find(%s, 12))", "26 true"},
+			{"fmt.Sprint(find(%s, 100))", "-1 false"},
+			{"gotos(%s)", 4},
+			{"defers(%s)", "x210!"},
+			{"panics(%s)", "10boom"},
+		} {
+			expr := fmt.Sprintf(test.expr, seq)
+			vals, _ := ir.Eval(expr)
+			if len(vals) != 1 || vals[0].Interface() != test.expect {
+				t.Errorf("%s: expecting %v, found %v", expr, test.expect, vals)
+			}
+		}
+	}
+
+	func() {
+		defer func() {
+			rec := fmt.Sprint(recover())
+			if !strings.Contains(rec, "continued iteration after function for loop body returned false") {
+				t.Errorf("range over ignore_false: unexpected panic %v", rec)
+			}
+		}()
+		ir.Eval("for range ignore_false { break }")
+		t.Errorf("range over ignore_false: expecting panic")
+	}()
+}
+
+// bound evaluation with steps, time and context limits
+func TestFastEvalContext(t *testing.T) {
+	if foundZ {
+		t.Skip("one or more tests marked with 'Z' i.e. run only those")
+	}
+	ir := fast.New()
+	ir.Eval(`func fib(n int) int { if n < 2 { return n }; return fib(n-1) + fib(n-2) }
+		func stubborn() { defer func() { recover() }(); for {} }
+		var n int`)
+
+	check := func(ctx context.Context, src string, kind fast.LimitKind) {
+		_, _, err := ir.EvalContext(ctx, src)
+		if lerr, ok := err.(*fast.LimitError); !ok || lerr.Kind != kind {
+			t.Errorf("EvalContext(%q): expecting LimitError with Kind = %v, found %v", src, kind, err)
+		}
+	}
+	ir.SetLimits(fast.Limits{MaxSteps: 100000})
+	check(context.Background(), "for {}", fast.LimitSteps)
+	check(context.Background(), "fib(40)", fast.LimitSteps)
+	if vals, _, err := ir.EvalContext(context.Background(), "fib(10)"); err != nil || vals[0].Interface() != 55 {
+		t.Errorf("EvalContext(fib(10)): expecting 55, found %v %v", vals, err)
+	}
+
+	ir.SetLimits(fast.Limits{Timeout: 50 * time.Millisecond})
+	check(context.Background(), "for { stubborn() }", fast.LimitTimeout)
+
+	ir.SetLimits(fast.Limits{})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	check(ctx, "for i := 0; i < 4; i++ { go func() { for { n++ } }() }; for {}", fast.LimitContext)
+	if err := ctx.Err(); err != context.DeadlineExceeded {
+		t.Errorf("expecting context.DeadlineExceeded, found %v", err)
+	}
+	// goroutines started by the stopped evaluation must be stopped too
+	time.Sleep(10 * time.Millisecond)
+	vals, _ := ir.Eval("n")
+	time.Sleep(10 * time.Millisecond)
+	if vals2, _ := ir.Eval("n"); vals[0].Interface() != vals2[0].Interface() {
+		t.Errorf("goroutines still running after EvalContext was stopped: n = %v then %v", vals[0], vals2[0])
+	}
+
+	if _, _, err := ir.EvalContext(ctx, "1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("EvalContext with expired context: expecting context.DeadlineExceeded, found %v", err)
+	}
+	if _, _, err := ir.EvalContext(context.Background(), "panic(42)"); err == nil || err.Error() != "42" {
+		t.Errorf("EvalContext(panic(42)): expecting error 42, found %v", err)
+	}
+}
+
+func TestFastPolicy(t *testing.T) {
+	if foundZ {
+		t.Skip("one or more tests marked with 'Z' i.e. run only those")
+	}
+	ir := fast.New()
+	for _, sym := range []string{"os", "os/Exit", "os.", ".Exit", "os/.Exit", "os.File.Close"} {
+		if err := ir.SetPolicy(fast.Policy{DenySymbols: []string{sym}}); err == nil {
+			t.Errorf("SetPolicy(DenySymbols: %q): expecting error, found success", sym)
+		}
+	}
+	if err := ir.SetPolicy(fast.Policy{
+		AllowPackages: []string{"fmt", "strings", "math/..."},
+		AllowSymbols:  []string{"os.Getenv", "os.File"},
+		DenySymbols:   []string{"fmt.Sscan"},
+		NoPlugin:      true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ok := func(src string) {
+		if _, _, err := ir.EvalContext(context.Background(), src); err != nil {
+			t.Errorf("Eval(%q): unexpected error %v", src, err)
+		}
+	}
+	fail := func(src string, line int, msg string) {
+		_, _, err := ir.EvalContext(context.Background(), src)
+		rerr, isr := err.(output.RuntimeError)
+		if !isr || !strings.Contains(err.Error(), msg) {
+			t.Errorf("Eval(%q): expecting error %q, found %v", src, msg, err)
+		} else if pos := rerr.Position(); pos.Line != line {
+			t.Errorf("Eval(%q): expecting error at line %d, found %v", src, line, pos)
+		}
+	}
+	ok(`import ("fmt"; "math/rand"; "os"); fmt.Sprint(rand.Int(), os.Getenv("HOME")); var f *os.File`)
+	fail("1\nimport \"os/exec\"", 2, `import "os/exec" forbidden by policy`)
+	fail("import _ \"math\"\nimport \"unsafe\"", 2, `import "unsafe" forbidden by policy`)
+	fail("os.Getpid()\n", 1, "use of os.Getpid forbidden by policy")
+	fail("\n\nos.Args[0] = \"\"", 3, "use of os.Args forbidden by policy")
+	fail("var s os.Signal", 1, "use of os.Signal forbidden by policy")
+	fail("fmt.Sscan", 1, "use of fmt.Sscan forbidden by policy")
+	fail("import . \"fmt\"\nSprint(1)\nSscan", 3, "undefined identifier: Sscan")
+	fail(`Eval(~quote{1}, Interp())`, 1, "passing an explicit interpreter to Eval() forbidden by policy")
+	fail(`import "github.com/no/such/package"`, 1, `import "github.com/no/such/package" forbidden by policy`)
+	// the policy also applies to code compiled by Eval and macros
+	ok(`Eval(~quote{fmt.Sprint(1)})`)
+	fail(`Eval(~quote{os.Exit(1)})`, 1, "use of os.Exit forbidden by policy")
+
+	if err := ir.SetPolicy(fast.Policy{NoPlugin: true}); err != nil {
+		t.Fatal(err)
+	}
+	fail(`import _3 "github.com/no/such/package"`, 1, "plugin imports are disabled")
+}
+
+func TestFastProcess(t *testing.T) {
+	if foundZ {
+		t.Skip("one or more tests marked with 'Z' i.e. run only those")
+	}
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"file.txt": "content"})
+	src := `import ("fmt"; "io/ioutil"; "log"; "os")
+		func exit() { defer func() { recover() }(); os.Exit(3) }
+		var n int
+		fmt.Scan(&n)
+		fmt.Println(os.Args[1], n)
+		fmt.Fprintln(os.Stderr, "err")
+		os.Stdout.WriteString("direct\n")
+		os.Setenv("FOO", os.Getenv("FOO") + "!")
+		wd, _ := os.Getwd()
+		f, _ := os.Open("file.txt")
+		data, _ := ioutil.ReadAll(f)
+		log.SetFlags(0)
+		log.Println(os.Getenv("FOO"), wd == os.Args[2], string(data))
+		println("builtin")
+		exit()
+		fmt.Println("unreachable")`
+
+	run := func(arg string, stdin string) (string, string) {
+		var stdout, stderr bytes.Buffer
+		ir := fast.New()
+		err := ir.SetProcess(fast.Process{
+			Stdin:  strings.NewReader(stdin),
+			Stdout: &stdout,
+			Stderr: &stderr,
+			Args:   []string{"script", arg, dir},
+			Env:    []string{"FOO=" + arg},
+			Dir:    dir,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _, err = ir.EvalContext(context.Background(), src)
+		if eerr, ok := err.(*fast.ExitError); !ok || eerr.Code != 3 {
+			t.Errorf("expecting ExitError with Code = 3, found %v", err)
+		}
+		ir.CloseProcess()
+		return stdout.String(), stderr.String()
+	}
+	for _, arg := range []string{"a", "b"} {
+		stdout, stderr := run(arg, "42\n")
+		if want := arg + " 42\ndirect\n"; stdout != want {
+			t.Errorf("wrong stdout: expecting %q, found %q", want, stdout)
+		}
+		if want := "err\n" + arg + "! true content\nbuiltin\n"; stderr != want {
+			t.Errorf("wrong stderr: expecting %q, found %q", want, stderr)
+		}
+	}
+	if _, ok := os.LookupEnv("FOO"); ok {
+		t.Errorf("os.Setenv in interpreted code modified the process environment")
+	}
+}
+
+func TestFastProcessGoExit(t *testing.T) {
+	if foundZ {
+		t.Skip("one or more tests marked with 'Z' i.e. run only those")
+	}
+	var stdout bytes.Buffer
+	ir := fast.New()
+	if err := ir.SetProcess(fast.Process{Stdout: &stdout}); err != nil {
+		t.Fatal(err)
+	}
+	defer ir.CloseProcess()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, _, err := ir.EvalContext(ctx, `import ("fmt"; "os")
+		go func() { os.Exit(4) }()
+		for i := 0; ; i++ {
+			if i == 1 {
+				fmt.Println("running")
+			}
+		}`)
+	if eerr, ok := err.(*fast.ExitError); !ok || eerr.Code != 4 {
+		t.Errorf("expecting ExitError with Code = 4, found %v", err)
+	}
+	if want := "running\n"; stdout.String() != want {
+		t.Errorf("wrong stdout: expecting %q, found %q", want, stdout.String())
+	}
+	// the interpreter can run code again
+	vals, _ := ir.Eval("1 + 2")
+	if len(vals) != 1 || vals[0].Int() != 3 {
+		t.Errorf("expecting 3 after exit from goroutine, found %v", vals)
+	}
+}
+
+func TestFastSession(t *testing.T) {
+	if foundZ {
+		t.Skip("one or more tests marked with 'Z' i.e. run only those")
+	}
+	dir, err := ioutil.TempDir("", "gomacro_session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "session.gomacro")
+
+	var warnings bytes.Buffer
+	ir := fast.New()
+	ir.Comp.Globals.Stderr = &warnings
+	ir.Eval(`macro second(a, b interface{}) interface{} { return b }`)
+	ir.Eval(`import ("fmt"; s "strings")
+		type Point struct { X, Y int; Tag string }
+		func (p Point) Sum() int { return p.X + p.Y }
+		const Big = 1 << 100
+		const Third = 1.0 / 3
+		const Typed int8 = -5
+		var pts = []Point{{1, 2, "a"}, {X: 3}}
+		var m = map[string][]float64{"b": {1.5}, "a": nil}
+		var any interface{} = Point{X: 7}
+		var ch = make(chan int)
+		var f = s.ToUpper
+		func double(x int) int { return x }
+		func double(x int) int { return 2 * x }`)
+	if err := ir.SaveSession(filename); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"ch", "f"} {
+		if want := "variable " + name; !strings.Contains(warnings.String(), want) {
+			t.Errorf("expecting a warning containing %q, found %q", want, warnings.String())
+		}
+	}
+
+	ir = fast.New()
+	if err := ir.LoadSession(filename); err != nil {
+		t.Fatal(err)
+	}
+	vals, _ := ir.Eval(`fmt.Sprint(pts, m, any, Big >> 98, Third, Typed, double(4), pts[0].Sum(), f == nil, ch == nil)`)
+	want := "[{1 2 a} {3 0 }] map[a:[] b:[1.5]] {7 0 } 4 0.3333333333333333 -5 8 3 true true"
+	if len(vals) != 1 || vals[0].Interface() != want {
+		t.Errorf("wrong session: expecting %q, found %v", want, vals)
+	}
+	if vals, _ = ir.Eval("second; 1; 2"); len(vals) != 1 || vals[0].Interface() != 2 {
+		t.Errorf("wrong macro second: expecting 2, found %v", vals)
+	}
+}
+
+func TestFastUndo(t *testing.T) {
+	if foundZ {
+		t.Skip("one or more tests marked with 'Z' i.e. run only those")
+	}
+	ir := fast.New()
+	defined := func(name string) bool {
+		_, ok := ir.Comp.Binds[name]
+		_, isType := ir.Comp.Types[name]
+		return ok || isType
+	}
+	eval := func(src string) interface{} {
+		vals, _ := ir.Eval(src)
+		if len(vals) == 0 {
+			return nil
+		}
+		return vals[0].Interface()
+	}
+	eval(`func f() int { return 1 }`)
+	eval(`type T struct{ X int }; func g() int { return f() + 1 }`)
+	eval(`var x = 3`)
+	eval(`x = 4`) // declares nothing, not counted by Undo
+	eval(`func f() int { return 10 }`)
+
+	if undone, dependents := ir.Undo(1); undone != 1 || len(dependents) != 1 || dependents[0] != "g" {
+		t.Errorf("Undo(1): expecting 1 [g], found %d %v", undone, dependents)
+	}
+	if v := eval(`f()`); v != 1 {
+		t.Errorf("after undo, expecting f() = 1, found %v", v)
+	}
+	if undone, _ := ir.Undo(2); undone != 2 || defined("x") || defined("g") || defined("T") || !defined("f") {
+		t.Errorf("Undo(2): expecting only f to be defined, undone = %d", undone)
+	}
+
+	eval(`type T struct{ X int }; var y T; func h() T { return y }`)
+	dependents, err := ir.Forget("T")
+	if err != nil || len(dependents) != 2 || dependents[0] != "h" || dependents[1] != "y" {
+		t.Errorf("Forget(T): expecting [h y], found %v %v", dependents, err)
+	}
+	if defined("T") {
+		t.Errorf("T still defined after Forget")
+	}
+	if _, err = ir.Forget("T"); err == nil {
+		t.Errorf("Forget(T) again: expecting error, found success")
+	}
+	if ir.Undo(1); !defined("T") {
+		t.Errorf("T not restored by Undo after Forget")
+	}
+
+	ir.Reset()
+	if defined("f") || defined("T") || ir.Comp.Path != "main" {
+		t.Errorf("declarations survived Reset")
+	}
+	if v := eval(`import "strings"; strings.ToUpper("ok")`); v != "OK" {
+		t.Errorf("after reset, expecting \"OK\", found %v", v)
+	}
+}
+
+func TestFastProfile(t *testing.T) {
+	if foundZ {
+		t.Skip("one or more tests marked with 'Z' i.e. run only those")
+	}
+	ir := fast.New()
+	ir.Eval(`func spin(n int) int { s := 0; for i := 0; i < n; i++ { s += i }; return s }`)
+	if err := ir.StartProfile(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ir.StartProfile(); err == nil {
+		t.Errorf("StartProfile twice: expecting error, found success")
+	}
+	for start := time.Now(); time.Since(start) < 200*time.Millisecond; {
+		ir.Eval(`spin(100000)`)
+	}
+	var buf bytes.Buffer
+	if err := ir.StopProfile(&buf); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte("main.spin")) {
+		t.Errorf("profile does not contain function main.spin")
+	}
+	if err := ir.StopProfile(&buf); err == nil {
+		t.Errorf("StopProfile twice: expecting error, found success")
+	}
+}
+
+func TestFastCoverage(t *testing.T) {
+	if foundZ {
+		t.Skip("one or more tests marked with 'Z' i.e. run only those")
+	}
+	ir := fast.New()
+	if err := ir.StartCoverage("count"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ir.StartCoverage("set"); err == nil {
+		t.Errorf("StartCoverage with different mode: expecting error, found success")
+	}
+	ir.Eval(`func sign(n int) int {
+	if n < 0 {
+		return -1
+	} else if n == 0 {
+		return 0
+	}
+	return 1
+}
+func twice(n int) int {
+	double := func(x int) int {
+		return x * 2
+	}
+	return double(n) + double(n)
+}`)
+	ir.Eval(`sign(-5); sign(3); sign(4); twice(1)`)
+
+	var buf bytes.Buffer
+	if err := ir.WriteCoverage(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := `mode: count
+repl.go:2.2,2.10 1 3
+repl.go:3.3,3.12 1 1
+repl.go:4.9,4.18 1 2
+repl.go:5.3,5.11 1 0
+repl.go:7.2,7.10 1 2
+repl.go:10.2,10.12 1 1
+repl.go:11.3,11.15 1 2
+repl.go:13.2,13.30 1 1
+`
+	if actual := buf.String(); actual != expected {
+		t.Errorf("expecting coverage\n%s\nfound\n%s", expected, actual)
+	}
+}
+
+func TestFastTrace(t *testing.T) {
+	if foundZ {
+		t.Skip("one or more tests marked with 'Z' i.e. run only those")
+	}
+	ir := fast.New()
+	var buf bytes.Buffer
+	ir.SetTraceOutput(&buf)
+	ir.Eval(`func fib(n int) int { if n < 2 { return n }; return fib(n-1) + fib(n-2) }`)
+	ir.Eval(`func boom(s string) { panic(s) }`)
+	ir.Eval(`func safe() (r interface{}) { defer func() { r = recover() }(); boom("x"); return nil }`)
+	for _, name := range []string{"fib", "boom"} {
+		if err := ir.Trace(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ir.Trace("safe2"); err == nil {
+		t.Errorf("Trace(safe2): expecting error, found success")
+	}
+	ir.Eval(`fib(2)`)
+	ir.Eval(`safe()`)
+	if err := ir.Untrace(""); err != nil || len(ir.Traced()) != 0 {
+		t.Errorf("Untrace(\"\"): expecting no traced functions, found %v %v", ir.Traced(), err)
+	}
+	ir.Eval(`fib(3)`)
+
+	var events []string
+	dec := json.NewDecoder(&buf)
+	for {
+		var e fast.TraceEvent
+		if err := dec.Decode(&e); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, fmt.Sprintf("%s %d %s%v%v%s", e.Event, e.Depth, e.Func, e.Args, e.Results, e.Panic))
+	}
+	expected := []string{
+		"call 0 fib[2][]",
+		"call 1 fib[1][]",
+		"return 1 fib[1][1]",
+		"call 1 fib[0][]",
+		"return 1 fib[0][0]",
+		"return 0 fib[2][1]",
+		"call 1 boom[\"x\"][]",
+		"panic 1 boom[\"x\"][]x",
+	}
+	if strings.Join(events, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expecting trace\n%s\nfound\n%s", strings.Join(expected, "\n"), strings.Join(events, "\n"))
+	}
+}
+
+func TestFastHooks(t *testing.T) {
+	if foundZ {
+		t.Skip("one or more tests marked with 'Z' i.e. run only those")
+	}
+	ir := fast.New()
+	ir.Eval(`func add(a, b int) int { return a + b }`)
+	ir.Eval(`func boom() { panic("x") }`)
+	ir.Eval(`func safe() (r interface{}) { defer func() { r = recover() }(); boom(); return nil }`)
+	// redefining safe() must not rename its function literal to main.safe.func2
+	ir.Eval(`func safe() (r interface{}) { defer func() { r = recover() }(); boom(); return nil }`)
+
+	var mu sync.Mutex
+	var events []string
+	record := func(format string, args ...interface{}) {
+		mu.Lock()
+		events = append(events, fmt.Sprintf(format, args...))
+		mu.Unlock()
+	}
+	goend := make(chan struct{})
+	ir.SetHooks(&fast.Hooks{
+		FuncEnter: func(call fast.CallInfo) { record("enter %s %d", call.Func, call.Depth) },
+		FuncExit:  func(call fast.CallInfo, panicking bool) { record("exit %s %v", call.Func, panicking) },
+		Panic:     func(value interface{}, pos token.Position) { record("panic %v %d", value, pos.Line) },
+		Recover:   func(value interface{}, pos token.Position) { record("recover %v", value) },
+		GoStart:   func(goid uintptr) { record("go start") },
+		GoEnd:     func(goid uintptr) { record("go end"); close(goend) },
+		Import:    func(path string) { record("import %s", path) },
+	})
+	ir.Eval(`add(1, 2)`)
+	ir.Eval(`safe()`)
+	ir.Eval(`import "container/list"`)
+	ir.Eval(`go add(3, 4)`)
+	select {
+	case <-goend:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for Hooks.GoEnd")
+	}
+	var lines []int
+	ir.SetHooks(&fast.Hooks{
+		Stmt: func(pos token.Position) { lines = append(lines, pos.Line) },
+	})
+	ir.Eval("x := 1\nx++\nx = add(x, 1)")
+	ir.SetHooks(nil)
+	ir.Eval(`add(5, 6)`)
+
+	expected := []string{
+		"enter main.add 1",
+		"exit main.add false",
+		"enter main.safe 1",
+		"enter main.boom 2",
+		"panic x 1",
+		"exit main.boom true",
+		"enter main.safe.func1 3", // invoked while unwinding boom()
+		"recover x",
+		"exit main.safe.func1 false",
+		"exit main.safe false",
+		"import container/list",
+		"go start",
+		"enter main.add 1",
+		"exit main.add false",
+		"go end",
+	}
+	if strings.Join(events, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expecting hook events\n%s\nfound\n%s", strings.Join(expected, "\n"), strings.Join(events, "\n"))
+	}
+	if fmt.Sprint(lines) != "[1 2 3 1 1]" {
+		t.Errorf("expecting Hooks.Stmt lines [1 2 3 1 1], found %v", lines)
+	}
+}
+
+func TestFastReload(t *testing.T) {
+	if foundZ {
+		t.Skip("one or more tests marked with 'Z' i.e. run only those")
+	}
+	dir := t.TempDir()
+	file := filepath.Join(dir, "service.go")
+	write := func(src string) {
+		writeFiles(t, dir, map[string]string{"service.go": src})
+	}
+	write(`package main
+type P struct{ X int }
+var count = 0
+func f(n int) int { count++; return n * 2 }
+func (p P) Get() int { return p.X }
+var h = f
+var get = P{5}.Get
+`)
+	ir := fast.New()
+	if _, err := ir.EvalFile(file); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ir.Reload(file); err != nil {
+		t.Fatal(err)
+	}
+	ir.Eval(`f(1); f(2)`)
+	ch := make(chan int)
+	ir.DeclVar("ch", nil, ch)
+	ir.Eval(`go func() { for n := range ch { ch <- h(n) } }()`)
+
+	write(`package main
+type P struct{ X int }
+var count = 0
+func f(n int) int { count += 10; return n * 3 }
+func (p P) Get() int { return p.X + 100 }
+var h = f
+var get = P{5}.Get
+func g() int { return 7 }
+`)
+	reloaded, err := ir.Reload(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(reloaded) != "[f P.Get g]" {
+		t.Errorf("Reload: expecting reloaded [f P.Get g], found %v", reloaded)
+	}
+	ch <- 4
+	if n := <-ch; n != 12 {
+		t.Errorf("after Reload, goroutine calling h(4): expecting 12, found %d", n)
+	}
+	close(ch)
+	for _, test := range []struct {
+		src      string
+		expected interface{}
+	}{
+		{"f(1)", 3},
+		{"h(1)", 3},
+		{"get()", 105},
+		{"P{1}.Get()", 101},
+		{"g()", 7},
+		{"count", 32}, // two calls before Reload, three after
+	} {
+		v, _ := ir.Eval1(test.src)
+		if !v.IsValid() || v.Interface() != test.expected {
+			t.Errorf("after Reload, expecting %s = %v, found %v", test.src, test.expected, v)
+		}
+	}
+
+	write(`package main
+type P struct{ X, Y int }
+func f(n int) string { return "" }
+func (p P) Get() int { return p.X }
+`)
+	reloaded, err = ir.Reload(file)
+	if err == nil || !strings.Contains(err.Error(), "cannot reload type P") {
+		t.Errorf("Reload of changed type: expecting error containing %q, found %v", "cannot reload type P", err)
+	}
+	if fmt.Sprint(reloaded) != "[f P.Get]" {
+		t.Errorf("Reload: expecting reloaded [f P.Get], found %v", reloaded)
+	}
+	if v, _ := ir.Eval1("h(1)"); !v.IsValid() || v.Interface() != 3 {
+		t.Errorf("after Reload of changed signature, expecting h(1) = 3, found %v", v)
+	}
+	if v, _ := ir.Eval1("f(1)"); !v.IsValid() || v.Interface() != "" {
+		t.Errorf("after Reload of changed signature, expecting f(1) = \"\", found %v", v)
+	}
+}
+
+// run with "go test -race" to detect data races between Reload and running goroutines
+func TestFastReloadConcurrent(t *testing.T) {
+	if foundZ {
+		t.Skip("one or more tests marked with 'Z' i.e. run only those")
+	}
+	dir := t.TempDir()
+	file := filepath.Join(dir, "service.go")
+	write := func(n int) {
+		writeFiles(t, dir, map[string]string{"service.go": fmt.Sprintf(`package main
+var count int
+func incr() { count += %d }
+`, n)})
+	}
+	write(1)
+	ir := fast.New()
+	if _, err := ir.EvalFile(file); err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan bool)
+	done := make(chan bool)
+	ir.DeclVar("stop", nil, stop)
+	ir.DeclVar("done", nil, done)
+	ir.Eval(`go func() {
+		incr()
+		done <- true
+		for {
+			select {
+			case <-stop:
+				done <- true
+				return
+			default:
+				incr()
+			}
+		}
+	}()`)
+	<-done
+
+	reloaded := make(chan error)
+	go func() {
+		// reload in another goroutine, as Cmd.Watch does
+		var err error
+		for i := 2; i <= 10 && err == nil; i++ {
+			write(i)
+			_, err = ir.Reload(file)
+		}
+		reloaded <- err
+	}()
+	// declare new globals, growing the top-level Env, during and after Reload
+	for i := 0; i < 10; i++ {
+		ir.Eval(fmt.Sprintf("var x%d = %d", i, i))
+	}
+	if err := <-reloaded; err != nil {
+		t.Fatal(err)
+	}
+	for i := 10; i < 20; i++ {
+		ir.Eval(fmt.Sprintf("var x%d = %d", i, i))
+	}
+	close(stop)
+	<-done
+	if v, _ := ir.Eval1("count"); !v.IsValid() || v.Int() <= 0 {
+		t.Errorf("after Reload, expecting count > 0, found %v", v)
+	}
+	if v, _ := ir.Eval1("x19"); !v.IsValid() || v.Interface() != 19 {
+		t.Errorf("expecting x19 = 19, found %v", v)
+	}
+}
+
+func TestFastLateBinding(t *testing.T) {
+	if foundZ {
+		t.Skip("one or more tests marked with 'Z' i.e. run only those")
+	}
+	ir := fast.New()
+	ir.Eval(`
+type T struct{}
+func g(n int) int { return n * 2 }
+func f() int { return g(3) }
+func (T) M() int { return g(5) }
+var h = g`)
+
+	eval := func(src string, expected interface{}) {
+		v, _ := ir.Eval1(src)
+		if !v.IsValid() || v.Interface() != expected {
+			t.Errorf("expecting %s = %v, found %v", src, expected, v)
+		}
+	}
+	// same signature: all callers and function values see the new definition
+	ir.Eval(`func g(n int) int { return n * 3 }`)
+	eval("f()", 9)
+	eval("T{}.M()", 15)
+	eval("h(1)", 3)
+
+	// different signature: callers are recompiled, function values keep the previous definition
+	ir.Eval(`func g(n ...int) int { return len(n) * 100 }`)
+	eval("f()", 100)
+	eval("T{}.M()", 100)
+	eval("h(1)", 3)
+
+	// callers that no longer compile keep the previous definition
+	ir.Eval(`func g(s string) string { return s + s }`)
+	eval("f()", 100)
+	eval(`g("a")`, "aa")
+
+	// callers of methods cannot be found, changing their signature is an error
+	func() {
+		defer func() {
+			rec := recover()
+			if rec == nil || !strings.Contains(fmt.Sprint(rec), "cannot redefine method main.T.M with a different signature") {
+				t.Errorf("redefining method with different signature: expecting error, found %v", rec)
+			}
+		}()
+		ir.Eval(`func (T) M() string { return "" }`)
+	}()
+	eval("T{}.M()", 100)
+}
+
 type shouldpanic struct{}
 
 func (shouldpanic) String() string {
@@ -164,6 +1578,11 @@ func (test *TestCase) fast(t *testing.T, ir *fast.Interp) {
 	} else {
 		ir.Comp.Options &^= OptKeepUntyped
 	}
+	if test.testfor&X != 0 {
+		ir.Comp.Options |= OptUnsafe
+	} else {
+		ir.Comp.Options &^= OptUnsafe
+	}
 
 	panicking := true
 	if test.result0 == panics {
@@ -538,7 +1957,8 @@ var testcases = []TestCase{
 	TestCase{A, "var_pointer", "var vp *string; vp", (*string)(nil), nil},
 	TestCase{A, "var_map", "var vm *map[error]bool; vm", (*map[error]bool)(nil), nil},
 	TestCase{A, "var_slice", "var vbs []byte; vbs", ([]byte)(nil), nil},
-	TestCase{A, "var_named_slice", "type Bytes []byte; var vns Bytes; vns", ([]byte)(nil), nil},
+	TestCase{C, "var_named_slice", "type Bytes []byte; var vns Bytes; vns", ([]byte)(nil), nil},
+	TestCase{F, "var_named_slice", "type Bytes []byte; var vns Bytes; vns", named("main.Bytes", ([]byte)(nil)), nil},
 	TestCase{A, "var_array", "var va [2]rune; va", [2]rune{}, nil},
 	TestCase{A, "var_interface_1", "var vi interface{} = 1; vi", 1, nil},
 	TestCase{A, "var_interface_2", "var vnil interface{}; vnil", nil, nil},
@@ -608,21 +2028,23 @@ var testcases = []TestCase{
 	TestCase{A, "typed_unary_4", "v7 = 2.5i; -v7", complex64(-2.5i), nil},
 	TestCase{A, "typed_unary_5", "v8 = 3.75i; -v8", complex128(-3.75i), nil},
 
-	TestCase{A, "type_int8", "type t8 int8; var u8 t8; u8", int8(0), nil},
+	TestCase{C, "type_int8", "type t8 int8; var u8 t8; u8", int8(0), nil},
+	TestCase{F, "type_int8", "type t8 int8; var u8 t8; u8", named("main.t8", int8(0)), nil},
 	TestCase{A, "type_complicated", "type tfff func(int,int) func(error, func(bool)) string; var vfff tfff; vfff", (func(int, int) func(error, func(bool)) string)(nil), nil},
 	TestCase{C, "type_interface_1", "type Stringer interface { String() string }; var s Stringer; s", classicObjStringer, nil},
 	TestCase{F, "type_interface_1", "type Stringer interface { String() string }; var s Stringer; s", fastObjStringer, nil},
 	TestCase{F, "type_struct_0", "type PairPrivate struct { a, b rune }; var pp PairPrivate; pp.a+pp.b", rune(0), nil},
-	TestCase{A, "type_struct_1", "type Pair struct { A rune; B string}; var pair Pair; pair", Pair{}, nil},
+	TestCase{C, "type_struct_1", "type Pair struct { A rune; B string}; var pair Pair; pair", Pair{}, nil},
+	TestCase{F, "type_struct_1", "type Pair struct { A rune; B string}; var pair Pair; pair", named("main.Pair", Pair{}), nil},
 	TestCase{A, "type_struct_2", "type Triple struct { Pair; C float32 }; var triple Triple; triple.C", float32(0), nil},
 	TestCase{A, "type_struct_3", "type TripleP struct { *Pair; D float64 }; var tp TripleP; tp.D", float64(0), nil},
-	TestCase{F, "tagged_struct_1", "type TagPair struct { A rune `json:\"foo\"`; B string `json:\"bar\"`}; var tagpair TagPair; tagpair", TagPair{}, nil},
-	TestCase{F, "tagged_struct_2", "type TagTriple struct { A rune; B, C string `json:\"baz\"`}; TagTriple{}", TagTriple{}, nil},
+	TestCase{F, "tagged_struct_1", "type TagPair struct { A rune `json:\"foo\"`; B string `json:\"bar\"`}; var tagpair TagPair; tagpair", named("main.TagPair", TagPair{}), nil},
+	TestCase{F, "tagged_struct_2", "type TagTriple struct { A rune; B, C string `json:\"baz\"`}; TagTriple{}", named("main.TagTriple", TagTriple{}), nil},
 
 	TestCase{A, "field_get_1", "pair.A", rune(0), nil},
 	TestCase{A, "field_get_2", "pair.B", "", nil},
-	TestCase{F, "field_anonymous_1", "triple.Pair", Pair{}, nil},
-	TestCase{F, "field_anonymous_2", "type X struct { *X }; X{}", structX{(*structX)(nil)}, nil},
+	TestCase{F, "field_anonymous_1", "triple.Pair", named("main.Pair", Pair{}), nil},
+	TestCase{F, "field_anonymous_2", "type X struct { *X }; X{}", named("main.X", structX{(*structX)(nil)}), nil},
 	TestCase{F, "field_embedded_1", "triple.A", rune(0), nil},
 	TestCase{F, "field_embedded_2", "triple.B", "", nil},
 	TestCase{F, "field_embedded_3", "triple.Pair.A", rune(0), nil},
@@ -630,7 +2052,7 @@ var testcases = []TestCase{
 	TestCase{F, "field_embedded_5", "tp.A", panics, nil},
 	TestCase{F, "field_embedded_6", "tp.Pair = &triple.Pair; tp.B", "", nil},
 
-	TestCase{F, "self_embedded_1", "X{}.X", (*structX)(nil), nil},
+	TestCase{F, "self_embedded_1", "X{}.X", named("*main.X", (*structX)(nil)), nil},
 	TestCase{F, "self_embedded_2", "var x X; x.X = &x; x.X.X.X.X.X.X.X.X == &x", true, nil},
 	TestCase{F, "self_embedded_3", "x.X.X.X == x.X.X.X.X.X", true, nil},
 
@@ -746,6 +2168,40 @@ var testcases = []TestCase{
 		('x' + 'y' + 'z') * 2, nil},
 	TestCase{A, "for_range_slice", `v0 = 0; for _, s := range [ ]string{"a", "bc"} { v0 += len(s); continue }; v0`, 3, nil},
 	TestCase{A, "for_range_string", `vrune = 0; for i, r := range "abc\u00ff" { vrune += r << (uint8(i)*8); continue }; vrune`, for_range_string("abc\u00ff"), nil},
+	TestCase{F, "for_range_int", `v0 = 0; for i := range 10 { if i == 1 { continue } else if i == 5 { break }; v0 += i }; v0`, 9, nil},
+	TestCase{F, "for_range_int_typed", `var vu8 uint8; for vu8 = range uint8(7) { }; vu8`, uint8(6), nil},
+	TestCase{F, "for_range_int_untyped", `var vi64 int64; for vi64 = range 4 { }; vi64`, int64(3), nil},
+	TestCase{F, "for_range_int_empty", `v0 = 7; for range -1 { v0 = 0 }; v0`, 7, nil},
+	TestCase{F, "for_range_func_1", `func vseq(n int) func(func(int) bool) {
+			return func(yield func(int) bool) { for i := 0; i < n; i++ { if !yield(i) { return } } }
+		}
+		v0 = 0; for i := range vseq(10) { if i == 1 { continue } else if i == 5 { break }; v0 += i }; v0`, 9, nil},
+	TestCase{F, "for_range_func_2", `func vpairs(yield func(string, int) bool) { _ = yield("a", 1) && yield("bc", 2) }
+		v0 = 0; for k, v := range vpairs { v0 += len(k) * v }; v0`, 5, nil},
+	TestCase{F, "for_range_func_loopvar", `var vfuns []func() int
+		for i := range vseq(3) { vfuns = append(vfuns, func() int { return i }) }
+		vfuns[0]() + 10*vfuns[1]() + 100*vfuns[2]()`, 210, nil},
+
+	TestCase{F, "goto_backward", `func goto_backward(n int) int { s, i := 0, 0
+	L: if i < n { s += i; i++; goto L }
+		return s }; goto_backward(5)`, 10, nil},
+	TestCase{F, "goto_forward", `func goto_forward(n int) int { s := 0
+		if n > 3 { goto M }
+		s = -1
+	M: return s }; goto_forward(4)`, 0, nil},
+	TestCase{F, "goto_forward_2", "goto_forward(2)", -1, nil},
+	TestCase{F, "goto_forward_loop", `func goto_forward_loop() int {
+		for i := 1; ; i++ { for j := 1; ; j++ { if i * j == 6 { goto Done } } }
+	Done: return 7 }; goto_forward_loop()`, 7, nil},
+	TestCase{F, "goto_forward_switch", `func goto_forward_switch(x interface{}) int {
+		switch x.(type) { case int: goto Int }
+		return 0
+	Int: return 1 }; goto_forward_switch(5)`, 1, nil},
+	TestCase{F, "goto_over_var", `func goto_over_var() int { goto L; x := 1; L: return x }`, panics, nil},
+	TestCase{F, "goto_into_block", `func goto_into_block() int { goto L; if true { L: return 1 }; return 0 }`, panics, nil},
+	TestCase{F, "goto_into_block_2", `func goto_into_block_2() int { if true { L: return 1 }; goto L }`, panics, nil},
+	TestCase{F, "goto_not_found", `func goto_not_found() { goto L }`, panics, nil},
+	TestCase{F, "label_duplicate", `func label_duplicate() { L: ; L: ; }`, panics, nil},
 
 	TestCase{A, "function_0", "func nop() { }; nop()", nil, none},
 	TestCase{A, "function_1", "func seven() int { return 7 }; seven()", 7, nil},
@@ -824,17 +2280,19 @@ var testcases = []TestCase{
 		arr[0], arr[1] = arr[1], arr[0]
 		arr`, [2]struct{ X int }{{4}, {3}}, nil},
 
-	TestCase{A, "field_set_1", `pair.A = 'k'; pair.B = "m"; pair`, Pair{'k', "m"}, nil},
-	TestCase{A, "field_set_2", `pair.A, pair.B = 'x', "y"; pair`, Pair{'x', "y"}, nil},
-	TestCase{F, "field_set_3", `triple.Pair.A, triple.C = 'a', 1.0; triple.Pair`, Pair{'a', ""}, nil},
-	TestCase{F, "field_set_embedded_1", `triple.A, triple.B = 'b', "xy"; triple.Pair`, Pair{'b', "xy"}, nil},
+	TestCase{C, "field_set_1", `pair.A = 'k'; pair.B = "m"; pair`, Pair{'k', "m"}, nil},
+	TestCase{F, "field_set_1", `pair.A = 'k'; pair.B = "m"; pair`, named("main.Pair", Pair{'k', "m"}), nil},
+	TestCase{C, "field_set_2", `pair.A, pair.B = 'x', "y"; pair`, Pair{'x', "y"}, nil},
+	TestCase{F, "field_set_2", `pair.A, pair.B = 'x', "y"; pair`, named("main.Pair", Pair{'x', "y"}), nil},
+	TestCase{F, "field_set_3", `triple.Pair.A, triple.C = 'a', 1.0; triple.Pair`, named("main.Pair", Pair{'a', ""}), nil},
+	TestCase{F, "field_set_embedded_1", `triple.A, triple.B = 'b', "xy"; triple.Pair`, named("main.Pair", Pair{'b', "xy"}), nil},
 	TestCase{F, "field_addr_1", "ppair := &triple.Pair; ppair.A", 'b', nil},
 	TestCase{F, "field_addr_2", "ppair.A++; triple.Pair.A", 'c', nil},
 
-	TestCase{F, "infer_type_compositelit_1", `[]Pair{{'a', "b"}, {'c', "d"}}`, []Pair{{'a', "b"}, {'c', "d"}}, nil},
-	TestCase{F, "infer_type_compositelit_2", `[]*Pair{{'a', "b"}, {'c', "d"}}`, []*Pair{{'a', "b"}, {'c', "d"}}, nil},
-	TestCase{F, "infer_type_compositelit_3", `[...]Pair{{'e', "f"}, {'g', "h"}}`, [...]Pair{{'e', "f"}, {'g', "h"}}, nil},
-	TestCase{F, "infer_type_compositelit_4", `map[int]Pair{1:{'i', "j"}, 2:{}}`, map[int]Pair{1: {'i', "j"}, 2: {}}, nil},
+	TestCase{F, "infer_type_compositelit_1", `[]Pair{{'a', "b"}, {'c', "d"}}`, named("[]main.Pair", []Pair{{'a', "b"}, {'c', "d"}}), nil},
+	TestCase{F, "infer_type_compositelit_2", `[]*Pair{{'a', "b"}, {'c', "d"}}`, named("[]*main.Pair", []*Pair{{'a', "b"}, {'c', "d"}}), nil},
+	TestCase{F, "infer_type_compositelit_3", `[...]Pair{{'e', "f"}, {'g', "h"}}`, named("[2]main.Pair", [...]Pair{{'e', "f"}, {'g', "h"}}), nil},
+	TestCase{F, "infer_type_compositelit_4", `map[int]Pair{1:{'i', "j"}, 2:{}}`, named("map[int]main.Pair", map[int]Pair{1: {'i', "j"}, 2: {}}), nil},
 	TestCase{F, "infer_type_compositelit_5", `map[int]map[int]int{1:{2:3}}`, map[int]map[int]int{1: {2: 3}}, nil},
 	TestCase{F, "infer_type_compositelit_6", `map[int]*map[int]int{1:{2:3}}`, map[int]*map[int]int{1: {2: 3}}, nil},
 
@@ -844,6 +2302,24 @@ var testcases = []TestCase{
 	TestCase{A, "dot_import_1", `import . "errors"`, nil, none},
 	TestCase{A, "dot_import_2", `reflect.ValueOf(New) == reflect.ValueOf(errors.New)`, true, nil}, // a small but very strict check... good
 
+	TestCase{F, "unsafe_disabled", `import "unsafe"; var ux int64; unsafe.Pointer(&ux)`, panics, nil},
+	TestCase{F, "unsafe_sizeof_disabled", `unsafe.Sizeof(ux)`, panics, nil},
+	TestCase{F | X, "unsafe_pointer_1", `up := unsafe.Pointer(&ux); *(*int64)(up) = 7; ux`, int64(7), nil},
+	TestCase{F | X, "unsafe_pointer_2", `up == unsafe.Pointer(&ux) && up != nil && uintptr(up) != 0`, true, nil},
+	TestCase{F | X, "unsafe_pointer_3", `ubytes := []byte("hello"); *(*string)(unsafe.Pointer(&ubytes))`, "hello", nil},
+	TestCase{F | X, "unsafe_pointer_4", `uf := 1.5; *(*uint64)(unsafe.Pointer(&uf))`, math.Float64bits(1.5), nil},
+	TestCase{F | X, "unsafe_pointer_5", `type UnsafeS struct { A int8; B int64; C struct { D int16; E int32 } }
+		var us UnsafeS
+		*(*int64)(unsafe.Pointer(uintptr(unsafe.Pointer(&us)) + unsafe.Offsetof(us.B))) = 9
+		us.B`, int64(9), nil},
+	TestCase{F | X, "unsafe_sizeof", `unsafe.Sizeof(us)`, unsafe.Sizeof(unsafeS{}), nil},
+	TestCase{F | X, "unsafe_alignof", `unsafe.Alignof(us.C)`, unsafe.Alignof(unsafeS{}.C), nil},
+	TestCase{F | X, "unsafe_offsetof_1", `unsafe.Offsetof(us.C)`, unsafe.Offsetof(unsafeS{}.C), nil},
+	TestCase{F | X, "unsafe_offsetof_2", `unsafe.Offsetof(us.C.E)`, unsafe.Offsetof(unsafeS{}.C.E), nil},
+	TestCase{F | X, "unsafe_offsetof_3", `type UnsafeT struct { X int8; UnsafeS }; unsafe.Offsetof((&UnsafeT{}).B)`, unsafe.Offsetof(unsafeT{}.B), nil},
+	TestCase{F | X, "unsafe_offsetof_4", `type UnsafeP struct { *UnsafeS }; unsafe.Offsetof(UnsafeP{}.B)`, panics, nil},
+	TestCase{F | X, "unsafe_const", `const usize = unsafe.Sizeof(int32(0)); var uarr [usize]byte; len(uarr)`, 4, nil},
+
 	TestCase{A, "goroutine_1", `go seti(9); time.Sleep(time.Second/50); i`, 9, nil},
 
 	TestCase{F, "big.Int", `(func() *big.Int { return 1<<1000 })()`, bigInt, nil},
@@ -851,7 +2327,8 @@ var testcases = []TestCase{
 	TestCase{F, "big.Float", `(func() *big.Float { var x *big.Float = 1e1234; x.Mul(x,x); x.Mul(x,x); return x })()`, bigFloat, nil},
 
 	TestCase{A, "builtin_append_1", "append(vbs,0,1,2)", []byte{0, 1, 2}, nil},
-	TestCase{A, "builtin_append_2", "append(vns,3,4)", []byte{3, 4}, nil},
+	TestCase{C, "builtin_append_2", "append(vns,3,4)", []byte{3, 4}, nil},
+	TestCase{F, "builtin_append_2", "append(vns,3,4)", named("main.Bytes", []byte{3, 4}), nil},
 	TestCase{A, "builtin_cap", "cap(va)", 2, nil},
 	TestCase{A, "builtin_len_1", "len(vs)", len("8y57riuh@#$"), nil},
 	TestCase{A, "builtin_len_2", "{ a := [...]int{1,2,3}; len(a) }", nil, none},
@@ -890,6 +2367,24 @@ var testcases = []TestCase{
 			nil),
 		nil,
 	},
+	TestCase{F, "builtin_min_1", "min(vbs[0], 'a', 'A')", byte('8'), nil},
+	TestCase{F, "builtin_min_2", "v6 = 0.5; min(v6, -1, 2)", float32(-1), nil},
+	TestCase{F, "builtin_min_3", `min("b", vs, "c")`, "8y57riuh@#$", nil},
+	TestCase{F, "builtin_min_4", "import \"math\"; max(math.Inf(-1), -0.5)", -0.5, nil},
+	TestCase{F, "builtin_max_1", "ints1 = []int{1,2,3}; max(ints1[0], ints1[2], ints1[1])", 3, nil},
+	TestCase{F, "builtin_max_2", "const cmax int8 = max(1, -7, 3.0); cmax", int8(3), nil},
+	TestCase{F, "builtin_max_3", "type Celsius float64; var temp Celsius = -2.5; max(temp, -4)", named("main.Celsius", -2.5), nil},
+	TestCase{F, "builtin_clear_1", "mclear := map[string]int{\"a\": 1, \"b\": 2}; clear(mclear); mclear", map[string]int{}, nil},
+	TestCase{F, "builtin_clear_2", "clear(ints1); ints1", []int{0, 0, 0}, nil},
+	TestCase{F | U, "untyped_builtin_min_1", "min(3, 1.5, 2)",
+		untyped.MakeLit(untyped.Float, constant.MakeFloat64(1.5), nil),
+		nil},
+	TestCase{F | U, "untyped_builtin_max_1", "max(1, 'x', 2)",
+		untyped.MakeLit(untyped.Rune, constant.MakeInt64('x'), nil),
+		nil},
+	TestCase{F | U, "untyped_builtin_max_2", `max("abc", "b")`,
+		untyped.MakeLit(untyped.String, constant.MakeString("b"), nil),
+		nil},
 
 	TestCase{A, "time_duration_0", `var td time.Duration = 1; td`, time.Duration(1), nil},
 	TestCase{A, "time_duration_1", `- td`, time.Duration(-1), nil},
@@ -916,12 +2411,16 @@ var testcases = []TestCase{
 	TestCase{A, "literal_map_address", `&map[int]byte{6:7, 8:9}`, &map[int]byte{6: 7, 8: 9}, nil},
 	TestCase{A, "literal_slice", "[]rune{'a','b','c'}", []rune{'a', 'b', 'c'}, nil},
 	TestCase{A, "literal_slice_address", "&[]rune{'x','y'}", &[]rune{'x', 'y'}, nil},
-	TestCase{A, "literal_struct", `Pair{A: 0x73, B: "\x94"}`, Pair{A: 0x73, B: "\x94"}, nil},
-	TestCase{A, "literal_struct_address", `&Pair{1,"2"}`, &Pair{A: 1, B: "2"}, nil},
+	TestCase{C, "literal_struct", `Pair{A: 0x73, B: "\x94"}`, Pair{A: 0x73, B: "\x94"}, nil},
+	TestCase{F, "literal_struct", `Pair{A: 0x73, B: "\x94"}`, named("main.Pair", Pair{A: 0x73, B: "\x94"}), nil},
+	TestCase{C, "literal_struct_address", `&Pair{1,"2"}`, &Pair{A: 1, B: "2"}, nil},
+	TestCase{F, "literal_struct_address", `&Pair{1,"2"}`, named("*main.Pair", &Pair{A: 1, B: "2"}), nil},
 
 	// issue #103
-	TestCase{A, "named_const_type_1", `type Int int
+	TestCase{C, "named_const_type_1", `type Int int
 				 const namedOne Int = Int(1); namedOne`, int(1), nil},
+	TestCase{F, "named_const_type_1", `type Int int
+				 const namedOne Int = Int(1); namedOne`, named("main.Int", int(1)), nil},
 
 	TestCase{A, "named_func_type_1", `import "context"
 				 _, cancel := context.WithCancel(context.Background())
@@ -935,7 +2434,7 @@ var testcases = []TestCase{
 	TestCase{A, "method_on_val_1", `pair.SetAV(11); pair.A`, rune(33), nil}, // method on value gets a copy of the receiver - changes to not propagate
 	TestCase{A, "method_on_val_2", `pair.String()`, "! y", nil},
 	// gophernotes issue 174
-	TestCase{F, "method_decl_and_use", `type person struct{}; func (p person) speak() {}; person.speak`, func(struct{}) {}, nil},
+	TestCase{F, "method_decl_and_use", `type person struct{}; func (p person) speak() {}; person.speak`, named("func(main.person)", nil), nil},
 	TestCase{F, "method_embedded=val_recv=ptr", `triple.SetA('1'); triple.A`, '1', nil},
 	TestCase{F, "method_embedded=val_recv=val", `triple.SetAV('2'); triple.A`, '1', nil},
 	TestCase{F, "method_embedded=ptr_recv=val", `tp.SetAV('3'); tp.A`, '1', nil}, // set by triple.SetA('1') above
@@ -1145,11 +2644,24 @@ var testcases = []TestCase{
 	TestCase{A, "typeassert_5", `xi = 7; xi.(int)+2`, 9, nil},
 	TestCase{F, "typeassert_6", `type T struct { Val int }; func (t T) String() string { return "T" }`, nil, none},
 	TestCase{F, "typeassert_7", `stringer = T{}; nil`, nil, nil},
-	TestCase{F, "typeassert_8", `st1 := stringer.(T); st1`, struct{ Val int }{0}, nil},
-	TestCase{F, "typeassert_9", `stringer.(T)`, nil, []interface{}{struct{ Val int }{0}, true}},
+	TestCase{F, "typeassert_8", `st1 := stringer.(T); st1`, named("main.T", struct{ Val int }{0}), nil},
+	TestCase{F, "typeassert_9", `stringer.(T)`, nil, []interface{}{named("main.T", struct{ Val int }{0}), true}},
 	// can interpreted type assertions distinguish between emulated named types with identical underlying type?
 	TestCase{F, "typeassert_10", `type U struct { Val int }; func (u U) String() string { return "U" }; nil`, nil, nil},
-	TestCase{F, "typeassert_11", `stringer.(U)`, nil, []interface{}{struct{ Val int }{0}, false}},
+	TestCase{F, "typeassert_11", `stringer.(U)`, nil, []interface{}{named("main.U", struct{ Val int }{0}), false}},
+	// interpreted types stored in interfaces, and interpreted interfaces
+	TestCase{F, "typeassert_12", `var xv interface{} = T{}; xs, ok12 := xv.(fmt.Stringer); ok12 && xs.String() == "T"`, true, nil},
+	TestCase{F, "typeassert_13", `type Namer interface { Name() string }; _, ok13 := xv.(Namer); ok13`, false, nil},
+	TestCase{F, "typeassert_14", `type Stringer2 interface { String() string }; xv.(Stringer2).String()`, "T", nil},
+	TestCase{F, "typeassert_15", `type P struct{}; func (p *P) String() string { return "P" }; xv = P{}; _, ok15 := xv.(fmt.Stringer); ok15`, false, nil},
+	TestCase{F, "typeassert_16", `xv = &P{}; xv.(fmt.Stringer).String()`, "P", nil},
+	TestCase{F, "typeassert_17", `var s2 Stringer2 = T{}; s2.(fmt.Stringer).String()`, "T", nil},
+	TestCase{F, "typeassert_18", `stringer = time.Minute; stringer.(Stringer2).String()`, "1m0s", nil},
+	TestCase{F, "typeassert_19", `type T19 struct{}; func check19(x interface{}) bool { _, ok := x.(fmt.Stringer); return ok }; check19(T19{})`, false, nil},
+	TestCase{F, "typeassert_20", `func (T19) String() string { return "T19" }; check19(T19{})`, true, nil},
+	TestCase{F, "typeswitch_7", `switch y := xv.(type) { case Namer: vi = 0; case fmt.Stringer: vi = len(y.String()); default: vi = -1 }; vi`, 1, nil},
+	TestCase{F, "typeswitch_8", `switch xv.(type) { case int, Stringer2: vi = 8; default: vi = 0 }; vi`, 8, nil},
+	TestCase{F, "typeswitch_9", `switch y := s2.(type) { case Namer: vi = 0; case fmt.Stringer: vi = len(y.String()) }; vi`, 1, nil},
 
 	TestCase{A, "quote_1", `~quote{7}`, &ast.BasicLit{Kind: token.INT, Value: "7"}, nil},
 	TestCase{A, "quote_2", `~quote{x}`, &ast.Ident{Name: "x"}, nil},
@@ -1493,14 +3005,14 @@ var testcases = []TestCase{
 		generic_type("PairX", "T1,T2") + `struct { First T1; Second T2 }`,
 		nil, none,
 	},
-	TestCase{F | G1 | G2, "generic_type_2", `var px PairX#[complex64, struct{}]; px`, PairX2{}, nil},
-	TestCase{F | G1 | G2, "generic_type_3", `PairX#[bool, interface{}] {true, "foo"}`, PairX3{true, "foo"}, nil},
+	TestCase{F | G1 | G2, "generic_type_2", `var px PairX#[complex64, struct{}]; px`, named("main.PairX#[complex64,struct {}]", PairX2{}), nil},
+	TestCase{F | G1 | G2, "generic_type_3", `PairX#[bool, interface{}] {true, "foo"}`, named("main.PairX#[bool,interface {}]", PairX3{true, "foo"}), nil},
 
 	TestCase{F | G1 | G2, "recursive_generic_type_1",
 		generic_type("ListX", "T") + `struct { First T; Rest *ListX#[T] }
-		var lx ListX#[error]; lx`, ListX2{nil, (*ListX2)(nil)}, nil},
+		var lx ListX#[error]; lx`, named("main.ListX#[error]", nil), nil},
 	TestCase{F | G1 | G2, "recursive_generic_type_2", `ListX#[interface{}]{}`,
-		ListX3{nil, (*ListX3)(nil)}, nil},
+		named("main.ListX#[interface {}]", nil), nil},
 
 	TestCase{F | G1, "specialized_generic_type_1", `
 		template[] for[struct{}] type ListX struct { }
@@ -1685,7 +3197,79 @@ var testcases = []TestCase{
 		xg2 := UInt(9)
 		var xg3 Eq#[UInt]
 		xg3 = xg2
-		xg2`, uint(9), nil},
+		xg2`, named("main.UInt", uint(9)), nil},
+
+	TestCase{F | G3, "go_generic_func_1", `
+		func MapG[T, U any](s []T, f func(T) U) []U {
+			r := make([]U, len(s))
+			for i, x := range s {
+				r[i] = f(x)
+			}
+			return r
+		}`, nil, none},
+	TestCase{F | G3, "go_generic_func_2", `MapG[string, int]([]string{"abc","xy","z"}, func(s string) int { return len(s) })`,
+		[]int{3, 2, 1}, nil},
+	TestCase{F | G3, "go_generic_func_3", `MapG([]int{1,2}, func(i int) bool { return i > 1 })`, []bool{false, true}, nil},
+	TestCase{F | G3, "go_generic_func_4", `
+		func FirstG[S ~[]E, E any](s S) E {
+			return s[0]
+		}
+		FirstG([]string{"q", "r"})`, "q", nil},
+	TestCase{F | G3, "go_generic_index", `xi := []int{5, 6}; xi[1]`, 6, nil},
+	TestCase{F | G3, "go_generic_constraint_1", `
+		type Signed interface { ~int | ~int8 | ~int16 | ~int32 | ~int64 }
+		type Unsigned interface { ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr }
+		type Integer interface { Signed | Unsigned }
+		func SumG[T Integer](xs ...T) T {
+			var total T
+			for _, x := range xs {
+				total += x
+			}
+			return total
+		}`, nil, none},
+	TestCase{F | G3, "go_generic_constraint_2", `SumG[int](1, 2, 3)`, 6, nil},
+	TestCase{F | G3, "go_generic_constraint_3", `type Celsius uint8; SumG[Celsius](4, 5)`, named("main.Celsius", uint8(9)), nil},
+	TestCase{F | G3, "go_generic_constraint_4", `SumG[string]("a", "b")`, panics, nil},
+	TestCase{F | G3, "go_generic_constraint_5", `
+		func LenG[K comparable, V any](m map[K]V) int {
+			return len(m)
+		}
+		LenG(map[string]bool{"a": true, "b": false})`, 2, nil},
+	TestCase{F | G3, "go_generic_constraint_6", `LenG[[]int, int](nil)`, panics, nil},
+	TestCase{F | G3, "go_generic_constraint_7", `
+		func MaxG[T int | float64](a, b T) T {
+			if a > b {
+				return a
+			}
+			return b
+		}
+		MaxG(2.5, 1.0)`, 2.5, nil},
+	TestCase{F | G3, "go_generic_type_1", `
+		type PairG[K comparable, V any] struct {
+			Key K
+			Val V
+		}
+		PairG[string, int]{"a", 1}.Val`, 1, nil},
+	TestCase{F | G3, "go_generic_method_1", `
+		type ListG[T any] struct {
+			items []T
+		}
+		func (l *ListG[T]) Push(x T) {
+			l.items = append(l.items, x)
+		}
+		var lg ListG[int]
+		lg.Push(3)
+		lg.Push(4)
+		len(lg.items)`, 2, nil},
+	TestCase{F | G3, "go_generic_method_2", `
+		func (l *ListG[E]) Len() int {
+			return len(l.items)
+		}
+		lg.Len()`, 2, nil},
+	TestCase{F | G3, "go_generic_method_3", `
+		type Lener interface { Len() int }
+		var lener Lener = &ListG[string]{items: []string{"x"}}
+		lener.Len()`, 1, nil},
 }
 
 func (c *TestCase) compareResults(t *testing.T, actual []r.Value) {
@@ -1703,6 +3287,10 @@ func (c *TestCase) compareResults(t *testing.T, actual []r.Value) {
 }
 
 func (c *TestCase) compareResult(t *testing.T, actualv r.Value, expected interface{}) {
+	if expected, ok := expected.(namedValue); ok {
+		c.compareNamed(t, actualv, expected)
+		return
+	}
 	if actualv == NilR || actualv == NoneR {
 		if expected != nil {
 			c.fail(t, nil, expected)
@@ -1743,6 +3331,83 @@ func (c *TestCase) compareResult(t *testing.T, actualv r.Value, expected interfa
 	}
 }
 
+// namedValue is the expected result of fast interpreter code that returns a value
+// whose type is declared by interpreted code: such types have a named reflect.Type
+// that compiled code cannot create
+type namedValue struct {
+	typ   string      // reflect.Type.String() of the result
+	value interface{} // expected value, of the underlying type. nil means check only the type
+}
+
+func named(typ string, value interface{}) namedValue {
+	return namedValue{typ, value}
+}
+
+func (c *TestCase) compareNamed(t *testing.T, actualv r.Value, expected namedValue) {
+	if !actualv.IsValid() || actualv == NilR || actualv == NoneR {
+		c.fail(t, nil, expected)
+	} else if actualv.Type().String() != expected.typ ||
+		(expected.value != nil && !equalUnderlying(actualv, r.ValueOf(expected.value))) {
+		c.fail(t, actualv.Interface(), expected)
+	}
+}
+
+var rtypeOfForward = r.TypeOf((*xr.Forward)(nil)).Elem()
+
+// equalUnderlying returns true if actualv, converted to the type of expectedv,
+// is equal to expectedv. Slices, arrays, maps, pointers and structs are converted element by element.
+// An expected xr.Forward, used to approximate recursive types, matches the value it contains
+func equalUnderlying(actualv r.Value, expectedv r.Value) bool {
+	rtype, etype := actualv.Type(), expectedv.Type()
+	if etype == rtypeOfForward && rtype.Kind() != r.Interface && !expectedv.IsNil() {
+		return equalUnderlying(actualv, expectedv.Elem())
+	} else if rtype.Kind() != etype.Kind() {
+		return false
+	} else if rtype.ConvertibleTo(etype) {
+		return r.DeepEqual(actualv.Convert(etype).Interface(), expectedv.Interface())
+	}
+	switch rtype.Kind() {
+	case r.Ptr:
+		if actualv.IsNil() || expectedv.IsNil() {
+			return actualv.IsNil() && expectedv.IsNil()
+		}
+		return equalUnderlying(actualv.Elem(), expectedv.Elem())
+	case r.Array, r.Slice:
+		if actualv.Len() != expectedv.Len() {
+			return false
+		}
+		for i, n := 0, actualv.Len(); i < n; i++ {
+			if !equalUnderlying(actualv.Index(i), expectedv.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case r.Map:
+		if actualv.Len() != expectedv.Len() || rtype.Key() != etype.Key() {
+			return false
+		}
+		for _, key := range expectedv.MapKeys() {
+			elem := actualv.MapIndex(key)
+			if !elem.IsValid() || !equalUnderlying(elem, expectedv.MapIndex(key)) {
+				return false
+			}
+		}
+		return true
+	case r.Struct:
+		if rtype.NumField() != etype.NumField() {
+			return false
+		}
+		for i, n := 0, rtype.NumField(); i < n; i++ {
+			if rtype.Field(i).Name != etype.Field(i).Name ||
+				!equalUnderlying(actualv.Field(i), expectedv.Field(i)) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
 func (c *TestCase) compareAst(t *testing.T, actual Ast, expected Ast) {
 	if actual == nil || expected == nil {
 		if actual != nil || expected != nil {
@@ -267,7 +267,7 @@ func (s *Scope) Func(node *ast.FuncDecl) []string {
 	inner := NewScope(s)
 
 	name := node.Name.Name
-	deps := inner.Expr(node.Type)
+	deps := inner.funcType(node.Type)
 
 	kind := Func
 	if node.Recv != nil && len(node.Recv.List) != 0 {
@@ -299,6 +299,20 @@ func (s *Scope) Func(node *ast.FuncDecl) []string {
 	return deps
 }
 
+// declare function params and results in current scope,
+// and compute dependencies for their types
+func (s *Scope) funcType(node *ast.FuncType) []string {
+	var deps []string
+	for _, list := range []*ast.FieldList{node.Params, node.Results} {
+		if list != nil {
+			for _, field := range list.List {
+				deps = append(deps, s.Expr(field)...)
+			}
+		}
+	}
+	return sort_unique_inplace(deps)
+}
+
 // type
 func (s *Scope) Type(node ast.Spec) []string {
 	var deps []string
@@ -338,10 +352,10 @@ func (s *Scope) AstExpr(in ast2.Ast) []string {
 	var deps []string
 	switch node := in.Interface().(type) {
 	case *ast.FuncLit:
-		deps = append(deps, s.Expr(node.Type)...)
-		in = ast2.BlockStmt{node.Body}
-		// open a new scope
+		// open a new scope, and declare params and results in it
 		s = NewScope(s)
+		deps = append(deps, s.funcType(node.Type)...)
+		in = ast2.BlockStmt{node.Body}
 	case *ast.BlockStmt, *ast.FuncType, *ast.InterfaceType, *ast.StructType:
 		// open a new scope
 		s = NewScope(s)
@@ -379,13 +393,11 @@ func (s *Scope) AstExpr(in ast2.Ast) []string {
 
 // return true if name refers to a local declaration
 func (s *Scope) isLocal(name string) bool {
-	outer := s.Outer
-	// outer == nil is top-level scope: not local
-	for ; outer != nil; s = outer {
+	// s.Outer == nil is top-level scope: not local
+	for ; s.Outer != nil; s = s.Outer {
 		if _, ok := s.Decls[name]; ok {
 			return true
 		}
-		outer = outer.Outer
 	}
 	return false
 }
@@ -58,11 +58,18 @@ const (
 	// 2. invoke "go build -buildmode=plugin" on the file to create a shared library
 	// 3. load such shared library with plugin.Open().Lookup("Packages")
 	ImPlugin
+
+	// ImSource import mechanism is:
+	// 1. locate the source files of $PKGPATH with go/packages, or in the module cache or in $GOPATH/src
+	// 2. let the interpreter parse and execute them, importing recursively their dependencies.
+	//    Slower than compiled packages, but does not need a Go toolchain
+	ImSource
 )
 
 type PackageRef struct {
 	imports.Package
-	Path string
+	Path   string
+	Source *SourcePackage // != nil if the package must be interpreted from source, see ImSource
 }
 
 func (ref *PackageRef) DefaultName() string {
@@ -74,10 +81,14 @@ func (ref *PackageRef) String() string {
 }
 
 type Importer struct {
-	srcDir     string
-	mode       types.ImportMode
-	PluginOpen r.Value // = reflect.ValueOf(plugin.Open)
-	output     *Output
+	srcDir       string
+	mode         types.ImportMode
+	PluginOpen   r.Value // = reflect.ValueOf(plugin.Open)
+	SourceImport bool    // true if the interpreter supports ImSource
+	// if true, packages not compiled into the interpreter are never compiled as plugins
+	// nor written to disk: they can only be imported with ImSource
+	NoPlugin bool
+	output   *Output
 }
 
 func DefaultImporter(o *Output) *Importer {
@@ -140,29 +151,19 @@ func (imp *Importer) ImportPackageOrError(alias, pkgpath string, enableModule bo
 	}
 	paths.GetImportsSrcDir() // warns if GOPATH or paths.ImportsDir may be wrong
 
+	mode := imp.importMode(alias)
+	if imp.NoPlugin && mode != ImSource {
+		return nil, imp.output.MakeRuntimeError(
+			"cannot import package %q: plugin imports are disabled", pkgpath)
+	}
+	if mode == ImSource {
+		return imp.importSource(pkgpath, enableModule)
+	}
 	o := imp.output
 	gpkg, err := imp.Load(pkgpath, enableModule) // loads names and types, not the values!
 	if err != nil {
 		return nil, imp.wrapImportError(pkgpath, enableModule, err)
 	}
-	var mode ImportMode
-	switch alias {
-	case "_b":
-		mode = ImBuiltin
-	case "_i":
-		mode = ImInception
-	case "_3":
-		mode = ImThirdParty
-	default:
-		if len(alias) == 0 {
-			alias = gpkg.Name()
-		}
-		if imp.havePluginOpen() {
-			mode = ImPlugin
-		} else {
-			mode = ImThirdParty
-		}
-	}
 	file := createImportFile(imp.output, pkgpath, gpkg, mode, enableModule)
 	ref = &PackageRef{Path: pkgpath}
 	if len(file) == 0 || mode != ImPlugin {
@@ -189,6 +190,34 @@ func (imp *Importer) ImportPackageOrError(alias, pkgpath string, enableModule bo
 	return ref, nil
 }
 
+// importMode chooses the import mechanism from the import alias:
+// _b, _i, _3 and _s select respectively ImBuiltin, ImInception, ImThirdParty and ImSource.
+// Otherwise prefer compiling a plugin, and fall back on interpreting the package source
+// if plugins or the Go toolchain are not available
+func (imp *Importer) importMode(alias string) ImportMode {
+	switch alias {
+	case "_b":
+		return ImBuiltin
+	case "_i":
+		return ImInception
+	case "_3":
+		return ImThirdParty
+	case "_s":
+		return ImSource
+	}
+	if imp.NoPlugin {
+		if imp.SourceImport {
+			return ImSource
+		}
+		return ImThirdParty
+	} else if imp.havePluginOpen() && haveGoCmd() {
+		return ImPlugin
+	} else if imp.SourceImport {
+		return ImSource
+	}
+	return ImThirdParty
+}
+
 func createImportFile(o *Output, pkgpath string, pkg *types.Package, mode ImportMode, enableModule bool) string {
 	dir := computeImportDir(o, pkgpath, mode)
 	if mode == ImPlugin {
@@ -0,0 +1,337 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * source.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package genimport
+
+import (
+	"fmt"
+	"go/build"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"golang.org/x/tools/go/packages"
+
+	"github.com/cosmos72/gomacro/base/paths"
+)
+
+// SourcePackage describes the source files of a package to be interpreted, see ImSource
+type SourcePackage struct {
+	Name  string   // package name, as written in its package clause
+	Path  string   // import path
+	Dir   string   // directory containing the source files
+	Files []string // source files to interpret. excludes tests and files not matching build constraints
+}
+
+func (src *SourcePackage) String() string {
+	return fmt.Sprintf("{%s %q, %d files in %q}", src.Name, src.Path, len(src.Files), src.Dir)
+}
+
+// return true if pkgpath belongs to Go standard library,
+// i.e. if its first element does not contain a dot
+func isStdlibPath(pkgpath string) bool {
+	first := pkgpath
+	if i := strings.IndexByte(pkgpath, '/'); i >= 0 {
+		first = pkgpath[:i]
+	}
+	return !strings.Contains(first, ".")
+}
+
+// return true if the Go toolchain is installed
+func haveGoCmd() bool {
+	_, err := exec.LookPath(chooseGoCmd())
+	return err == nil
+}
+
+func (imp *Importer) importSource(pkgpath string, enableModule bool) (*PackageRef, error) {
+	if !imp.SourceImport {
+		return nil, imp.output.MakeRuntimeError(
+			"error importing package %q: this interpreter cannot import packages from source", pkgpath)
+	}
+	src, err := imp.LoadSource(pkgpath, enableModule)
+	if err != nil {
+		return nil, imp.wrapImportError(pkgpath, enableModule, err)
+	}
+	// do not cache the package in imports.Packages:
+	// it contains no compiled symbols, the interpreter caches its own import
+	ref := &PackageRef{Path: pkgpath, Source: src}
+	ref.Name = src.Name
+	return ref, nil
+}
+
+// LoadSource locates the source files of package pkgpath.
+// Uses go/packages if the Go toolchain is installed,
+// otherwise searches the module cache and $GOPATH/src
+func (imp *Importer) LoadSource(pkgpath string, enableModule bool) (*SourcePackage, error) {
+	if isStdlibPath(pkgpath) {
+		return nil, fmt.Errorf("standard library package %q cannot be interpreted from source", pkgpath)
+	}
+	if haveGoCmd() {
+		src, err := loadSourceWithGoCmd(pkgpath, enableModule)
+		if err == nil {
+			return src, nil
+		}
+		imp.output.Debugf("go/packages could not locate package %q, searching module cache and $GOPATH/src: %v", pkgpath, err)
+	}
+	dir := findSourceDir(pkgpath, enableModule)
+	if len(dir) == 0 {
+		return nil, fmt.Errorf("source for package %q not found in module cache %q or in $GOPATH/src ($GOPATH=%s)",
+			pkgpath, moduleCacheDir(), build.Default.GOPATH)
+	}
+	return LoadSourceDir(pkgpath, dir)
+}
+
+func loadSourceWithGoCmd(pkgpath string, enableModule bool) (*SourcePackage, error) {
+	cfg := packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles,
+		Env:  environForCompiler(enableModule),
+	}
+	list, err := packages.Load(&cfg, "pattern="+pkgpath)
+	if err != nil {
+		return nil, err
+	}
+	for _, pkg := range list {
+		if pkg.PkgPath != pkgpath {
+			continue
+		} else if len(pkg.Errors) != 0 {
+			return nil, errorList{pkg.Errors, mergeErrorMessages(pkg.Errors)}
+		} else if len(pkg.GoFiles) == 0 {
+			break
+		}
+		// use go/build to obtain the exact list of files: GoFiles may contain cgo-generated files
+		return LoadSourceDir(pkgpath, filepath.Dir(pkg.GoFiles[0]))
+	}
+	return nil, fmt.Errorf("packages.Load() could not find package %q", pkgpath)
+}
+
+// LoadSourceDir lists the source files in dir that match current build constraints.
+// Test files are excluded
+func LoadSourceDir(pkgpath string, dir string) (*SourcePackage, error) {
+	bpkg, err := build.Default.ImportDir(dir, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(bpkg.CgoFiles) != 0 {
+		return nil, fmt.Errorf("package %q uses cgo, it cannot be interpreted from source", pkgpath)
+	}
+	files := make([]string, len(bpkg.GoFiles))
+	for i, name := range bpkg.GoFiles {
+		files[i] = filepath.Join(dir, name)
+	}
+	return &SourcePackage{
+		Name:  bpkg.Name,
+		Path:  pkgpath,
+		Dir:   dir,
+		Files: files,
+	}, nil
+}
+
+// LoadTestSourceDir lists the source files in dir that match current build constraints,
+// including test files. Returns the package, including its *_test.go files that belong to it,
+// and the external test package "PKG_test" or nil if there are no such files.
+// The import path is computed with go/packages if the Go toolchain is installed
+func LoadTestSourceDir(dir string) (pkg *SourcePackage, xtest *SourcePackage, err error) {
+	bpkg, err := build.Default.ImportDir(dir, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	pkgpath := importPathOfDir(dir, bpkg)
+	if len(bpkg.CgoFiles) != 0 {
+		return nil, nil, fmt.Errorf("package %q uses cgo, it cannot be interpreted from source", pkgpath)
+	}
+	pkg = &SourcePackage{
+		Name:  bpkg.Name,
+		Path:  pkgpath,
+		Dir:   dir,
+		Files: joinFiles(dir, bpkg.GoFiles, bpkg.TestGoFiles),
+	}
+	if len(bpkg.XTestGoFiles) != 0 {
+		xtest = &SourcePackage{
+			Name:  bpkg.Name + "_test",
+			Path:  pkgpath + "_test",
+			Dir:   dir,
+			Files: joinFiles(dir, bpkg.XTestGoFiles),
+		}
+	}
+	return pkg, xtest, nil
+}
+
+// importPathOfDir returns the import path of the package in dir
+func importPathOfDir(dir string, bpkg *build.Package) string {
+	if haveGoCmd() {
+		cfg := packages.Config{Mode: packages.NeedName, Dir: dir}
+		if list, err := packages.Load(&cfg, "."); err == nil && len(list) == 1 && len(list[0].PkgPath) != 0 {
+			return list[0].PkgPath
+		}
+	}
+	if bpkg.ImportPath != "." {
+		return bpkg.ImportPath
+	}
+	// same convention as the Go toolchain for packages outside $GOPATH and modules
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	return "_" + filepath.ToSlash(dir)
+}
+
+func joinFiles(dir string, lists ...[]string) []string {
+	var files []string
+	for _, list := range lists {
+		for _, name := range list {
+			files = append(files, filepath.Join(dir, name))
+		}
+	}
+	return files
+}
+
+// findSourceDir searches the directory containing package pkgpath
+// in the module cache and in $GOPATH/src. Returns "" if not found
+func findSourceDir(pkgpath string, enableModule bool) string {
+	if enableModule {
+		if dir := findInModuleCache(pkgpath); len(dir) != 0 {
+			return dir
+		}
+	}
+	for _, srcdir := range paths.GoSrcDirs {
+		dir := filepath.Join(srcdir, filepath.FromSlash(pkgpath))
+		if isDir(dir) {
+			return dir
+		}
+	}
+	if !enableModule {
+		return findInModuleCache(pkgpath)
+	}
+	return ""
+}
+
+func moduleCacheDir() string {
+	if dir := os.Getenv("GOMODCACHE"); len(dir) != 0 {
+		return dir
+	}
+	gopath := filepath.SplitList(build.Default.GOPATH)
+	if len(gopath) == 0 {
+		return ""
+	}
+	return filepath.Join(gopath[0], "pkg", "mod")
+}
+
+// findInModuleCache searches package pkgpath in the module cache.
+// Each prefix of pkgpath is a candidate module path: for each of them, use the version
+// required by the go.mod in current directory or its parents, or the highest version downloaded
+func findInModuleCache(pkgpath string) string {
+	modcache := moduleCacheDir()
+	if len(modcache) == 0 {
+		return ""
+	}
+	required := requiredModules()
+	for modpath := pkgpath; ; modpath = path.Dir(modpath) {
+		subdir := filepath.FromSlash(strings.TrimPrefix(pkgpath[len(modpath):], "/"))
+		if dir, ok := required[modpath]; ok && filepath.IsAbs(dir) {
+			// replaced by a local directory
+			if dir = filepath.Join(dir, subdir); isDir(dir) {
+				return dir
+			}
+		} else if moddir := findModuleInCache(modcache, modpath, dir); len(moddir) != 0 {
+			if dir = filepath.Join(moddir, subdir); isDir(dir) {
+				return dir
+			}
+		}
+		if !strings.Contains(modpath, "/") {
+			break
+		}
+	}
+	return ""
+}
+
+// findModuleInCache returns the directory of module modpath in the module cache.
+// Prefers the specified version, otherwise uses the highest version found
+func findModuleInCache(modcache string, modpath string, version string) string {
+	escpath, err := module.EscapePath(modpath)
+	if err != nil {
+		return ""
+	}
+	prefix := filepath.Join(modcache, filepath.FromSlash(escpath)) + "@"
+	if len(version) != 0 {
+		if escversion, err := module.EscapeVersion(version); err == nil && isDir(prefix+escversion) {
+			return prefix + escversion
+		}
+	}
+	matches, _ := filepath.Glob(prefix + "*")
+	var best, bestdir string
+	for _, match := range matches {
+		version := match[len(prefix):]
+		if semver.IsValid(version) && isDir(match) && (len(best) == 0 || semver.Compare(version, best) > 0) {
+			best, bestdir = version, match
+		}
+	}
+	return bestdir
+}
+
+// requiredModules parses the go.mod in current directory or its parents,
+// and returns the required version of each module.
+// Modules replaced by a local directory are mapped to such absolute directory instead
+func requiredModules() map[string]string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil
+	}
+	for {
+		gomod := filepath.Join(dir, "go.mod")
+		if data, err := ioutil.ReadFile(gomod); err == nil {
+			return parseRequiredModules(gomod, data)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+}
+
+func parseRequiredModules(gomod string, data []byte) map[string]string {
+	file, err := modfile.ParseLax(gomod, data, nil)
+	if err != nil {
+		return nil
+	}
+	required := make(map[string]string)
+	for _, req := range file.Require {
+		required[req.Mod.Path] = req.Mod.Version
+	}
+	for _, rep := range file.Replace {
+		if len(rep.New.Version) != 0 {
+			if rep.New.Path == rep.Old.Path {
+				required[rep.Old.Path] = rep.New.Version
+			}
+		} else if modfile.IsDirectoryPath(rep.New.Path) {
+			dir := rep.New.Path
+			if !filepath.IsAbs(dir) {
+				dir = filepath.Join(filepath.Dir(gomod), dir)
+			}
+			required[rep.Old.Path] = dir
+		}
+	}
+	return required
+}
+
+func isDir(dir string) bool {
+	info, err := os.Stat(dir)
+	return err == nil && info.IsDir()
+}
@@ -18,14 +18,18 @@ func init() {
 			"ImBuiltin":         r.ValueOf(ImBuiltin),
 			"ImInception":       r.ValueOf(ImInception),
 			"ImPlugin":          r.ValueOf(ImPlugin),
+			"ImSource":          r.ValueOf(ImSource),
 			"ImThirdParty":      r.ValueOf(ImThirdParty),
+			"LoadSourceDir":     r.ValueOf(LoadSourceDir),
+			"LoadTestSourceDir": r.ValueOf(LoadTestSourceDir),
 			"LookupPackage":     r.ValueOf(LookupPackage),
 		}, Types: map[string]r.Type{
-			"ImportMode":  r.TypeOf((*ImportMode)(nil)).Elem(),
-			"Importer":    r.TypeOf((*Importer)(nil)).Elem(),
-			"Output":      r.TypeOf((*Output)(nil)).Elem(),
-			"PackageRef":  r.TypeOf((*PackageRef)(nil)).Elem(),
-			"TypeVisitor": r.TypeOf((*TypeVisitor)(nil)).Elem(),
+			"ImportMode":    r.TypeOf((*ImportMode)(nil)).Elem(),
+			"Importer":      r.TypeOf((*Importer)(nil)).Elem(),
+			"Output":        r.TypeOf((*Output)(nil)).Elem(),
+			"PackageRef":    r.TypeOf((*PackageRef)(nil)).Elem(),
+			"SourcePackage": r.TypeOf((*SourcePackage)(nil)).Elem(),
+			"TypeVisitor":   r.TypeOf((*TypeVisitor)(nil)).Elem(),
 		}, Wrappers: map[string][]string{
 			"Output":     []string{"Copy", "ErrorAt", "Errorf", "Fprintf", "IncLine", "IncLineBytes", "MakeRuntimeError", "Position", "Sprintf", "ToString"},
 			"PackageRef": []string{"LazyInit", "Merge"},
@@ -64,6 +64,7 @@ type Globals struct {
 	MacroChar    rune // prefix for macro-related keywords macro, quote, quasiquote, splice... The default is '~'
 	ReplCmdChar  byte // prefix for special REPL commands env, help, inspect, quit, unload... The default is ':'
 	Inspector    Inspector
+	LangVersion  int // minor number of Go language version implemented by the interpreter, i.e. 22 means go1.22
 }
 
 func NewGlobals() *Globals {
@@ -94,6 +95,7 @@ func NewGlobals() *Globals {
 		ParserMode:   0,
 		MacroChar:    '~',
 		ReplCmdChar:  ':', // Jupyter and gophernotes would probably set this to '%'
+		LangVersion:  LangDefault,
 	}
 	g.Importer = genimport.DefaultImporter(&g.Output)
 	return g
@@ -0,0 +1,70 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * lang.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package base
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Go language versions that change the semantics of existing code
+const (
+	LangGo121 = 21 // go1.21: variables declared by "for" statements are shared among iterations
+	LangGo122 = 22 // go1.22: variables declared by "for" statements are per-iteration
+
+	LangLatest  = LangGo122 // latest Go language version that changes the semantics of existing code
+	LangDefault = LangGo121 // default Go language version: keeps the semantics of scripts written for older gomacro
+)
+
+// ParseLangVersion parses a Go language version as "go1.21" or "1.21"
+// and returns its minor number, i.e. 21
+func ParseLangVersion(str string) (int, error) {
+	s := strings.TrimPrefix(strings.TrimSpace(str), "go")
+	if !strings.HasPrefix(s, "1.") {
+		return 0, fmt.Errorf("invalid Go language version %q, expecting go1.N", str)
+	}
+	s = s[2:]
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		s = s[:i] // ignore patch level, as in go1.21.3
+	}
+	minor, err := strconv.Atoi(s)
+	if err != nil || minor < 0 {
+		return 0, fmt.Errorf("invalid Go language version %q, expecting go1.N", str)
+	}
+	return minor, nil
+}
+
+// LangVersionString formats a Go language version minor number as "go1.N"
+func LangVersionString(minor int) string {
+	return fmt.Sprintf("go1.%d", minor)
+}
+
+// SetLangVersion parses and sets the Go language version implemented by the interpreter.
+// Versions newer than LangLatest are accepted, and behave as LangLatest
+func (g *Globals) SetLangVersion(str string) error {
+	minor, err := ParseLangVersion(str)
+	if err == nil {
+		g.LangVersion = minor
+	}
+	return err
+}
+
+// LoopVarPerIteration returns true if variables declared by "for" statements
+// are per-iteration instead of shared, as specified by go1.22 and later
+func (g *Globals) LoopVarPerIteration() bool {
+	return g.LangVersion >= LangGo122
+}
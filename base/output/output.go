@@ -97,17 +97,18 @@ func (st *Stringer) Errorf(format string, args ...interface{}) (r.Value, []r.Val
 }
 
 func (st *Stringer) ErrorAt(pos token.Pos, format string, args ...interface{}) (r.Value, []r.Value) {
-	if st != nil {
-		args = st.toPrintables(format, args)
-		if st.Fileset != nil {
-			position := st.Fileset.Position(pos).String()
-			if position != "" && position != "-" {
-				args = append([]interface{}{position}, args...)
-				format = "%s: " + format
-			}
-		}
+	if st == nil {
+		panic(RuntimeError{nil, format, args})
 	}
-	panic(RuntimeError{nil, format, args})
+	// copy st: its Pos will change while compiling or executing more code
+	at := *st
+	at.Pos = pos
+	panic(RuntimeError{&at, format, args})
+}
+
+// Position returns the source position where the error occurred, if known
+func (err RuntimeError) Position() token.Position {
+	return err.st.Position()
 }
 
 func Warnf(format string, args ...interface{}) {
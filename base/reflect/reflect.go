@@ -214,7 +214,7 @@ func ValueType(value xr.Value) r.Type {
 func IsNillableKind(k r.Kind) bool {
 	switch k {
 	case r.Invalid, // nil is nillable...
-		r.Chan, r.Func, r.Interface, r.Map, r.Ptr, r.Slice:
+		r.Chan, r.Func, r.Interface, r.Map, r.Ptr, r.Slice, r.UnsafePointer:
 		return true
 	default:
 		return false
@@ -52,6 +52,7 @@ const (
 	OptShowParse
 	OptShowPrompt
 	OptShowTime
+	OptUnsafe // allow conversions from/to unsafe.Pointer and unsafe.Alignof, unsafe.Offsetof, unsafe.Sizeof
 )
 
 const (
@@ -88,6 +89,7 @@ var optNames = map[Options]string{
 	OptShowParse:           "Parse.Show",
 	OptShowPrompt:          "Prompt.Show",
 	OptShowTime:            "Time.Show",
+	OptUnsafe:              "Unsafe",
 }
 
 var optValues = map[string]Options{}
@@ -17,6 +17,7 @@
 package untyped
 
 import (
+	"fmt"
 	"go/constant"
 	"go/token"
 	"math/big"
@@ -418,3 +419,44 @@ func ConvertLiteralCheckOverflow(src interface{}, to xr.Type) interface{} {
 	}
 	return vto.Interface()
 }
+
+// ================================= MinMax =================================
+
+// MinMax returns the smallest (if op == token.LSS) or the largest (if op == token.GTR)
+// of the given untyped constants, as computed by builtins min() and max().
+// The result has the "largest" kind among the arguments: for example min(1, 2.5) is the untyped float 1.0
+func MinMax(op token.Token, lits []Lit) (Lit, error) {
+	if op != token.LSS && op != token.GTR {
+		return Lit{}, fmt.Errorf("MinMax: invalid operator %v, expecting %v or %v", op, token.LSS, token.GTR)
+	} else if len(lits) == 0 {
+		return Lit{}, fmt.Errorf("MinMax: not enough arguments, expecting at least one")
+	}
+	ret := lits[0]
+	kind := ret.Kind
+	for i, lit := range lits {
+		switch lit.Kind {
+		case Int, Rune, Float:
+			if kind == String {
+				return Lit{}, fmt.Errorf("mismatched untyped constants %v and %v", ret, lit)
+			}
+			// Int < Rune < Float, as in Go language specification
+			if lit.Kind > kind {
+				kind = lit.Kind
+			}
+		case String:
+			if kind != String {
+				return Lit{}, fmt.Errorf("mismatched untyped constants %v and %v", ret, lit)
+			}
+		default:
+			return Lit{}, fmt.Errorf("cannot compare untyped constant %v: not an untyped number or string", lit)
+		}
+		if i != 0 && constant.Compare(lit.Val, op, ret.Val) {
+			ret = lit
+		}
+	}
+	val := ret.Val
+	if kind == Float {
+		val = constant.ToFloat(val)
+	}
+	return MakeLit(kind, val, ret.basicTypes), nil
+}
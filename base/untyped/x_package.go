@@ -20,6 +20,7 @@ func init() {
 		"Int":	r.ValueOf(Int),
 		"MakeKind":	r.ValueOf(MakeKind),
 		"MakeLit":	r.ValueOf(MakeLit),
+		"MinMax":	r.ValueOf(MinMax),
 		"Marshal":	r.ValueOf(Marshal),
 		"None":	r.ValueOf(None),
 		"Rune":	r.ValueOf(Rune),
@@ -28,6 +28,11 @@ func init() {
 			"IsGensymAnonymous":          r.ValueOf(IsGensymAnonymous),
 			"IsGensymInterface":          r.ValueOf(IsGensymInterface),
 			"IsGensymPrivate":            r.ValueOf(IsGensymPrivate),
+			"LangDefault":                r.ValueOf(LangDefault),
+			"LangGo121":                  r.ValueOf(LangGo121),
+			"LangGo122":                  r.ValueOf(LangGo122),
+			"LangLatest":                 r.ValueOf(LangLatest),
+			"LangVersionString":          r.ValueOf(LangVersionString),
 			"MakeBufReadline":            r.ValueOf(MakeBufReadline),
 			"MakeNestedQuote":            r.ValueOf(MakeNestedQuote),
 			"MakeQuote":                  r.ValueOf(MakeQuote),
@@ -67,6 +72,8 @@ func init() {
 			"OptShowPrompt":              r.ValueOf(OptShowPrompt),
 			"OptShowTime":                r.ValueOf(OptShowTime),
 			"OptTrapPanic":               r.ValueOf(OptTrapPanic),
+			"OptUnsafe":                  r.ValueOf(OptUnsafe),
+			"ParseLangVersion":           r.ValueOf(ParseLangVersion),
 			"ParseOptions":               r.ValueOf(ParseOptions),
 			"ReadBytes":                  r.ValueOf(ReadBytes),
 			"ReadMultiline":              r.ValueOf(ReadMultiline),
@@ -23,6 +23,7 @@ import (
 	"io/ioutil"
 	"os"
 	"strings"
+	"time"
 
 	. "github.com/cosmos72/gomacro/base"
 	"github.com/cosmos72/gomacro/base/genimport"
@@ -30,6 +31,9 @@ import (
 	"github.com/cosmos72/gomacro/base/paths"
 	"github.com/cosmos72/gomacro/fast"
 	"github.com/cosmos72/gomacro/fast/debug"
+	"github.com/cosmos72/gomacro/fast/debug/dap"
+	"github.com/cosmos72/gomacro/fast/gotest"
+	"github.com/cosmos72/gomacro/fast/rpc"
 	"github.com/cosmos72/gomacro/go/etoken"
 )
 
@@ -70,6 +74,16 @@ func (cmd *Cmd) Main(args []string) (err error) {
 
 	var set, clear Options
 	var repl, forcerepl = true, false
+	var covermode, coverprofile = "set", ""
+	var watching bool
+	defer func() {
+		if len(coverprofile) == 0 {
+			return
+		}
+		if err1 := ir.WriteCoverageFile(coverprofile); err == nil && err1 != nil {
+			err = fmt.Errorf("gomacro: %v", err1)
+		}
+	}()
 	cmd.WriteDeclsAndStmts = false
 	cmd.OverwriteFiles = false
 
@@ -90,6 +104,31 @@ func (cmd *Cmd) Main(args []string) (err error) {
 				}
 				args = args[1:]
 			}
+		case "--covermode":
+			if len(args) < 2 {
+				return fmt.Errorf("gomacro: option '%s' requires an argument.\nTry 'gomacro --help' for more information", args[0])
+			} else if len(coverprofile) != 0 {
+				// coverage already started, with the previous mode
+				return fmt.Errorf("gomacro: option '%s' must precede '--coverprofile'.\nTry 'gomacro --help' for more information", args[0])
+			}
+			covermode = args[1]
+			args = args[1:]
+		case "--coverprofile":
+			if len(args) < 2 {
+				return fmt.Errorf("gomacro: option '%s' requires an argument.\nTry 'gomacro --help' for more information", args[0])
+			}
+			if err := ir.StartCoverage(covermode); err != nil {
+				return fmt.Errorf("gomacro: %v", err)
+			}
+			coverprofile = args[1]
+			args = args[1:]
+		case "--dap":
+			return cmd.ServeDAP("")
+		case "--dap-listen":
+			if len(args) < 2 {
+				return fmt.Errorf("gomacro: option '%s' requires an argument.\nTry 'gomacro --help' for more information", args[0])
+			}
+			return cmd.ServeDAP(args[1])
 		case "-f", "--force-overwrite":
 			cmd.OverwriteFiles = true
 		case "-g", "--genimport":
@@ -106,6 +145,25 @@ func (cmd *Cmd) Main(args []string) (err error) {
 			return cmd.Usage()
 		case "-i", "--repl":
 			forcerepl = true
+		case "-l", "--lang":
+			if len(args) < 2 {
+				return fmt.Errorf("gomacro: option '%s' requires an argument.\nTry 'gomacro --help' for more information", args[0])
+			}
+			if err := g.SetLangVersion(args[1]); err != nil {
+				return fmt.Errorf("gomacro: %v", err)
+			}
+			args = args[1:]
+		case "run":
+			if len(args) < 2 {
+				return fmt.Errorf("gomacro: command '%s' requires an argument.\nTry 'gomacro --help' for more information", args[0])
+			}
+			g.Options &^= OptShowPrompt | OptShowEval | OptShowEvalType // cleared by default, overridden by -s, -v and -vv
+			g.Options = (g.Options | set) &^ clear
+			return cmd.RunPackage(args[1], args[2:])
+		case "test":
+			g.Options &^= OptShowPrompt | OptShowEval | OptShowEvalType
+			g.Options = (g.Options | set) &^ clear
+			return cmd.TestPackage(args[1:])
 		case "-m", "--macro-only":
 			set |= OptMacroExpandOnly
 			clear &^= OptMacroExpandOnly
@@ -115,6 +173,15 @@ func (cmd *Cmd) Main(args []string) (err error) {
 		case "-t", "--trap":
 			set |= OptTrapPanic | OptPanicStackTrace
 			clear &= OptTrapPanic | OptPanicStackTrace
+		case "--serve":
+			return cmd.Serve("")
+		case "--serve-unix":
+			if len(args) < 2 {
+				return fmt.Errorf("gomacro: option '%s' requires an argument.\nTry 'gomacro --help' for more information", args[0])
+			}
+			return cmd.Serve(args[1])
+		case "-u", "--unsafe":
+			g.Options |= OptUnsafe
 		case "-s", "--silent":
 			set &^= OptShowPrompt | OptShowEval | OptShowEvalType
 			clear |= OptShowPrompt | OptShowEval | OptShowEvalType
@@ -126,6 +193,18 @@ func (cmd *Cmd) Main(args []string) (err error) {
 			clear &^= OptShowEval | OptShowEvalType
 		case "-w", "--write-decls":
 			cmd.WriteDeclsAndStmts = true
+		case "--watch":
+			if len(args) < 2 {
+				return fmt.Errorf("gomacro: option '%s' requires an argument.\nTry 'gomacro --help' for more information", args[0])
+			}
+			repl = false
+			g.Options &^= OptShowPrompt | OptShowEval | OptShowEvalType // cleared by default, overridden by -s, -v and -vv
+			g.Options = (g.Options | set) &^ clear
+			// start watching before evaluating the file, that may never return
+			go cmd.Watch(args[1], watchInterval, nil)
+			watching = true
+			cmd.EvalFile(args[1])
+			args = args[1:]
 		case "-x", "--exec":
 			clear |= OptMacroExpandOnly
 			set &^= OptMacroExpandOnly
@@ -150,16 +229,61 @@ func (cmd *Cmd) Main(args []string) (err error) {
 		g.Options |= OptShowPrompt | OptShowEval | OptShowEvalType // set by default, overridden by -s, -v and -vv
 		g.Options = (g.Options | set) &^ clear
 		ir.ReplStdin()
+	} else if watching {
+		// keep reloading watched files until the process is interrupted
+		select {}
 	}
 	return nil
 }
 
+const watchInterval = 500 * time.Millisecond
+
+// Watch checks every interval if filepath changed and, if so, reloads it with Interp.Reload.
+// Returns when stop is closed
+func (cmd *Cmd) Watch(filepath string, interval time.Duration, stop <-chan struct{}) {
+	ir := cmd.Interp
+	g := &ir.Comp.Globals
+	info, _ := os.Stat(filepath)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		curr, err := os.Stat(filepath)
+		if err != nil || (info != nil && curr.ModTime().Equal(info.ModTime()) && curr.Size() == info.Size()) {
+			continue
+		}
+		info = curr
+		reloaded, err := ir.Reload(filepath)
+		if len(reloaded) != 0 {
+			g.Fprintf(g.Stdout, "// reloaded %s: %s\n", filepath, strings.Join(reloaded, ", "))
+		}
+		if err != nil {
+			g.Fprintf(g.Stderr, "// reload %s: %v\n", filepath, err)
+		}
+	}
+}
+
 func (cmd *Cmd) Usage() error {
 	g := &cmd.Interp.Comp.Globals
 	fmt.Fprint(g.Stdout, `usage: gomacro [OPTIONS] [files-and-dirs]
+       gomacro [OPTIONS] run DIR [ARGUMENTS]
+       gomacro [OPTIONS] test [FLAGS] [DIR]
 
   Recognized options:
     -c,   --collect          collect declarations and statements, to print them later
+          --covermode MODE   coverage mode for --coverprofile: set (default), count or atomic.
+                             must precede --coverprofile
+          --coverprofile FILE
+                             collect coverage of interpreted code compiled after this option,
+                             and write it to FILE on exit in the format read by "go tool cover"
+          --dap              run a Debug Adapter Protocol server on standard input and output,
+                             and exit when the client disconnects. Used by VS Code, Neovim
+                             and other DAP clients
+          --dap-listen ADDR  run a Debug Adapter Protocol server on TCP address ADDR, as 127.0.0.1:4711
     -e,   --expr EXPR        evaluate expression
     -f,   --force-overwrite  option -w will overwrite existing files
     -g,   --genimport [PATH] write x_package.go bindings for specified import path and exit.
@@ -168,10 +292,17 @@ func (cmd *Cmd) Usage() error {
     -h,   --help             show this help and exit
     -i,   --repl             interactive. start a REPL after evaluating expression, files and dirs.
                              default: start a REPL only if no expressions, files or dirs are specified
+    -l,   --lang VERSION     Go language version to implement, as go1.22. default: go1.21
+                             go1.21 and older share loop variables among iterations
     -m,   --macro-only       do not execute code, only parse and macroexpand it.
                              useful to run gomacro as a Go preprocessor
     -n,   --no-trap          do not trap panics in the interpreter
     -t,   --trap             trap panics in the interpreter (default)
+          --serve            run a JSON-RPC 2.0 server on standard input and output, one message per line.
+                             Supported methods: eval, complete, inspect, interrupt, reset
+          --serve-unix PATH  run a JSON-RPC 2.0 server on Unix domain socket PATH
+    -u,   --unsafe           allow conversions from/to unsafe.Pointer and unsafe.Alignof, Offsetof, Sizeof.
+                             can also be enabled at REPL with :options Unsafe
     -s,   --silent           silent. do NOT show startup message, prompt, and expressions results.
                              default when executing files and dirs.
     -v,   --verbose          verbose. show startup message, prompt, and expressions results.
@@ -181,15 +312,82 @@ func (cmd *Cmd) Usage() error {
     -w,   --write-decls      write collected declarations and statements to *.go files.
                              implies -c
     -x,   --exec             execute parsed code (default). disabled by -m
+          --watch FILE       evaluate FILE, then reload it each time it changes: changed functions
+                             and methods are recompiled, and global variables keep their values
 
     Options are processed in order, except for -i that is always processed as last.
 
+    "gomacro run DIR" interprets the Go package in directory DIR as "go run" does:
+    it loads all the *.go files matching current build constraints, excluding tests,
+    then executes all the init() functions and main(). ARGUMENTS are passed to main() in os.Args
+
+    "gomacro test DIR" interprets the Go package in directory DIR and its *_test.go files
+    as "go test" does: it executes TestXxx, ExampleXxx and FuzzXxx functions (seed corpus only)
+    and, if -bench is specified, BenchmarkXxx functions. DIR defaults to the current directory.
+    Supported FLAGS: -run, -skip, -bench, -benchtime, -benchmem, -count, -failfast,
+                     -json, -list, -short, -v
+
     Collected declarations and statements can be also written to standard output
     or to a file with the REPL command :write
 `)
 	return nil
 }
 
+// ServeDAP runs a Debug Adapter Protocol server on TCP address addr,
+// or on standard input and output if addr is empty
+func (cmd *Cmd) ServeDAP(addr string) error {
+	ir := cmd.Interp
+	if addr == "" {
+		return dap.ServeStdio(ir, cmd.EvalFileOrDir)
+	}
+	return dap.ListenAndServe(ir, addr, cmd.EvalFileOrDir)
+}
+
+// Serve runs a JSON-RPC server on Unix domain socket path,
+// or on standard input and output if path is empty
+func (cmd *Cmd) Serve(path string) error {
+	newInterp := func() *fast.Interp {
+		cmd.Init()
+		return cmd.Interp
+	}
+	if path == "" {
+		return rpc.ServeStdio(cmd.Interp, newInterp)
+	}
+	return rpc.ListenAndServe(cmd.Interp, path, newInterp)
+}
+
+// RunPackage interprets the Go package in directory dir as "go run" does,
+// passing args to its main() function in os.Args
+func (cmd *Cmd) RunPackage(dir string, args []string) error {
+	os.Args = append([]string{dir}, args...)
+	return cmd.Interp.RunPackage(dir)
+}
+
+// TestPackage interprets the Go package in a directory and its tests as "go test" does.
+// args contains the test flags, optionally followed by the directory.
+// Returns ExitStatus(1) if some test failed
+func (cmd *Cmd) TestPackage(args []string) error {
+	ok, err := gotest.Main(cmd.Interp, args)
+	if err != nil {
+		return err
+	} else if !ok {
+		return ExitStatus(1)
+	}
+	return nil
+}
+
+// ExitStatus is returned by Cmd.Main to request a non-zero exit status
+// without printing any error message
+type ExitStatus int
+
+func (e ExitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+func (e ExitStatus) ExitStatus() int {
+	return int(e)
+}
+
 func (cmd *Cmd) EvalFilesAndDirs(filesAndDirs ...string) error {
 	for _, fileOrDir := range filesAndDirs {
 		err := cmd.EvalFileOrDir(fileOrDir)
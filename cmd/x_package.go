@@ -15,6 +15,7 @@ func init() {
 		"New":	r.ValueOf(New),
 	}, Types: map[string]r.Type{
 		"Cmd":	r.TypeOf((*Cmd)(nil)).Elem(),
+		"ExitStatus":	r.TypeOf((*ExitStatus)(nil)).Elem(),
 	}, 
 	}
 }
@@ -0,0 +1,368 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * breakpoint.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package fast
+
+import (
+	"fmt"
+	"go/token"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	satomic "sync/atomic"
+
+	"github.com/cosmos72/gomacro/atomic"
+	"github.com/cosmos72/gomacro/base"
+	bstrings "github.com/cosmos72/gomacro/base/strings"
+	"github.com/cosmos72/gomacro/go/etoken"
+	xr "github.com/cosmos72/gomacro/xreflect"
+)
+
+// Breakpoint is a location where the debugger stops execution:
+// either a source line FILE:LINE or the first statement of a function.
+// Breakpoints are resolved at runtime against Env.DebugPos,
+// thus they also work for code compiled before setting them
+type Breakpoint struct {
+	ID      int
+	File    string // empty if breakpoint is set on a function, or on LINE of any file
+	Line    int    // zero if breakpoint is set on a function
+	Func    string // function or method name. empty if breakpoint is set on a source line
+	Cond    string // optional condition: an interpreted Go expression evaluated in the stopped Env
+	Enabled bool
+	Hits    int // how many times execution stopped at this breakpoint
+}
+
+func (bp *Breakpoint) Location() string {
+	if len(bp.Func) != 0 {
+		return bp.Func
+	} else if len(bp.File) != 0 {
+		return fmt.Sprintf("%s:%d", bp.File, bp.Line)
+	}
+	return strconv.Itoa(bp.Line)
+}
+
+func (bp *Breakpoint) String() string {
+	var state string
+	if bp.Enabled {
+		state = "enabled"
+	} else {
+		state = "disabled"
+	}
+	str := fmt.Sprintf("breakpoint %d at %s, %s, hit %d times", bp.ID, bp.Location(), state, bp.Hits)
+	if len(bp.Cond) != 0 {
+		str = fmt.Sprintf("%s, if %s", str, bp.Cond)
+	}
+	return str
+}
+
+// Breakpoints contains the breakpoints of an interpreter.
+// They are shared by all goroutines executing interpreted code
+type Breakpoints struct {
+	lock    atomic.SpinLock
+	enabled int32 // number of enabled breakpoints. read without locking
+	lastID  int
+	list    []*Breakpoint
+	cache   map[token.Pos]*Breakpoint // enabled FILE:LINE breakpoint for each statement position, or nil
+}
+
+// active returns true if at least one breakpoint is enabled
+func (bps *Breakpoints) active() bool {
+	return satomic.LoadInt32(&bps.enabled) != 0
+}
+
+// must be invoked with bps.lock held
+func (bps *Breakpoints) changed() {
+	var n int32
+	for _, bp := range bps.list {
+		if bp.Enabled {
+			n++
+		}
+	}
+	satomic.StoreInt32(&bps.enabled, n)
+	bps.cache = nil
+}
+
+// must be invoked with bps.lock held
+func (bps *Breakpoints) find(id int) (int, *Breakpoint) {
+	for i, bp := range bps.list {
+		if bp.ID == id {
+			return i, bp
+		}
+	}
+	return -1, nil
+}
+
+// parseBreakpointLocation parses FILE:LINE, LINE or FUNCTION
+func parseBreakpointLocation(location string) (Breakpoint, error) {
+	location = strings.TrimSpace(location)
+	var bp Breakpoint
+	if len(location) == 0 {
+		return bp, fmt.Errorf("missing breakpoint location, expecting FILE:LINE, LINE or FUNCTION")
+	}
+	file, line := "", location
+	if i := strings.LastIndexByte(location, ':'); i >= 0 {
+		file, line = location[:i], location[i+1:]
+		if len(file) == 0 {
+			return bp, fmt.Errorf("invalid breakpoint location %q: missing file name before ':'", location)
+		}
+	}
+	if n, err := strconv.Atoi(line); err == nil {
+		if n <= 0 {
+			return bp, fmt.Errorf("invalid breakpoint location %q: line must be positive", location)
+		}
+		bp.File, bp.Line = file, n
+	} else if len(file) != 0 {
+		return bp, fmt.Errorf("invalid breakpoint location %q: expecting FILE:LINE", location)
+	} else if !token.IsIdentifier(location) {
+		return bp, fmt.Errorf("invalid breakpoint location %q: expecting FILE:LINE, LINE or FUNCTION", location)
+	} else {
+		bp.Func = location
+	}
+	return bp, nil
+}
+
+// AddBreakpoint sets a breakpoint at location, which can be FILE:LINE, LINE or FUNCTION.
+// cond is an optional interpreted Go expression: if not empty,
+// execution will stop at the breakpoint only if it evaluates to true
+func (ir *Interp) AddBreakpoint(location string, cond string) (Breakpoint, error) {
+	bp, err := parseBreakpointLocation(location)
+	if err != nil {
+		return bp, err
+	}
+	bp.Cond = strings.TrimSpace(cond)
+	bp.Enabled = true
+
+	bps := &ir.env.Run.Breakpoints
+	bps.lock.Lock()
+	defer bps.lock.Unlock()
+	bps.lastID++
+	bp.ID = bps.lastID
+	newbp := bp
+	bps.list = append(bps.list, &newbp)
+	bps.changed()
+	return bp, nil
+}
+
+// ListBreakpoints returns a copy of all the breakpoints
+func (ir *Interp) ListBreakpoints() []Breakpoint {
+	bps := &ir.env.Run.Breakpoints
+	bps.lock.Lock()
+	defer bps.lock.Unlock()
+	list := make([]Breakpoint, len(bps.list))
+	for i, bp := range bps.list {
+		list[i] = *bp
+	}
+	return list
+}
+
+// EnableBreakpoint enables or disables the breakpoint with given id
+func (ir *Interp) EnableBreakpoint(id int, enable bool) error {
+	bps := &ir.env.Run.Breakpoints
+	bps.lock.Lock()
+	defer bps.lock.Unlock()
+	_, bp := bps.find(id)
+	if bp == nil {
+		return fmt.Errorf("no breakpoint %d", id)
+	}
+	bp.Enabled = enable
+	bps.changed()
+	return nil
+}
+
+// DeleteBreakpoint deletes the breakpoint with given id
+func (ir *Interp) DeleteBreakpoint(id int) error {
+	bps := &ir.env.Run.Breakpoints
+	bps.lock.Lock()
+	defer bps.lock.Unlock()
+	i, _ := bps.find(id)
+	if i < 0 {
+		return fmt.Errorf("no breakpoint %d", id)
+	}
+	bps.list = append(bps.list[:i], bps.list[i+1:]...)
+	bps.changed()
+	return nil
+}
+
+// return true if filename matches the file name of a breakpoint,
+// which can also be a relative path or a base name
+func matchBreakpointFile(file string, filename string) bool {
+	if len(file) == 0 || file == filename {
+		return true
+	}
+	n := len(filename) - len(file)
+	return n > 0 && filename[n:] == file && (filename[n-1] == '/' || filename[n-1] == filepath.Separator)
+}
+
+// return the enabled FILE:LINE breakpoint at position pos, or nil.
+// must be invoked with bps.lock held
+func (bps *Breakpoints) atPos(fileset *etoken.FileSet, pos token.Pos) *Breakpoint {
+	if ret, ok := bps.cache[pos]; ok {
+		return ret
+	}
+	var ret *Breakpoint
+	if position := fileset.Position(pos); position.IsValid() {
+		for _, bp := range bps.list {
+			if bp.Enabled && bp.Line == position.Line && matchBreakpointFile(bp.File, position.Filename) {
+				ret = bp
+				break
+			}
+		}
+	}
+	if bps.cache == nil {
+		bps.cache = make(map[token.Pos]*Breakpoint)
+	}
+	bps.cache[pos] = ret
+	return ret
+}
+
+// return the enabled breakpoint on function name, or nil.
+// must be invoked with bps.lock held
+func (bps *Breakpoints) atFunc(name string) *Breakpoint {
+	for _, bp := range bps.list {
+		if bp.Enabled && bp.Func == name {
+			return bp
+		}
+	}
+	return nil
+}
+
+// breakpoint returns the breakpoint where execution should stop
+// before executing the statement env.Code[env.IP], or nil
+func (run *Run) breakpoint(c *Comp, env *Env) *Breakpoint {
+	bps := &run.Breakpoints
+	if !bps.active() {
+		return nil
+	}
+	var bp *Breakpoint
+	bps.lock.Lock()
+	if ip := env.IP; ip < len(env.DebugPos) && env.DebugPos[ip] != token.NoPos && run.Fileset != nil {
+		bp = bps.atPos(run.Fileset, env.DebugPos[ip])
+	}
+	if bp == nil && env.IP == 0 && env.Caller != nil && c.FuncMaker != nil && len(c.FuncMaker.Name) != 0 {
+		bp = bps.atFunc(c.FuncMaker.Name)
+	}
+	cond := ""
+	if bp != nil {
+		cond = bp.Cond
+	}
+	bps.lock.Unlock()
+
+	// stop only once on each source line, even if it contains multiple statements.
+	// stop again if execution jumps backward, as in a loop, or if env is reused by a new function call
+	if bp == run.lastBreak && env == run.lastBreakEnv && env.IP > run.lastBreakIP {
+		run.lastBreakIP = env.IP
+		return nil
+	}
+	run.lastBreak, run.lastBreakEnv, run.lastBreakIP = bp, env, env.IP
+	if bp == nil || !run.breakpointCond(c, env, cond) {
+		return nil
+	}
+	bps.lock.Lock()
+	bp.Hits++
+	bps.lock.Unlock()
+	return bp
+}
+
+// evaluate the condition of a breakpoint in the stopped env.
+// if evaluation fails, show the error and stop anyway
+func (run *Run) breakpointCond(c *Comp, env *Env, cond string) (ret bool) {
+	if len(cond) == 0 {
+		return true
+	}
+	// evaluating the condition must not alter the debugger state
+	debugDepth, execFlags, signals := run.DebugDepth, run.ExecFlags, run.Signals
+	run.noBreak++
+	defer func() {
+		run.noBreak--
+		run.DebugDepth, run.ExecFlags, run.Signals = debugDepth, execFlags, signals
+		if rec := recover(); rec != nil {
+			if run.Options&base.OptPanicStackTrace != 0 {
+				run.Fprintf(run.Stderr, "// breakpoint condition %s failed: %v\n%s", cond, rec, debug.Stack())
+			} else {
+				run.Fprintf(run.Stderr, "// breakpoint condition %s failed: %v\n", cond, rec)
+			}
+			ret = true
+		}
+	}()
+	inner := NewInnerInterp(&Interp{c, env}, "breakpoint", "breakpoint")
+	vals, _ := inner.Eval(cond)
+	if len(vals) == 0 || !vals[0].IsValid() || vals[0].Kind() != xr.Bool {
+		run.Fprintf(run.Stderr, "// breakpoint condition %s is not a bool expression\n", cond)
+		return true
+	}
+	return vals[0].Bool()
+}
+
+// BreakCmd executes a breakpoint command, as typed by the user at REPL or debugger prompt:
+//
+//	(empty)                  list breakpoints
+//	LOCATION [if COND]       set a breakpoint at FILE:LINE, LINE or FUNCTION
+//	delete|disable|enable ID...
+func (ir *Interp) BreakCmd(arg string) {
+	g := &ir.Comp.Globals
+	arg = strings.TrimSpace(arg)
+	if len(arg) == 0 {
+		list := ir.ListBreakpoints()
+		if len(list) == 0 {
+			g.Fprintf(g.Stdout, "// no breakpoints\n")
+		}
+		for i := range list {
+			g.Fprintf(g.Stdout, "// %v\n", &list[i])
+		}
+		return
+	}
+	word, rest := bstrings.Split2(arg, ' ')
+	var action func(id int) error
+	switch word {
+	case "delete":
+		action = ir.DeleteBreakpoint
+	case "disable":
+		action = func(id int) error { return ir.EnableBreakpoint(id, false) }
+	case "enable":
+		action = func(id int) error { return ir.EnableBreakpoint(id, true) }
+	}
+	if action != nil {
+		ids := strings.Fields(rest)
+		if len(ids) == 0 {
+			g.Fprintf(g.Stdout, "// break %s: missing breakpoint ID\n", word)
+		}
+		for _, str := range ids {
+			id, err := strconv.Atoi(str)
+			if err == nil {
+				err = action(id)
+			}
+			if err != nil {
+				g.Fprintf(g.Stdout, "// break %s: %v\n", word, err)
+			}
+		}
+		return
+	}
+	var cond string
+	if len(rest) != 0 {
+		if kw, expr := bstrings.Split2(rest, ' '); kw == "if" && len(expr) != 0 {
+			cond = expr
+		} else {
+			g.Fprintf(g.Stdout, "// break: expecting LOCATION [if COND], found: %s\n", arg)
+			return
+		}
+	}
+	bp, err := ir.AddBreakpoint(word, cond)
+	if err != nil {
+		g.Fprintf(g.Stdout, "// break: %v\n", err)
+	} else {
+		g.Fprintf(g.Stdout, "// %v\n", &bp)
+	}
+}
@@ -21,6 +21,8 @@ import (
 	"go/ast"
 	"go/constant"
 	"go/token"
+	"io"
+	"math"
 	"os"
 	r "reflect"
 
@@ -85,6 +87,8 @@ func (ir *Interp) addBuiltins() {
 	ir.DeclTypeAlias("rune", c.TypeOfInt32())
 	ir.DeclType(c.TypeOfError())
 	c.loadProxy("error", r.TypeOf((*proxy_error)(nil)).Elem(), c.TypeOfError())
+	ir.DeclTypeAlias("any", c.TypeOfInterface())
+	c.declComparable()
 
 	// https://golang.org/ref/spec#Constants
 	// "Literal constants, true, false, iota, and certain constant expressions containing only untyped constant operands are untyped."
@@ -96,6 +100,7 @@ func (ir *Interp) addBuiltins() {
 
 	ir.DeclBuiltin("append", Builtin{compileAppend, 1, base.MaxUint16})
 	ir.DeclBuiltin("cap", Builtin{compileCap, 1, 1})
+	ir.DeclBuiltin("clear", Builtin{compileClear, 1, 1})
 	ir.DeclBuiltin("close", Builtin{compileClose, 1, 1})
 	ir.DeclBuiltin("copy", Builtin{compileCopy, 2, 2})
 	ir.DeclBuiltin("complex", Builtin{compileComplex, 2, 2})
@@ -103,6 +108,8 @@ func (ir *Interp) addBuiltins() {
 	ir.DeclBuiltin("imag", Builtin{compileRealImag, 1, 1})
 	ir.DeclBuiltin("len", Builtin{compileLen, 1, 1})
 	ir.DeclBuiltin("make", Builtin{compileMake, 1, 3})
+	ir.DeclBuiltin("max", Builtin{compileMinMax, 1, base.MaxUint16})
+	ir.DeclBuiltin("min", Builtin{compileMinMax, 1, base.MaxUint16})
 	ir.DeclBuiltin("new", Builtin{compileNew, 1, 1})
 	ir.DeclBuiltin("panic", Builtin{compilePanic, 1, 1})
 	ir.DeclBuiltin("print", Builtin{compilePrint, 0, base.MaxUint16})
@@ -216,6 +223,33 @@ func compileCap(c *Comp, sym Symbol, node *ast.CallExpr) *Call {
 	return newCall1(fun, arg, arg.Const(), tout)
 }
 
+// --- clear() ---
+
+func callClear(val xr.Value) {
+	v := val.ReflectValue()
+	switch v.Kind() {
+	case r.Map:
+		clearMap(v)
+	case r.Slice:
+		zero := r.Zero(v.Type().Elem())
+		for i, n := 0, v.Len(); i < n; i++ {
+			v.Index(i).Set(zero)
+		}
+	}
+}
+
+func compileClear(c *Comp, sym Symbol, node *ast.CallExpr) *Call {
+	arg := c.expr1(node.Args[0], nil)
+	tin := arg.Type
+	if arg.Const() || tin == nil || (tin.Kind() != r.Map && tin.Kind() != r.Slice) {
+		return c.badBuiltinCallArgType(sym.Name, node.Args[0], tin, "map, slice")
+	}
+	t := c.Universe.FuncOf([]xr.Type{tin}, zeroTypes, false)
+	sym.Type = t
+	fun := exprLit(Lit{Type: t, Value: callClear}, &sym)
+	return newCall1(fun, arg, false)
+}
+
 // --- close() ---
 
 func callClose(val xr.Value) {
@@ -645,6 +679,188 @@ func compileMake(c *Comp, sym Symbol, node *ast.CallExpr) *Call {
 	return &Call{Fun: fun, Args: args, OutTypes: outtypes, Const: false}
 }
 
+// --- min(), max() ---
+
+// placeholder for builtins min() and max(): Comp.call_builtin() replaces it
+// with a function specialized for the arguments type, see minMaxFun()
+func callMinMax(args ...xr.Value) xr.Value {
+	return xr.Value{}
+}
+
+func compileMinMax(c *Comp, sym Symbol, node *ast.CallExpr) *Call {
+	if node.Ellipsis != token.NoPos {
+		c.Errorf("invalid operation: invalid use of ... with builtin %s: %v", sym.Name, node)
+		return nil
+	}
+	op := token.LSS
+	if sym.Name == "max" {
+		op = token.GTR
+	}
+	args := make([]*Expr, len(node.Args))
+	var t xr.Type
+	for i, arg := range node.Args {
+		args[i] = c.expr1(arg, nil)
+		if args[i].Untyped() {
+			continue
+		} else if ti := args[i].Type; t == nil {
+			t = ti
+		} else if !ti.IdenticalTo(t) {
+			c.Errorf("invalid argument: mismatched types <%v> and <%v> in %v", t, ti, node)
+			return nil
+		}
+	}
+	if t == nil {
+		return compileMinMaxUntyped(c, sym, node, op, args)
+	}
+	switch reflect.Category(t.Kind()) {
+	case r.Int, r.Uint, r.Float64, r.String:
+	default:
+		return c.badBuiltinCallArgType(sym.Name, node.Args[0], t, "integer, floating point or string")
+	}
+	argtypes := make([]xr.Type, len(args))
+	isconst := true
+	for i, arg := range args {
+		if arg.Untyped() {
+			arg.ConstTo(t)
+		}
+		argtypes[i] = t
+		isconst = isconst && arg.Const()
+	}
+	touts := []xr.Type{t}
+	tfun := c.Universe.FuncOf(argtypes, touts, false)
+	sym.Type = tfun
+	fun := exprLit(Lit{Type: tfun, Value: callMinMax}, &sym)
+	// min() and max() of constants are constants: they can be computed at compile time
+	return &Call{Fun: fun, Args: args, OutTypes: touts, Const: isconst}
+}
+
+func compileMinMaxUntyped(c *Comp, sym Symbol, node *ast.CallExpr, op token.Token, args []*Expr) *Call {
+	lits := make([]UntypedLit, len(args))
+	for i, arg := range args {
+		lits[i] = arg.Value.(UntypedLit)
+	}
+	val, err := untyped.MinMax(op, lits)
+	if err != nil {
+		c.Errorf("invalid argument: %v in %v", err, node)
+		return nil
+	}
+	touts := []xr.Type{c.TypeOfUntypedLit()}
+	tfun := c.Universe.FuncOf(nil, touts, false)
+	sym.Type = tfun
+	fun := exprLit(Lit{Type: tfun, Value: val}, &sym)
+	// min() and max() of untyped constants are both untyped and constant: they can be computed at compile time
+	return &Call{Fun: fun, Args: nil, OutTypes: touts, Const: true}
+}
+
+// minMaxFun returns a function that computes min() or max() of call.Args.
+// Floating point arguments follow the same rules as math.Min() and math.Max()
+// i.e. any NaN argument produces NaN, and -0.0 is smaller than +0.0
+func minMaxFun(call *Call) I {
+	argfuns := call.MakeArgfunsX1()
+	argfun, argfuns := argfuns[0], argfuns[1:]
+	ismin := call.Fun.Sym.Name == "min"
+	t := call.OutTypes[0]
+	var ret I
+	switch reflect.Category(t.Kind()) {
+	case r.Int:
+		fun := func(env *Env) int64 {
+			ret := argfun(env).Int()
+			for _, argfun := range argfuns {
+				if x := argfun(env).Int(); (x < ret) == ismin && x != ret {
+					ret = x
+				}
+			}
+			return ret
+		}
+		switch t.Kind() {
+		case xr.Int:
+			ret = func(env *Env) int {
+				return int(fun(env))
+			}
+		case xr.Int8:
+			ret = func(env *Env) int8 {
+				return int8(fun(env))
+			}
+		case xr.Int16:
+			ret = func(env *Env) int16 {
+				return int16(fun(env))
+			}
+		case xr.Int32:
+			ret = func(env *Env) int32 {
+				return int32(fun(env))
+			}
+		default:
+			ret = fun
+		}
+	case r.Uint:
+		fun := func(env *Env) uint64 {
+			ret := argfun(env).Uint()
+			for _, argfun := range argfuns {
+				if x := argfun(env).Uint(); (x < ret) == ismin && x != ret {
+					ret = x
+				}
+			}
+			return ret
+		}
+		switch t.Kind() {
+		case xr.Uint:
+			ret = func(env *Env) uint {
+				return uint(fun(env))
+			}
+		case xr.Uint8:
+			ret = func(env *Env) uint8 {
+				return uint8(fun(env))
+			}
+		case xr.Uint16:
+			ret = func(env *Env) uint16 {
+				return uint16(fun(env))
+			}
+		case xr.Uint32:
+			ret = func(env *Env) uint32 {
+				return uint32(fun(env))
+			}
+		case xr.Uintptr:
+			ret = func(env *Env) uintptr {
+				return uintptr(fun(env))
+			}
+		default:
+			ret = fun
+		}
+	case r.Float64:
+		op := math.Max
+		if ismin {
+			op = math.Min
+		}
+		fun := func(env *Env) float64 {
+			ret := argfun(env).Float()
+			for _, argfun := range argfuns {
+				ret = op(ret, argfun(env).Float())
+			}
+			return ret
+		}
+		if t.Kind() == xr.Float32 {
+			ret = func(env *Env) float32 {
+				return float32(fun(env))
+			}
+		} else {
+			ret = fun
+		}
+	case r.String:
+		ret = func(env *Env) string {
+			ret := argfun(env).String()
+			for _, argfun := range argfuns {
+				if x := argfun(env).String(); (x < ret) == ismin && x != ret {
+					ret = x
+				}
+			}
+			return ret
+		}
+	default:
+		output.Errorf("internal error: unimplemented %s() for type %v", call.Fun.Sym.Name, t)
+	}
+	return ret
+}
+
 // --- new() ---
 
 func compileNew(c *Comp, sym Symbol, node *ast.CallExpr) *Call {
@@ -698,14 +914,20 @@ func callParse(argv xr.Value, interpv xr.Value) xr.Value {
 // --- print(), println() ---
 
 func callPrint(args ...I) {
-	w := os.Stderr
+	fprint(os.Stderr, args)
+}
+
+func callPrintln(args ...I) {
+	fprintln(os.Stderr, args)
+}
+
+func fprint(w io.Writer, args []I) {
 	for _, arg := range args {
 		fmt.Fprint(w, arg)
 	}
 }
 
-func callPrintln(args ...I) {
-	w := os.Stderr
+func fprintln(w io.Writer, args []I) {
 	n := len(args)
 	if n > 1 {
 		for _, arg := range args[:n-1] {
@@ -730,6 +952,19 @@ func compilePrint(c *Comp, sym Symbol, node *ast.CallExpr) *Call {
 	if sym.Name == "println" {
 		call = callPrintln
 	}
+	if proc := c.process; proc != nil {
+		// write to the standard error set by Interp.SetProcess
+		w := proc.errw
+		if sym.Name == "println" {
+			call = func(args ...I) {
+				fprintln(w, args)
+			}
+		} else {
+			call = func(args ...I) {
+				fprint(w, args)
+			}
+		}
+	}
 	fun := exprLit(Lit{Type: t, Value: call}, &sym)
 	return &Call{Fun: fun, Args: args, OutTypes: zeroTypes, Const: false, Ellipsis: node.Ellipsis != token.NoPos}
 }
@@ -832,6 +1067,17 @@ func callRecover(v xr.Value) xr.Value {
 		return nilInterface
 	}
 	rec := run.Panic
+	if rec == base.SigInterrupt && run.limit.isStopped() {
+		if debug {
+			output.Debugf("recover() cannot consume a panic caused by an evaluation limit")
+		}
+		return nilInterface
+	} else if _, ok := rec.(*ExitError); ok {
+		if debug {
+			output.Debugf("recover() cannot consume a panic caused by os.Exit()")
+		}
+		return nilInterface
+	}
 	if rec == nil {
 		if debug {
 			output.Debugf("recover() consuming current panic: nil")
@@ -843,6 +1089,9 @@ func callRecover(v xr.Value) xr.Value {
 		}
 		v = xr.ValueOf(rec).Convert(base.TypeOfInterface) // keep the I type
 	}
+	if h := run.loadHooks(); h != nil && h.Recover != nil {
+		h.Recover(rec, h.position(env))
+	}
 	// consume the current panic
 	run.Panic = nil
 	run.PanicFun = nil
@@ -859,6 +1108,12 @@ func compileRecover(c *Comp, sym Symbol, node *ast.CallExpr) *Call {
 	sym.Type = t
 	fun := exprLit(Lit{Type: t, Value: callRecover}, &sym)
 	arg := exprX1(ti, argEnv)
+	for o := c; o != nil; o = o.Outer {
+		if o.Func != nil {
+			o.Func.Recover = true
+			break
+		}
+	}
 	return newCall1(fun, arg, false, ti)
 }
 
@@ -891,6 +1146,10 @@ func (c *Comp) call_builtin(call *Call) I {
 	switch fun := call.Fun.Value.(type) {
 	case UntypedLit: // complex(), real(), imag() of untyped constants
 		ret = fun
+	case uintptr: // unsafe.Alignof(), unsafe.Offsetof(), unsafe.Sizeof()
+		ret = func(*Env) uintptr {
+			return fun
+		}
 	case func(float32, float32) complex64: // complex
 		arg0fun := argfuns[0].(func(*Env) float32)
 		arg1fun := argfuns[1].(func(*Env) float32)
@@ -1031,6 +1290,9 @@ func (c *Comp) call_builtin(call *Call) I {
 		if name == "panic" {
 			ret = func(env *Env) {
 				arg := argfun(env).Interface()
+				if h := env.Run.loadHooks(); h != nil && h.Panic != nil {
+					h.Panic(arg, h.position(env))
+				}
 				panic(arg)
 			}
 		} else {
@@ -1056,7 +1318,7 @@ func (c *Comp) call_builtin(call *Call) I {
 				fun(args...)
 			}
 		}
-	case func(xr.Value): // close()
+	case func(xr.Value): // clear(), close()
 		argfun := call.MakeArgfunsX1()[0]
 		if name == "close" {
 			ret = func(env *Env) {
@@ -1173,6 +1435,8 @@ func (c *Comp) call_builtin(call *Call) I {
 				}
 			}
 		}
+	case func(...xr.Value) xr.Value: // min(), max()
+		ret = minMaxFun(call)
 	case func(xr.Type) xr.Value: // new(), make()
 		arg0 := args[0].Value.(xr.Type)
 		if name == "new" {
@@ -1252,6 +1516,8 @@ func (c *Comp) callFunction(node *ast.CallExpr, fun *Expr) (newfun *Expr, lastar
 		lastarg = exprX1(c.TypeOfInterface(), func(env *Env) xr.Value {
 			return xr.ValueOf(&Interp{Comp: c, env: env})
 		})
+	} else {
+		c.checkEnvFunc(fun)
 	}
 	return newfun, lastarg
 }
@@ -0,0 +1,30 @@
+// +build !go1.21
+
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * builtin_clear_go1_20.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package fast
+
+import (
+	r "reflect"
+)
+
+// delete all entries from a map.
+// Go < 1.21 provides no way to delete entries with NaN keys, they are kept
+func clearMap(v r.Value) {
+	for _, key := range v.MapKeys() {
+		v.SetMapIndex(key, r.Value{})
+	}
+}
@@ -0,0 +1,27 @@
+// +build go1.21
+
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * builtin_clear_go1_21.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package fast
+
+import (
+	r "reflect"
+)
+
+// delete all entries from a map, including those with NaN keys
+func clearMap(v r.Value) {
+	v.Clear()
+}
@@ -0,0 +1,70 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * callers.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package fast
+
+import (
+	"go/token"
+
+	"github.com/cosmos72/gomacro/gls"
+)
+
+// Frame describes a statement being executed by an interpreted function
+type Frame struct {
+	Pos  token.Position // position of the statement. Invalid if unknown
+	Func *Stmt          // identifies the interpreted function: it is the address of its first statement
+}
+
+// Callers returns the statements being executed by interpreted functions
+// in the current goroutine, innermost first.
+// Useful for compiled functions invoked by interpreted code
+// that need to report the position of their caller, as testing.T.Log()
+func (ir *Interp) Callers() []Frame {
+	run := ir.env.Run.IrGlobals.glsGet(gls.GoID())
+	if run == nil {
+		return nil
+	}
+	var frames []Frame
+	run.callers(func(env *Env) {
+		var frame Frame
+		if ip := env.IP; ip < len(env.DebugPos) && run.Fileset != nil {
+			frame.Pos = run.Fileset.Position(env.DebugPos[ip])
+		}
+		if len(env.Code) != 0 {
+			frame.Func = &env.Code[0]
+		}
+		frames = append(frames, frame)
+	})
+	return frames
+}
+
+// callers invokes visit on the *Env of each interpreted function
+// being executed by run, innermost first
+func (run *Run) callers(visit func(env *Env)) {
+	for env := run.CurrEnv; env != nil; {
+		visit(env)
+
+		// nested *Env share the Code of their function body:
+		// find the function body, then continue with its caller
+		for env.Caller == nil && env.Outer != nil && sameCode(env.Code, env.Outer.Code) {
+			env = env.Outer
+		}
+		env = env.Caller
+	}
+}
+
+func sameCode(a, b []Stmt) bool {
+	return len(a) != 0 && len(b) != 0 && &a[0] == &b[0]
+}
@@ -19,7 +19,9 @@ package fast
 import (
 	"errors"
 	"io"
+	"os"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/cosmos72/gomacro/base/paths"
@@ -270,17 +272,35 @@ var Commands Cmds
 
 func init() {
 	Commands.m = map[byte][]Cmd{
+		'b': []Cmd{{"break", (*Interp).cmdBreak, `break [LOCATION [if COND]]
+                   set a breakpoint at FILE:LINE, LINE or FUNCTION, or list breakpoints.
+                   break delete|disable|enable ID... modifies existing breakpoints`}},
 		'c': []Cmd{{"copyright", (*Interp).cmdCopyright, `copyright         show copyright and license`}},
 		'd': []Cmd{{"debug", (*Interp).cmdDebug, `debug EXPR        debug expression or statement interactively`}},
 		'e': []Cmd{{"env", (*Interp).cmdEnv, `env [NAME]        show available functions, variables and constants
                    in current package, or from imported package NAME`}},
+		'f': []Cmd{{"forget", (*Interp).cmdForget, `forget NAME       remove declaration NAME from current package`}},
 		'h': []Cmd{{"help", (*Interp).cmdHelp, `help              show this help`}},
 		'i': []Cmd{{"inspect", (*Interp).cmdInspect, `inspect EXPR|TYPE inspect expression or type interactively`}},
+		'l': []Cmd{{"lang", (*Interp).cmdLang, `lang [VERSION]    show or set Go language version, as go1.22
+                   go1.21 and older share loop variables among iterations`},
+			{"load", (*Interp).cmdLoad, `load FILE         load a session saved with :save`}},
 		'o': []Cmd{{"options", (*Interp).cmdOptions, `options [OPTS]    show or toggle interpreter options`}},
-		'p': []Cmd{{"package", (*Interp).cmdPackage, `package "PKGPATH" switch to package PKGPATH, importing it if possible`}},
+		'p': []Cmd{{"package", (*Interp).cmdPackage, `package "PKGPATH" switch to package PKGPATH, importing it if possible`},
+			{"profile", (*Interp).cmdProfile, `profile start|stop [FILE]
+                   start or stop profiling interpreted code. on stop,
+                   write the profile to FILE in pprof format`}},
 		'q': []Cmd{{"quit", (*Interp).cmdQuit, `quit              quit the interpreter`}},
-		'u': []Cmd{{"unload", (*Interp).cmdUnload, `unload "PKGPATH"  remove package PKGPATH from the list of known packages.
-                   later attempts to import it will trigger a recompile`}},
+		'r': []Cmd{{"reset", (*Interp).cmdReset, `reset             discard all declarations, imports and packages created with :package.
+                   already loaded packages can be imported again without loading them`}},
+		's': []Cmd{{"save", (*Interp).cmdSave, `save FILE         save current package, its imports, declarations
+                   and variable values to FILE`}},
+		't': []Cmd{{"trace", (*Interp).cmdTrace, `trace [NAME...]   log calls to functions NAME, or list traced functions.
+                   trace -json FILE|-text logs calls as JSON lines to FILE, or as text`}},
+		'u': []Cmd{{"undo", (*Interp).cmdUndo, `undo [N]          undo the declarations of the last N inputs, default 1`},
+			{"unload", (*Interp).cmdUnload, `unload "PKGPATH"  remove package PKGPATH from the list of known packages.
+                   later attempts to import it will trigger a recompile`},
+			{"untrace", (*Interp).cmdUntrace, `untrace [NAME...] stop logging calls to functions NAME, or to all functions`}},
 		'w': []Cmd{{"write", (*Interp).cmdWrite, `write [FILE]      write collected declarations and/or statements to standard output or to FILE
                    use %copt Declarations and/or %copt Statements to start collecting them`}},
 	}
@@ -318,6 +338,11 @@ func (ir *Interp) Cmd(src string) (string, base.CmdOpt) {
 	return src, opt
 }
 
+func (ir *Interp) cmdBreak(arg string, opt base.CmdOpt) (string, base.CmdOpt) {
+	ir.BreakCmd(arg)
+	return "", opt
+}
+
 func (ir *Interp) cmdDebug(arg string, opt base.CmdOpt) (string, base.CmdOpt) {
 	g := &ir.Comp.Globals
 	if len(arg) == 0 {
@@ -342,6 +367,18 @@ func (ir *Interp) cmdCopyright(arg string, opt base.CmdOpt) (string, base.CmdOpt
 	return "", opt
 }
 
+func (ir *Interp) cmdForget(name string, opt base.CmdOpt) (string, base.CmdOpt) {
+	g := &ir.Comp.Globals
+	if name = strings.TrimSpace(name); len(name) == 0 {
+		g.Fprintf(g.Stdout, "// missing NAME. usage: :forget NAME\n")
+	} else if dependents, err := ir.Forget(name); err != nil {
+		g.Warnf("%v", err)
+	} else if len(dependents) != 0 {
+		g.Warnf("still using forgotten %s: %s", name, strings.Join(dependents, " "))
+	}
+	return "", opt
+}
+
 func (ir *Interp) cmdHelp(arg string, opt base.CmdOpt) (string, base.CmdOpt) {
 	Commands.ShowHelp(&ir.Comp.Globals)
 	return "", opt
@@ -357,6 +394,28 @@ func (ir *Interp) cmdInspect(arg string, opt base.CmdOpt) (string, base.CmdOpt)
 	return "", opt
 }
 
+func (ir *Interp) cmdLang(arg string, opt base.CmdOpt) (string, base.CmdOpt) {
+	g := &ir.Comp.Globals
+	if arg = strings.TrimSpace(arg); len(arg) != 0 {
+		if err := g.SetLangVersion(arg); err != nil {
+			g.Warnf("%v", err)
+		}
+	} else {
+		g.Fprintf(g.Stdout, "// current Go language version: %s\n", base.LangVersionString(g.LangVersion))
+	}
+	return "", opt
+}
+
+func (ir *Interp) cmdLoad(filepath string, opt base.CmdOpt) (string, base.CmdOpt) {
+	g := &ir.Comp.Globals
+	if filepath = strings.TrimSpace(filepath); len(filepath) == 0 {
+		g.Fprintf(g.Stdout, "// missing FILE. usage: :load FILE\n")
+	} else if err := ir.LoadSession(filepath); err != nil {
+		g.Warnf("%v", err)
+	}
+	return "", opt
+}
+
 func (ir *Interp) cmdOptions(arg string, opt base.CmdOpt) (string, base.CmdOpt) {
 	c := ir.Comp
 	g := &c.Globals
@@ -398,11 +457,106 @@ func (ir *Interp) cmdPackage(path string, cmdopt base.CmdOpt) (string, base.CmdO
 	return "", cmdopt
 }
 
+func (ir *Interp) cmdProfile(arg string, opt base.CmdOpt) (string, base.CmdOpt) {
+	g := &ir.Comp.Globals
+	args := strings.Fields(arg)
+	var filepath string
+	if len(args) == 2 {
+		filepath = args[1]
+	}
+	switch {
+	case len(args) == 0 || len(args) > 2:
+	case args[0] == "start":
+		if err := ir.StartProfile(); err != nil {
+			g.Warnf("%v", err)
+		} else {
+			ir.env.Run.loadProfiler().file = filepath
+		}
+		return "", opt
+	case args[0] == "stop":
+		if p := ir.env.Run.loadProfiler(); p != nil && len(filepath) == 0 {
+			filepath = p.file
+		}
+		if len(filepath) == 0 {
+			g.Fprintf(g.Stdout, "// missing FILE. usage: :profile stop FILE\n")
+		} else if err := ir.stopProfile(filepath); err != nil {
+			g.Warnf("%v", err)
+		}
+		return "", opt
+	}
+	g.Fprintf(g.Stdout, "// usage: :profile start|stop [FILE]\n")
+	return "", opt
+}
+
 func (ir *Interp) cmdQuit(_ string, opt base.CmdOpt) (string, base.CmdOpt) {
 	return "", opt | base.CmdOptQuit
 }
 
-// remove package 'path' from the list of known packages
+func (ir *Interp) cmdReset(_ string, opt base.CmdOpt) (string, base.CmdOpt) {
+	ir.Reset()
+	return "", opt
+}
+
+func (ir *Interp) cmdSave(filepath string, opt base.CmdOpt) (string, base.CmdOpt) {
+	g := &ir.Comp.Globals
+	if filepath = strings.TrimSpace(filepath); len(filepath) == 0 {
+		g.Fprintf(g.Stdout, "// missing FILE. usage: :save FILE\n")
+	} else if err := ir.SaveSession(filepath); err != nil {
+		g.Warnf("%v", err)
+	}
+	return "", opt
+}
+
+func (ir *Interp) cmdTrace(arg string, opt base.CmdOpt) (string, base.CmdOpt) {
+	g := &ir.Comp.Globals
+	args := strings.Fields(arg)
+	switch {
+	case len(args) == 0:
+		g.Fprintf(g.Stdout, "// traced functions: %s\n", strings.Join(ir.Traced(), " "))
+	case args[0] == "-text":
+		ir.SetTraceOutput(nil)
+	case args[0] == "-json":
+		if len(args) != 2 {
+			g.Fprintf(g.Stdout, "// missing FILE. usage: :trace -json FILE\n")
+			break
+		}
+		f, err := os.OpenFile(args[1], os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			g.Warnf("%v", err)
+			break
+		}
+		ir.SetTraceOutput(f)
+		ir.Comp.tracer.file = f
+	default:
+		for _, name := range args {
+			if err := ir.Trace(name); err != nil {
+				g.Warnf("%v", err)
+			}
+		}
+	}
+	return "", opt
+}
+
+func (ir *Interp) cmdUndo(arg string, opt base.CmdOpt) (string, base.CmdOpt) {
+	g := &ir.Comp.Globals
+	n := 1
+	if arg = strings.TrimSpace(arg); len(arg) != 0 {
+		var err error
+		if n, err = strconv.Atoi(arg); err != nil || n <= 0 {
+			g.Fprintf(g.Stdout, "// invalid N %q. usage: :undo [N]\n", arg)
+			return "", opt
+		}
+	}
+	undone, dependents := ir.Undo(n)
+	if g.Options&base.OptShowPrompt != 0 {
+		g.Debugf("undone %d inputs", undone)
+	}
+	if len(dependents) != 0 {
+		g.Warnf("still using undone declarations: %s", strings.Join(dependents, " "))
+	}
+	return "", opt
+}
+
 func (ir *Interp) cmdUnload(path string, opt base.CmdOpt) (string, base.CmdOpt) {
 	if len(path) != 0 {
 		ir.Comp.UnloadPackage(path)
@@ -410,6 +564,20 @@ func (ir *Interp) cmdUnload(path string, opt base.CmdOpt) (string, base.CmdOpt)
 	return "", opt
 }
 
+func (ir *Interp) cmdUntrace(arg string, opt base.CmdOpt) (string, base.CmdOpt) {
+	g := &ir.Comp.Globals
+	args := strings.Fields(arg)
+	if len(args) == 0 {
+		ir.Untrace("")
+	}
+	for _, name := range args {
+		if err := ir.Untrace(name); err != nil {
+			g.Warnf("%v", err)
+		}
+	}
+	return "", opt
+}
+
 func (ir *Interp) cmdWrite(filepath string, opt base.CmdOpt) (string, base.CmdOpt) {
 	g := &ir.Comp.Globals
 	if len(filepath) == 0 {
@@ -77,24 +77,56 @@ func (run *Run) applyAsyncSignal(sig base.Signal) {
 	case base.SigDebug:
 		run.applyDebugOp(DebugOpStep)
 	default:
+		if e := run.exit; e != nil {
+			// another goroutine called os.Exit(): keep stopping
+			run.Signals.Async = sig
+			panic(e)
+		}
+		if run.limit.isStopped() {
+			// keep stopping, even if interpreted or compiled code calls recover()
+			run.Signals.Async = sig
+		}
 		panic(base.SigInterrupt)
 	}
 }
 
-func pushDefer(g *Run, deferOf *Env, panicking bool) (retg *Run, deferOf_ *Env, isDefer bool) {
-	deferOf_ = g.DeferOfFun
+// deferState is the bookkeeping saved by pushDefer() and restored by popDefer()
+type deferState struct {
+	run      *Run
+	deferOf  *Env
+	panicFun *Env
+	panic    interface{}
+	isDefer  bool
+}
+
+// pushDefer prepares to execute a function deferred by deferOf.
+// If panicking, rec is the current panic and it can be consumed by recover().
+// The previous panic, if any, is saved: a function can recover() from a nested panic
+// while an outer panic is still in progress
+func pushDefer(run *Run, deferOf *Env, panicking bool, rec interface{}) deferState {
+	s := deferState{
+		run:      run,
+		deferOf:  run.DeferOfFun,
+		panicFun: run.PanicFun,
+		panic:    run.Panic,
+		isDefer:  run.ExecFlags.IsDefer(),
+	}
 	if panicking {
-		g.PanicFun = deferOf
+		run.PanicFun = deferOf
+		run.Panic = rec
 	}
-	g.DeferOfFun = deferOf
-	g.ExecFlags.SetStartDefer(true)
-	return g, deferOf_, g.ExecFlags.IsDefer()
+	run.DeferOfFun = deferOf
+	run.ExecFlags.SetStartDefer(true)
+	return s
 }
 
-func popDefer(run *Run, deferOf *Env, isDefer bool) {
-	run.DeferOfFun = deferOf
+func popDefer(s deferState) {
+	run := s.run
+	run.DeferOfFun = s.deferOf
+	run.PanicFun = s.panicFun
+	run.Panic = s.panic
 	run.ExecFlags.SetStartDefer(false)
-	run.ExecFlags.SetDefer(isDefer)
+	run.ExecFlags.SetDefer(s.isDefer)
 }
 
 func restore(run *Run, isDefer bool, interrupt Stmt, caller *Env) {
@@ -116,6 +148,17 @@ func maybeRepanic(run *Run) bool {
 	return false
 }
 
+// runDeferredByCompiled executes the body of an interpreted function
+// invoked by compiled code as a deferred call, while panicking with value rec.
+// The interpreted function was the one that called recover() at Go level,
+// thus panic rec is no longer in progress:
+// panic again with the same value, unless funcbody invokes recover()
+func (run *Run) runDeferredByCompiled(env *Env, rec interface{}, funcbody func(*Env)) {
+	defer popDefer(pushDefer(run, env, true, rec))
+	funcbody(env)
+	maybeRepanic(run)
+}
+
 func (run *Run) interrupt() {
 	const CtrlCDebug = base.OptDebugger | base.OptCtrlCEnterDebugger
 	var sig base.Signal
@@ -151,8 +194,8 @@ func exec(all []Stmt, pos []token.Pos) func(*Env) {
 		run := env.Run
 		run.Signals.Sync = base.SigNone
 		if run.ExecFlags != 0 {
-			// code to support defer and debugger is slower... isolate it in a separate function
-			reExecWithFlags(env, all, pos, all[0], 0)
+			// code to support defer, debugger, hooks, step limit and profiler is slower... isolate it in a separate function
+			enterWithFlags(env, all, pos)
 			return
 		}
 		if sig := run.Signals.Async; sig != base.SigNone {
@@ -240,7 +283,41 @@ func exec(all []Stmt, pos []token.Pos) func(*Env) {
 // execWithFlags returns a function that will execute the given compiled code, including support for defer() and debugger
 func execWithFlags(all []Stmt, pos []token.Pos) func(*Env) {
 	return func(env *Env) {
-		env.Run.Signals.Sync = base.SigNone
+		run := env.Run
+		run.Signals.Sync = base.SigNone
+		enterWithFlags(env, all, pos)
+	}
+}
+
+// enterWithFlags executes the given compiled code from its beginning,
+// invoking Hooks.FuncEnter and Hooks.FuncExit if env is a function body
+func enterWithFlags(env *Env, all []Stmt, pos []token.Pos) {
+	run := env.Run
+	if run.ExecFlags.IsSteps() {
+		if run.steps--; run.steps < 0 {
+			run.refillSteps()
+		}
+	}
+	var h *hooks
+	if run.ExecFlags.IsHooks() && env.CallDepth > 0 {
+		h = run.loadHooks()
+	}
+	if h == nil || (h.FuncEnter == nil && h.FuncExit == nil) {
+		reExecWithFlags(env, all, pos, all[0], 0)
+		return
+	}
+	info := h.funcInfo(env, pos)
+	if h.FuncEnter != nil {
+		h.FuncEnter(info)
+	}
+	if h.FuncExit != nil {
+		panicking := true
+		defer func() {
+			h.FuncExit(info, panicking)
+		}()
+		reExecWithFlags(env, all, pos, all[0], 0)
+		panicking = false
+	} else {
 		reExecWithFlags(env, all, pos, all[0], 0)
 	}
 }
@@ -270,12 +347,13 @@ func reExecWithFlags(env *Env, all []Stmt, pos []token.Pos, stmt Stmt, ip int) {
 
 	panicking, panicking2 := true, false
 	rundefer := func(fun func()) {
+		var rec interface{}
 		if panicking || panicking2 {
 			panicking = true
 			panicking2 = false
-			run.Panic = recover()
+			rec = recover()
 		}
-		defer popDefer(pushDefer(run, funenv, panicking))
+		defer popDefer(pushDefer(run, funenv, panicking, rec))
 		panicking2 = true // detect panics inside defer
 		fun()
 		panicking2 = false
@@ -283,6 +361,41 @@ func reExecWithFlags(env *Env, all []Stmt, pos []token.Pos, stmt Stmt, ip int) {
 			panicking = maybeRepanic(run)
 		}
 	}
+	// functions without arguments are deferred directly, see Comp.Defer(),
+	// so that compiled code can recover() by itself. They are surrounded by
+	// beforeDirect and afterDirect, which perform the bookkeeping of rundefer
+	var direct deferState
+	var directPanic interface{}
+	beforeDirect := func() {
+		directPanic = nil
+		if panicking || panicking2 {
+			panicking = true
+			panicking2 = false
+			directPanic = recover()
+		}
+		direct = pushDefer(run, funenv, panicking, directPanic)
+		panicking2 = true // detect panics inside defer
+		if panicking {
+			// panic again, so that the function deferred directly can recover()
+			panic(directPanic)
+		}
+	}
+	afterDirect := func() {
+		rec := recover()
+		// interpreted code invoked recover()
+		consumed := panicking && run.PanicFun == nil
+		popDefer(direct)
+		panicking2 = false
+		if consumed || (panicking && rec == nil && directPanic != nil) {
+			// recovered by interpreted or compiled code.
+			// Compiled code recovering panic(nil) cannot be detected
+			panicking = false
+		} else if panicking || rec != nil {
+			// still panicking, or the function deferred directly panicked
+			panicking = true
+			panic(rec)
+		}
+	}
 
 	if stmt == nil || !run.Signals.IsEmpty() {
 		goto signal
@@ -304,6 +417,11 @@ again:
 													if stmt, env = stmt(env); stmt != nil {
 														if stmt, env = stmt(env); stmt != nil {
 															if stmt, env = stmt(env); stmt != nil {
+																if run.ExecFlags.IsSteps() {
+																	if run.steps -= 14; run.steps < 0 {
+																		run.refillSteps()
+																	}
+																}
 																if run.Signals.IsEmpty() {
 																	continue
 																}
@@ -325,7 +443,15 @@ again:
 			run.Signals.Sync = base.SigNone
 			fun := run.InstallDefer
 			run.InstallDefer = nil
-			defer rundefer(fun)
+			if run.DeferDirect {
+				// let fun call recover() by itself
+				run.DeferDirect = false
+				defer afterDirect()
+				defer fun()
+				defer beforeDirect()
+			} else {
+				defer rundefer(fun)
+			}
 			stmt = env.Code[env.IP]
 			if stmt == nil {
 				goto signal
@@ -355,11 +481,24 @@ again:
 		stmt, env = stmt(env)
 		stmt, env = stmt(env)
 
+		if run.ExecFlags.IsSteps() {
+			if run.steps -= 15; run.steps < 0 {
+				run.refillSteps()
+			}
+		}
 		for run.Signals.Sync == base.SigDefer {
 			run.Signals.Sync = base.SigNone
 			fun := run.InstallDefer
 			run.InstallDefer = nil
-			defer rundefer(fun)
+			if run.DeferDirect {
+				// let fun call recover() by itself
+				run.DeferDirect = false
+				defer afterDirect()
+				defer fun()
+				defer beforeDirect()
+			} else {
+				defer rundefer(fun)
+			}
 			// single step
 			stmt = env.Code[env.IP]
 			stmt, env = stmt(env)
@@ -24,6 +24,7 @@ import (
 	. "github.com/cosmos72/gomacro/ast2"
 	"github.com/cosmos72/gomacro/base"
 	"github.com/cosmos72/gomacro/base/dep"
+	"github.com/cosmos72/gomacro/base/genimport"
 	"github.com/cosmos72/gomacro/gls"
 	xr "github.com/cosmos72/gomacro/xreflect"
 )
@@ -65,10 +66,13 @@ func (c *Comp) FileComp() *Comp {
 }
 
 func NewIrGlobals() *IrGlobals {
-	return &IrGlobals{
+	g := &IrGlobals{
 		gls:     make(map[uintptr]*Run),
 		Globals: *base.NewGlobals(),
 	}
+	// Globals was copied: the importer must use the Output of the copy
+	g.Importer = genimport.DefaultImporter(&g.Output)
+	return g
 }
 
 func (g *IrGlobals) glsGet(goid uintptr) *Run {
@@ -94,6 +98,10 @@ func (tg *Run) glsStore() {
 	g.lock.Lock()
 	g.gls[goid] = tg
 	g.lock.Unlock()
+	if tg.limit.isStopped() {
+		// evaluation was stopped before this goroutine started
+		tg.Signals.Async = base.SigInterrupt
+	}
 }
 
 func (tg *Run) glsDel() {
@@ -105,11 +113,18 @@ func (tg *Run) glsDel() {
 }
 
 func (run *Run) new(goid uintptr) *Run {
-	return &Run{
+	ret := &Run{
 		IrGlobals: run.IrGlobals,
 		goid:      goid,
+		limit:     run.limit,
 		// Interrupt, Signal, PoolSize and Pool are zero-initialized, fine with that
 	}
+	if run.loadHooks() != nil {
+		ret.applyDebugOp(DebugOpContinue)
+	} else {
+		ret.updateSteps()
+	}
+	return ret
 }
 
 // common part between NewEnv() and newEnv4Func()
@@ -236,6 +251,33 @@ func (env *Env) MarkUsedByClosure() {
 	}
 }
 
+// copyLoopVars gives a new copy of loop variables to the next iteration
+// of a "for" statement, as specified by go1.22 per-iteration loop variables.
+// vals contains the indexes of loop variables stored in env.Vals[]
+func (env *Env) copyLoopVars(vals []int) *Env {
+	if env.UsedByClosure || env.IntAddressTaken {
+		// some closure or pointer refers to env: leave it alone, and continue on a copy
+		inner := NewEnv(env.Outer, len(env.Vals), len(env.Ints))
+		copy(inner.Vals, env.Vals)
+		copy(inner.Ints, env.Ints)
+		inner.IP = env.IP
+		inner.Code = env.Code
+		inner.DebugPos = env.DebugPos
+		inner.DebugComp = env.DebugComp
+		env = inner
+	}
+	// variables in env.Vals[] are stored in reflect.Value places:
+	// someone may have taken their address, give them new places
+	for _, index := range vals {
+		if v := env.Vals[index]; v.IsValid() {
+			place := xr.NewR(v.Type()).Elem()
+			place.Set(v)
+			env.Vals[index] = place
+		}
+	}
+	return env
+}
+
 // FreeEnv tells the interpreter that given nested *Env is no longer needed.
 func (env *Env) FreeEnv() {
 	run := env.Run
@@ -336,22 +378,33 @@ func (c *Comp) Compile(in Ast) *Expr {
 
 	decls := sorter.All()
 
+	var exprs []*Expr
 	switch n := len(decls); n {
 	case 0:
 		return nil
 	case 1:
-		return c.compileDecl(decls[0])
+		e := c.compileDecl(decls[0])
+		if e != nil {
+			exprs = []*Expr{e}
+		}
 	default:
-		exprs := make([]*Expr, 0, n)
+		exprs = make([]*Expr, 0, n)
 		for _, decl := range decls {
 			e := c.compileDecl(decl)
 			if e != nil {
 				exprs = append(exprs, e)
 			}
 		}
+	}
+	exprs = append(exprs, c.recompileDependents()...)
+	switch len(exprs) {
+	case 0:
+		return nil
+	case 1:
+		return exprs[0]
+	default:
 		return exprList(exprs, c.CompileOptions())
 	}
-	return nil
 }
 
 // compile code. support out-of-order declarations too
@@ -377,14 +430,18 @@ func (c *Comp) compileDecl(decl *dep.Decl) *Expr {
 			top.setIota(extra.Iota)
 
 			c.DeclConsts(extra.Spec(), nil, nil)
+			c.recordDecl(decl)
 			return c.Code.AsExpr()
 		case dep.Var:
 			c.DeclVars(extra.Spec())
+			c.recordDecl(decl)
 			return c.Code.AsExpr()
 		}
 	}
 	if node := decl.Node; node != nil {
-		return c.compileNode(node, decl.Kind)
+		e := c.compileNode(node, decl.Kind)
+		c.recordDecl(decl)
+		return e
 	}
 	// may happen for second and later variables in VarMulti,
 	// which CANNOT be declared individually
@@ -438,7 +495,7 @@ func (c *Comp) compileNode(node ast.Node, kind dep.Kind) *Expr {
 		// special case of statement
 		return c.Expr(node.X, nil)
 	case ast.Stmt:
-		c.Stmt(node)
+		c.stmtList([]ast.Stmt{node})
 	case *ast.File:
 		// not c.File(node): unnecessary and risks an infinite recursion
 		for _, decl := range node.Decls {
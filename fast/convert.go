@@ -48,6 +48,8 @@ func (c *Comp) convert(e *Expr, t xr.Type, nodeOpt ast.Expr) *Expr {
 	} else if e.Type == nil && reflect.IsNillableKind(t.Kind()) {
 		e.Type = t
 		e.Value = xr.Zero(t).Interface()
+	} else if isUnsafeConversion(e.Type, t) {
+		return c.convertUnsafe(e, t, nodeOpt)
 	} else if e.Type != nil && e.Type.ConvertibleTo(t) {
 	} else {
 		c.Errorf("cannot convert %v to %v: %v", e.Type, t, nodeOpt)
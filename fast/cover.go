@@ -0,0 +1,296 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * cover.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package fast
+
+import (
+	"bufio"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+
+	"github.com/cosmos72/gomacro/go/etoken"
+)
+
+// coverage counts the executions of each basic block
+// of the code compiled while it is enabled
+type coverage struct {
+	mode    string // "set", "count" or "atomic"
+	blocks  []*coverBlock
+	returns map[*ast.ReturnStmt]token.Pos // position of "return" before macroexpansion
+}
+
+// coverBlock is a sequence of statements executed together,
+// as the blocks of "go test -cover"
+type coverBlock struct {
+	pos, end token.Pos
+	stmts    int
+	count    uint32
+}
+
+// StartCoverage instruments the code compiled from now on to collect coverage,
+// in the same modes as "go test -covermode": "set", "count" or "atomic".
+// Code compiled before calling StartCoverage is not instrumented
+func (ir *Interp) StartCoverage(mode string) error {
+	switch mode {
+	case "set", "count", "atomic":
+	default:
+		return fmt.Errorf("invalid coverage mode %q, expecting one of: set count atomic", mode)
+	}
+	g := ir.Comp.CompGlobals
+	if cov := g.coverage; cov != nil {
+		if cov.mode != mode {
+			return fmt.Errorf("coverage already started in mode %q", cov.mode)
+		}
+		return nil
+	}
+	g.coverage = &coverage{mode: mode}
+	return nil
+}
+
+// WriteCoverage writes the coverage collected since StartCoverage to out,
+// in the cover profile format read by "go tool cover"
+func (ir *Interp) WriteCoverage(out io.Writer) error {
+	g := ir.Comp.CompGlobals
+	cov := g.coverage
+	if cov == nil {
+		return fmt.Errorf("coverage not started")
+	}
+	w := bufio.NewWriter(out)
+	fmt.Fprintf(w, "mode: %s\n", cov.mode)
+	for _, line := range cov.lines(g.Fileset) {
+		fmt.Fprintln(w, line)
+	}
+	return w.Flush()
+}
+
+// WriteCoverageFile is a convenience wrapper around WriteCoverage
+func (ir *Interp) WriteCoverageFile(filepath string) error {
+	f, err := os.Create(filepath)
+	if err != nil {
+		return err
+	}
+	err = ir.WriteCoverage(f)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	return err
+}
+
+// lines returns the cover profile lines, sorted by file and position
+func (cov *coverage) lines(fileset *etoken.FileSet) []string {
+	type line struct {
+		start, end token.Position
+		stmts      int
+		count      uint32
+	}
+	var list []line
+	for _, block := range cov.blocks {
+		start, end := fileset.Position(block.pos), fileset.Position(block.end)
+		if !start.IsValid() || !end.IsValid() {
+			continue
+		}
+		list = append(list, line{start, end, block.stmts, atomic.LoadUint32(&block.count)})
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i].start, list[j].start
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		// files are parsed in chunks: offsets are relative to each chunk
+		return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+	})
+	ret := make([]string, len(list))
+	for i, l := range list {
+		ret[i] = fmt.Sprintf("%s:%d.%d,%d.%d %d %d", coverFilename(l.start.Filename),
+			l.start.Line, l.start.Column, l.end.Line, l.end.Column, l.stmts, l.count)
+	}
+	return ret
+}
+
+// coverFilename returns the absolute path of existing files,
+// because "go tool cover" interprets relative ones as package paths
+func coverFilename(filename string) string {
+	if _, err := os.Stat(filename); err == nil {
+		if abs, err := filepath.Abs(filename); err == nil {
+			return abs
+		}
+	}
+	return filename
+}
+
+// stmtList compiles a list of statements.
+// If coverage is enabled, also splits them into basic blocks and counts their executions
+func (c *Comp) stmtList(list []ast.Stmt) {
+	cov := c.coverage
+	var block *coverBlock
+	for _, node := range list {
+		if cov != nil {
+			block = c.coverStmt(block, node)
+		}
+		c.Stmt(node)
+	}
+}
+
+// coverStmt adds node to the current basic block, or starts a new one.
+// Returns the basic block for the next statement, or nil if node ends the current one
+func (c *Comp) coverStmt(block *coverBlock, node ast.Stmt) *coverBlock {
+	inner := node
+	labeled := false
+	for l, ok := inner.(*ast.LabeledStmt); ok; l, ok = inner.(*ast.LabeledStmt) {
+		// labels can be jumped to: they start a new block
+		inner, labeled = l.Stmt, true
+	}
+	switch inner.(type) {
+	case *ast.BlockStmt:
+		// compiled as a separate list
+		return nil
+	case *ast.EmptyStmt:
+		return block
+	}
+	cov := c.coverage
+	start := cov.start(inner)
+	if block == nil || labeled {
+		block = &coverBlock{pos: node.Pos()}
+		if !labeled {
+			block.pos = start
+		}
+		cov.blocks = append(cov.blocks, block)
+		c.coverNext = block
+	}
+	block.stmts++
+	// control flow statements end the block.
+	// for compound statements, the block ends before their body
+	// statements containing function literals also end the block,
+	// before the literal: its body has its own blocks
+	var end token.Pos
+	switch inner := inner.(type) {
+	case *ast.IfStmt:
+		end = coverHeaderEnd(inner.Body, inner.Init, inner.Cond)
+	case *ast.ForStmt:
+		end = coverHeaderEnd(inner.Body, inner.Init, inner.Cond, inner.Post)
+	case *ast.RangeStmt:
+		end = coverHeaderEnd(inner.Body, inner.X)
+	case *ast.SwitchStmt:
+		end = coverHeaderEnd(inner.Body, inner.Init, inner.Tag)
+	case *ast.TypeSwitchStmt:
+		end = coverHeaderEnd(inner.Body, inner.Init, inner.Assign)
+	case *ast.SelectStmt:
+		end = coverHeaderEnd(inner.Body)
+	case *ast.ReturnStmt:
+		if inner.Return != token.NoPos || len(inner.Results) != 0 {
+			end = coverFuncLit(inner, inner.End())
+		}
+	case *ast.BranchStmt:
+		end = inner.End()
+	default:
+		if end = coverFuncLit(inner, token.NoPos); end == token.NoPos {
+			block.end = inner.End()
+			return block
+		}
+	}
+	if end != token.NoPos {
+		block.end = end
+	}
+	return nil
+}
+
+// setReturnPos remembers the position of "return" in ret,
+// because macroexpansion discards it
+func (cov *coverage) setReturnPos(ret *ast.ReturnStmt, pos token.Pos) {
+	if cov.returns == nil {
+		cov.returns = make(map[*ast.ReturnStmt]token.Pos)
+	}
+	cov.returns[ret] = pos
+}
+
+// start returns the position of a statement,
+// including the position of "return" discarded by macroexpansion
+func (cov *coverage) start(node ast.Stmt) token.Pos {
+	ret, ok := node.(*ast.ReturnStmt)
+	if !ok || ret.Return != token.NoPos {
+		return node.Pos()
+	} else if pos, ok := cov.returns[ret]; ok {
+		delete(cov.returns, ret)
+		return pos
+	} else if len(ret.Results) != 0 {
+		// created by a macro
+		return ret.Results[0].Pos()
+	}
+	return token.NoPos
+}
+
+// coverHeaderEnd returns the end of the header of a compound statement,
+// i.e. the position of the first function literal in its header if any,
+// otherwise the end of its last header node or, if none, the position of its body
+func coverHeaderEnd(body *ast.BlockStmt, header ...ast.Node) token.Pos {
+	end := token.NoPos
+	for _, node := range header {
+		if node == nil {
+			continue
+		} else if pos := coverFuncLit(node, token.NoPos); pos != token.NoPos {
+			return pos
+		} else if node.End() > end {
+			end = node.End()
+		}
+	}
+	if end == token.NoPos && body != nil {
+		end = body.Lbrace
+	}
+	return end
+}
+
+// coverFuncLit returns the position of the first function literal inside node,
+// or def if there is none
+func coverFuncLit(node ast.Node, def token.Pos) token.Pos {
+	pos := def
+	found := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		if lit, ok := n.(*ast.FuncLit); ok && !found {
+			pos, found = lit.Pos(), true
+		}
+		return !found
+	})
+	return pos
+}
+
+// coverCounter returns the statement that counts the executions of block
+func (c *Comp) coverCounter(block *coverBlock) Stmt {
+	switch c.coverage.mode {
+	case "set":
+		return func(env *Env) (Stmt, *Env) {
+			block.count = 1
+			env.IP++
+			return env.Code[env.IP], env
+		}
+	case "count":
+		return func(env *Env) (Stmt, *Env) {
+			block.count++
+			env.IP++
+			return env.Code[env.IP], env
+		}
+	default:
+		return func(env *Env) (Stmt, *Env) {
+			atomic.AddUint32(&block.count, 1)
+			env.IP++
+			return env.Code[env.IP], env
+		}
+	}
+}
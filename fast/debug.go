@@ -85,7 +85,22 @@ func singleStep(env *Env) (Stmt, *Env) {
 	if run.Signals.Debug == base.SigNone {
 		return stmt, env // resume normal execution
 	}
+	if env.IP == len(env.Code)-1 {
+		// reached the spinInterrupt appended by Code.Exec(), i.e. the end of code:
+		// nothing to debug, and spinInterrupt would spin forever while run.Signals.Debug is set
+		if sig := run.Signals.Async; sig != base.SigNone {
+			run.applyAsyncSignal(sig)
+		}
+		run.Signals.Sync = base.SigReturn
+		return stmt, env
+	}
 
+	if h := run.loadHooks(); h != nil && h.Stmt != nil {
+		// skip statements created by the compiler, they have no position
+		if pos := h.position(env); pos.IsValid() {
+			h.Stmt(pos)
+		}
+	}
 	if env.CallDepth < run.DebugDepth {
 		if run.Options&base.OptDebugDebugger != 0 {
 			run.Debugf("single-stepping: stmt = %p, env = %p, IP = %v, env.CallDepth = %d, g.DebugDepth = %d", stmt, env, env.IP, env.CallDepth, run.DebugDepth)
@@ -99,6 +114,15 @@ func singleStep(env *Env) (Stmt, *Env) {
 				run.Signals.Debug = sig
 			}
 		}
+	} else if c := env.DebugComp; c != nil && run.noBreak == 0 && run.breakpoint(c, env) != nil {
+		if run.Options&base.OptDebugDebugger != 0 {
+			run.Debugf("breakpoint: stmt = %p, env = %p, IP = %v, env.CallDepth = %d", stmt, env, env.IP, env.CallDepth)
+		}
+		ir := Interp{c, env}
+		sig := ir.debug(true)
+		if sig != base.SigNone {
+			run.Signals.Debug = sig
+		}
 	}
 
 	// single step
@@ -115,18 +139,25 @@ func (ir *Interp) debug(breakpoint bool) base.Signal {
 		ir.Comp.Warnf("// breakpoint: no debugger set with Interp.SetDebugger(), resuming execution (warned only once)")
 		run.Debugger = stubDebugger{}
 	}
-	var op DebugOp
-	if breakpoint {
-		op = run.Debugger.Breakpoint(ir, ir.env)
-	} else {
-		op = run.Debugger.At(ir, ir.env)
-	}
+	op := run.callDebugger(ir, breakpoint)
 	if run.Options&base.OptDebugDebugger != 0 {
 		run.Debugf("Debugger returned op = %v", op)
 	}
 	return run.applyDebugOp(op)
 }
 
+// invoke the debugger. code evaluated by the debugger does not stop at breakpoints
+func (run *Run) callDebugger(ir *Interp, breakpoint bool) DebugOp {
+	run.noBreak++
+	defer func() {
+		run.noBreak--
+	}()
+	if breakpoint {
+		return run.Debugger.Breakpoint(ir, ir.env)
+	}
+	return run.Debugger.At(ir, ir.env)
+}
+
 func (run *Run) applyDebugOp(op DebugOp) base.Signal {
 	if op.Panic != nil {
 		if run.Options&base.OptDebugDebugger != 0 {
@@ -138,6 +169,11 @@ func (run *Run) applyDebugOp(op DebugOp) base.Signal {
 	var sig base.Signal
 	if op.Depth > 0 {
 		sig = base.SigDebug
+	} else if (run.noBreak == 0 && run.Breakpoints.active()) || run.hasStmtHook() {
+		// single-step to check for breakpoints or to invoke Hooks.Stmt,
+		// without invoking the debugger at each statement
+		sig = base.SigDebug
+		op.Depth = 0
 	} else {
 		sig = base.SigNone
 		op.Depth = 0
@@ -151,6 +187,8 @@ func (run *Run) applyDebugOp(op DebugOp) base.Signal {
 	}
 	run.DebugDepth = op.Depth
 	run.ExecFlags.SetDebug(sig != base.SigNone)
+	run.ExecFlags.SetHooks(run.loadHooks() != nil)
+	run.updateSteps()
 	run.Signals.Debug = sig
 	return sig
 }
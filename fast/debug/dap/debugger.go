@@ -0,0 +1,196 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * debugger.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package dap
+
+import (
+	"errors"
+	"go/token"
+	"path/filepath"
+
+	"github.com/cosmos72/gomacro/base"
+	"github.com/cosmos72/gomacro/fast"
+)
+
+var errNotStopped = errors.New("execution is not stopped")
+
+// debugger implements fast.Debugger by forwarding to the DAP client
+type debugger struct {
+	s *Server
+}
+
+func (d debugger) Breakpoint(ir *fast.Interp, env *fast.Env) fast.DebugOp {
+	return d.s.stop(ir, env, "breakpoint")
+}
+
+func (d debugger) At(ir *fast.Interp, env *fast.Env) fast.DebugOp {
+	return d.s.stop(ir, env, "step")
+}
+
+func killOp() fast.DebugOp {
+	var panick interface{} = base.SigInterrupt
+	return fast.DebugOp{Depth: 0, Panic: &panick}
+}
+
+// a stack frame: the function body Env, and the nested Env where execution is
+type frame struct {
+	fun *fast.Env // nil for top-level code
+	at  *fast.Env
+}
+
+// the state of interpreted code stopped in the debugger
+type stopped struct {
+	env    *fast.Env
+	frames []frame
+	refs   []varRef // variablesReference N is refs[N-1]
+	resume chan fast.DebugOp
+	calls  chan func()
+}
+
+// stop is invoked by the interpreter when execution reaches a breakpoint or completes a step.
+// it notifies the client, then executes its requests until the client resumes execution
+func (s *Server) stop(ir *fast.Interp, env *fast.Env, reason string) fast.DebugOp {
+	s.lock.Lock()
+	killed, noDebug := s.killed, s.noDebug
+	if s.pausing {
+		reason = "pause"
+		s.pausing = false
+	}
+	s.lock.Unlock()
+	if killed {
+		return killOp()
+	} else if noDebug {
+		return fast.DebugOpContinue
+	}
+	if ip := env.IP; ip >= len(env.DebugPos) || env.DebugPos[ip] == token.NoPos {
+		// skip synthetic statements
+		return fast.DebugOp{Depth: env.Run.DebugDepth}
+	}
+	st := &stopped{
+		env:    env,
+		frames: stackFrames(env),
+		resume: make(chan fast.DebugOp),
+		calls:  make(chan func()),
+	}
+	s.lock.Lock()
+	s.stopped = st
+	s.lock.Unlock()
+
+	s.event("stopped", stoppedBody{Reason: reason, ThreadID: mainThreadID, AllThreadsStopped: true})
+	for {
+		select {
+		case call := <-st.calls:
+			call()
+		case op := <-st.resume:
+			s.lock.Lock()
+			s.stopped = nil
+			s.lock.Unlock()
+			return op
+		}
+	}
+}
+
+// execute call in the goroutine stopped in the debugger, and wait for it to complete.
+// interpreted code must be executed by the goroutine that created the interpreter
+func (s *Server) whenStopped(call func(st *stopped)) error {
+	s.lock.Lock()
+	st := s.stopped
+	s.lock.Unlock()
+	if st == nil {
+		return errNotStopped
+	}
+	done := make(chan struct{})
+	st.calls <- func() {
+		defer close(done)
+		call(st)
+	}
+	<-done
+	return nil
+}
+
+// return the function calls active in env, innermost first, followed by top-level code
+func stackFrames(env *fast.Env) []frame {
+	var frames []frame
+	at := env
+	for env != nil {
+		if env.Caller != nil {
+			// function body
+			frames = append(frames, frame{fun: env, at: at})
+			env = env.Caller
+			at = env
+		} else {
+			// nested env
+			env = env.Outer
+		}
+	}
+	return append(frames, frame{at: at})
+}
+
+func (st *stopped) frame(id int) (*frame, error) {
+	if id <= 0 || id > len(st.frames) {
+		return nil, errors.New("invalid frameId")
+	}
+	return &st.frames[id-1], nil
+}
+
+func (s *Server) stackTrace(req *request) (interface{}, error) {
+	var args stackTraceArguments
+	if err := unmarshal(req, &args); err != nil {
+		return nil, err
+	}
+	var body stackTraceBody
+	err := s.whenStopped(func(st *stopped) {
+		n := len(st.frames)
+		start, end := args.StartFrame, n
+		if start < 0 || start > n {
+			start = n
+		}
+		if args.Levels > 0 && start+args.Levels < n {
+			end = start + args.Levels
+		}
+		body.TotalFrames = n
+		body.StackFrames = make([]stackFrame, 0, end-start)
+		for i := start; i < end; i++ {
+			body.StackFrames = append(body.StackFrames, s.stackFrame(i+1, &st.frames[i]))
+		}
+	})
+	return body, err
+}
+
+func (s *Server) stackFrame(id int, f *frame) stackFrame {
+	name := "main"
+	if f.fun != nil {
+		name = "???"
+		if c := f.fun.DebugComp; c != nil && c.FuncMaker != nil {
+			name = c.FuncMaker.Name
+		}
+	}
+	ret := stackFrame{ID: id, Name: name}
+	env := f.at
+	fileset := s.interp.Comp.Fileset
+	if ip := env.IP; ip < len(env.DebugPos) && fileset != nil {
+		pos := fileset.Position(env.DebugPos[ip])
+		if pos.IsValid() {
+			src := &source{Name: filepath.Base(pos.Filename)}
+			if filepath.IsAbs(pos.Filename) {
+				src.Path = pos.Filename
+			}
+			s.lock.Lock()
+			ret.Source, ret.Line, ret.Column = src, pos.Line-1+s.lineBase, pos.Column-1+s.columnBase
+			s.lock.Unlock()
+		}
+	}
+	return ret
+}
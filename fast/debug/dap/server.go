@@ -0,0 +1,580 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * server.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+// Package dap implements a Debug Adapter Protocol server for the gomacro fast interpreter,
+// allowing DAP clients as VS Code or Neovim to debug interpreted code
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/cosmos72/gomacro/base"
+	"github.com/cosmos72/gomacro/fast"
+)
+
+// the only thread reported to clients: interpreted goroutines are not debugged
+const mainThreadID = 1
+
+// Server is a Debug Adapter Protocol server.
+// It reads requests from a DAP client and executes them on a fast.Interp
+type Server struct {
+	// Launch is invoked to execute the program specified in a "launch" request.
+	// The default is to evaluate it with Interp.EvalFile()
+	Launch func(program string) error
+
+	interp *fast.Interp
+	in     *bufio.Reader
+	out    io.Writer
+	jobs   chan func() // interpreted code to execute in the goroutine running Serve()
+
+	wlock sync.Mutex // protects out and seq
+	seq   int
+
+	lock       sync.Mutex // protects the fields below
+	stopped    *stopped   // non-nil while interpreted code is stopped in the debugger
+	running    bool       // true while interpreted code is executing
+	killed     bool       // true if the client asked to terminate execution
+	pausing    bool       // true if the client asked to pause execution
+	noDebug    bool       // true if the client launched the program without debugging
+	lineBase   int        // 1 if client lines start at 1, 0 if they start at 0
+	columnBase int        // 1 if client columns start at 1, 0 if they start at 0
+
+	// accessed only by the goroutine executing Serve()
+	program     *launchArguments // program to launch after "configurationDone"
+	configured  bool
+	sourceBreak map[string][]int // ids of breakpoints set by "setBreakpoints" for each source
+	funcBreak   []int            // ids of breakpoints set by "setFunctionBreakpoints"
+}
+
+// NewServer creates a DAP server that reads requests from in and writes responses and events to out.
+// It installs itself as the debugger of ir, and redirects interpreter output to DAP "output" events
+func NewServer(ir *fast.Interp, in io.Reader, out io.Writer) *Server {
+	s := &Server{
+		interp:      ir,
+		in:          bufio.NewReader(in),
+		out:         out,
+		jobs:        make(chan func(), 1),
+		lineBase:    1,
+		columnBase:  1,
+		sourceBreak: make(map[string][]int),
+	}
+	s.Launch = func(program string) error {
+		_, err := ir.EvalFile(program)
+		return err
+	}
+	g := &ir.Comp.Globals
+	// OptDebugger must be set before compiling the code to debug
+	g.Options |= base.OptDebugger | base.OptCtrlCEnterDebugger
+	g.Options &^= base.OptShowPrompt | base.OptShowEval | base.OptShowEvalType
+	g.Stdout = outputWriter{s, "stdout"}
+	g.Stderr = outputWriter{s, "stderr"}
+	ir.SetDebugger(debugger{s})
+	return s
+}
+
+// ServeStdio runs a DAP server on standard input and standard output.
+// While it runs, os.Stdout and os.Stderr are redirected to DAP "output" events,
+// in order to keep the output of compiled functions as fmt.Println() separate from the protocol
+func ServeStdio(ir *fast.Interp, launch func(program string) error) error {
+	in, out := os.Stdin, os.Stdout
+	s := NewServer(ir, in, out)
+	if launch != nil {
+		s.Launch = launch
+	}
+	restore, err := s.redirectStdio()
+	if err != nil {
+		return err
+	}
+	defer restore()
+	return s.Serve()
+}
+
+// ListenAndServe accepts connections on TCP address addr and runs a DAP server on each of them, one at a time.
+// The interpreter, and thus its declarations, are preserved across connections
+func ListenAndServe(ir *fast.Interp, addr string, launch func(program string) error) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	g := &ir.Comp.Globals
+	stderr := g.Stderr
+	for {
+		g.Fprintf(stderr, "// DAP server listening on %v\n", listener.Addr())
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		s := NewServer(ir, conn, conn)
+		if launch != nil {
+			s.Launch = launch
+		}
+		err = s.Serve()
+		conn.Close()
+		if err != nil {
+			g.Fprintf(stderr, "// DAP connection closed: %v\n", err)
+		}
+	}
+}
+
+// errDisconnect is returned by request handlers to terminate Serve()
+var errDisconnect = errors.New("disconnect")
+
+// pending is returned by request handlers that will send the response later
+type pending struct{}
+
+type handler func(s *Server, req *request) (interface{}, error)
+
+var handlers = map[string]handler{
+	"initialize":              (*Server).initialize,
+	"launch":                  (*Server).launch,
+	"attach":                  (*Server).attach,
+	"configurationDone":       (*Server).configurationDone,
+	"setBreakpoints":          (*Server).setBreakpoints,
+	"setFunctionBreakpoints":  (*Server).setFunctionBreakpoints,
+	"setExceptionBreakpoints": (*Server).setExceptionBreakpoints,
+	"threads":                 (*Server).threads,
+	"stackTrace":              (*Server).stackTrace,
+	"scopes":                  (*Server).scopes,
+	"variables":               (*Server).variables,
+	"evaluate":                (*Server).evaluate,
+	"continue":                (*Server).cont,
+	"next":                    (*Server).next,
+	"stepIn":                  (*Server).stepIn,
+	"stepOut":                 (*Server).stepOut,
+	"pause":                   (*Server).pause,
+	"terminate":               (*Server).terminate,
+	"disconnect":              (*Server).disconnect,
+}
+
+// Serve reads and executes requests until the client disconnects or closes the connection.
+// It must be invoked by the goroutine that created the interpreter,
+// because it uses such goroutine to execute interpreted code
+func (s *Server) Serve() error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.readRequests()
+	}()
+	for {
+		select {
+		case job := <-s.jobs:
+			job()
+		case err := <-errc:
+			return err
+		}
+	}
+}
+
+// read and execute requests
+func (s *Server) readRequests() error {
+	for {
+		buf, err := readMessage(s.in)
+		if err == io.EOF {
+			s.kill()
+			return nil
+		} else if err != nil {
+			s.kill()
+			return err
+		}
+		var req request
+		if err = json.Unmarshal(buf, &req); err != nil {
+			s.kill()
+			return fmt.Errorf("invalid DAP message: %v", err)
+		}
+		if req.Type != "request" {
+			continue
+		}
+		h := handlers[req.Command]
+		if h == nil {
+			s.respond(&req, nil, fmt.Errorf("unsupported request %q", req.Command))
+			continue
+		}
+		body, err := h(s, &req)
+		if _, ok := body.(pending); ok {
+			continue
+		}
+		if err == errDisconnect {
+			s.respond(&req, body, nil)
+			return nil
+		}
+		s.respond(&req, body, err)
+	}
+}
+
+// send a response to req
+func (s *Server) respond(req *request, body interface{}, err error) {
+	resp := response{
+		Type:       "response",
+		RequestSeq: req.Seq,
+		Success:    err == nil,
+		Command:    req.Command,
+		Body:       body,
+	}
+	if err != nil {
+		resp.Message = err.Error()
+		resp.Body = nil
+	}
+	s.write(func(seq int) interface{} {
+		resp.Seq = seq
+		return &resp
+	})
+}
+
+// send an event
+func (s *Server) event(name string, body interface{}) {
+	s.write(func(seq int) interface{} {
+		return &event{Seq: seq, Type: "event", Event: name, Body: body}
+	})
+}
+
+func (s *Server) write(msg func(seq int) interface{}) {
+	s.wlock.Lock()
+	defer s.wlock.Unlock()
+	s.seq++
+	writeMessage(s.out, msg(s.seq))
+}
+
+// outputWriter converts writes to DAP "output" events
+type outputWriter struct {
+	s        *Server
+	category string
+}
+
+func (w outputWriter) Write(p []byte) (int, error) {
+	w.s.event("output", outputBody{Category: w.category, Output: string(p)})
+	return len(p), nil
+}
+
+// redirect os.Stdout and os.Stderr to DAP "output" events.
+// return a function that restores them
+func (s *Server) redirectStdio() (restore func(), err error) {
+	var wg sync.WaitGroup
+	redirect := func(file **os.File, category string) (func(), error) {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return nil, err
+		}
+		saved := *file
+		*file = w
+		wg.Add(1)
+		go func() {
+			io.Copy(outputWriter{s, category}, r)
+			r.Close()
+			wg.Done()
+		}()
+		return func() {
+			*file = saved
+			w.Close()
+		}, nil
+	}
+	restoreStdout, err := redirect(&os.Stdout, "stdout")
+	if err != nil {
+		return nil, err
+	}
+	restoreStderr, err := redirect(&os.Stderr, "stderr")
+	if err != nil {
+		restoreStdout()
+		return nil, err
+	}
+	return func() {
+		restoreStderr()
+		restoreStdout()
+		wg.Wait()
+	}, nil
+}
+
+func unmarshal(req *request, args interface{}) error {
+	if len(req.Arguments) == 0 {
+		return nil
+	}
+	return json.Unmarshal(req.Arguments, args)
+}
+
+// ------------------------ session requests -------------------------
+
+func (s *Server) initialize(req *request) (interface{}, error) {
+	var args initializeArguments
+	if err := unmarshal(req, &args); err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	if args.LinesStartAt1 != nil && !*args.LinesStartAt1 {
+		s.lineBase = 0
+	}
+	if args.ColumnsStartAt1 != nil && !*args.ColumnsStartAt1 {
+		s.columnBase = 0
+	}
+	s.lock.Unlock()
+
+	s.respond(req, capabilities{
+		SupportsConfigurationDoneRequest: true,
+		SupportsFunctionBreakpoints:      true,
+		SupportsConditionalBreakpoints:   true,
+		SupportsEvaluateForHovers:        true,
+		SupportsTerminateRequest:         true,
+	}, nil)
+	// client can now send breakpoints, followed by "configurationDone"
+	s.event("initialized", nil)
+	return pending{}, nil
+}
+
+func (s *Server) launch(req *request) (interface{}, error) {
+	var args launchArguments
+	if err := unmarshal(req, &args); err != nil {
+		return nil, err
+	}
+	if len(args.Program) == 0 {
+		return nil, errors.New("launch: missing program")
+	}
+	// breakpoints sent by clients contain absolute paths
+	if abs, err := filepath.Abs(args.Program); err == nil {
+		args.Program = abs
+	}
+	if _, err := os.Stat(args.Program); err != nil {
+		return nil, err
+	}
+	s.program = &args
+	if s.configured {
+		s.startProgram()
+	}
+	return nil, nil
+}
+
+// attach to the interpreter without launching a program.
+// code can then be executed with "evaluate" requests
+func (s *Server) attach(req *request) (interface{}, error) {
+	return nil, nil
+}
+
+func (s *Server) configurationDone(req *request) (interface{}, error) {
+	s.configured = true
+	if s.program != nil {
+		// send the response before "stopped" or "exited" events
+		s.respond(req, nil, nil)
+		s.startProgram()
+		return pending{}, nil
+	}
+	return nil, nil
+}
+
+func (s *Server) terminate(req *request) (interface{}, error) {
+	s.kill()
+	return nil, nil
+}
+
+func (s *Server) disconnect(req *request) (interface{}, error) {
+	s.kill()
+	return nil, errDisconnect
+}
+
+// execute the launched program
+func (s *Server) startProgram() {
+	args := s.program
+	s.program = nil
+	s.lock.Lock()
+	s.noDebug = args.NoDebug
+	s.lock.Unlock()
+	if args.StopOnEntry && !args.NoDebug {
+		s.pause(nil)
+	}
+	exitCode := 0
+	s.start(func() {
+		g := &s.interp.Comp.Globals
+		// a panic terminates the program, as in compiled Go
+		saveOptions := g.Options
+		g.Options &^= base.OptTrapPanic
+		err := s.Launch(args.Program)
+		g.Options = saveOptions
+
+		if err != nil {
+			s.lock.Lock()
+			killed := s.killed
+			s.lock.Unlock()
+			if !killed {
+				g.Fprintf(g.Stderr, "%v\n", err)
+			}
+			exitCode = 1
+		}
+	}, func() {
+		s.event("exited", exitedBody{ExitCode: exitCode})
+		s.event("terminated", nil)
+	})
+}
+
+// execute run in the goroutine running Serve(), then invoke done.
+// return false if it is already executing interpreted code
+func (s *Server) start(run func(), done func()) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.running {
+		return false
+	}
+	s.running = true
+	s.killed = false
+	s.jobs <- func() {
+		run()
+		s.lock.Lock()
+		s.running = false
+		s.killed = false
+		s.pausing = false
+		s.lock.Unlock()
+		done()
+	}
+	return true
+}
+
+// terminate execution of interpreted code
+func (s *Server) kill() {
+	s.lock.Lock()
+	st, running := s.stopped, s.running
+	if running {
+		s.killed = true
+	}
+	s.lock.Unlock()
+	if st != nil {
+		st.resume <- killOp()
+	} else if running {
+		// enter the debugger at next statement. it will see s.killed
+		s.interp.Interrupt(os.Interrupt)
+	}
+}
+
+// ------------------------ breakpoint requests ----------------------
+
+func (s *Server) setBreakpoints(req *request) (interface{}, error) {
+	var args setBreakpointsArguments
+	if err := unmarshal(req, &args); err != nil {
+		return nil, err
+	}
+	path := args.Source.Path
+	if len(path) == 0 {
+		path = args.Source.Name
+	}
+	ir := s.interp
+	for _, id := range s.sourceBreak[path] {
+		ir.DeleteBreakpoint(id)
+	}
+	var ids []int
+	s.lock.Lock()
+	lineBase := s.lineBase
+	s.lock.Unlock()
+	list := make([]breakpoint, len(args.Breakpoints))
+	for i, sbp := range args.Breakpoints {
+		line := sbp.Line + 1 - lineBase
+		bp, err := ir.AddBreakpoint(path+":"+strconv.Itoa(line), sbp.Condition)
+		list[i] = makeBreakpoint(bp, err)
+		list[i].Source = &args.Source
+		list[i].Line = sbp.Line
+		if err == nil {
+			ids = append(ids, bp.ID)
+		}
+	}
+	s.sourceBreak[path] = ids
+	return breakpointsBody{list}, nil
+}
+
+func (s *Server) setFunctionBreakpoints(req *request) (interface{}, error) {
+	var args setFunctionBreakpointsArguments
+	if err := unmarshal(req, &args); err != nil {
+		return nil, err
+	}
+	ir := s.interp
+	for _, id := range s.funcBreak {
+		ir.DeleteBreakpoint(id)
+	}
+	s.funcBreak = nil
+	list := make([]breakpoint, len(args.Breakpoints))
+	for i, fbp := range args.Breakpoints {
+		bp, err := ir.AddBreakpoint(fbp.Name, fbp.Condition)
+		list[i] = makeBreakpoint(bp, err)
+		if err == nil {
+			s.funcBreak = append(s.funcBreak, bp.ID)
+		}
+	}
+	return breakpointsBody{list}, nil
+}
+
+func makeBreakpoint(bp fast.Breakpoint, err error) breakpoint {
+	if err != nil {
+		return breakpoint{Verified: false, Message: err.Error()}
+	}
+	return breakpoint{ID: bp.ID, Verified: true}
+}
+
+// exceptions are not supported: accept and ignore the request
+func (s *Server) setExceptionBreakpoints(req *request) (interface{}, error) {
+	return breakpointsBody{[]breakpoint{}}, nil
+}
+
+// ------------------------ execution requests -----------------------
+
+func (s *Server) threads(req *request) (interface{}, error) {
+	return threadsBody{[]thread{{ID: mainThreadID, Name: "main"}}}, nil
+}
+
+func (s *Server) cont(req *request) (interface{}, error) {
+	return s.resume(req, continueBody{AllThreadsContinued: true}, func(env *fast.Env) fast.DebugOp {
+		return fast.DebugOpContinue
+	})
+}
+
+func (s *Server) next(req *request) (interface{}, error) {
+	return s.resume(req, nil, func(env *fast.Env) fast.DebugOp {
+		return fast.DebugOp{Depth: env.CallDepth + 1}
+	})
+}
+
+func (s *Server) stepIn(req *request) (interface{}, error) {
+	return s.resume(req, nil, func(env *fast.Env) fast.DebugOp {
+		return fast.DebugOpStep
+	})
+}
+
+func (s *Server) stepOut(req *request) (interface{}, error) {
+	return s.resume(req, nil, func(env *fast.Env) fast.DebugOp {
+		return fast.DebugOp{Depth: env.CallDepth}
+	})
+}
+
+func (s *Server) pause(req *request) (interface{}, error) {
+	s.lock.Lock()
+	s.pausing = true
+	s.lock.Unlock()
+	s.interp.Interrupt(os.Interrupt)
+	return nil, nil
+}
+
+// resume execution of stopped code.
+// the response is sent before resuming, because clients expect it before any further "stopped" event
+func (s *Server) resume(req *request, body interface{}, op func(env *fast.Env) fast.DebugOp) (interface{}, error) {
+	s.lock.Lock()
+	st := s.stopped
+	s.lock.Unlock()
+	if st == nil {
+		return nil, errNotStopped
+	}
+	s.respond(req, body, nil)
+	st.resume <- op(st.env)
+	return pending{}, nil
+}
@@ -0,0 +1,325 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * variables.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package dap
+
+import (
+	"errors"
+	"fmt"
+	r "reflect"
+	"sort"
+	"strings"
+
+	"github.com/cosmos72/gomacro/fast"
+	xr "github.com/cosmos72/gomacro/xreflect"
+)
+
+// show at most this number of elements of arrays, slices and maps
+const maxChildren = 100
+
+// a variablesReference: either a scope, i.e. a list of Env, or a value with children
+type varRef struct {
+	envs  []*fast.Env
+	value r.Value
+}
+
+// return a new variablesReference for ref
+func (st *stopped) ref(ref varRef) int {
+	st.refs = append(st.refs, ref)
+	return len(st.refs)
+}
+
+func (s *Server) scopes(req *request) (interface{}, error) {
+	var args scopesArguments
+	if err := unmarshal(req, &args); err != nil {
+		return nil, err
+	}
+	var body scopesBody
+	var err error
+	err2 := s.whenStopped(func(st *stopped) {
+		var f *frame
+		if f, err = st.frame(args.FrameID); err != nil {
+			return
+		}
+		// local scopes: from innermost Env up to the function body, or up to file Env for top-level code
+		var locals []*fast.Env
+		for env := f.at; env != nil && env != env.FileEnv; env = env.Outer {
+			locals = append(locals, env)
+			if env == f.fun {
+				break
+			}
+		}
+		body.Scopes = []scope{{Name: "Locals", VariablesReference: st.ref(varRef{envs: locals})}}
+		if file := f.at.FileEnv; file != nil {
+			body.Scopes = append(body.Scopes, scope{
+				Name:               "Globals",
+				VariablesReference: st.ref(varRef{envs: []*fast.Env{file}}),
+				Expensive:          true,
+			})
+		}
+	})
+	if err == nil {
+		err = err2
+	}
+	return body, err
+}
+
+func (s *Server) variables(req *request) (interface{}, error) {
+	var args variablesArguments
+	if err := unmarshal(req, &args); err != nil {
+		return nil, err
+	}
+	body := variablesBody{[]variable{}}
+	var err error
+	err2 := s.whenStopped(func(st *stopped) {
+		id := args.VariablesReference
+		if id <= 0 || id > len(st.refs) {
+			err = errors.New("invalid variablesReference")
+			return
+		}
+		ref := st.refs[id-1]
+		if ref.envs != nil {
+			body.Variables = st.envVariables(ref.envs)
+		} else {
+			body.Variables = st.children(ref.value)
+		}
+	})
+	if err == nil {
+		err = err2
+	}
+	return body, err
+}
+
+// return the variables declared in envs. inner variables shadow outer ones
+func (st *stopped) envVariables(envs []*fast.Env) []variable {
+	vars := []variable{}
+	seen := make(map[string]bool)
+	for _, env := range envs {
+		c := env.DebugComp
+		if c == nil {
+			continue
+		}
+		binds := make([]*fast.Bind, 0, len(c.Binds))
+		for _, bind := range c.Binds {
+			if !seen[bind.Name] && bind.Desc.Class() != fast.ConstBind {
+				seen[bind.Name] = true
+				binds = append(binds, bind)
+			}
+		}
+		sort.Slice(binds, func(i, j int) bool {
+			return binds[i].Name < binds[j].Name
+		})
+		for _, bind := range binds {
+			if bind.Name == "_" {
+				continue
+			}
+			value := bind.RuntimeValue(c.CompGlobals, env)
+			vars = append(vars, st.variable(bind.Name, value.ReflectValue(), bind.Type))
+		}
+	}
+	return vars
+}
+
+// return a variable describing value. if value has children, allocate a variablesReference for them
+func (st *stopped) variable(name string, value r.Value, t xr.Type) variable {
+	v := variable{Name: name, Value: formatValue(value)}
+	if t != nil {
+		v.Type = t.String()
+	} else if value.IsValid() {
+		v.Type = value.Type().String()
+	}
+	if hasChildren(value) {
+		v.VariablesReference = st.ref(varRef{value: value})
+	}
+	return v
+}
+
+func formatValue(value r.Value) string {
+	if !value.IsValid() {
+		return "nil"
+	}
+	switch value.Kind() {
+	case r.String:
+		return fmt.Sprintf("%q", value.String())
+	case r.Ptr, r.Map, r.Slice, r.Chan, r.Func, r.Interface:
+		if value.IsNil() {
+			return "nil"
+		}
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+func hasChildren(value r.Value) bool {
+	switch value.Kind() {
+	case r.Ptr, r.Interface:
+		return !value.IsNil()
+	case r.Map, r.Slice:
+		return value.Len() != 0
+	case r.Array, r.Struct:
+		return true
+	}
+	return false
+}
+
+// return the children of a pointer, interface, array, slice, map or struct
+func (st *stopped) children(value r.Value) []variable {
+	var vars []variable
+	switch value.Kind() {
+	case r.Ptr, r.Interface:
+		if !value.IsNil() {
+			vars = append(vars, st.variable("*", value.Elem(), nil))
+		}
+	case r.Array, r.Slice:
+		n := value.Len()
+		if n > maxChildren {
+			n = maxChildren
+		}
+		for i := 0; i < n; i++ {
+			vars = append(vars, st.variable(fmt.Sprintf("[%d]", i), value.Index(i), nil))
+		}
+	case r.Map:
+		keys := value.MapKeys()
+		names := make([]string, len(keys))
+		for i, key := range keys {
+			names[i] = formatValue(key)
+		}
+		sort.Sort(byName{names, keys})
+		if len(keys) > maxChildren {
+			keys = keys[:maxChildren]
+		}
+		for i, key := range keys {
+			vars = append(vars, st.variable("["+names[i]+"]", value.MapIndex(key), nil))
+		}
+	case r.Struct:
+		t := value.Type()
+		for i, n := 0, t.NumField(); i < n; i++ {
+			vars = append(vars, st.variable(t.Field(i).Name, value.Field(i), nil))
+		}
+	}
+	if vars == nil {
+		vars = []variable{}
+	}
+	return vars
+}
+
+type byName struct {
+	names []string
+	keys  []r.Value
+}
+
+func (b byName) Len() int           { return len(b.names) }
+func (b byName) Less(i, j int) bool { return b.names[i] < b.names[j] }
+func (b byName) Swap(i, j int) {
+	b.names[i], b.names[j] = b.names[j], b.names[i]
+	b.keys[i], b.keys[j] = b.keys[j], b.keys[i]
+}
+
+// ------------------------ evaluate ---------------------------------
+
+func (s *Server) evaluate(req *request) (interface{}, error) {
+	var args evaluateArguments
+	if err := unmarshal(req, &args); err != nil {
+		return nil, err
+	}
+	var body evaluateBody
+	var err error
+	err2 := s.whenStopped(func(st *stopped) {
+		env := st.env
+		if args.FrameID != 0 {
+			var f *frame
+			if f, err = st.frame(args.FrameID); err != nil {
+				return
+			}
+			env = f.at
+		}
+		ir := env.DebugInterp()
+		if ir == nil {
+			err = errors.New("cannot evaluate in this frame: it was not compiled for debugging")
+			return
+		}
+		// preserve existing Binds, compiled Code and IP of the code being debugged
+		ir = fast.NewInnerInterp(ir, "dap", "dap")
+		var vals []xr.Value
+		var types []xr.Type
+		vals, types, err = eval(ir, args.Expression)
+		if err == nil {
+			body = st.evaluateBody(vals, types)
+		}
+	})
+	if err2 == errNotStopped {
+		// not stopped: execute the expression in the goroutine running Serve(), and respond when it completes.
+		// breakpoints and stepping are honored, as for launched programs
+		var result evaluateBody
+		var resultErr error
+		if s.start(func() {
+			var vals []xr.Value
+			var types []xr.Type
+			vals, types, resultErr = eval(s.interp, args.Expression)
+			if resultErr == nil {
+				result = evaluateBodyNoRefs(vals, types)
+			}
+		}, func() {
+			s.respond(req, result, resultErr)
+		}) {
+			return pending{}, nil
+		}
+		err2 = errors.New("cannot evaluate while program is running")
+	}
+	if err == nil {
+		err = err2
+	}
+	return body, err
+}
+
+// evaluate src, converting panics to errors
+func eval(ir *fast.Interp, src string) (vals []xr.Value, types []xr.Type, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			switch rec := rec.(type) {
+			case error:
+				err = rec
+			default:
+				err = errors.New(fmt.Sprint(rec))
+			}
+		}
+	}()
+	vals, types = ir.Eval(src)
+	return vals, types, nil
+}
+
+func (st *stopped) evaluateBody(vals []xr.Value, types []xr.Type) evaluateBody {
+	if len(vals) == 1 {
+		var t xr.Type
+		if len(types) != 0 {
+			t = types[0]
+		}
+		v := st.variable("", vals[0].ReflectValue(), t)
+		return evaluateBody{Result: v.Value, Type: v.Type, VariablesReference: v.VariablesReference}
+	}
+	return evaluateBodyNoRefs(vals, types)
+}
+
+// variablesReference are valid only while stopped: do not create them
+func evaluateBodyNoRefs(vals []xr.Value, types []xr.Type) evaluateBody {
+	results := make([]string, len(vals))
+	for i, val := range vals {
+		results[i] = formatValue(val.ReflectValue())
+	}
+	body := evaluateBody{Result: strings.Join(results, ", ")}
+	if len(vals) == 1 && len(types) != 0 && types[0] != nil {
+		body.Type = types[0].String()
+	}
+	return body
+}
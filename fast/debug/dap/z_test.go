@@ -0,0 +1,211 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * z_test.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cosmos72/gomacro/fast"
+)
+
+const testProgram = `package main
+
+func fact(n int) int {
+	if n <= 1 {
+		return 1
+	}
+	return n * fact(n-1)
+}
+
+var result = fact(4)
+`
+
+// a scripted DAP client
+type client struct {
+	t    *testing.T
+	in   *bufio.Reader
+	out  io.Writer
+	seq  int
+	msgs chan map[string]interface{}
+}
+
+func newClient(t *testing.T) *client {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	go func() {
+		// Serve() must run in the goroutine that created the interpreter
+		s := NewServer(fast.New(), serverIn, serverOut)
+		s.Serve()
+		serverOut.Close()
+	}()
+	c := &client{t: t, in: bufio.NewReader(clientIn), out: clientOut, msgs: make(chan map[string]interface{}, 100)}
+	go func() {
+		defer close(c.msgs)
+		for {
+			buf, err := readMessage(c.in)
+			if err != nil {
+				return
+			}
+			var msg map[string]interface{}
+			if json.Unmarshal(buf, &msg) == nil {
+				c.msgs <- msg
+			}
+		}
+	}()
+	return c
+}
+
+func (c *client) send(command string, args interface{}) int {
+	c.seq++
+	writeMessage(c.out, map[string]interface{}{
+		"seq": c.seq, "type": "request", "command": command, "arguments": args,
+	})
+	return c.seq
+}
+
+// wait for the response to request seq, or for event name. skip other messages
+func (c *client) wait(seq int, name string) map[string]interface{} {
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case msg, ok := <-c.msgs:
+			if !ok {
+				c.t.Fatalf("connection closed while waiting for %d %q", seq, name)
+			}
+			c.t.Logf("%v", msg)
+			if msg["type"] == "response" && msg["request_seq"] == float64(seq) {
+				if msg["success"] != true {
+					c.t.Fatalf("request %v failed: %v", msg["command"], msg["message"])
+				}
+				return msg
+			} else if msg["type"] == "event" && msg["event"] == name {
+				return msg
+			}
+		case <-timeout:
+			c.t.Fatalf("timeout while waiting for %d %q", seq, name)
+		}
+	}
+}
+
+func (c *client) request(command string, args interface{}) map[string]interface{} {
+	resp := c.wait(c.send(command, args), "")
+	body, _ := resp["body"].(map[string]interface{})
+	return body
+}
+
+func TestLaunchBreakpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomacro_dap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	program := filepath.Join(dir, "fact.gomacro")
+	if err = ioutil.WriteFile(program, []byte(testProgram), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newClient(t)
+	c.request("initialize", map[string]interface{}{"adapterID": "gomacro"})
+	c.wait(0, "initialized")
+	body := c.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]interface{}{"path": program},
+		"breakpoints": []interface{}{map[string]interface{}{"line": 7, "condition": "n == 3"}},
+	})
+	if bps := body["breakpoints"].([]interface{}); bps[0].(map[string]interface{})["verified"] != true {
+		t.Errorf("breakpoint not verified: %v", bps)
+	}
+	c.request("launch", map[string]interface{}{"program": program})
+	c.request("configurationDone", nil)
+
+	stopped := c.wait(0, "stopped")
+	if reason := stopped["body"].(map[string]interface{})["reason"]; reason != "breakpoint" {
+		t.Errorf("stopped with reason %v, expecting breakpoint", reason)
+	}
+	frames := c.request("stackTrace", map[string]interface{}{"threadId": mainThreadID})["stackFrames"].([]interface{})
+	if len(frames) != 3 {
+		t.Fatalf("expecting 3 stack frames, found %v", frames)
+	}
+	top := frames[0].(map[string]interface{})
+	if top["name"] != "fact" || top["line"] != float64(7) {
+		t.Errorf("unexpected top stack frame %v", top)
+	}
+	scopes := c.request("scopes", map[string]interface{}{"frameId": top["id"]})["scopes"].([]interface{})
+	ref := scopes[0].(map[string]interface{})["variablesReference"]
+	vars := c.request("variables", map[string]interface{}{"variablesReference": ref})["variables"].([]interface{})
+	if v := vars[0].(map[string]interface{}); v["name"] != "n" || v["value"] != "3" || v["type"] != "int" {
+		t.Errorf("unexpected local variables %v", vars)
+	}
+	// evaluate in the caller frame
+	caller := frames[1].(map[string]interface{})
+	result := c.request("evaluate", map[string]interface{}{"expression": "n * 10", "frameId": caller["id"]})
+	if result["result"] != "40" {
+		t.Errorf("evaluate returned %v, expecting 40", result)
+	}
+
+	c.request("continue", map[string]interface{}{"threadId": mainThreadID})
+	exited := c.wait(0, "exited")
+	if code := exited["body"].(map[string]interface{})["exitCode"]; code != float64(0) {
+		t.Errorf("program exited with code %v", code)
+	}
+	c.wait(0, "terminated")
+
+	// program declarations are preserved after it terminates
+	result = c.request("evaluate", map[string]interface{}{"expression": "result", "context": "repl"})
+	if result["result"] != "24" {
+		t.Errorf("evaluate returned %v, expecting 24", result)
+	}
+	c.request("disconnect", nil)
+}
+
+func TestAttachStep(t *testing.T) {
+	c := newClient(t)
+	c.request("initialize", nil)
+	c.request("attach", nil)
+	c.request("configurationDone", nil)
+	c.request("evaluate", map[string]interface{}{"expression": "func twice(x int) int {\n\ty := x * 2\n\treturn y\n}"})
+	c.request("setFunctionBreakpoints", map[string]interface{}{
+		"breakpoints": []interface{}{map[string]interface{}{"name": "twice"}},
+	})
+
+	seq := c.send("evaluate", map[string]interface{}{"expression": "twice(21)", "context": "repl"})
+	c.wait(0, "stopped")
+	c.request("next", map[string]interface{}{"threadId": mainThreadID})
+	if reason := c.wait(0, "stopped")["body"].(map[string]interface{})["reason"]; reason != "step" {
+		t.Errorf("stopped with reason %v, expecting step", reason)
+	}
+	frames := c.request("stackTrace", map[string]interface{}{"threadId": mainThreadID})["stackFrames"].([]interface{})
+	if line := frames[0].(map[string]interface{})["line"]; line != float64(2) {
+		t.Errorf("stopped at line %v, expecting 2", line)
+	}
+	c.request("continue", map[string]interface{}{"threadId": mainThreadID})
+	resp := c.wait(seq, "")
+	if result := resp["body"].(map[string]interface{})["result"]; result != "42" {
+		t.Errorf("evaluate returned %v, expecting 42", result)
+	}
+
+	// terminate while stopped
+	seq = c.send("evaluate", map[string]interface{}{"expression": "twice(1)", "context": "repl"})
+	c.wait(0, "stopped")
+	c.request("terminate", nil)
+	c.send("disconnect", nil)
+}
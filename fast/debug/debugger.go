@@ -28,6 +28,10 @@ func (d *Debugger) Help() {
 	g := d.globals
 	g.Fprintf(g.Stdout, "%s", `// debugger commands:
 backtrace       show call stack
+break [LOCATION [if COND]]
+                set a breakpoint at FILE:LINE, LINE or FUNCTION, or list breakpoints
+break delete|disable|enable ID...
+                modify existing breakpoints
 env [NAME]      show available functions, variables and constants
                 in current scope, or from imported package NAME
 ?               show this help
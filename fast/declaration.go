@@ -336,8 +336,15 @@ func (c *CompBinds) NewBind(o *base.Output, name string, class BindClass, t xr.T
 		// unnamed function result, or unnamed switch/range/... expression
 	} else if bind := c.Binds[name]; bind != nil {
 		o.Warnf("redefined identifier: %v", name)
+		if c.session != nil {
+			// the bind index may be reused below, losing the current value
+			c.session.saveValue(bind)
+		}
 		oldclass := bind.Desc.Class()
-		if (oldclass == IntBind) == (class == IntBind) {
+		if oldclass == FuncBind && class == FuncBind && !bind.Type.IdenticalTo(t) {
+			// function redefined with a different signature: do not reuse the bind index,
+			// code compiled for the previous signature still reads it
+		} else if (oldclass == IntBind) == (class == IntBind) {
 			// both are IntBind, or neither is.
 			if bind.Type.Kind() == r.Complex128 || t.Kind() != r.Complex128 {
 				// the new bind occupies fewer slots than the old one,
@@ -183,7 +183,7 @@ func (c *Comp) Expr1OrType(expr ast.Expr) (e *Expr, t xr.Type) {
 			for o := c; o != nil; o = o.Outer {
 				bind, okb := o.Binds[name]
 				var okt bool
-				if okb && (GENERICS_V1_CXX() || GENERICS_V2_CTI()) {
+				if okb && GENERICS_ENABLED() {
 					_, okt = bind.Value.(*GenericType) // generic types are stored in Comp.Bind[]
 					okb = !okt
 				}
@@ -194,11 +194,15 @@ func (c *Comp) Expr1OrType(expr ast.Expr) (e *Expr, t xr.Type) {
 				}
 			}
 		case *ast.IndexExpr:
-			if GENERICS_V1_CXX() || GENERICS_V2_CTI() {
+			if GENERICS_ENABLED() {
 				if lit, ok := n.Index.(*ast.CompositeLit); ok && lit.Type == nil {
 					// foo#[a, b...] can be a generic function or a generic type
 					node = n.X
 					continue
+				} else if GENERICS_V3_GO() {
+					// foo[a] can be a generic function, a generic type or an index expression
+					node = n.X
+					continue
 				}
 			}
 		}
@@ -219,7 +223,7 @@ func (c *Comp) Expr1OrType(expr ast.Expr) (e *Expr, t xr.Type) {
 // IndexExpr compiles a read operation on obj[idx]
 // or a generic function name#[T1, T2...]
 func (c *Comp) IndexExpr(node *ast.IndexExpr) *Expr {
-	if GENERICS_V1_CXX() || GENERICS_V2_CTI() {
+	if GENERICS_ENABLED() {
 		if e := c.GenericFunc(node); e != nil {
 			return e
 		}
@@ -230,7 +234,7 @@ func (c *Comp) IndexExpr(node *ast.IndexExpr) *Expr {
 // IndexExpr1 compiles a single-valued read operation on obj[idx]
 // or a generic function name#[T1, T2...]
 func (c *Comp) IndexExpr1(node *ast.IndexExpr) *Expr {
-	if GENERICS_V1_CXX() || GENERICS_V2_CTI() {
+	if GENERICS_ENABLED() {
 		if e := c.GenericFunc(node); e != nil {
 			return e
 		}
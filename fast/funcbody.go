@@ -0,0 +1,195 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * funcbody.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package fast
+
+import (
+	"fmt"
+	"go/ast"
+	"io/ioutil"
+	r "reflect"
+	"strings"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/cosmos72/gomacro/base"
+	"github.com/cosmos72/gomacro/base/dep"
+	"github.com/cosmos72/gomacro/go/types"
+	xr "github.com/cosmos72/gomacro/xreflect"
+)
+
+// funcCell contains the current body of a top-level function or method.
+// Redefining them with the same signature replaces the body in the cell,
+// thus function values created before the redefinition - stored in variables,
+// passed to compiled code or executed by goroutines - also execute the new body
+type funcCell struct {
+	body    unsafe.Pointer // *funcBody. accessed atomically
+	t       xr.Type
+	binds   []BindDescriptor // parameters and results
+	recover bool
+	env     *Env // where the function value was created
+}
+
+// funcBody is a compiled function body, plus the size of its Env
+type funcBody struct {
+	exec      func(*Env) // nil if body is empty
+	nbind     int
+	nintbind  int
+	debugComp *Comp
+}
+
+type methodKey struct {
+	recv types.Type // xr.Type is not comparable
+	name string
+}
+
+func (cell *funcCell) load() *funcBody {
+	return (*funcBody)(atomic.LoadPointer(&cell.body))
+}
+
+func (cell *funcCell) store(body *funcBody) {
+	atomic.StorePointer(&cell.body, unsafe.Pointer(body))
+}
+
+// exec executes the current body of the function.
+// The Env was created for the body compiled with the function,
+// grow it if the current body needs more slots
+func (cell *funcCell) exec(env *Env) {
+	body := cell.load()
+	if n := body.nbind; n > len(env.Vals) {
+		env.Vals = append(env.Vals, make([]xr.Value, n-len(env.Vals))...)
+	}
+	if n := body.nintbind; n > len(env.Ints) {
+		env.Ints = append(env.Ints, make([]uint64, n-len(env.Ints))...)
+	}
+	if body.debugComp != nil {
+		env.DebugComp = body.debugComp
+	}
+	if body.exec != nil {
+		body.exec(env)
+	}
+}
+
+// define is invoked at runtime when the function declaration is executed:
+// it sets the body of the function, and returns true if the function value
+// must be created in env
+func (cell *funcCell) define(env *Env, body *funcBody) bool {
+	cell.store(body)
+	if cell.env == env {
+		return false
+	}
+	cell.env = env
+	return true
+}
+
+// funcCellCreate is as funcCreate, for top-level functions and methods.
+// If old is compatible with the new function, i.e. has the same signature
+// and stores parameters and results in the same Env slots, it is reused
+// and function values created from it will execute the new body
+func (c *Comp) funcCellCreate(old *funcCell, t xr.Type, info *FuncInfo, resultfuns []I, funcbody func(*Env)) (*funcCell, *funcBody, func(*Env) xr.Value) {
+	body := &funcBody{exec: funcbody, nbind: c.BindNum, nintbind: c.IntBindNum}
+	if c.Globals.Options&base.OptDebugger != 0 {
+		body.debugComp = c
+	}
+	cell := &funcCell{t: t, recover: info.Recover}
+	for _, bind := range info.Param {
+		cell.binds = append(cell.binds, bind.Desc)
+	}
+	for _, bind := range info.Result {
+		cell.binds = append(cell.binds, bind.Desc)
+	}
+	if old.compatible(cell) {
+		cell = old
+	}
+	return cell, body, c.funcCreate(t, info, resultfuns, cell.exec)
+}
+
+func (old *funcCell) compatible(cell *funcCell) bool {
+	if old == nil || old.recover != cell.recover || !old.t.IdenticalTo(cell.t) || len(old.binds) != len(cell.binds) {
+		return false
+	}
+	for i, desc := range old.binds {
+		if desc != cell.binds[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// methodCell returns the funcCell of a method, or nil if not found
+func (g *CompGlobals) methodCell(trecv xr.Type, name string) *funcCell {
+	return g.methodCells[methodKey{trecv.GoType(), name}]
+}
+
+func (g *CompGlobals) setMethodCell(trecv xr.Type, name string, cell *funcCell) {
+	if g.methodCells == nil {
+		g.methodCells = make(map[methodKey]*funcCell)
+	}
+	g.methodCells[methodKey{trecv.GoType(), name}] = cell
+}
+
+func isNilFunc(fun r.Value) bool {
+	return !fun.IsValid() || fun.IsNil()
+}
+
+// recompileDependents recompiles the recorded functions and methods
+// that use functions redefined with a different signature by current input:
+// their compiled code still calls the previous definitions.
+// Dependents that no longer compile keep calling the previous definitions
+func (c *Comp) recompileDependents() []*Expr {
+	s := c.session
+	if s == nil || len(s.changedFuncs) == 0 {
+		return nil
+	}
+	names := s.changedFuncs
+	s.changedFuncs = nil
+	var exprs []*Expr
+	for _, key := range c.dependents(names) {
+		if s.pendingDecl(key) {
+			// declared by current input, already compiled against the new definitions
+			continue
+		}
+		node, ok := s.decls[key].(*ast.FuncDecl)
+		if !ok {
+			// variables keep their value
+			continue
+		}
+		e, err := c.recompileDependent(node)
+		if err != nil {
+			c.Warnf("cannot recompile %s, it still uses the previous %s: %v", key, strings.Join(names, ", "), err)
+			continue
+		}
+		c.Warnf("recompiled %s, it uses %s redefined with a different signature", key, strings.Join(names, ", "))
+		if e != nil {
+			exprs = append(exprs, e)
+		}
+	}
+	return exprs
+}
+
+func (c *Comp) recompileDependent(node *ast.FuncDecl) (e *Expr, err error) {
+	// discard the warnings "redefined identifier" and similar:
+	// recompileDependents() explains what happened
+	stderr := c.Output.Stderr
+	c.Output.Stderr = ioutil.Discard
+	defer func() {
+		c.Output.Stderr = stderr
+		if rec := recover(); rec != nil {
+			c.Code.Clear()
+			err = fmt.Errorf("%v", rec)
+		}
+	}()
+	return c.compileNode(node, dep.Unknown), nil
+}
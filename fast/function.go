@@ -33,6 +33,7 @@ type funcMaker struct {
 	Result    []*Bind
 	resultfun []I
 	funcbody  func(*Env)
+	recover   bool // true if function body directly calls recover()
 }
 
 // DeclFunc compiles a function, macro or method declaration
@@ -50,7 +51,7 @@ func (c *Comp) DeclFunc(funcdecl *ast.FuncDecl) {
 			c.methodDecl(funcdecl)
 			return
 		default:
-			if GENERICS_V1_CXX() || GENERICS_V2_CTI() {
+			if GENERICS_ENABLED() {
 				c.DeclGenericFunc(funcdecl)
 				return
 			}
@@ -81,15 +82,16 @@ func (c *Comp) DeclFunc(funcdecl *ast.FuncDecl) {
 	} else {
 		funcbind = c.NewBind(funcname, FuncBind, t)
 	}
+	c.registerFunc(funcdecl, funcname)
 	cf := NewComp(c, nil)
 	info, resultfuns := cf.funcBinds(funcname, functype, t, paramnames, resultnames)
 	cf.Func = info
 
 	if body := funcdecl.Body; body != nil {
+		info.Labels = funcLabels(body)
+		cf.declLabels(body.List)
 		// in Go, function arguments/results and function body are in the same scope
-		for _, node := range body.List {
-			cf.Stmt(node)
-		}
+		cf.stmtList(body.List)
 	}
 
 	funcindex := funcbind.Desc.Index()
@@ -118,27 +120,35 @@ func (c *Comp) DeclFunc(funcdecl *ast.FuncDecl) {
 	} else {
 		// a function declaration is a statement:
 		// executing it creates the function in the runtime environment
-		f := cf.funcCreate(t, info, resultfuns, funcbody)
+		var oldcell *funcCell
+		if oldbind != nil {
+			oldcell = oldbind.cell
+		}
+		cell, body, f := cf.funcCellCreate(oldcell, t, info, resultfuns, funcbody)
+		funcbind.cell = cell
 
 		stmt = func(env *Env) (Stmt, *Env) {
-			fun := f(env)
-			// Debugf("setting env.Binds[%d] = %v <%v>", funcindex, fun.Interface(), fun.Type())
-			env.Vals[funcindex] = fun
+			if cell.define(env, body) {
+				fun := f(env)
+				// Debugf("setting env.Binds[%d] = %v <%v>", funcindex, fun.Interface(), fun.Type())
+				env.Vals[funcindex] = fun
+			}
 			env.IP++
 			return env.Code[env.IP], env
 		}
 	}
 	c.Append(stmt, funcdecl.Pos())
 	panicking = false
+	if !ismacro && oldbind != nil && oldbind.Desc.Class() == FuncBind && !oldbind.Type.IdenticalTo(t) && c.session != nil {
+		// existing callers must be recompiled, see Comp.recompileDependents()
+		c.session.changedFuncs = append(c.session.changedFuncs, funcname)
+	}
 }
 
 func (c *Comp) methodAdd(funcdecl *ast.FuncDecl, t xr.Type) (methodindex int, methods *[]r.Value) {
 	name := funcdecl.Name.Name
-	trecv := t.In(0)
-	if trecv.Kind() == r.Ptr && !trecv.Named() {
-		// receiver is an unnamed pointer type. add the method to its element type
-		trecv = trecv.Elem()
-	}
+	// if receiver is an unnamed pointer type, add the method to its element type
+	trecv := methodRecv(t)
 
 	panicking := true
 	defer func() {
@@ -166,29 +176,13 @@ func (c *Comp) methodDecl(funcdecl *ast.FuncDecl) {
 			n, funcdecl.Recv, funcdecl.Name)
 		return
 	}
-	recvdecl := funcdecl.Recv.List[0]
-
-	functype := funcdecl.Type
-	t, paramnames, resultnames := c.TypeFunctionOrMethod(recvdecl, functype)
-
-	// gtype := t.GoType().Underlying().(*types.Signature)
-	// c.Debugf("declaring method (%v).%s%s %s\n\treflect.Type: <%v>", gtype.Recv().Type(), funcdecl.Name.Name, gtype.Params(), gtype.Results(), t.ReflectType())
-
-	// declare the method name and type before compiling its body: allows recursive methods
-	methodindex, methods := c.methodAdd(funcdecl, t)
-
-	cf := NewComp(c, nil)
-	info, resultfuns := cf.funcBinds(funcdecl.Name.Name, functype, t, paramnames, resultnames)
-	cf.Func = info
-
-	body := funcdecl.Body
-	if body != nil && len(body.List) != 0 {
-		// in Go, function arguments/results and function body are in the same scope
-		cf.List(body.List)
+	if GENERICS_ENABLED() {
+		if typ, params := c.genericMethodRecv(funcdecl.Recv.List[0]); typ != nil {
+			c.DeclGenericMethod(funcdecl, typ, params)
+			return
+		}
 	}
-	// do NOT keep a reference to compile environment!
-	funcbody := cf.Code.Exec()
-	f := cf.funcCreate(t, info, resultfuns, funcbody)
+	t, cell, body, f, methodindex, methods := c.methodCreate(funcdecl)
 
 	// a method declaration is a statement:
 	// executing it sets the method value in the receiver type
@@ -201,14 +195,20 @@ func (c *Comp) methodDecl(funcdecl *ast.FuncDecl) {
 		}
 		methodname := funcdecl.Name
 		stmt = func(env *Env) (Stmt, *Env) {
-			(*methods)[methodindex] = f(env).ReflectValue()
+			// redefining a method may clear its value in the receiver type
+			if cell.define(env, body) || isNilFunc((*methods)[methodindex]) {
+				(*methods)[methodindex] = f(env).ReflectValue()
+			}
 			env.Run.Debugf("implemented method %s.%s", tname, methodname)
 			env.IP++
 			return env.Code[env.IP], env
 		}
 	} else {
 		stmt = func(env *Env) (Stmt, *Env) {
-			(*methods)[methodindex] = f(env).ReflectValue()
+			// redefining a method may clear its value in the receiver type
+			if cell.define(env, body) || isNilFunc((*methods)[methodindex]) {
+				(*methods)[methodindex] = f(env).ReflectValue()
+			}
 			env.IP++
 			return env.Code[env.IP], env
 		}
@@ -216,17 +216,80 @@ func (c *Comp) methodDecl(funcdecl *ast.FuncDecl) {
 	c.Append(stmt, funcdecl.Pos())
 }
 
+// methodCreate adds a method to its receiver type, and compiles it.
+// Returns the method type, its funcCell and body, the function that creates the method at runtime,
+// the method index and the receiver type methods
+func (c *Comp) methodCreate(funcdecl *ast.FuncDecl) (t xr.Type, cell *funcCell, fbody *funcBody, f func(*Env) xr.Value, methodindex int, methods *[]r.Value) {
+	recvdecl := funcdecl.Recv.List[0]
+
+	functype := funcdecl.Type
+	t, paramnames, resultnames := c.TypeFunctionOrMethod(recvdecl, functype)
+
+	// gtype := t.GoType().Underlying().(*types.Signature)
+	// c.Debugf("declaring method (%v).%s%s %s\n\treflect.Type: <%v>", gtype.Recv().Type(), funcdecl.Name.Name, gtype.Params(), gtype.Results(), t.ReflectType())
+
+	trecv, name := methodRecv(t), funcdecl.Name.Name
+	oldcell := c.methodCell(trecv, name)
+	if oldcell != nil && !oldcell.t.IdenticalTo(t) {
+		// dependency analysis cannot find the callers of a method, thus we cannot recompile them
+		c.Errorf("cannot redefine method %v.%s with a different signature: was <%v>, now <%v>. Redefine type %v to change it",
+			trecv, name, oldcell.t, t, trecv)
+	}
+	var oldfuns []r.Value
+	if oldcell != nil {
+		oldfuns = append(oldfuns, *trecv.GetMethods()...)
+	}
+	// declare the method name and type before compiling its body: allows recursive methods
+	methodindex, methods = c.methodAdd(funcdecl, t)
+	panicking := true
+	defer func() {
+		// methodAdd cleared the method value. On compile error, restore it
+		if panicking && methodindex < len(oldfuns) {
+			(*methods)[methodindex] = oldfuns[methodindex]
+		}
+	}()
+	c.registerFunc(funcdecl, methodName(funcdecl))
+
+	cf := NewComp(c, nil)
+	info, resultfuns := cf.funcBinds(funcdecl.Name.Name, functype, t, paramnames, resultnames)
+	cf.Func = info
+
+	body := funcdecl.Body
+	info.Labels = funcLabels(body)
+	if body != nil && len(body.List) != 0 {
+		// in Go, function arguments/results and function body are in the same scope
+		cf.List(body.List)
+	}
+	// do NOT keep a reference to compile environment!
+	funcbody := cf.Code.Exec()
+	cell, fbody, f = cf.funcCellCreate(oldcell, t, info, resultfuns, funcbody)
+	c.setMethodCell(trecv, name, cell)
+	panicking = false
+	return t, cell, fbody, f, methodindex, methods
+}
+
+// methodRecv returns the type that contains the method with type t
+func methodRecv(t xr.Type) xr.Type {
+	trecv := t.In(0)
+	if trecv.Kind() == r.Ptr && !trecv.Named() {
+		trecv = trecv.Elem()
+	}
+	return trecv
+}
+
 // FuncLit compiles a function literal, i.e. a closure.
 // For functions or methods declarations, use FuncDecl()
 func (c *Comp) FuncLit(funclit *ast.FuncLit) *Expr {
 	functype := funclit.Type
 	t, paramnames, resultnames := c.TypeFunction(functype)
+	c.registerFunc(funclit, "")
 
 	cf := NewComp(c, nil)
 	info, resultfuns := cf.funcBinds("", functype, t, paramnames, resultnames)
 	cf.Func = info
 
 	body := funclit.Body
+	info.Labels = funcLabels(body)
 	if body != nil && len(body.List) != 0 {
 		// in Go, function arguments/results and function body are in the same scope
 		cf.List(body.List)
@@ -326,6 +389,7 @@ func (c *Comp) funcMaker(info *FuncInfo, resultfuns []I, funcbody func(*Env)) *f
 		Result:    info.Result,
 		resultfun: resultfuns,
 		funcbody:  funcbody,
+		recover:   info.Recover,
 	}
 	c.FuncMaker = m // store it for debugger command 'backtrace'
 	return m
@@ -340,8 +404,9 @@ func (c *Comp) funcCreate(t xr.Type, info *FuncInfo, resultfuns []I, funcbody fu
 	nin := t.NumIn()
 	nout := t.NumOut()
 
-	// do not create optimized functions if arguments or results are named types
-	optimize := rtype != rtypeOfForward
+	// do not create optimized functions if arguments or results are named types,
+	// or if the function calls recover(): funcGeneric supports it
+	optimize := rtype != rtypeOfForward && !m.recover
 	for i := 0; optimize && i < nin; i++ {
 		rt := rtype.In(i)
 		k := rt.Kind()
@@ -421,29 +486,51 @@ func (c *Comp) funcGeneric(t xr.Type, m *funcMaker) func(*Env) xr.Value {
 		debugC = c
 	}
 
+	// create the Env of a function call and copy runtime arguments into allocated binds
+	enter := func(env *Env, args []xr.Value) *Env {
+		env = newEnv4Func(env, nbinds, nintbinds, debugC)
+		for i, decl := range paramdecls {
+			if decl != nil {
+				// decl == nil means the argument is ignored inside the function
+				decl(env, args[i])
+			}
+		}
+		return env
+	}
+	// read results from allocated binds and return them
+	leave := func(env *Env) []xr.Value {
+		rets := make([]xr.Value, len(resultexprs))
+		for i, expr := range resultexprs {
+			rets[i] = expr(env)
+		}
+		env.freeEnv4Func()
+		return rets
+	}
+	if m.recover {
+		return func(env *Env) xr.Value {
+			env.MarkUsedByClosure()
+			// use xr.MakeFuncR: recover() must be invoked directly by the function created by reflect
+			return xr.MakeFuncR(t, func(args []r.Value) []r.Value {
+				env := enter(env, xr.FromReflectValues(args))
+				// recover() at Go level succeeds only if this function
+				// was invoked directly as defer by compiled code, while panicking
+				if rec := recover(); rec != nil {
+					env.Run.runDeferredByCompiled(env, rec, funcbody)
+				} else {
+					funcbody(env)
+				}
+				return xr.ToReflectValues(leave(env))
+			})
+		}
+	}
 	return func(env *Env) xr.Value {
 		// function is closed over the env used to DECLARE it
 		env.MarkUsedByClosure()
 		return xr.MakeFunc(t, func(args []xr.Value) []xr.Value {
-			env := newEnv4Func(env, nbinds, nintbinds, debugC)
-
-			// copy runtime arguments into allocated binds
-			for i, decl := range paramdecls {
-				if decl != nil {
-					// decl == nil means the argument is ignored inside the function
-					decl(env, args[i])
-				}
-			}
+			env := enter(env, args)
 			// execute the body
 			funcbody(env)
-
-			// read results from allocated binds and return them
-			rets := make([]xr.Value, len(resultexprs))
-			for i, expr := range resultexprs {
-				rets[i] = expr(env)
-			}
-			env.freeEnv4Func()
-			return rets
+			return leave(env)
 		})
 	}
 }
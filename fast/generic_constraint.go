@@ -0,0 +1,210 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * generic_constraint.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package fast
+
+import (
+	"go/ast"
+	"go/token"
+	r "reflect"
+
+	etoken "github.com/cosmos72/gomacro/go/etoken"
+	"github.com/cosmos72/gomacro/go/types"
+	xr "github.com/cosmos72/gomacro/xreflect"
+)
+
+// a single term of a type constraint union, i.e. T or ~T
+type typeTerm struct {
+	tilde bool
+	typ   xr.Type // nil means any type
+}
+
+// a compiled type constraint, as used by GENERICS_V3_GO.
+// a type satisfies it if it implements all ifaces,
+// is comparable (if requested) and matches at least one term of each union
+type typeConstraint struct {
+	ifaces     []xr.Type
+	comparable bool
+	unions     [][]typeTerm
+}
+
+type typeConstraintType struct{}
+
+// key used to store *typeConstraint inside named interface types, see xr.Type.SetUserData()
+var typeConstraintKey typeConstraintType
+
+// return the type constraint stored in named interface type t, or nil if not present
+func typeConstraintOf(t xr.Type) *typeConstraint {
+	if t == nil || t.Kind() != r.Interface || !t.Named() {
+		return nil
+	}
+	data, _ := t.GetUserData(typeConstraintKey)
+	cons, _ := data.(*typeConstraint)
+	return cons
+}
+
+// if node is an interface containing type elements, as for example
+// interface { ~int | ~string }, store the corresponding type constraint inside
+// the named type t. Otherwise remove any type constraint from t
+func (c *Comp) setTypeConstraint(t xr.Type, node ast.Expr) {
+	if t == nil || t.Kind() != r.Interface || !GENERICS_V3_GO() {
+		return
+	}
+	var cons *typeConstraint
+	if _, ok := node.(*ast.InterfaceType); ok {
+		cons = c.typeConstraint(node)
+		if !cons.comparable && len(cons.unions) == 0 {
+			cons = nil
+		}
+	}
+	t.SetUserData(typeConstraintKey, cons)
+}
+
+// compile a type constraint
+func (c *Comp) typeConstraint(node ast.Expr) *typeConstraint {
+	switch n := node.(type) {
+	case nil:
+		return &typeConstraint{}
+	case *ast.ParenExpr:
+		return c.typeConstraint(n.X)
+	case *ast.InterfaceType:
+		cons := &typeConstraint{}
+		if t := c.TypeInterface(n); t.NumAllMethod() != 0 {
+			cons.ifaces = append(cons.ifaces, t)
+		}
+		if n.Methods != nil {
+			for _, field := range n.Methods.List {
+				if len(field.Names) == 0 {
+					cons.add(c.typeConstraint(field.Type))
+				}
+			}
+		}
+		return cons
+	case *ast.BinaryExpr:
+		if n.Op == token.OR {
+			return &typeConstraint{unions: [][]typeTerm{c.typeTerms(node, nil)}}
+		}
+	case *ast.UnaryExpr:
+		if n.Op == etoken.TILDE {
+			return &typeConstraint{unions: [][]typeTerm{c.typeTerms(node, nil)}}
+		}
+	}
+	t := c.Type(node)
+	if cons := typeConstraintOf(t); cons != nil {
+		return cons
+	} else if t.Kind() == r.Interface {
+		if t.NumAllMethod() == 0 {
+			return &typeConstraint{}
+		}
+		return &typeConstraint{ifaces: []xr.Type{t}}
+	}
+	return &typeConstraint{unions: [][]typeTerm{{{typ: t}}}}
+}
+
+// compile the terms of a type constraint union term1 | term2 ...
+func (c *Comp) typeTerms(node ast.Expr, terms []typeTerm) []typeTerm {
+	switch n := node.(type) {
+	case *ast.ParenExpr:
+		return c.typeTerms(n.X, terms)
+	case *ast.BinaryExpr:
+		if n.Op == token.OR {
+			terms = c.typeTerms(n.X, terms)
+			return c.typeTerms(n.Y, terms)
+		}
+	case *ast.UnaryExpr:
+		if n.Op == etoken.TILDE {
+			t := c.Type(n.X)
+			if t.Kind() == r.Interface {
+				c.Errorf("invalid use of ~ in type constraint: %v is an interface: %v", t, node)
+			}
+			return append(terms, typeTerm{tilde: true, typ: t})
+		}
+	}
+	t := c.Type(node)
+	if cons := typeConstraintOf(t); cons != nil {
+		// embedded constraint in union, as for example Signed | Unsigned
+		if len(cons.ifaces) != 0 || len(cons.unions) > 1 {
+			c.Errorf("cannot use a type constraint with methods in union: %v", node)
+		}
+		if len(cons.unions) == 0 {
+			return append(terms, typeTerm{})
+		}
+		return append(terms, cons.unions[0]...)
+	} else if t.Kind() == r.Interface {
+		if t.NumAllMethod() != 0 {
+			c.Errorf("cannot use an interface with methods in union: %v", node)
+		}
+		return append(terms, typeTerm{})
+	}
+	return append(terms, typeTerm{typ: t})
+}
+
+// add to cons all the requirements of other
+func (cons *typeConstraint) add(other *typeConstraint) {
+	cons.ifaces = append(cons.ifaces, other.ifaces...)
+	cons.comparable = cons.comparable || other.comparable
+	cons.unions = append(cons.unions, other.unions...)
+}
+
+// return true if t satisfies the constraint
+func (cons *typeConstraint) satisfiedBy(t xr.Type) bool {
+	for _, iface := range cons.ifaces {
+		if !t.Implements(iface) {
+			return false
+		}
+	}
+	if cons.comparable && !t.Comparable() {
+		return false
+	}
+	for _, union := range cons.unions {
+		if !unionSatisfiedBy(union, t) {
+			return false
+		}
+	}
+	return true
+}
+
+func unionSatisfiedBy(union []typeTerm, t xr.Type) bool {
+	for _, term := range union {
+		if term.typ == nil || t.IdenticalTo(term.typ) {
+			return true
+		} else if term.tilde && types.Identical(t.GoType().Underlying(), term.typ.GoType().Underlying()) {
+			return true
+		}
+	}
+	return false
+}
+
+// declare the predeclared constraint 'comparable'
+func (c *Comp) declComparable() {
+	t := c.Universe.NamedOf("comparable", "")
+	t.SetUnderlying(c.TypeOfInterface())
+	t.SetUserData(typeConstraintKey, &typeConstraint{comparable: true})
+	c.DeclType0(t)
+}
+
+// check that generic arguments satisfy the constraints of the corresponding generic params.
+// must be invoked on the *Comp where generic params have been injected by injectGenericBinds()
+func (c *Comp) checkGenericConstraints(params []string, constraints []ast.Expr, vals []I, targs []xr.Type) {
+	for i, constraint := range constraints {
+		if constraint == nil || vals[i] != nil {
+			continue
+		}
+		t := targs[i]
+		if !c.typeConstraint(constraint).satisfiedBy(t) {
+			c.Errorf("%v does not satisfy %v (generic parameter %s)", t, constraint, params[i])
+		}
+	}
+}
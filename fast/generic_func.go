@@ -35,9 +35,10 @@ type GenericFuncInstance struct {
 // a generic function declaration.
 // either general, or partially specialized or fully specialized
 type GenericFuncDecl struct {
-	Decl   *ast.FuncLit // generic function declaration. use a *ast.FuncLit because we will compile it with Comp.FuncLit()
-	Params []string     // generic param names
-	For    []ast.Expr   // partial or full specialization
+	Decl        *ast.FuncLit // generic function declaration. use a *ast.FuncLit because we will compile it with Comp.FuncLit()
+	Params      []string     // generic param names
+	Constraints []ast.Expr   // generic param constraints. nil if all params are unconstrained
+	For         []ast.Expr   // partial or full specialization
 }
 
 // generic function
@@ -111,13 +112,12 @@ func (master *GenericFuncDecl) Signature(name string) string {
 	}
 
 	buf.WriteString(name)
-	buf.WriteString("#[")
-	for i, param := range master.Params {
-		if i != 0 {
-			buf.WriteString(", ")
-		}
-		buf.WriteString(param)
+	if GENERICS_V3_GO() {
+		buf.WriteByte('[')
+	} else {
+		buf.WriteString("#[")
 	}
+	writeGenericParams(&buf, master.Params, master.Constraints)
 	buf.WriteString("] ")
 	gname := buf.String()
 	buf.Reset()
@@ -147,15 +147,16 @@ func (c *Comp) DeclGenericFunc(decl *ast.FuncDecl) {
 			decl.Recv.List[1].Type, decl)
 	}
 
-	params, fors := c.genericParams(lit.Elts, "function or method", decl)
+	params, constraints, fors := c.genericParams(lit.Elts, "function or method", decl)
 
 	fdecl := GenericFuncDecl{
 		Decl: &ast.FuncLit{
 			Type: decl.Type,
 			Body: decl.Body,
 		},
-		Params: params,
-		For:    fors,
+		Params:      params,
+		Constraints: constraints,
+		For:         fors,
 	}
 	name := decl.Name.Name
 
@@ -301,6 +302,7 @@ func (maker *genericMaker) instantiateFunc(fun *GenericFunc, node ast.Node) *Gen
 			c.ErrorAt(node.Pos(), "error instantiating generic function: %v\n\t%v", maker, recover())
 		}
 	}()
+	c.checkGenericConstraints(special.decl.Params, special.decl.Constraints, special.vals, special.types)
 
 	if c.Globals.Options&base.OptDebugGenerics != 0 {
 		c.Debugf("forward-declaring generic function before instantiation: %v", maker)
@@ -23,6 +23,7 @@ import (
 	r "reflect"
 
 	"github.com/cosmos72/gomacro/base/untyped"
+	etoken "github.com/cosmos72/gomacro/go/etoken"
 	xr "github.com/cosmos72/gomacro/xreflect"
 )
 
@@ -180,6 +181,9 @@ func (inf *inferFuncType) args() (vals []I, types []xr.Type) {
 		}
 	}
 
+	// third pass: constraints with a core type, as for example S ~[]E
+	inf.constraints()
+
 	params := inf.tfun.Master.Params
 	n := len(params)
 	vals = make([]I, n)
@@ -266,6 +270,21 @@ func (inf *inferFuncType) arg(pattern ast.Expr, targ xr.Type, exact bool) {
 	}
 }
 
+// partially infer type of generic function from the constraints of already inferred params,
+// as for example E from S in func Sort[S ~[]E, E any](s S)
+func (inf *inferFuncType) constraints() {
+	master := &inf.tfun.Master
+	for i, constraint := range master.Constraints {
+		tilde, ok := constraint.(*ast.UnaryExpr)
+		if !ok || tilde.Op != etoken.TILDE {
+			continue
+		}
+		if inferred := inf.inferred[master.Params[i]]; inferred.Type != nil {
+			inf.arg(tilde.X, inferred.Type, false)
+		}
+	}
+}
+
 // partially infer type of generic function from an array or slice parameter
 func (inf *inferFuncType) arrayType(node *ast.ArrayType, targ xr.Type, exact bool) (ast.Expr, xr.Type, bool) {
 	if node.Len == nil {
@@ -27,6 +27,7 @@ import (
 
 	"github.com/cosmos72/gomacro/ast2"
 	"github.com/cosmos72/gomacro/base"
+	"github.com/cosmos72/gomacro/base/output"
 	etoken "github.com/cosmos72/gomacro/go/etoken"
 	xr "github.com/cosmos72/gomacro/xreflect"
 )
@@ -41,6 +42,16 @@ func GENERICS_V2_CTI() bool {
 	return etoken.GENERICS.V2_CTI()
 }
 
+// enable standard Go 1.18+ generics?
+func GENERICS_V3_GO() bool {
+	return etoken.GENERICS.V3_GO()
+}
+
+// enable any flavor of generics?
+func GENERICS_ENABLED() bool {
+	return GENERICS_V1_CXX() || GENERICS_V2_CTI() || GENERICS_V3_GO()
+}
+
 type genericMaker struct {
 	comp  *Comp
 	sym   *Symbol
@@ -67,20 +78,17 @@ type genericFuncCandidate struct {
 }
 
 func (special *genericFuncCandidate) injectBinds(c *Comp) {
-	for i, name := range special.decl.Params {
-		t := special.types[i]
-		if val := special.vals[i]; val != nil {
-			c.DeclConst0(name, t, val, t)
-		} else {
-			c.declTypeAlias(name, t)
-		}
-	}
+	injectGenericBinds(c, special.decl.Params, special.vals, special.types)
 }
 
 func (special *genericTypeCandidate) injectBinds(c *Comp) {
-	for i, name := range special.decl.Params {
-		t := special.types[i]
-		if val := special.vals[i]; val != nil {
+	injectGenericBinds(c, special.decl.Params, special.vals, special.types)
+}
+
+func injectGenericBinds(c *Comp, params []string, vals []I, types []xr.Type) {
+	for i, name := range params {
+		t := types[i]
+		if val := vals[i]; val != nil {
 			c.DeclConst0(name, t, val, t)
 		} else {
 			c.declTypeAlias(name, t)
@@ -95,7 +103,11 @@ func (maker *genericMaker) String() string {
 	}
 	var buf bytes.Buffer
 	buf.WriteString(maker.sym.Name)
-	buf.WriteString("#[")
+	if GENERICS_V3_GO() {
+		buf.WriteByte('[')
+	} else {
+		buf.WriteString("#[")
+	}
 
 	for i, val := range maker.vals {
 		if i != 0 {
@@ -112,12 +124,16 @@ func (maker *genericMaker) String() string {
 }
 
 func (c *Comp) genericMaker(node *ast.IndexExpr, which BindClass) *genericMaker {
-	name, genericArgs, ok := splitGenericArgs(node)
+	name, genericArgs, implicit, ok := splitGenericArgs(node)
 	if !ok {
 		return nil
 	}
 	sym, upc := c.tryResolve(name)
 	if sym == nil {
+		if implicit && which == GenericFuncBind {
+			// let Comp.indexExpr() report the error
+			return nil
+		}
 		c.Errorf("undefined identifier: %v", name)
 	}
 	n := len(genericArgs)
@@ -141,6 +157,10 @@ func (c *Comp) genericMaker(node *ast.IndexExpr, which BindClass) *genericMaker
 		}
 	}
 	if !ok {
+		if implicit && which == GenericFuncBind {
+			// name[index] is not a generic function instantiation
+			return nil
+		}
 		c.Errorf("symbol is not a %v, cannot use #[...] on it: %s", which, name)
 	}
 	if n != len(params) {
@@ -169,6 +189,20 @@ func (c *Comp) genericMaker(node *ast.IndexExpr, which BindClass) *genericMaker
 	return &genericMaker{upc, sym, ifun, genericArgs, vals, types, GenericKey(vals, types), "", node.Pos()}
 }
 
+// write generic params, and their constraints if present, into buf
+func writeGenericParams(buf *bytes.Buffer, params []string, constraints []ast.Expr) {
+	for i, param := range params {
+		if i != 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(param)
+		if i < len(constraints) && constraints[i] != nil {
+			buf.WriteByte(' ')
+			(*output.Stringer).Fprintf(nil, buf, "%v", constraints[i])
+		}
+	}
+}
+
 func GenericKey(vals []I, types []xr.Type) I {
 	// slices cannot be used as map keys. use an array and reflection
 	key := xr.NewR(r.ArrayOf(len(types), rtypeOfInterface)).Elem()
@@ -227,23 +261,47 @@ func (c *Comp) constToAstExpr(val interface{}, pos token.Pos) ast.Expr {
 	}
 }
 
-func splitGenericArgs(node *ast.IndexExpr) (string, []ast.Expr, bool) {
+// split name#[T1, T2...] or, with GENERICS_V3_GO, name[T1, T2...]
+// into name and generic arguments.
+//
+// With GENERICS_V3_GO, the parser stores a single generic argument directly into ast.IndexExpr.Index:
+// in such case returns implicit = true, because node may also be a normal index expression obj[idx]
+func splitGenericArgs(node *ast.IndexExpr) (name string, args []ast.Expr, implicit bool, ok bool) {
 	if ident, _ := node.X.(*ast.Ident); ident != nil {
 		cindex, _ := node.Index.(*ast.CompositeLit)
 		if cindex != nil && cindex.Type == nil {
-			return ident.Name, cindex.Elts, true
+			return ident.Name, cindex.Elts, false, true
+		} else if GENERICS_V3_GO() {
+			return ident.Name, []ast.Expr{node.Index}, true, true
 		}
 	}
-	return "", nil, false
+	return "", nil, false, false
 }
 
-func (c *Comp) genericParams(params []ast.Expr, errlabel string, node ast.Node) ([]string, []ast.Expr) {
+// return the names, the constraints (nil if all generic params are unconstrained)
+// and the partial or full specialization of a generic declaration
+func (c *Comp) genericParams(params []ast.Expr, errlabel string, node ast.Node) ([]string, []ast.Expr, []ast.Expr) {
 	names := make([]string, 0, len(params))
-	var exprs []ast.Expr
+	var constraints, exprs []ast.Expr
 	for i, param := range params {
 		switch param := param.(type) {
 		case *ast.Ident:
 			names = append(names, param.Name)
+			if constraints != nil {
+				constraints = append(constraints, nil)
+			}
+		case *ast.KeyValueExpr:
+			// generic parameter with constraint, i.e. T C or T: C
+			ident, ok := param.Key.(*ast.Ident)
+			if !ok {
+				c.Errorf("invalid generic %s declaration: generic parameter %d should be *ast.Ident, found %T: %v",
+					errlabel, i, param.Key, node)
+			}
+			if constraints == nil {
+				constraints = make([]ast.Expr, len(names), len(params))
+			}
+			names = append(names, ident.Name)
+			constraints = append(constraints, param.Value)
 		case *ast.BadExpr:
 		case *ast.CompositeLit:
 			exprs = param.Elts
@@ -252,7 +310,7 @@ func (c *Comp) genericParams(params []ast.Expr, errlabel string, node ast.Node)
 				errlabel, i, param, node)
 		}
 	}
-	return names, exprs
+	return names, constraints, exprs
 }
 
 // return the most specialized function declaration applicable to used params.
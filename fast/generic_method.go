@@ -0,0 +1,157 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * generic_method.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package fast
+
+import (
+	"go/ast"
+
+	"github.com/cosmos72/gomacro/base"
+	xr "github.com/cosmos72/gomacro/xreflect"
+)
+
+// a method declared on a generic type, as for example
+// func (l *List[T]) Len() int { ... }
+type GenericMethodDecl struct {
+	Decl   *ast.FuncDecl
+	Params []string // generic param names, as written in the receiver type
+}
+
+// an instantiated named generic type, and the generic arguments used to instantiate it
+type genericTypeInstance struct {
+	vals  []I
+	types []xr.Type
+	typ   xr.Type
+}
+
+// if recv is a receiver of generic type Foo[T1, T2...] or *Foo[T1, T2...],
+// return the generic type and the generic param names T1, T2...
+func (c *Comp) genericMethodRecv(recv *ast.Field) (*GenericType, []string) {
+	node := recv.Type
+	for {
+		switch n := node.(type) {
+		case *ast.ParenExpr:
+			node = n.X
+			continue
+		case *ast.StarExpr:
+			node = n.X
+			continue
+		}
+		break
+	}
+	index, ok := node.(*ast.IndexExpr)
+	if !ok {
+		return nil, nil
+	}
+	name, args, _, ok := splitGenericArgs(index)
+	if !ok {
+		return nil, nil
+	}
+	sym := c.TryResolve(name)
+	if sym == nil || sym.Desc.Class() != GenericTypeBind {
+		return nil, nil
+	}
+	typ, _ := sym.Value.(*GenericType)
+	if typ == nil {
+		return nil, nil
+	}
+	params := make([]string, len(args))
+	for i, arg := range args {
+		ident, ok := arg.(*ast.Ident)
+		if !ok {
+			c.Errorf("invalid receiver type %v: generic parameter %d should be *ast.Ident, found %T: %v",
+				recv.Type, i, arg, arg)
+		}
+		params[i] = ident.Name
+	}
+	if n := len(typ.Master.Params); n != len(params) {
+		c.Errorf("invalid receiver type %v: generic type expects exactly %d generic parameters %v, found %d",
+			recv.Type, n, typ.Master.Params, len(params))
+	}
+	return typ, params
+}
+
+// DeclGenericMethod compiles a method declaration on a generic type.
+// The method is compiled for all existing instantiations of the generic type,
+// and will be compiled for any further instantiation
+func (c *Comp) DeclGenericMethod(decl *ast.FuncDecl, typ *GenericType, params []string) {
+	if typ.DeclScope != c {
+		c.Errorf("cannot declare method on generic type %v declared in a different scope: %v", decl.Recv.List[0].Type, decl.Name)
+	}
+	method := GenericMethodDecl{Decl: decl, Params: params}
+	for _, inst := range typ.named {
+		typ.instantiateMethod(method, inst)
+	}
+	typ.Methods = append(typ.Methods, method)
+
+	// a generic method declaration is a statement:
+	// executing it installs all the compiled methods that were waiting for the runtime environment
+	c.Append(func(env *Env) (Stmt, *Env) {
+		typ.setEnv(env)
+		env.IP++
+		return env.Code[env.IP], env
+	}, decl.Pos())
+}
+
+// add an instantiated named type, and compile all the methods declared on the generic type for it
+func (typ *GenericType) addInstance(inst genericTypeInstance) {
+	for _, method := range typ.Methods {
+		typ.instantiateMethod(method, inst)
+	}
+	typ.named = append(typ.named, inst)
+}
+
+// compile a generic method for an instantiated named type
+func (typ *GenericType) instantiateMethod(method GenericMethodDecl, inst genericTypeInstance) {
+	// create a new nested Comp
+	c := NewComp(typ.DeclScope, nil)
+	c.UpCost = 0
+	c.Depth--
+
+	// and inject the generic arguments, using the param names written in the receiver type
+	injectGenericBinds(c, method.Params, inst.vals, inst.types)
+
+	if c.Globals.Options&base.OptDebugGenerics != 0 {
+		c.Debugf("instantiating generic method (%v).%v", inst.typ, method.Decl.Name)
+	}
+	_, cell, body, f, methodindex, methods := c.methodCreate(method.Decl)
+
+	typ.install(func(env *Env) {
+		if cell.define(env, body) || isNilFunc((*methods)[methodindex]) {
+			(*methods)[methodindex] = f(env).ReflectValue()
+		}
+	})
+}
+
+// install a compiled method now if the runtime environment is known,
+// otherwise as soon as it becomes known
+func (typ *GenericType) install(install func(*Env)) {
+	if typ.env != nil {
+		install(typ.env)
+	} else {
+		typ.pending = append(typ.pending, install)
+	}
+}
+
+// set the runtime environment where the generic type is declared,
+// and install the compiled methods waiting for it
+func (typ *GenericType) setEnv(env *Env) {
+	typ.env = env
+	pending := typ.pending
+	typ.pending = nil
+	for _, install := range pending {
+		install(env)
+	}
+}
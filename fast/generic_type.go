@@ -29,16 +29,22 @@ import (
 // a generic type declaration.
 // either general, or partially specialized or fully specialized
 type GenericTypeDecl struct {
-	Decl   ast.Expr   // type declaration body. use an ast.Expr because we will compile it with Comp.Type()
-	Alias  bool       // true if declaration is an alias: 'type Foo = ...'
-	Params []string   // generic param names
-	For    []ast.Expr // for partial or full specialization
+	Decl        ast.Expr   // type declaration body. use an ast.Expr because we will compile it with Comp.Type()
+	Alias       bool       // true if declaration is an alias: 'type Foo = ...'
+	Params      []string   // generic param names
+	Constraints []ast.Expr // generic param constraints. nil if all params are unconstrained
+	For         []ast.Expr // for partial or full specialization
 }
 
 type GenericType struct {
 	Master    GenericTypeDecl            // master (i.e. non specialized) declaration
 	Special   map[string]GenericTypeDecl // partially or fully specialized declarations. key is TemplateTypeDecl.For converted to string
 	Instances map[I]xr.Type              // cache of instantiated types. key is [N]interface{}{T1, T2...}
+	DeclScope *Comp                      // scope where generic type is declared
+	Methods   []GenericMethodDecl        // methods declared on the generic type
+	named     []genericTypeInstance      // instantiated named types, needed to add them methods declared later
+	env       *Env                       // runtime environment where generic type is declared. nil until known
+	pending   []func(*Env)               // compiled methods waiting for env to be known
 }
 
 func (t *GenericType) Pos() token.Pos {
@@ -64,6 +70,10 @@ func (t *GenericType) String() string {
 			buf.WriteString(param)
 		}
 		buf.WriteString("] type ")
+	} else if GENERICS_V3_GO() {
+		buf.WriteString("type [")
+		writeGenericParams(&buf, decl.Params, decl.Constraints)
+		buf.WriteString("] ")
 	} else {
 		buf.WriteString("type #[")
 		for i, param := range decl.Params {
@@ -117,13 +127,14 @@ func (c *Comp) DeclGenericType(spec *ast.TypeSpec) {
 		c.Errorf("invalid generic type declaration: expecting an *ast.CompositeLit, found &ast.CompositeLit{Type: &ast.CompositeLit{}}: %v",
 			spec)
 	}
-	params, fors := c.genericParams(lit.Elts, "type", spec)
+	params, constraints, fors := c.genericParams(lit.Elts, "type", spec)
 
 	tdecl := GenericTypeDecl{
-		Decl:   lit.Type,
-		Alias:  spec.Assign != token.NoPos,
-		Params: params,
-		For:    fors,
+		Decl:        lit.Type,
+		Alias:       spec.Assign != token.NoPos,
+		Params:      params,
+		Constraints: constraints,
+		For:         fors,
 	}
 	name := spec.Name.Name
 
@@ -141,6 +152,7 @@ func (c *Comp) DeclGenericType(spec *ast.TypeSpec) {
 			Master:    tdecl,
 			Special:   make(map[string]GenericTypeDecl),
 			Instances: make(map[I]xr.Type),
+			DeclScope: c,
 		}
 		return
 	}
@@ -215,6 +227,7 @@ func (maker *genericMaker) instantiateType(typ *GenericType, node *ast.IndexExpr
 			c.ErrorAt(node.Pos(), "error instantiating generic type: %v\n\t%v", maker, recover())
 		}
 	}()
+	c.checkGenericConstraints(special.decl.Params, special.decl.Constraints, special.vals, special.types)
 	// compile the type instantiation
 	//
 	var t xr.Type
@@ -233,6 +246,8 @@ func (maker *genericMaker) instantiateType(typ *GenericType, node *ast.IndexExpr
 		typ.Instances[key] = t
 		u := c.Type(special.decl.Decl)
 		c.SetUnderlyingType(t, u)
+		c.setTypeConstraint(t, special.decl.Decl)
+		typ.addInstance(genericTypeInstance{vals: maker.vals, types: maker.types, typ: t})
 	} else {
 		// either the generic type is an alias, or name == "_" (discards the result of type declaration)
 		t = c.Type(special.decl.Decl)
@@ -23,6 +23,8 @@ import (
 	"go/token"
 	r "reflect"
 	"sort"
+	"sync"
+	"unsafe"
 
 	"github.com/cosmos72/gomacro/atomic"
 	"github.com/cosmos72/gomacro/base"
@@ -294,6 +296,7 @@ type Bind struct {
 	Lit
 	Desc BindDescriptor
 	Name string
+	cell *funcCell // for top-level functions: their current body
 }
 
 func (bind *Bind) String() string {
@@ -328,7 +331,7 @@ func (bind *Bind) RuntimeValue(g *CompGlobals, env *Env) xr.Value {
 	case VarBind, FuncBind:
 		v = env.Vals[bind.Desc.Index()]
 	case GenericFuncBind, GenericTypeBind:
-		if GENERICS_V1_CXX() || GENERICS_V2_CTI() {
+		if GENERICS_ENABLED() {
 			v = bind.Lit.ConstValue()
 			break
 		}
@@ -472,6 +475,16 @@ type FuncInfo struct {
 	Param        []*Bind
 	Result       []*Bind
 	NamedResults bool
+	Labels       map[string]bool // all labels in function body. used for goto error messages
+	Recover      bool            // true if function body directly calls recover()
+	rangeFunc    *rangeFuncInfo  // non-nil if function is the body of a range-over-func loop
+}
+
+// a goto that jumps to a label not compiled yet
+type forwardGoto struct {
+	Pos        token.Pos
+	BindNum    int // Comp.BindNum of label's *Comp when goto was compiled
+	IntBindNum int // Comp.IntBindNum of label's *Comp when goto was compiled
 }
 
 const (
@@ -484,6 +497,8 @@ const (
 	EFStartDefer ExecFlags = 1 << iota // true next executed function body is a defer
 	EFDefer                            // function body being executed is a defer
 	EFDebug                            // function body is executed with debugging enabled
+	EFHooks                            // execution hooks are installed
+	EFSteps                            // executed statements are counted: a step limit or the profiler is active
 )
 
 func (ef ExecFlags) StartDefer() bool {
@@ -498,6 +513,14 @@ func (ef ExecFlags) IsDebug() bool {
 	return ef&EFDebug != 0
 }
 
+func (ef ExecFlags) IsHooks() bool {
+	return ef&EFHooks != 0
+}
+
+func (ef ExecFlags) IsSteps() bool {
+	return ef&EFSteps != 0
+}
+
 func (ef *ExecFlags) SetDefer(flag bool) {
 	if flag {
 		(*ef) |= EFDefer
@@ -522,6 +545,22 @@ func (ef *ExecFlags) SetDebug(flag bool) {
 	}
 }
 
+func (ef *ExecFlags) SetHooks(flag bool) {
+	if flag {
+		(*ef) |= EFHooks
+	} else {
+		(*ef) &^= EFHooks
+	}
+}
+
+func (ef *ExecFlags) SetSteps(flag bool) {
+	if flag {
+		(*ef) |= EFSteps
+	} else {
+		(*ef) &^= EFSteps
+	}
+}
+
 type DebugOp struct {
 	// statements at env.CallDepth < Depth will be executed in single-stepping mode,
 	// i.e. invoking the debugger after every statement
@@ -544,8 +583,12 @@ type Debugger interface {
 
 // IrGlobals contains interpreter configuration
 type IrGlobals struct {
-	gls  map[uintptr]*Run
-	lock atomic.SpinLock
+	gls         map[uintptr]*Run
+	lock        atomic.SpinLock
+	limits      Limits         // set by Interp.SetLimits
+	profiler    unsafe.Pointer // *profiler set by Interp.StartProfile. accessed atomically
+	hooks       unsafe.Pointer // *hooks set by Interp.SetHooks. accessed atomically
+	Breakpoints Breakpoints
 	base.Globals
 }
 
@@ -558,12 +601,21 @@ type Run struct {
 	ExecFlags    ExecFlags
 	CurrEnv      *Env        // caller of current function. used ONLY at function entry to build call stack
 	InstallDefer func()      // defer function to be installed
+	DeferDirect  bool        // true if InstallDefer must be deferred directly, so that compiled code can recover()
 	DeferOfFun   *Env        // function whose defer are running
 	PanicFun     *Env        // the currently panicking function
 	Panic        interface{} // current panic. needed for recover()
 	CmdOpt       base.CmdOpt
 	Debugger     Debugger
 	DebugDepth   int // depth of function to debug with single-step
+	noBreak      int // if > 0, do not stop at breakpoints. set while debugger is running
+	lastBreak    *Breakpoint
+	lastBreakEnv *Env
+	lastBreakIP  int
+	limit        *evalLimit // set by Interp.EvalContext, inherited by goroutines
+	exit         *ExitError // set when interpreted code called os.Exit() in another goroutine
+	steps        int        // statements this goroutine can execute before calling refillSteps. counted only if ExecFlags.IsSteps()
+	profileTick  int64      // profiler tick of last sample
 	PoolSize     int
 	Pool         [poolCapacity]*Env
 }
@@ -571,12 +623,25 @@ type Run struct {
 // CompGlobals contains interpreter compile bookeeping information
 type CompGlobals struct {
 	*IrGlobals
-	Universe     *xr.Universe
-	KnownImports map[string]*Import // map[path]*Import cache of known imports
-	interf2proxy map[r.Type]r.Type  // interface -> proxy
-	proxy2interf map[r.Type]xr.Type // proxy -> interface
-	Prompt       string
-	Jit          *Jit
+	Universe      *xr.Universe
+	KnownImports  map[string]*Import // map[path]*Import cache of known imports
+	interf2proxy  map[r.Type]r.Type  // interface -> proxy
+	proxy2interf  map[r.Type]xr.Type // proxy -> interface
+	Prompt        string
+	Jit           *Jit
+	topEnv        *Env                 // Env of universe scope. used to interpret packages imported from source
+	sourceImports map[string]bool      // packages being imported from source. used to detect import cycles
+	policy        *policy              // set by Interp.SetPolicy
+	process       *process             // set by Interp.SetProcess
+	funcs         []funcRange          // compiled functions sorted by position, to name them in profiles and hooks
+	funcNames     map[string]token.Pos // function name -> position of its current declaration
+	funcLock      sync.Mutex           // protects funcs and funcNames, read at runtime by hooks
+	funcLits      map[string]int       // number of function literals in each function
+	coverage      *coverage            // set by Interp.StartCoverage
+	tracer        *tracer              // set by Interp.Trace
+	methodCells   map[methodKey]*funcCell
+	reloads       map[string]map[string]string // file -> declaration key -> source. used by Interp.Reload
+	evalLock      evalLock                     // serializes Interp.Eval and Interp.Reload
 }
 
 func (cg *CompGlobals) CompileOptions() CompileOptions {
@@ -600,6 +665,7 @@ type CompBinds struct {
 	Types      map[string]xr.Type
 	Name       string // set by "package" directive
 	Path       string
+	session    *session // top-level declarations to save, or nil
 }
 
 // Comp is a tree-of-closures builder: it transforms ast.Nodes into closures
@@ -615,8 +681,10 @@ type Comp struct {
 	Loop      *LoopInfo // != nil when compiling a for or switch
 	Func      *FuncInfo // != nil when compiling a function
 	Labels    map[string]*int
+	Gotos     map[string][]forwardGoto // forward gotos, waiting for their label to be compiled
 	Outer     *Comp
-	FuncMaker *funcMaker // used by debugger command 'backtrace' to obtain function name, type and binds for arguments and results
+	FuncMaker *funcMaker  // used by debugger command 'backtrace' to obtain function name, type and binds for arguments and results
+	coverNext *coverBlock // basic block starting at next statement, see Comp.coverStmt
 }
 
 // ================================= Env =================================
@@ -0,0 +1,327 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * benchmark.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package gotest
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// B is the type passed to interpreted Benchmark functions.
+// It is compatible with testing.B
+type B struct {
+	common
+	N           int
+	benchTime   benchTime
+	timerOn     bool
+	timerStart  time.Time
+	startAllocs uint64
+	startBytes  uint64
+	netAllocs   uint64
+	netBytes    uint64
+	bytes       int64
+	showAllocs  bool
+	extra       map[string]float64
+	loopN       int
+}
+
+// PB is used by RunParallel for running parallel benchmarks.
+// It is compatible with testing.PB
+type PB struct {
+	n int
+}
+
+// benchTime is the value of -benchtime flag: either a duration or a number of iterations
+type benchTime struct {
+	d time.Duration
+	n int
+}
+
+func parseBenchTime(s string) (benchTime, error) {
+	if strings.HasSuffix(s, "x") {
+		n, err := strconv.ParseInt(s[:len(s)-1], 10, 0)
+		if err != nil || n <= 0 {
+			return benchTime{}, fmt.Errorf("invalid count %q", s)
+		}
+		return benchTime{n: int(n)}, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return benchTime{}, fmt.Errorf("invalid duration %q", s)
+	}
+	return benchTime{d: d}, nil
+}
+
+// StartTimer starts timing a test. This function is called automatically
+// before a benchmark starts, but it can also be used to resume timing after a call to StopTimer
+func (b *B) StartTimer() {
+	if !b.timerOn {
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		b.startAllocs = stats.Mallocs
+		b.startBytes = stats.TotalAlloc
+		b.timerStart = time.Now()
+		b.timerOn = true
+	}
+}
+
+// StopTimer stops timing a test
+func (b *B) StopTimer() {
+	if b.timerOn {
+		b.duration += time.Since(b.timerStart)
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		b.netAllocs += stats.Mallocs - b.startAllocs
+		b.netBytes += stats.TotalAlloc - b.startBytes
+		b.timerOn = false
+	}
+}
+
+// ResetTimer zeroes the elapsed benchmark time and memory allocation counters
+// and deletes user-reported metrics. It does not affect whether the timer is running
+func (b *B) ResetTimer() {
+	if b.extra == nil {
+		b.extra = make(map[string]float64)
+	} else {
+		for k := range b.extra {
+			delete(b.extra, k)
+		}
+	}
+	if b.timerOn {
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		b.startAllocs = stats.Mallocs
+		b.startBytes = stats.TotalAlloc
+		b.timerStart = time.Now()
+	}
+	b.duration = 0
+	b.netAllocs = 0
+	b.netBytes = 0
+}
+
+// Elapsed returns the measured elapsed time of the benchmark
+func (b *B) Elapsed() time.Duration {
+	d := b.duration
+	if b.timerOn {
+		d += time.Since(b.timerStart)
+	}
+	return d
+}
+
+// SetBytes records the number of bytes processed in a single operation
+func (b *B) SetBytes(n int64) {
+	b.bytes = n
+}
+
+// ReportAllocs enables malloc statistics for this benchmark
+func (b *B) ReportAllocs() {
+	b.showAllocs = true
+}
+
+// ReportMetric adds "n unit" to the reported benchmark results
+func (b *B) ReportMetric(n float64, unit string) {
+	if len(unit) == 0 {
+		panic("metric unit must not be empty")
+	}
+	if strings.IndexFunc(unit, func(r rune) bool { return r == ' ' || r == '\t' || r == '\n' }) >= 0 {
+		panic("metric unit must not contain whitespace")
+	}
+	b.extra[unit] = n
+}
+
+// Loop returns true as long as the benchmark should continue running.
+// Typical usage is "for b.Loop() { ... }"
+func (b *B) Loop() bool {
+	if b.loopN == 0 {
+		b.ResetTimer()
+	}
+	if b.loopN < b.N {
+		b.loopN++
+		return true
+	}
+	b.StopTimer()
+	return false
+}
+
+// Run benchmarks f as a subbenchmark with the given name.
+// Reports whether there were any failures
+func (b *B) Run(name string, f func(b *B)) bool {
+	b.mu.Lock()
+	b.hasSub = true
+	b.mu.Unlock()
+	benchName, ok, partial := b.r.benchMatch.fullName(&b.common, name)
+	if !ok {
+		return true
+	}
+	sub := &B{benchTime: b.benchTime}
+	sub.init(b.r, &b.common, benchName)
+	if partial {
+		// some of its subbenchmarks may match: run it once to discover them
+		sub.hasSub = true
+	}
+	b.r.runB(sub, f)
+	if b.r.hasPanic() {
+		runtime.Goexit()
+	}
+	return !sub.Failed()
+}
+
+// RunParallel runs a benchmark body.
+// Currently, the body is executed sequentially in a single goroutine
+func (b *B) RunParallel(body func(*PB)) {
+	b.ResetTimer()
+	body(&PB{n: b.N})
+}
+
+// Next reports whether there are more iterations to execute
+func (pb *PB) Next() bool {
+	if pb.n <= 0 {
+		return false
+	}
+	pb.n--
+	return true
+}
+
+// runN executes the benchmark function with b.N = n, in a new goroutine
+func (b *B) runN(f func(b *B), n int) bool {
+	runtime.GC()
+	b.N = n
+	b.loopN = 0
+	b.done = make(chan struct{})
+	b.ResetTimer()
+	b.StartTimer()
+	go b.tRunner(func() {
+		f(b)
+		b.StopTimer()
+	})
+	<-b.done
+	return !b.Failed() && !b.Skipped() && !b.r.hasPanic()
+}
+
+// launch executes the benchmark function repeatedly, increasing b.N,
+// until it runs for at least the requested -benchtime
+func (b *B) launch(f func(b *B)) {
+	if b.benchTime.n > 0 {
+		if b.benchTime.n > 1 {
+			b.runN(f, b.benchTime.n)
+		}
+		return
+	}
+	d := b.benchTime.d
+	for n := int64(1); b.duration < d && n < 1e9; {
+		last := n
+		prevIters := int64(b.N)
+		prevns := b.duration.Nanoseconds()
+		if prevns <= 0 {
+			prevns = 1
+		}
+		n = d.Nanoseconds() * prevIters / prevns
+		n += n / 5
+		if n > 100*last {
+			n = 100 * last
+		}
+		if n < last+1 {
+			n = last + 1
+		}
+		if n > 1e9 {
+			n = 1e9
+		}
+		if !b.runN(f, int(n)) {
+			return
+		}
+	}
+}
+
+func (b *B) result() BenchmarkResult {
+	extra := make(map[string]float64, len(b.extra))
+	for k, v := range b.extra {
+		extra[k] = v
+	}
+	return BenchmarkResult{
+		N:         b.N,
+		T:         b.duration,
+		Bytes:     b.bytes,
+		MemAllocs: b.netAllocs,
+		MemBytes:  b.netBytes,
+		Extra:     extra,
+	}
+}
+
+// runB executes a benchmark or subbenchmark and reports its result
+func (r *runner) runB(b *B, f func(b *B)) {
+	// run once, to discover subbenchmarks and to fail early
+	ok := b.runN(f, 1)
+	if ok && !b.hasSub {
+		b.launch(f)
+		ok = !b.Failed() && !b.Skipped() && !b.r.hasPanic()
+	}
+	b.mu.Lock()
+	hasSub := b.hasSub
+	b.mu.Unlock()
+
+	name := benchmarkName(b.name)
+	switch {
+	case b.Failed():
+		b.flushToParent(b.name, "--- FAIL: %s\n", name)
+	case b.Skipped():
+		if r.chatty {
+			b.flushToParent(b.name, "--- SKIP: %s\n", name)
+		}
+	case ok && !hasSub:
+		results := b.result().String()
+		if r.opts.BenchMem || b.showAllocs {
+			results += "\t" + b.result().MemString()
+		}
+		r.out.updatef(b.name, "%-*s\t%s\n", r.benchMaxLen, name, results)
+		if len(b.output) != 0 {
+			b.flushToParent(b.name, "--- BENCH: %s\n", name)
+		}
+	}
+}
+
+// benchmarkName appends GOMAXPROCS to the benchmark name, as package "testing" does
+func benchmarkName(name string) string {
+	if procs := runtime.GOMAXPROCS(-1); procs != 1 {
+		return fmt.Sprintf("%s-%d", name, procs)
+	}
+	return name
+}
+
+// benchmark implements testing.Benchmark: it benchmarks a single function,
+// without printing anything
+func (r *runner) benchmark(f func(b *B)) BenchmarkResult {
+	b := &B{benchTime: benchTime{d: time.Second}}
+	// use a private runner: output must not be printed
+	r2 := &runner{ir: r.ir, opts: r.opts, out: &printer{w: discard{}}}
+	b.init(r2, &common{}, "")
+	b.parent.init(r2, nil, "")
+	if b.runN(f, 1) {
+		b.launch(f)
+	}
+	if b.Failed() || b.Skipped() {
+		return BenchmarkResult{}
+	}
+	return b.result()
+}
+
+type discard struct{}
+
+func (discard) Write(p []byte) (int, error) {
+	return len(p), nil
+}
@@ -0,0 +1,436 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * common.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package gotest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cosmos72/gomacro/fast"
+)
+
+// common contains the state shared by T, B and F, and implements their common methods.
+// It mimics the unexported type with the same name in package "testing"
+type common struct {
+	mu         sync.Mutex
+	r          *runner
+	parent     *common
+	name       string // full name, as TestFoo/subtest
+	level      int    // nesting level: 0 for the root, 1 for top-level tests
+	output     []byte // output buffered until the test completes
+	failed     bool
+	skipped    bool
+	finished   bool
+	hasSub     bool // true if the test invoked Run()
+	helpers    map[*fast.Stmt]bool
+	cleanups   []func()
+	ctx        context.Context
+	cancel     context.CancelFunc
+	duration   time.Duration
+	tempDir    string
+	tempDirSeq int
+	done       chan struct{} // closed when the test goroutine terminates
+}
+
+func (c *common) init(r *runner, parent *common, name string) {
+	c.r = r
+	c.parent = parent
+	c.name = name
+	if parent != nil {
+		c.level = parent.level + 1
+	}
+	c.done = make(chan struct{})
+}
+
+// Name returns the name of the running test or benchmark
+func (c *common) Name() string {
+	return c.name
+}
+
+// Fail marks the function as having failed but continues execution
+func (c *common) Fail() {
+	if c.parent != nil {
+		c.parent.Fail()
+	}
+	if !c.isRunning() {
+		panic("Fail in goroutine after " + c.name + " has completed")
+	}
+	c.mu.Lock()
+	c.failed = true
+	c.mu.Unlock()
+}
+
+// Failed reports whether the function has failed
+func (c *common) Failed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.failed
+}
+
+// FailNow marks the function as having failed and stops its execution
+// by calling runtime.Goexit. It must be called from the goroutine running the test
+func (c *common) FailNow() {
+	c.Fail()
+	c.mu.Lock()
+	c.finished = true
+	c.mu.Unlock()
+	runtime.Goexit()
+}
+
+// Log formats its arguments using default formatting, analogous to fmt.Println,
+// and records the text in the test log
+func (c *common) Log(args ...interface{}) {
+	c.log(fmt.Sprintln(args...))
+}
+
+// Logf formats its arguments according to the format, analogous to fmt.Printf,
+// and records the text in the test log
+func (c *common) Logf(format string, args ...interface{}) {
+	c.log(fmt.Sprintf(format, args...))
+}
+
+// Error is equivalent to Log followed by Fail
+func (c *common) Error(args ...interface{}) {
+	c.log(fmt.Sprintln(args...))
+	c.Fail()
+}
+
+// Errorf is equivalent to Logf followed by Fail
+func (c *common) Errorf(format string, args ...interface{}) {
+	c.log(fmt.Sprintf(format, args...))
+	c.Fail()
+}
+
+// Fatal is equivalent to Log followed by FailNow
+func (c *common) Fatal(args ...interface{}) {
+	c.log(fmt.Sprintln(args...))
+	c.FailNow()
+}
+
+// Fatalf is equivalent to Logf followed by FailNow
+func (c *common) Fatalf(format string, args ...interface{}) {
+	c.log(fmt.Sprintf(format, args...))
+	c.FailNow()
+}
+
+// Skip is equivalent to Log followed by SkipNow
+func (c *common) Skip(args ...interface{}) {
+	c.log(fmt.Sprintln(args...))
+	c.SkipNow()
+}
+
+// Skipf is equivalent to Logf followed by SkipNow
+func (c *common) Skipf(format string, args ...interface{}) {
+	c.log(fmt.Sprintf(format, args...))
+	c.SkipNow()
+}
+
+// SkipNow marks the test as having been skipped and stops its execution
+// by calling runtime.Goexit. It must be called from the goroutine running the test
+func (c *common) SkipNow() {
+	c.mu.Lock()
+	c.skipped = true
+	c.finished = true
+	c.mu.Unlock()
+	runtime.Goexit()
+}
+
+// Skipped reports whether the test was skipped
+func (c *common) Skipped() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.skipped
+}
+
+// Helper marks the calling function as a test helper function:
+// when printing file and line information, that function will be skipped
+func (c *common) Helper() {
+	frames := c.r.ir.Callers()
+	if len(frames) == 0 || frames[0].Func == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.helpers == nil {
+		c.helpers = make(map[*fast.Stmt]bool)
+	}
+	c.helpers[frames[0].Func] = true
+}
+
+// Cleanup registers a function to be called when the test and all its subtests complete.
+// Cleanup functions will be called in last added, first called order
+func (c *common) Cleanup(f func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cleanups = append(c.cleanups, f)
+}
+
+// TempDir returns a temporary directory for the test to use.
+// The directory is automatically removed when the test and all its subtests complete
+func (c *common) TempDir() string {
+	c.mu.Lock()
+	if len(c.tempDir) == 0 {
+		dir, err := ioutil.TempDir("", sanitizeName(c.name))
+		if err != nil {
+			c.mu.Unlock()
+			c.Fatalf("TempDir: %v", err)
+		}
+		c.tempDir = dir
+		c.cleanups = append(c.cleanups, func() {
+			if err := os.RemoveAll(dir); err != nil {
+				c.Errorf("TempDir RemoveAll cleanup: %v", err)
+			}
+		})
+	}
+	c.tempDirSeq++
+	dir := filepath.Join(c.tempDir, fmt.Sprintf("%03d", c.tempDirSeq))
+	c.mu.Unlock()
+	if err := os.Mkdir(dir, 0777); err != nil {
+		c.Fatalf("TempDir: %v", err)
+	}
+	return dir
+}
+
+// Setenv calls os.Setenv(key, value) and uses Cleanup to
+// restore the environment variable to its original value after the test
+func (c *common) Setenv(key, value string) {
+	prev, ok := os.LookupEnv(key)
+	if err := os.Setenv(key, value); err != nil {
+		c.Fatalf("Setenv: %v", err)
+	}
+	if ok {
+		c.Cleanup(func() { os.Setenv(key, prev) })
+	} else {
+		c.Cleanup(func() { os.Unsetenv(key) })
+	}
+}
+
+// Chdir calls os.Chdir(dir) and uses Cleanup to
+// restore the current working directory to its original value after the test
+func (c *common) Chdir(dir string) {
+	prev, err := os.Getwd()
+	if err != nil {
+		c.Fatalf("Chdir: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		c.Fatalf("Chdir: %v", err)
+	}
+	c.Cleanup(func() {
+		if err := os.Chdir(prev); err != nil {
+			panic("testing.Chdir: " + err.Error())
+		}
+	})
+}
+
+// Context returns a context that is canceled just before Cleanup-registered functions are called
+func (c *common) Context() context.Context {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ctx == nil {
+		c.ctx, c.cancel = context.WithCancel(context.Background())
+	}
+	return c.ctx
+}
+
+// log adds file and line information to s, then prints it immediately if running in verbose mode,
+// otherwise stores it in the test output
+func (c *common) log(s string) {
+	s = c.decorate(s)
+	if !c.isRunning() {
+		panic("Log in goroutine after " + c.name + " has completed: " + s)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.r.chatty {
+		c.r.out.printf(c.name, "%s", s)
+	} else {
+		c.output = append(c.output, s...)
+	}
+}
+
+// isRunning returns true if the test goroutine did not terminate yet.
+// It may be executing the cleanup functions
+func (c *common) isRunning() bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// decorate prefixes s with the file and line of the interpreted code that invoked
+// the logging function, skipping the functions marked with Helper()
+func (c *common) decorate(s string) string {
+	file, line := "???", 1
+	if frame, ok := c.callerFrame(); ok {
+		file, line = filepath.Base(frame.Pos.Filename), frame.Pos.Line
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "    %s:%d: ", file, line)
+	lines := strings.Split(s, "\n")
+	if n := len(lines); n > 1 && lines[n-1] == "" {
+		lines = lines[:n-1]
+	}
+	for i, line := range lines {
+		if i > 0 {
+			buf.WriteString("\n        ")
+		}
+		buf.WriteString(line)
+	}
+	buf.WriteByte('\n')
+	return buf.String()
+}
+
+// callerFrame returns the innermost interpreted call frame that is not a helper
+func (c *common) callerFrame() (fast.Frame, bool) {
+	frames := c.r.ir.Callers()
+	for _, frame := range frames {
+		if frame.Pos.IsValid() && !c.isHelper(frame.Func) {
+			return frame, true
+		}
+	}
+	if len(frames) != 0 && frames[len(frames)-1].Pos.IsValid() {
+		return frames[len(frames)-1], true
+	}
+	return fast.Frame{}, false
+}
+
+func (c *common) isHelper(fun *fast.Stmt) bool {
+	for ; c != nil; c = c.parent {
+		c.mu.Lock()
+		helper := c.helpers[fun]
+		c.mu.Unlock()
+		if helper {
+			return true
+		}
+	}
+	return false
+}
+
+// runCleanup calls the functions registered with Cleanup(), in reverse order
+func (c *common) runCleanup() {
+	c.mu.Lock()
+	cancel := c.cancel
+	c.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	for {
+		c.mu.Lock()
+		n := len(c.cleanups)
+		if n == 0 {
+			c.mu.Unlock()
+			return
+		}
+		f := c.cleanups[n-1]
+		c.cleanups = c.cleanups[:n-1]
+		c.mu.Unlock()
+		f()
+	}
+}
+
+// tRunner executes fn in the current goroutine, which must be dedicated to the test.
+// The test stops if fn calls runtime.Goexit() or panics, then c.done is closed
+func (c *common) tRunner(fn func()) {
+	defer close(c.done)
+	defer func() {
+		if rec := recover(); rec != nil {
+			c.r.setPanic(rec)
+			for p := c; p != nil; p = p.parent {
+				p.mu.Lock()
+				p.failed = true
+				p.mu.Unlock()
+			}
+		}
+		c.mu.Lock()
+		c.finished = true
+		c.mu.Unlock()
+	}()
+	defer c.runCleanup()
+	fn()
+}
+
+// report writes the result of a test or subtest
+func (c *common) report() {
+	if c.parent == nil {
+		return
+	}
+	var status string
+	switch {
+	case c.Failed():
+		status = "FAIL"
+	case !c.r.chatty:
+		return
+	case c.Skipped():
+		status = "SKIP"
+	default:
+		status = "PASS"
+	}
+	c.flushToParent(c.name, "--- %s: %s (%s)\n", status, c.name, fmtDuration(c.duration))
+}
+
+// flushToParent writes the test result and its buffered output
+// either to the output stream or to the parent buffered output
+func (c *common) flushToParent(name, format string, args ...interface{}) {
+	c.mu.Lock()
+	text := fmt.Sprintf(format, args...) + string(c.output)
+	c.output = nil
+	c.mu.Unlock()
+
+	p := c.parent
+	if p.parent == nil || c.r.json {
+		c.r.out.updatef(name, "%s", indent(text, c.level-1))
+		return
+	}
+	p.mu.Lock()
+	p.output = append(p.output, indent(text, 1)...)
+	p.mu.Unlock()
+}
+
+// indent prefixes each line of text with 4*n spaces
+func indent(text string, n int) string {
+	if n <= 0 || len(text) == 0 {
+		return text
+	}
+	prefix := strings.Repeat("    ", n)
+	text = strings.TrimSuffix(text, "\n")
+	return prefix + strings.Replace(text, "\n", "\n"+prefix, -1) + "\n"
+}
+
+func fmtDuration(d time.Duration) string {
+	return fmt.Sprintf("%.2fs", d.Seconds())
+}
+
+// sanitizeName removes from a test name the characters that are not allowed
+// or not convenient in file names
+func sanitizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', '<', '>', ':', '"', '|', '?', '*':
+			return '_'
+		}
+		return r
+	}, name)
+}
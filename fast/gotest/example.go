@@ -0,0 +1,109 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * example.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package gotest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// example is an interpreted Example function with an output comment
+type example struct {
+	name      string
+	fn        func()
+	output    string
+	unordered bool
+}
+
+// runExample executes an example, capturing its standard output,
+// and compares the output with the expected one
+func (r *runner) runExample(eg *example) bool {
+	if r.chatty {
+		r.out.updatef(eg.name, "=== RUN   %s\n", eg.name)
+	}
+	start := time.Now()
+	stdout, finished, rec, err := captureStdout(eg.fn)
+	if err != nil {
+		r.out.updatef(eg.name, "--- FAIL: %s (%s)\n%v\n", eg.name, fmtDuration(0), err)
+		return false
+	}
+	dstr := fmtDuration(time.Since(start))
+
+	var fail string
+	got := strings.TrimSpace(strings.Replace(stdout, "\r\n", "\n", -1))
+	want := strings.TrimSpace(eg.output)
+	if eg.unordered {
+		if sortLines(got) != sortLines(want) && rec == nil {
+			fail = fmt.Sprintf("got:\n%s\nwant (unordered):\n%s\n", stdout, eg.output)
+		}
+	} else if got != want && rec == nil {
+		fail = fmt.Sprintf("got:\n%s\nwant:\n%s\n", got, want)
+	}
+	ok := len(fail) == 0 && finished && rec == nil
+	if !ok {
+		r.out.updatef(eg.name, "--- FAIL: %s (%s)\n%s", eg.name, dstr, fail)
+	} else if r.chatty {
+		r.out.updatef(eg.name, "--- PASS: %s (%s)\n", eg.name, dstr)
+	}
+	if rec != nil {
+		r.setPanic(rec)
+	}
+	return ok
+}
+
+// captureStdout calls fn in a new goroutine, redirecting os.Stdout to a pipe.
+// Returns the captured output, whether fn returned normally and the recovered panic, if any
+func captureStdout(fn func()) (stdout string, finished bool, rec interface{}, err error) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return "", false, nil, err
+	}
+	outC := make(chan string)
+	go func() {
+		var buf bytes.Buffer
+		io.Copy(&buf, pr)
+		pr.Close()
+		outC <- buf.String()
+	}()
+	save := os.Stdout
+	os.Stdout = pw
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer func() {
+			rec = recover()
+		}()
+		fn()
+		finished = true
+	}()
+	<-done
+
+	os.Stdout = save
+	pw.Close()
+	return <-outC, finished, rec, nil
+}
+
+func sortLines(output string) string {
+	lines := strings.Split(output, "\n")
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,330 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * fuzz.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package gotest
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// F is the type passed to interpreted Fuzz functions.
+// It is compatible with testing.F, but only executes the seed corpus:
+// the values passed to F.Add and the files in testdata/fuzz/FuzzXxx
+type F struct {
+	common
+	corpus     []corpusEntry
+	fuzzCalled bool
+}
+
+type corpusEntry struct {
+	name   string
+	values []interface{}
+}
+
+var supportedFuzzTypes = map[reflect.Type]bool{
+	reflect.TypeOf(""):         true,
+	reflect.TypeOf([]byte{}):   true,
+	reflect.TypeOf(false):      true,
+	reflect.TypeOf(float32(0)): true,
+	reflect.TypeOf(float64(0)): true,
+	reflect.TypeOf(int(0)):     true,
+	reflect.TypeOf(int8(0)):    true,
+	reflect.TypeOf(int16(0)):   true,
+	reflect.TypeOf(int32(0)):   true,
+	reflect.TypeOf(int64(0)):   true,
+	reflect.TypeOf(uint(0)):    true,
+	reflect.TypeOf(uint8(0)):   true,
+	reflect.TypeOf(uint16(0)):  true,
+	reflect.TypeOf(uint32(0)):  true,
+	reflect.TypeOf(uint64(0)):  true,
+}
+
+// Add adds the arguments to the seed corpus for the fuzz test
+func (f *F) Add(args ...interface{}) {
+	for _, arg := range args {
+		if t := reflect.TypeOf(arg); !supportedFuzzTypes[t] {
+			panic(fmt.Sprintf("testing: unsupported type to Add %v", t))
+		}
+	}
+	f.corpus = append(f.corpus, corpusEntry{
+		name:   fmt.Sprintf("seed#%d", len(f.corpus)),
+		values: args,
+	})
+}
+
+// Fuzz runs the fuzz function ff on each entry of the seed corpus,
+// as a subtest. ff must be a function with signature func(*testing.T, ...)
+// whose remaining parameters have types supported by F.Add
+func (f *F) Fuzz(ff interface{}) {
+	if f.fuzzCalled {
+		panic("testing: F.Fuzz called more than once")
+	}
+	f.fuzzCalled = true
+
+	fn := reflect.ValueOf(ff)
+	ftype := fn.Type()
+	if ftype.Kind() != reflect.Func || ftype.NumIn() < 1 || ftype.In(0) != reflect.TypeOf((*T)(nil)) || ftype.NumOut() != 0 {
+		panic("testing: F.Fuzz function must have signature func(*testing.T, ...)")
+	}
+	types := make([]reflect.Type, ftype.NumIn()-1)
+	for i := range types {
+		types[i] = ftype.In(i + 1)
+		if !supportedFuzzTypes[types[i]] {
+			panic(fmt.Sprintf("testing: unsupported type for fuzzing %v", types[i]))
+		}
+	}
+	files, err := readCorpusDir(filepath.Join(f.r.dir, "testdata", "fuzz", f.name))
+	if err != nil {
+		f.Fatal(err)
+	}
+	corpus := append(f.corpus, files...)
+	for _, e := range corpus {
+		if err := checkCorpusEntry(e, types); err != nil {
+			f.Fatal(err)
+		}
+	}
+	for _, e := range corpus {
+		name := f.name + "/" + e.name
+		if _, ok, _ := f.r.match.fullName(nil, name); !ok {
+			continue
+		}
+		args := make([]reflect.Value, len(e.values)+1)
+		for i, v := range e.values {
+			args[i+1] = reflect.ValueOf(v)
+		}
+		t := &T{}
+		t.init(f.r, &f.common, name)
+		f.r.runT(t, func(t *T) {
+			args[0] = reflect.ValueOf(t)
+			fn.Call(args)
+		})
+	}
+}
+
+func checkCorpusEntry(e corpusEntry, types []reflect.Type) error {
+	if len(e.values) != len(types) {
+		return fmt.Errorf("wrong number of values in corpus entry %s: %d, want %d", e.name, len(e.values), len(types))
+	}
+	for i, v := range e.values {
+		if t := reflect.TypeOf(v); t != types[i] {
+			return fmt.Errorf("mismatched types in corpus entry %s: %v, want %v", e.name, t, types[i])
+		}
+	}
+	return nil
+}
+
+// runF executes a fuzz target in a new goroutine and waits for it to complete
+func (r *runner) runF(f *F, fn func(f *F)) bool {
+	if r.chatty {
+		r.out.updatef(f.name, "=== RUN   %s\n", f.name)
+	}
+	start := time.Now()
+	go f.tRunner(func() {
+		fn(f)
+	})
+	<-f.done
+	f.duration = time.Since(start)
+	f.report()
+	return !f.Failed()
+}
+
+// readCorpusDir reads the seed corpus files in dir, written in "go test fuzz v1" format.
+// Returns nil if dir does not exist
+func readCorpusDir(dir string) ([]corpusEntry, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, nil
+	}
+	var entries []corpusEntry
+	for _, info := range infos {
+		if info.IsDir() {
+			continue
+		}
+		filename := filepath.Join(dir, info.Name())
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		values, err := parseCorpusFile(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal %q: %v", filename, err)
+		}
+		entries = append(entries, corpusEntry{name: info.Name(), values: values})
+	}
+	return entries, nil
+}
+
+const corpusHeader = "go test fuzz v1"
+
+// parseCorpusFile parses a seed corpus file, as
+//
+//	go test fuzz v1
+//	string("abc")
+//	int(7)
+func parseCorpusFile(data []byte) ([]interface{}, error) {
+	lines := strings.Split(string(data), "\n")
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != corpusHeader {
+		return nil, fmt.Errorf("missing version header %q", corpusHeader)
+	}
+	var values []interface{}
+	for _, line := range lines[1:] {
+		if line = strings.TrimSpace(line); len(line) == 0 {
+			continue
+		}
+		v, err := parseCorpusValue(line)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("must include at least one value")
+	}
+	return values, nil
+}
+
+func parseCorpusValue(line string) (interface{}, error) {
+	expr, err := parser.ParseExpr(line)
+	if err != nil {
+		return nil, err
+	}
+	call, ok := expr.(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 {
+		return nil, fmt.Errorf("expected a call expression, found %q", line)
+	}
+	var typename string
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		typename = fun.Name
+	case *ast.ArrayType:
+		if elt, ok := fun.Elt.(*ast.Ident); ok && fun.Len == nil && elt.Name == "byte" {
+			typename = "[]byte"
+		}
+	case *ast.SelectorExpr:
+		if pkg, ok := fun.X.(*ast.Ident); ok && pkg.Name == "math" {
+			typename = "math." + fun.Sel.Name
+		}
+	}
+	val, err := parseCorpusLiteral(call.Args[0])
+	if err != nil {
+		return nil, fmt.Errorf("%v in %q", err, line)
+	}
+	switch typename {
+	case "string":
+		if val.Kind() == constant.String {
+			return constant.StringVal(val), nil
+		}
+	case "[]byte":
+		if val.Kind() == constant.String {
+			return []byte(constant.StringVal(val)), nil
+		}
+	case "bool":
+		if val.Kind() == constant.Bool {
+			return constant.BoolVal(val), nil
+		}
+	case "float32", "float64":
+		if val = constant.ToFloat(val); val.Kind() == constant.Float {
+			f, _ := constant.Float64Val(val)
+			if typename == "float32" {
+				return float32(f), nil
+			}
+			return f, nil
+		}
+	case "math.Float32frombits":
+		if u, ok := constant.Uint64Val(val); ok && u <= math.MaxUint32 {
+			return math.Float32frombits(uint32(u)), nil
+		}
+	case "math.Float64frombits":
+		if u, ok := constant.Uint64Val(val); ok {
+			return math.Float64frombits(u), nil
+		}
+	case "int", "int8", "int16", "int32", "int64", "rune":
+		if i, ok := constant.Int64Val(constant.ToInt(val)); ok {
+			return convertInt(typename, reflect.ValueOf(i))
+		}
+	case "uint", "uint8", "uint16", "uint32", "uint64", "byte":
+		if u, ok := constant.Uint64Val(constant.ToInt(val)); ok {
+			return convertInt(typename, reflect.ValueOf(u))
+		}
+	default:
+		return nil, fmt.Errorf("unsupported type in %q", line)
+	}
+	return nil, fmt.Errorf("invalid value in %q", line)
+}
+
+var intTypes = map[string]reflect.Type{
+	"int":    reflect.TypeOf(int(0)),
+	"int8":   reflect.TypeOf(int8(0)),
+	"int16":  reflect.TypeOf(int16(0)),
+	"int32":  reflect.TypeOf(int32(0)),
+	"int64":  reflect.TypeOf(int64(0)),
+	"rune":   reflect.TypeOf(rune(0)),
+	"uint":   reflect.TypeOf(uint(0)),
+	"uint8":  reflect.TypeOf(uint8(0)),
+	"uint16": reflect.TypeOf(uint16(0)),
+	"uint32": reflect.TypeOf(uint32(0)),
+	"uint64": reflect.TypeOf(uint64(0)),
+	"byte":   reflect.TypeOf(byte(0)),
+}
+
+// convertInt converts v to the integer type typename, checking for overflow
+func convertInt(typename string, v reflect.Value) (interface{}, error) {
+	t := intTypes[typename]
+	conv := v.Convert(t)
+	if conv.Convert(v.Type()).Interface() != v.Interface() {
+		return nil, fmt.Errorf("value %v overflows %s", v.Interface(), typename)
+	}
+	return conv.Interface(), nil
+}
+
+// parseCorpusLiteral evaluates a literal, possibly negated, as -1 or '\x00' or "abc"
+func parseCorpusLiteral(expr ast.Expr) (constant.Value, error) {
+	switch expr := expr.(type) {
+	case *ast.BasicLit:
+		val := constant.MakeFromLiteral(expr.Value, expr.Kind, 0)
+		if val.Kind() == constant.Unknown {
+			return nil, fmt.Errorf("malformed literal %s", expr.Value)
+		}
+		return val, nil
+	case *ast.Ident:
+		switch expr.Name {
+		case "true":
+			return constant.MakeBool(true), nil
+		case "false":
+			return constant.MakeBool(false), nil
+		}
+	case *ast.UnaryExpr:
+		if expr.Op == token.SUB {
+			val, err := parseCorpusLiteral(expr.X)
+			if err != nil {
+				return nil, err
+			}
+			return constant.UnaryOp(token.SUB, val, 0), nil
+		}
+	case *ast.ParenExpr:
+		return parseCorpusLiteral(expr.X)
+	}
+	return nil, fmt.Errorf("unsupported literal")
+}
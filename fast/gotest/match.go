@@ -0,0 +1,201 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * match.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package gotest
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// matcher implements the -run, -bench and -skip filters,
+// with the same semantics as package "testing":
+// each filter is split by '/' into per-level regular expressions,
+// and by '|' into alternatives
+type matcher struct {
+	filter   [][]*regexp.Regexp // alternatives of per-level regexps. nil means match everything
+	skip     [][]*regexp.Regexp // nil means skip nothing
+	mu       sync.Mutex
+	subNames map[string]int // used to generate unique subtest names
+}
+
+func newMatcher(pattern, flag, skip string) (*matcher, error) {
+	m := &matcher{subNames: make(map[string]int)}
+	var err error
+	if len(pattern) != 0 {
+		if m.filter, err = splitRegexp(pattern, flag); err != nil {
+			return nil, err
+		}
+	}
+	if len(skip) != 0 {
+		if m.skip, err = splitRegexp(skip, "-test.skip"); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// fullName returns the full name of subtest 'subname' of test c,
+// made unique by appending "#NN" if needed. If c is nil or the root,
+// subname is a top-level test and it is returned unchanged.
+// Also returns whether the test matches the filters, and whether
+// it matches only partially i.e. some of its subtests may not match
+func (m *matcher) fullName(c *common, subname string) (name string, ok, partial bool) {
+	name = subname
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if c != nil && c.level > 0 {
+		name = m.unique(c.name, rewrite(subname))
+	}
+	elems := strings.Split(name, "/")
+	if ok, partial = matches(m.filter, elems); !ok {
+		return name, false, false
+	}
+	if len(m.skip) != 0 {
+		if skip, skipPartial := matches(m.skip, elems); skip && !skipPartial {
+			return name, false, false
+		}
+	}
+	return name, ok, partial
+}
+
+// unique creates a unique name for subtest subname of parent
+func (m *matcher) unique(parent, subname string) string {
+	name := parent + "/" + subname
+	empty := len(subname) == 0
+	for {
+		next, exists := m.subNames[name]
+		if !empty && !exists {
+			m.subNames[name] = 1
+			return name
+		}
+		m.subNames[name] = next + 1
+		name = fmt.Sprintf("%s#%02d", name, next)
+		empty = false
+	}
+}
+
+// matches checks whether the elements of a test name match one of the alternatives
+func matches(alternatives [][]*regexp.Regexp, elems []string) (ok, partial bool) {
+	if alternatives == nil {
+		return true, false
+	}
+	for _, filter := range alternatives {
+		ok = true
+		for i, elem := range elems {
+			if i >= len(filter) {
+				break
+			}
+			if !filter[i].MatchString(elem) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true, len(elems) < len(filter)
+		}
+	}
+	return false, false
+}
+
+// splitRegexp splits s by top-level '|' and '/', i.e. not inside [] or ()
+// and compiles each element
+func splitRegexp(s, flag string) ([][]*regexp.Regexp, error) {
+	var alternatives [][]string
+	var elems []string
+	cs, cp := 0, 0 // nesting level of [] and ()
+	for i := 0; i < len(s); {
+		switch s[i] {
+		case '[':
+			cs++
+		case ']':
+			if cs--; cs < 0 {
+				cs = 0
+			}
+		case '(':
+			if cs == 0 {
+				cp++
+			}
+		case ')':
+			if cs == 0 {
+				cp--
+			}
+		case '\\':
+			i++
+		case '/', '|':
+			if cs == 0 && cp == 0 {
+				elems = append(elems, s[:i])
+				if s[i] == '|' {
+					alternatives = append(alternatives, elems)
+					elems = nil
+				}
+				s = s[i+1:]
+				i = 0
+				continue
+			}
+		}
+		i++
+	}
+	alternatives = append(alternatives, append(elems, s))
+
+	ret := make([][]*regexp.Regexp, len(alternatives))
+	for i, elems := range alternatives {
+		for j, elem := range elems {
+			rx, err := regexp.Compile(elem)
+			if err != nil {
+				return nil, fmt.Errorf("testing: invalid regexp for element %d of %s (%q): %s", j, flag, elem, err)
+			}
+			ret[i] = append(ret[i], rx)
+		}
+	}
+	return ret, nil
+}
+
+// rewrite replaces white space in subtest names with underscores,
+// and quotes non-printable characters
+func rewrite(s string) string {
+	var b []byte
+	for _, r := range s {
+		switch {
+		case unicode.IsSpace(r):
+			b = append(b, '_')
+		case !strconv.IsPrint(r):
+			s := strconv.QuoteRune(r)
+			b = append(b, s[1:len(s)-1]...)
+		default:
+			b = append(b, string(r)...)
+		}
+	}
+	return string(b)
+}
+
+// isTest returns true if name is a test, benchmark, example or fuzz target
+// with the given prefix, i.e. it is not followed by a lowercase letter
+func isTest(name, prefix string) bool {
+	if !strings.HasPrefix(name, prefix) {
+		return false
+	}
+	if len(name) == len(prefix) {
+		return true
+	}
+	for _, r := range name[len(prefix):] {
+		return !unicode.IsLower(r)
+	}
+	return true
+}
@@ -0,0 +1,189 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * output.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package gotest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// printer writes test output in "go test" text format.
+// In verbose mode, it also inserts "=== NAME" lines when the output
+// of a test follows the output of a different test, as "go test -v" does
+type printer struct {
+	mu       sync.Mutex
+	w        io.Writer
+	lastName string
+}
+
+// printf writes the output of a test
+func (p *printer) printf(testName, format string, args ...interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.lastName) == 0 {
+		p.lastName = testName
+	} else if p.lastName != testName {
+		fmt.Fprintf(p.w, "=== NAME  %s\n", testName)
+		p.lastName = testName
+	}
+	fmt.Fprintf(p.w, format, args...)
+}
+
+// updatef writes a test status change, as "=== RUN" or "--- PASS"
+func (p *printer) updatef(testName, format string, args ...interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lastName = testName
+	fmt.Fprintf(p.w, format, args...)
+}
+
+// event is a JSON test event, in the same format produced by "go test -json"
+// i.e. by "go tool test2json"
+type event struct {
+	Time    *time.Time `json:",omitempty"`
+	Action  string
+	Package string   `json:",omitempty"`
+	Test    string   `json:",omitempty"`
+	Elapsed *float64 `json:",omitempty"`
+	Output  *string  `json:",omitempty"`
+}
+
+// converter reads test output in "go test -v" text format
+// and writes the corresponding JSON events, as "go tool test2json" does
+type converter struct {
+	enc  *json.Encoder
+	pkg  string
+	test string // test that produced the most recent output
+}
+
+func newConverter(w io.Writer, pkg string) *converter {
+	return &converter{enc: json.NewEncoder(w), pkg: pkg}
+}
+
+// convert reads all text from in and writes it as JSON events
+func (c *converter) convert(in io.Reader) {
+	c.emit("start", "", nil, nil)
+	r := bufio.NewReader(in)
+	for {
+		line, err := r.ReadString('\n')
+		if len(line) != 0 {
+			c.line(line)
+		}
+		if err != nil {
+			break
+		}
+	}
+}
+
+var resultActions = []struct {
+	prefix, action string
+}{
+	{"--- PASS: ", "pass"},
+	{"--- FAIL: ", "fail"},
+	{"--- SKIP: ", "skip"},
+	{"--- BENCH: ", "bench"},
+}
+
+func (c *converter) line(line string) {
+	for _, prefix := range []string{"=== RUN   ", "=== NAME  ", "=== PAUSE ", "=== CONT  "} {
+		if strings.HasPrefix(line, prefix) {
+			c.test = strings.TrimSpace(line[len(prefix):])
+			if prefix == "=== RUN   " {
+				c.emit("run", c.test, nil, nil)
+			}
+			c.emit("output", c.test, nil, &line)
+			return
+		}
+	}
+	trimmed := strings.TrimLeft(line, " ")
+	for _, result := range resultActions {
+		if !strings.HasPrefix(trimmed, result.prefix) {
+			continue
+		}
+		name, elapsed := parseResult(trimmed[len(result.prefix):])
+		c.emit("output", name, nil, &line)
+		if result.action != "bench" {
+			c.emit(result.action, name, elapsed, nil)
+		}
+		// following output belongs to the parent test, if any
+		c.test = ""
+		if i := strings.LastIndexByte(name, '/'); i >= 0 {
+			c.test = name[:i]
+		}
+		return
+	}
+	for _, result := range []struct{ prefix, action string }{
+		{"ok  \t", "pass"},
+		{"FAIL\t", "fail"},
+		{"?   \t", "skip"},
+	} {
+		if strings.HasPrefix(line, result.prefix) {
+			c.emit("output", "", nil, &line)
+			c.emit(result.action, "", parseElapsed(line), nil)
+			return
+		}
+	}
+	test := c.test
+	if line == "PASS\n" || line == "FAIL\n" {
+		test = ""
+	}
+	c.emit("output", test, nil, &line)
+}
+
+func (c *converter) emit(action, test string, elapsed *float64, output *string) {
+	now := time.Now()
+	c.enc.Encode(event{
+		Time:    &now,
+		Action:  action,
+		Package: c.pkg,
+		Test:    test,
+		Elapsed: elapsed,
+		Output:  output,
+	})
+}
+
+// parseResult parses "TestFoo (0.01s)\n"
+func parseResult(s string) (name string, elapsed *float64) {
+	s = strings.TrimSpace(s)
+	if i := strings.LastIndex(s, " ("); i >= 0 && strings.HasSuffix(s, "s)") {
+		if f, err := strconv.ParseFloat(s[i+2:len(s)-2], 64); err == nil {
+			return s[:i], &f
+		}
+	}
+	return s, nil
+}
+
+// parseElapsed parses the elapsed time at the end of "ok  \tpkg\t0.012s\n"
+func parseElapsed(line string) *float64 {
+	fields := strings.Split(strings.TrimSpace(line), "\t")
+	if len(fields) < 3 {
+		return nil
+	}
+	if fields = strings.Fields(fields[2]); len(fields) == 0 {
+		return nil
+	}
+	s := fields[0]
+	if f, err := strconv.ParseFloat(strings.TrimSuffix(s, "s"), 64); err == nil && strings.HasSuffix(s, "s") {
+		return &f
+	}
+	return nil
+}
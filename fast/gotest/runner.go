@@ -0,0 +1,533 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * runner.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package gotest
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/doc"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cosmos72/gomacro/base/genimport"
+	"github.com/cosmos72/gomacro/fast"
+)
+
+// Options contains the settings of "gomacro test".
+// They have the same meaning as the corresponding flags of "go test"
+type Options struct {
+	Run       string // run only tests, examples and fuzz targets matching this regexp
+	Skip      string // do not run tests, examples, fuzz targets and benchmarks matching this regexp
+	Bench     string // run benchmarks matching this regexp. Default: none
+	BenchTime string // run each benchmark for this duration, or for N iterations if written as Nx. Default: 1s
+	BenchMem  bool   // print memory allocation statistics for benchmarks
+	List      string // list tests, benchmarks, fuzz targets and examples matching this regexp, instead of running them
+	Count     int    // run each test, benchmark, fuzz target and example this many times. Default: 1
+	FailFast  bool   // do not start new tests after the first test failure
+	Short     bool   // tell long-running tests to shorten their run time
+	Verbose   bool   // print all tests as they are run, and their log output
+	JSON      bool   // print output as JSON events, same format as "go test -json"
+}
+
+type test struct {
+	name string
+	fn   func(t *T)
+}
+
+type benchmark struct {
+	name string
+	fn   func(b *B)
+}
+
+type fuzzTarget struct {
+	name string
+	fn   func(f *F)
+}
+
+// runner executes the tests of a package
+type runner struct {
+	ir          *fast.Interp
+	opts        Options
+	pkg         string // import path
+	dir         string
+	chatty      bool
+	json        bool
+	out         *printer
+	match       *matcher
+	benchMatch  *matcher // nil if benchmarks must not run
+	benchTime   benchTime
+	benchMaxLen int
+	root        common
+	tests       []test
+	benchmarks  []benchmark
+	fuzzTargets []fuzzTarget
+	examples    []*example
+	testMain    func(m *M)
+	ran         bool // true if at least one test, fuzz target or example was run
+	exitCode    int
+	panicMu     sync.Mutex
+	panicked    bool
+	panic       interface{}
+}
+
+// Main parses args, which are the flags accepted by "gomacro test" optionally followed by a directory,
+// then executes the tests of the package in such directory (default: the current directory)
+// printing results to standard output in the same format as "go test".
+// Returns false if some test failed
+func Main(ir *fast.Interp, args []string) (bool, error) {
+	opts, dir, err := ParseArgs(args)
+	if err != nil {
+		return false, err
+	}
+	return Test(ir, dir, opts, os.Stdout)
+}
+
+// ParseArgs parses the flags accepted by "gomacro test", optionally followed by a directory.
+// Each flag can also be written with the prefix "test." as in "-test.run"
+func ParseArgs(args []string) (opts Options, dir string, err error) {
+	fs := flag.NewFlagSet("gomacro test", flag.ContinueOnError)
+	for _, prefix := range []string{"", "test."} {
+		fs.StringVar(&opts.Run, prefix+"run", "", "run only tests, examples and fuzz targets matching `regexp`")
+		fs.StringVar(&opts.Skip, prefix+"skip", "", "do not list or run tests, examples, fuzz targets and benchmarks matching `regexp`")
+		fs.StringVar(&opts.Bench, prefix+"bench", "", "run only benchmarks matching `regexp`")
+		fs.StringVar(&opts.BenchTime, prefix+"benchtime", "1s", "run each benchmark for duration `d` or N times if `d` is of the form Nx")
+		fs.BoolVar(&opts.BenchMem, prefix+"benchmem", false, "print memory allocations for benchmarks")
+		fs.StringVar(&opts.List, prefix+"list", "", "list tests, examples, benchmarks and fuzz targets matching `regexp` then exit")
+		fs.IntVar(&opts.Count, prefix+"count", 1, "run tests, benchmarks, fuzz targets and examples `n` times")
+		fs.BoolVar(&opts.FailFast, prefix+"failfast", false, "do not start new tests after the first test failure")
+		fs.BoolVar(&opts.Short, prefix+"short", false, "run smaller test suite to save time")
+		fs.BoolVar(&opts.Verbose, prefix+"v", false, "verbose: print additional output")
+		fs.BoolVar(&opts.JSON, prefix+"json", false, "print output as JSON events")
+	}
+	for {
+		if err = fs.Parse(args); err != nil {
+			return opts, "", err
+		}
+		if args = fs.Args(); len(args) == 0 {
+			break
+		} else if len(dir) != 0 {
+			return opts, "", fmt.Errorf("gomacro test: too many arguments: %q", args)
+		}
+		dir = args[0]
+		args = args[1:]
+	}
+	if len(dir) == 0 {
+		dir = "."
+	}
+	return opts, dir, nil
+}
+
+// Test interprets the package in directory dir together with its *_test.go files,
+// then executes its tests, fuzz targets (seed corpus only), examples and benchmarks
+// as "go test" does, writing results to w in "go test" format.
+// Returns false if some test failed or the package could not be compiled.
+//
+// Interpreted code that imports "testing" receives a compatible implementation
+// provided by this package.
+func Test(ir *fast.Interp, dir string, opts Options, w io.Writer) (bool, error) {
+	start := time.Now()
+	r, err := newRunner(ir, dir, opts)
+	if err != nil {
+		return false, err
+	}
+	pkg, xtest, err := genimport.LoadTestSourceDir(dir)
+	if err != nil {
+		return false, err
+	}
+	r.pkg = pkg.Path
+
+	if r.json {
+		pr, pw, err := os.Pipe()
+		if err != nil {
+			return false, err
+		}
+		done := make(chan struct{})
+		conv := newConverter(w, r.pkg)
+		go func() {
+			conv.convert(pr)
+			pr.Close()
+			close(done)
+		}()
+		// in JSON mode, everything printed by tests must be converted too
+		saveStdout, saveStderr := os.Stdout, os.Stderr
+		os.Stdout, os.Stderr = pw, pw
+		defer func() {
+			os.Stdout, os.Stderr = saveStdout, saveStderr
+			pw.Close()
+			<-done
+		}()
+		w = pw
+	}
+	r.out = &printer{w: w}
+
+	testFiles := filterTestFiles(pkg.Files)
+	if len(testFiles) == 0 && xtest == nil {
+		r.out.updatef("", "?   \t%s\t[no test files]\n", r.pkg)
+		return true, nil
+	}
+	if err := r.load(pkg, testFiles, xtest); err != nil {
+		r.out.updatef("", "# %s\n%v\nFAIL\t%s [build failed]\n", r.pkg, err, r.pkg)
+		return false, nil
+	}
+	ok := true
+	if len(opts.List) != 0 {
+		r.list(opts.List)
+	} else if r.testMain != nil {
+		m := &M{r: r}
+		r.testMain(m)
+		ok = r.exitCode == 0
+	} else {
+		ok = r.runAll()
+	}
+	if rec, panicked := r.getPanic(); panicked {
+		r.out.updatef("", "panic: %v [recovered]\n", rec)
+		ok = false
+	}
+	status, suffix := "ok  ", ""
+	if !ok {
+		status = "FAIL"
+	} else if !r.ran && r.benchMatch == nil && len(opts.List) == 0 {
+		suffix = " [no tests to run]"
+	}
+	r.out.updatef("", "%s\t%s\t%.3fs%s\n", status, r.pkg, time.Since(start).Seconds(), suffix)
+	return ok, nil
+}
+
+func newRunner(ir *fast.Interp, dir string, opts Options) (*runner, error) {
+	if opts.Count <= 0 {
+		opts.Count = 1
+	}
+	r := &runner{
+		ir:     ir,
+		opts:   opts,
+		dir:    dir,
+		chatty: opts.Verbose || opts.JSON,
+		json:   opts.JSON,
+	}
+	r.root.init(r, nil, "")
+	var err error
+	if len(opts.BenchTime) == 0 {
+		opts.BenchTime = "1s"
+	}
+	if r.benchTime, err = parseBenchTime(opts.BenchTime); err != nil {
+		return nil, fmt.Errorf("gomacro test: invalid -benchtime: %v", err)
+	}
+	if r.match, err = newMatcher(opts.Run, "-test.run", opts.Skip); err != nil {
+		return nil, err
+	}
+	if len(opts.Bench) != 0 {
+		if r.benchMatch, err = newMatcher(opts.Bench, "-test.bench", opts.Skip); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func filterTestFiles(files []string) []string {
+	var tests []string
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			tests = append(tests, file)
+		}
+	}
+	return tests
+}
+
+// load interprets the package and its tests, then collects the tests to run
+func (r *runner) load(pkg *genimport.SourcePackage, testFiles []string, xtest *genimport.SourcePackage) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("panic: %v", rec)
+		}
+	}()
+	r.ir.OverrideImport("testing", r.bindings())
+
+	inner, err := r.ir.LoadPackage(pkg)
+	if err != nil {
+		return err
+	}
+	if err = r.discover(inner, testFiles); err != nil {
+		return err
+	}
+	if xtest != nil {
+		if inner, err = r.ir.LoadPackage(xtest); err != nil {
+			return err
+		}
+		if err = r.discover(inner, xtest.Files); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// discover collects the tests, benchmarks, fuzz targets, examples and TestMain
+// declared in files, in the same order as "go test"
+func (r *runner) discover(ir *fast.Interp, files []string) error {
+	fset := token.NewFileSet()
+	var errs []string
+	lookup := func(name string) interface{} {
+		if v := ir.ValueOf(name); v.IsValid() && v.CanInterface() {
+			return v.Interface()
+		}
+		return nil
+	}
+	for _, filename := range files {
+		file, err := parser.ParseFile(fset, filename, nil, parser.ParseComments)
+		if err != nil {
+			return err
+		}
+		for _, decl := range file.Decls {
+			fun, ok := decl.(*ast.FuncDecl)
+			if !ok || fun.Recv != nil {
+				continue
+			}
+			pos := fset.Position(fun.Pos())
+			name := fun.Name.Name
+			switch {
+			case name == "TestMain":
+				if r.testMain != nil {
+					errs = append(errs, fmt.Sprintf("%s: multiple definitions of TestMain", pos))
+				} else if fn, ok := lookup(name).(func(*M)); ok {
+					r.testMain = fn
+				} else {
+					errs = append(errs, fmt.Sprintf("%s: wrong signature for TestMain, must be: func TestMain(m *testing.M)", pos))
+				}
+			case isTest(name, "Test"):
+				if fn, ok := lookup(name).(func(*T)); ok {
+					r.tests = append(r.tests, test{name, fn})
+				} else {
+					errs = append(errs, fmt.Sprintf("%s: wrong signature for %s, must be: func %s(t *testing.T)", pos, name, name))
+				}
+			case isTest(name, "Benchmark"):
+				if fn, ok := lookup(name).(func(*B)); ok {
+					r.benchmarks = append(r.benchmarks, benchmark{name, fn})
+				} else {
+					errs = append(errs, fmt.Sprintf("%s: wrong signature for %s, must be: func %s(b *testing.B)", pos, name, name))
+				}
+			case isTest(name, "Fuzz"):
+				if fn, ok := lookup(name).(func(*F)); ok {
+					r.fuzzTargets = append(r.fuzzTargets, fuzzTarget{name, fn})
+				} else {
+					errs = append(errs, fmt.Sprintf("%s: wrong signature for %s, must be: func %s(f *testing.F)", pos, name, name))
+				}
+			}
+		}
+		for _, eg := range doc.Examples(file) {
+			if len(eg.Output) == 0 && !eg.EmptyOutput {
+				// examples without output comment are compiled but not executed
+				continue
+			}
+			name := "Example" + eg.Name
+			if fn, ok := lookup(name).(func()); ok {
+				r.examples = append(r.examples, &example{name, fn, eg.Output, eg.Unordered})
+			} else {
+				errs = append(errs, fmt.Sprintf("%s: wrong signature for %s, must be: func %s()", fset.Position(eg.Code.Pos()), name, name))
+			}
+		}
+	}
+	if len(errs) != 0 {
+		return errors.New(strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// list prints the names of tests, benchmarks, fuzz targets and examples matching regexp pattern
+func (r *runner) list(pattern string) {
+	rx, err := regexp.Compile(pattern)
+	if err != nil {
+		r.out.updatef("", "testing: invalid regexp in -test.list (%q): %s\n", pattern, err)
+		return
+	}
+	var names []string
+	for _, test := range r.tests {
+		names = append(names, test.name)
+	}
+	for _, bench := range r.benchmarks {
+		names = append(names, bench.name)
+	}
+	for _, fuzz := range r.fuzzTargets {
+		names = append(names, fuzz.name)
+	}
+	for _, eg := range r.examples {
+		names = append(names, eg.name)
+	}
+	for _, name := range names {
+		if rx.MatchString(name) {
+			r.out.updatef("", "%s\n", name)
+		}
+	}
+}
+
+// runAll executes tests, fuzz targets, examples and then benchmarks,
+// as testing.M.Run does
+func (r *runner) runAll() bool {
+	r.ran = false
+	ok := r.runTests()
+	if _, panicked := r.getPanic(); !panicked {
+		ok = r.runFuzzTargets() && ok
+	}
+	if _, panicked := r.getPanic(); !panicked {
+		ok = r.runExamples() && ok
+	}
+	if !r.ran && r.benchMatch == nil {
+		r.out.updatef("", "testing: warning: no tests to run\n")
+	}
+	if _, panicked := r.getPanic(); panicked {
+		r.exitCode = 2
+		return false
+	}
+	if ok {
+		ok = r.runBenchmarks()
+	}
+	if ok {
+		r.out.updatef("", "PASS\n")
+		r.exitCode = 0
+	} else {
+		r.out.updatef("", "FAIL\n")
+		r.exitCode = 1
+	}
+	return ok
+}
+
+// stop returns true if no more tests must be started
+func (r *runner) stop(ok bool) bool {
+	_, panicked := r.getPanic()
+	return panicked || (!ok && r.opts.FailFast)
+}
+
+func (r *runner) runTests() bool {
+	ok := true
+	for i := 0; i < r.opts.Count; i++ {
+		for _, test := range r.tests {
+			name, match, _ := r.match.fullName(nil, test.name)
+			if !match {
+				continue
+			}
+			r.ran = true
+			t := &T{}
+			t.init(r, &r.root, name)
+			ok = r.runT(t, test.fn) && ok
+			if r.stop(ok) {
+				return false
+			}
+		}
+	}
+	return ok
+}
+
+func (r *runner) runFuzzTargets() bool {
+	ok := true
+	for i := 0; i < r.opts.Count; i++ {
+		for _, fuzz := range r.fuzzTargets {
+			name, match, _ := r.match.fullName(nil, fuzz.name)
+			if !match {
+				continue
+			}
+			r.ran = true
+			f := &F{}
+			f.init(r, &r.root, name)
+			ok = r.runF(f, fuzz.fn) && ok
+			if r.stop(ok) {
+				return false
+			}
+		}
+	}
+	return ok
+}
+
+func (r *runner) runExamples() bool {
+	ok := true
+	for i := 0; i < r.opts.Count; i++ {
+		for _, eg := range r.examples {
+			if _, match, _ := r.match.fullName(nil, eg.name); !match {
+				continue
+			}
+			r.ran = true
+			ok = r.runExample(eg) && ok
+			if r.stop(ok) {
+				return false
+			}
+		}
+	}
+	return ok
+}
+
+func (r *runner) runBenchmarks() bool {
+	if r.benchMatch == nil {
+		return true
+	}
+	type matched struct {
+		benchmark
+		partial bool
+	}
+	var list []matched
+	for _, bench := range r.benchmarks {
+		if _, ok, partial := r.benchMatch.fullName(nil, bench.name); ok {
+			list = append(list, matched{bench, partial})
+			if n := len(benchmarkName(bench.name)); n > r.benchMaxLen {
+				r.benchMaxLen = n
+			}
+		}
+	}
+	if len(list) == 0 {
+		return true
+	}
+	r.out.updatef("", "goos: %s\ngoarch: %s\npkg: %s\n", runtime.GOOS, runtime.GOARCH, r.pkg)
+	ok := true
+	for i := 0; i < r.opts.Count; i++ {
+		for _, bench := range list {
+			b := &B{benchTime: r.benchTime}
+			b.init(r, &r.root, bench.name)
+			b.hasSub = bench.partial
+			r.runB(b, bench.fn)
+			ok = !b.Failed() && ok
+			if r.stop(ok) {
+				return false
+			}
+		}
+	}
+	return ok
+}
+
+// setPanic records the first panic raised by a test
+func (r *runner) setPanic(rec interface{}) {
+	r.panicMu.Lock()
+	if !r.panicked {
+		r.panicked, r.panic = true, rec
+	}
+	r.panicMu.Unlock()
+}
+
+// getPanic returns the first panic raised by a test
+func (r *runner) getPanic() (interface{}, bool) {
+	r.panicMu.Lock()
+	defer r.panicMu.Unlock()
+	return r.panic, r.panicked
+}
+
+func (r *runner) hasPanic() bool {
+	_, panicked := r.getPanic()
+	return panicked
+}
@@ -0,0 +1,146 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * testing.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package gotest
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/cosmos72/gomacro/imports"
+)
+
+// T is the type passed to interpreted Test functions.
+// It is compatible with testing.T
+type T struct {
+	common
+}
+
+// TB is the interface common to T, B and F.
+// It is compatible with testing.TB
+type TB interface {
+	Chdir(dir string)
+	Cleanup(func())
+	Context() context.Context
+	Error(args ...interface{})
+	Errorf(format string, args ...interface{})
+	Fail()
+	FailNow()
+	Failed() bool
+	Fatal(args ...interface{})
+	Fatalf(format string, args ...interface{})
+	Helper()
+	Log(args ...interface{})
+	Logf(format string, args ...interface{})
+	Name() string
+	Setenv(key, value string)
+	Skip(args ...interface{})
+	SkipNow()
+	Skipf(format string, args ...interface{})
+	Skipped() bool
+	TempDir() string
+}
+
+// M is the type passed to an interpreted TestMain function.
+// It is compatible with testing.M
+type M struct {
+	r *runner
+}
+
+// BenchmarkResult contains the results of a benchmark run
+type BenchmarkResult = testing.BenchmarkResult
+
+// Run runs f as a subtest of t called name, in a separate goroutine,
+// and blocks until f returns. Reports whether f succeeded
+func (t *T) Run(name string, f func(t *T)) bool {
+	t.mu.Lock()
+	t.hasSub = true
+	t.mu.Unlock()
+	testName, ok, _ := t.r.match.fullName(&t.common, name)
+	if !ok {
+		return true
+	}
+	sub := &T{}
+	sub.init(t.r, &t.common, testName)
+	return t.r.runT(sub, f)
+}
+
+// Parallel signals that this test may run in parallel with other tests.
+// Currently ignored: interpreted tests are always executed sequentially
+func (t *T) Parallel() {
+}
+
+// Deadline reports the time at which the test will exceed the timeout.
+// Interpreted tests have no timeout
+func (t *T) Deadline() (deadline time.Time, ok bool) {
+	return deadline, false
+}
+
+// Run runs the tests, fuzz targets, examples and benchmarks.
+// Returns an exit code to pass to os.Exit
+func (m *M) Run() int {
+	if m.r.runAll() {
+		return 0
+	}
+	return 1
+}
+
+// runT executes a test or subtest in a new goroutine and waits for it to complete
+func (r *runner) runT(t *T, f func(t *T)) bool {
+	if r.chatty {
+		r.out.updatef(t.name, "=== RUN   %s\n", t.name)
+	}
+	start := time.Now()
+	go t.tRunner(func() {
+		f(t)
+	})
+	<-t.done
+	t.duration = time.Since(start)
+	t.report()
+	if t.parent.level > 0 && r.hasPanic() {
+		// a test panicked: stop the parent test too
+		runtime.Goexit()
+	}
+	return !t.Failed()
+}
+
+// bindings returns the package that replaces "testing" in interpreted code
+func (r *runner) bindings() imports.Package {
+	return imports.Package{
+		Name: "testing",
+		Binds: map[string]reflect.Value{
+			"AllocsPerRun": reflect.ValueOf(testing.AllocsPerRun),
+			"Benchmark":    reflect.ValueOf(r.benchmark),
+			"CoverMode":    reflect.ValueOf(func() string { return "" }),
+			"Coverage":     reflect.ValueOf(func() float64 { return 0 }),
+			"Init":         reflect.ValueOf(func() {}),
+			"Short":        reflect.ValueOf(func() bool { return r.opts.Short }),
+			"Testing":      reflect.ValueOf(func() bool { return true }),
+			"Verbose":      reflect.ValueOf(func() bool { return r.chatty }),
+		},
+		Types: map[string]reflect.Type{
+			"B":               reflect.TypeOf((*B)(nil)).Elem(),
+			"BenchmarkResult": reflect.TypeOf((*BenchmarkResult)(nil)).Elem(),
+			"F":               reflect.TypeOf((*F)(nil)).Elem(),
+			"M":               reflect.TypeOf((*M)(nil)).Elem(),
+			"PB":              reflect.TypeOf((*PB)(nil)).Elem(),
+			"T":               reflect.TypeOf((*T)(nil)).Elem(),
+			"TB":              reflect.TypeOf((*TB)(nil)).Elem(),
+		},
+	}
+}
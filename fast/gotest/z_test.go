@@ -0,0 +1,225 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * z_test.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package gotest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cosmos72/gomacro/fast"
+)
+
+const srcPkg = `package p
+
+func Add(a, b int) int { return a + b }
+`
+
+const srcTest = `package p
+
+import (
+	"fmt"
+	"testing"
+)
+
+func check(t *testing.T, got, want int) {
+	t.Helper()
+	if got != want {
+		t.Errorf("got %d, want %d", got, want)
+	}
+}
+
+func TestAdd(t *testing.T) {
+	check(t, Add(1, 2), 3)
+	t.Log("hello")
+	t.Run("sub", func(t *testing.T) {
+		t.Logf("in %s", t.Name())
+	})
+}
+
+func TestFail(t *testing.T) {
+	check(t, Add(1, 2), 4)
+}
+
+func TestSkip(t *testing.T) {
+	t.Skip("skipping")
+}
+
+func BenchmarkAdd(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Add(i, i)
+	}
+}
+
+func FuzzAdd(f *testing.F) {
+	f.Add(1, 2)
+	f.Fuzz(func(t *testing.T, a, b int) {
+		if Add(a, b) != Add(b, a) {
+			t.Fatal("not commutative")
+		}
+	})
+}
+
+func ExampleAdd() {
+	fmt.Println(Add(2, 3))
+	// Output: 5
+}
+`
+
+const srcXTest = `package p_test
+
+import (
+	"testing"
+
+	"p"
+)
+
+func TestExt(t *testing.T) {
+	if p.Add(2, 2) != 4 {
+		t.Fatal("bad")
+	}
+}
+`
+
+const srcCorpus = "go test fuzz v1\nint(7)\nint(-3)\n"
+
+func writePkg(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "gomacro_gotest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"go.mod":                      "module p\n\ngo 1.13\n",
+		"p.go":                        srcPkg,
+		"p_test.go":                   srcTest,
+		"x_test.go":                   srcXTest,
+		"testdata/fuzz/FuzzAdd/entry": srcCorpus,
+	}
+	for name, content := range files {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func runTest(t *testing.T, args ...string) (string, bool) {
+	dir := writePkg(t)
+	defer os.RemoveAll(dir)
+	opts, _, err := ParseArgs(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	ok, err := Test(fast.New(), dir, opts, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.String(), ok
+}
+
+func checkContains(t *testing.T, out string, wants ...string) {
+	for _, want := range wants {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestVerbose(t *testing.T) {
+	out, ok := runTest(t, "-v")
+	if ok {
+		t.Errorf("expecting failure, got success")
+	}
+	checkContains(t, out,
+		"=== RUN   TestAdd\n    p_test.go:17: hello\n",
+		"=== RUN   TestAdd/sub\n    p_test.go:19: in TestAdd/sub\n",
+		"--- PASS: TestAdd (",
+		"    --- PASS: TestAdd/sub (",
+		"--- FAIL: TestFail (",
+		"    p_test.go:24: got 3, want 4\n",
+		"--- SKIP: TestSkip (",
+		"--- PASS: TestExt (",
+		"    --- PASS: FuzzAdd/seed#0 (",
+		"    --- PASS: FuzzAdd/entry (",
+		"--- PASS: ExampleAdd (",
+		"\nFAIL\n",
+	)
+	if strings.Contains(out, "BenchmarkAdd") {
+		t.Errorf("benchmarks should not run without -bench:\n%s", out)
+	}
+}
+
+func TestRunFilter(t *testing.T) {
+	out, ok := runTest(t, "-run", "Add/sub|Ext", "-bench", ".", "-benchtime", "3x")
+	if !ok {
+		t.Errorf("expecting success, got failure:\n%s", out)
+	}
+	checkContains(t, out, "BenchmarkAdd", "\t       3\t", "\nPASS\nok  \t")
+	if strings.Contains(out, "TestFail") || strings.Contains(out, "RUN") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	out, ok := runTest(t, "-json", "-run", "TestAdd")
+	if !ok {
+		t.Errorf("expecting success, got failure:\n%s", out)
+	}
+	var actions []string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		var e event
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", line, err)
+		}
+		if e.Action != "output" {
+			actions = append(actions, e.Action+" "+e.Test)
+		}
+	}
+	got := strings.Join(actions, ", ")
+	want := "start , run TestAdd, run TestAdd/sub, pass TestAdd/sub, pass TestAdd, pass "
+	if got != want {
+		t.Errorf("wrong JSON actions:\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestParseCorpus(t *testing.T) {
+	values, err := parseCorpusFile([]byte("go test fuzz v1\nstring(\"a\\n\")\n[]byte(\"xy\")\nbyte('z')\nuint16(65535)\nfloat32(-1.5)\nbool(true)\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{"a\n", []byte("xy"), byte('z'), uint16(65535), float32(-1.5), true}
+	if len(values) != len(want) {
+		t.Fatalf("got %d values, want %d", len(values), len(want))
+	}
+	for i, v := range values {
+		if !reflect.DeepEqual(v, want[i]) {
+			t.Errorf("value %d: got %#v, want %#v", i, v, want[i])
+		}
+	}
+	if _, err := parseCorpusFile([]byte("go test fuzz v1\nint8(200)\n")); err == nil {
+		t.Errorf("expecting overflow error, got success")
+	}
+}
@@ -0,0 +1,116 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * hooks.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package fast
+
+import (
+	"go/token"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// Hooks receives execution events from the interpreter, see Interp.SetHooks.
+// Any field can be nil. Hooks are invoked by the goroutine executing
+// interpreted code, thus they must be safe for concurrent use
+// if interpreted code starts goroutines
+type Hooks struct {
+	// Stmt is invoked before executing each interpreted statement.
+	// It makes execution much slower: interpreted code is single-stepped
+	Stmt func(pos token.Position)
+	// FuncEnter is invoked when an interpreted function starts executing
+	FuncEnter func(call CallInfo)
+	// FuncExit is invoked when an interpreted function returns or panics
+	FuncExit func(call CallInfo, panicking bool)
+	// Panic is invoked when interpreted code calls panic()
+	Panic func(value interface{}, pos token.Position)
+	// Recover is invoked when interpreted code stops a panic by calling recover()
+	Recover func(value interface{}, pos token.Position)
+	// GoStart and GoEnd are invoked by goroutines started by interpreted code
+	// when they start and end
+	GoStart func(goid uintptr)
+	GoEnd   func(goid uintptr)
+	// Import is invoked after a package is imported
+	Import func(path string)
+}
+
+// CallInfo describes an interpreted function entered or exited
+type CallInfo struct {
+	Func  string         // function name, as "main.fib", "main.T.Method" or "main.fib.func1". Empty if unknown
+	Pos   token.Position // position of the function, or of its first statement if unknown
+	Depth int            // Env.CallDepth of the function body
+}
+
+type hooks struct {
+	Hooks
+	g     *CompGlobals
+	calls sync.Map // token.Pos of function first statement -> CallInfo
+}
+
+func (g *IrGlobals) loadHooks() *hooks {
+	return (*hooks)(atomic.LoadPointer(&g.hooks))
+}
+
+// SetHooks installs h to receive execution events, or removes them if h is nil.
+// When no hooks are installed, execution is as fast as without hooks.
+//
+// Goroutines already executing interpreted code keep their current
+// Hooks.Stmt and function hooks until they start executing a new top-level expression
+func (ir *Interp) SetHooks(h *Hooks) {
+	var p unsafe.Pointer
+	if h != nil {
+		p = unsafe.Pointer(&hooks{Hooks: *h, g: ir.Comp.CompGlobals})
+	}
+	atomic.StorePointer(&ir.env.Run.IrGlobals.hooks, p)
+	ir.env.Run.applyDebugOp(DebugOpContinue)
+}
+
+// funcInfo returns the CallInfo of the function body executed by env
+func (h *hooks) funcInfo(env *Env, pos []token.Pos) CallInfo {
+	var first token.Pos
+	if len(pos) != 0 {
+		first = pos[0]
+	}
+	var info CallInfo
+	if cached, ok := h.calls.Load(first); ok {
+		info = cached.(CallInfo)
+	} else {
+		g := h.g
+		g.funcLock.Lock()
+		f := g.funcAt(first)
+		g.funcLock.Unlock()
+		if f != nil {
+			info = CallInfo{Func: f.name, Pos: g.Fileset.Position(f.pos)}
+		} else {
+			info = CallInfo{Pos: g.Fileset.Position(first)}
+		}
+		h.calls.Store(first, info)
+	}
+	info.Depth = env.CallDepth
+	return info
+}
+
+// position returns the position of the statement being executed by env
+func (h *hooks) position(env *Env) token.Position {
+	if ip := env.IP; ip >= 0 && ip < len(env.DebugPos) {
+		return h.g.Fileset.Position(env.DebugPos[ip])
+	}
+	return token.Position{}
+}
+
+func (run *Run) hasStmtHook() bool {
+	h := run.loadHooks()
+	return h != nil && h.Stmt != nil
+}
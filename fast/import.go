@@ -29,6 +29,7 @@ import (
 	"github.com/cosmos72/gomacro/base/paths"
 	"github.com/cosmos72/gomacro/base/reflect"
 	"github.com/cosmos72/gomacro/base/untyped"
+	"github.com/cosmos72/gomacro/imports"
 	xr "github.com/cosmos72/gomacro/xreflect"
 )
 
@@ -41,6 +42,14 @@ func (cg *CompGlobals) UnloadPackage(path string) {
 	delete(cg.KnownImports, path)
 }
 
+// OverrideImport makes interpreted code that imports 'path' use the compiled package 'pkg'
+// instead of the one found by the importer. Useful to provide alternative implementations
+// of existing packages, as the "testing" package used by "gomacro test"
+func (ir *Interp) OverrideImport(path string, pkg imports.Package) {
+	g := ir.Comp.CompGlobals
+	g.KnownImports[path] = g.NewImport(&genimport.PackageRef{Package: pkg, Path: path})
+}
+
 // ========================== switch to package ================================
 
 func (ir *Interp) ChangePackage(name, path string) {
@@ -86,6 +95,7 @@ func (ir *Interp) ChangePackage(name, path string) {
 	// env is at file/package level => its FileEnv is itself
 	ir.env.FileEnv = ir.env
 	ir.env.Run.Globals.PackagePath = path
+	ir.enableSession()
 }
 
 // convert *Interp to *Import. used to change package from 'ir'
@@ -144,15 +154,35 @@ func (c *Comp) ImportPackage(alias, path string) *Import {
 // If name is the empty string, it defaults to the identifier
 // specified in the package clause of the imported package
 func (c *Comp) ImportPackageOrError(alias, path string) (*Import, error) {
+	if err := c.checkImport(path); err != nil {
+		return nil, err
+	}
 	g := c.CompGlobals
 	imp := g.KnownImports[path]
 	if imp == nil {
+		mode := alias
+		if len(g.sourceImports) != 0 {
+			// dependencies of packages interpreted from source
+			// are also interpreted from source, unless they are compiled in
+			mode = "_s"
+		}
 		pkgref, err := g.Importer.ImportPackageOrError(
-			alias, path, g.Options&base.OptModuleImport != 0)
+			mode, path, g.Options&base.OptModuleImport != 0)
 		if err != nil {
 			return nil, err
 		}
-		imp = g.NewImport(pkgref)
+		if pkgref.Source != nil {
+			if imp, err = c.importSource(pkgref.Source); err != nil {
+				return nil, err
+			}
+		} else {
+			imp = g.NewImport(pkgref)
+		}
+	}
+	if alias == "_s" {
+		// import _s "path" only requests to interpret the package source.
+		// use the package name as alias
+		alias = ""
 	}
 	if alias == "." {
 		c.declDotImport0(imp)
@@ -166,6 +196,9 @@ func (c *Comp) ImportPackageOrError(alias, path string) (*Import, error) {
 		c.declImport0(alias, imp)
 	}
 	g.KnownImports[path] = imp
+	if h := g.loadHooks(); h != nil && h.Import != nil {
+		h.Import(path)
+	}
 	return imp, nil
 }
 
@@ -221,7 +254,11 @@ func (c *Comp) declDotImport0(imp *Import) {
 	if c.Types == nil {
 		c.Types = make(map[string]xr.Type)
 	}
+	p := c.policy
 	for name, typ := range imp.Types {
+		if !p.allowSymbol(imp.Path, name) {
+			continue
+		}
 		if t, exists := c.Types[name]; exists {
 			c.Warnf("redefined type: %v", t)
 		}
@@ -234,6 +271,9 @@ func (c *Comp) declDotImport0(imp *Import) {
 	var findexv []int
 
 	for name, bind := range imp.Binds {
+		if !p.allowSymbol(imp.Path, name) {
+			continue
+		}
 		// use c.CompBinds.NewBind() to prevent optimization VarBind -> IntBind
 		// also, if class == IntBind, we must preserve the address of impenv.Ints[idx]
 		// thus we must convert it into a VarBind (argh!)
@@ -291,6 +331,9 @@ func (g *CompGlobals) NewImport(pkgref *genimport.PackageRef) *Import {
 		imp.loadTypes(g, pkgref)
 		imp.loadBinds(g, pkgref)
 		g.loadProxies(pkgref.Proxies, imp.Types)
+		if imp.Path == "unsafe" {
+			imp.loadUnsafeBuiltins(g)
+		}
 	}
 	return imp
 }
@@ -398,6 +441,7 @@ func (imp *Import) selectorPlace(c *Comp, name string, opt PlaceOption) *Place {
 	if !ok {
 		c.Errorf("package %v %q has no symbol %s", imp.Name, imp.Path, name)
 	}
+	c.checkSymbol(imp, name)
 	class := bind.Desc.Class()
 	if bind.Desc.Index() != NoIndex {
 		switch class {
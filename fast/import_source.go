@@ -0,0 +1,152 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * import_source.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package fast
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io/ioutil"
+	"strconv"
+
+	"github.com/cosmos72/gomacro/base"
+	"github.com/cosmos72/gomacro/base/genimport"
+)
+
+// importSource interprets the source files of a package, and returns it as an *Import.
+// The package dependencies are imported recursively with Comp.ImportPackageOrError(),
+// thus the non-standard ones are also interpreted from source if needed.
+// See genimport.ImSource
+func (c *Comp) importSource(src *genimport.SourcePackage) (imp *Import, err error) {
+	g := c.CompGlobals
+	path := src.Path
+	if g.sourceImports[path] {
+		return nil, g.MakeRuntimeError("import cycle not allowed: package %q imports itself", path)
+	}
+	if g.sourceImports == nil {
+		g.sourceImports = make(map[string]bool)
+	}
+	g.sourceImports[path] = true
+
+	defer func() {
+		delete(g.sourceImports, path)
+		if imp == nil && err == nil {
+			err = g.MakeRuntimeError("error importing package %q from source: %v", path, recover())
+		}
+	}()
+	ir, expr, inits := c.compileSource(src)
+	ir.runSource(expr, inits)
+	imp = ir.asImport()
+	return imp, nil
+}
+
+// compileSource parses and compiles the source files of a package in a new inner Interp.
+// Returns the inner Interp, its compiled package-level declarations
+// and the names of its init() functions
+func (c *Comp) compileSource(src *genimport.SourcePackage) (ir *Interp, expr *Expr, inits []string) {
+	g := c.CompGlobals
+	saveFilepath, saveLine, saveOptions := g.Filepath, g.Line, g.Options
+	defer func() {
+		g.Filepath, g.Line, g.Options = saveFilepath, saveLine, saveOptions
+	}()
+	g.Options &^= base.OptShowPrompt | base.OptShowEval | base.OptShowEvalType
+
+	top := &Interp{c.TopComp(), g.topEnv}
+	ir = NewInnerInterp(top, src.Name, src.Path)
+	ir.env.UsedByClosure = true // do not free this *Env
+
+	file, inits := ir.parseSource(src)
+	return ir, ir.CompileNode(file), inits
+}
+
+// runSource executes the package-level declarations and the init() functions
+// returned by compileSource
+func (ir *Interp) runSource(expr *Expr, inits []string) {
+	ir.RunExpr(expr)
+
+	// execute init() functions in the order they appear
+	for _, name := range inits {
+		ir.ValueOf(name).Interface().(func())()
+	}
+}
+
+// parseSource parses the source files of a package, and merges them into a single *ast.File
+// in order to support declarations that refer to other files of the same package.
+// Also renames init() functions and returns their new names
+func (ir *Interp) parseSource(src *genimport.SourcePackage) (file *ast.File, inits []string) {
+	g := ir.Comp.CompGlobals
+	var imports, decls []ast.Decl
+	imported := make(map[string]bool)
+
+	for _, filepath := range src.Files {
+		bytes, err := ioutil.ReadFile(filepath)
+		if err != nil {
+			g.Errorf("error reading file %q: %v", filepath, err)
+		}
+		g.Filepath, g.Line = filepath, 0
+		for _, node := range ir.Comp.ParseBytes(bytes) {
+			switch decl := node.(type) {
+			case *ast.GenDecl:
+				if decl.Tok == token.PACKAGE {
+					continue
+				} else if decl.Tok == token.IMPORT {
+					// imports are per-file in Go, but we merge all files:
+					// omit duplicate imports, and move them before all declarations
+					// because dep.Sorter only sorts consecutive declarations
+					if decl.Specs = dedupImports(decl.Specs, imported); len(decl.Specs) != 0 {
+						imports = append(imports, decl)
+					}
+					continue
+				}
+			case *ast.FuncDecl:
+				if decl.Recv == nil && decl.Name.Name == "init" {
+					// a package can contain multiple init() functions
+					decl.Name.Name = fmt.Sprintf("init.%d", len(inits))
+					inits = append(inits, decl.Name.Name)
+				}
+			default:
+				g.Errorf("unexpected top-level node in file %q: %v <%T>", filepath, node, node)
+			}
+			decls = append(decls, node.(ast.Decl))
+		}
+	}
+	file = &ast.File{
+		Name:  &ast.Ident{Name: src.Name},
+		Decls: append(imports, decls...),
+	}
+	return file, inits
+}
+
+func dedupImports(specs []ast.Spec, imported map[string]bool) []ast.Spec {
+	var ret []ast.Spec
+	for _, spec := range specs {
+		if spec, ok := spec.(*ast.ImportSpec); ok {
+			key := spec.Path.Value
+			if path, err := strconv.Unquote(key); err == nil {
+				key = path
+			}
+			if spec.Name != nil {
+				key = spec.Name.Name + " " + key
+			}
+			if imported[key] {
+				continue
+			}
+			imported[key] = true
+		}
+		ret = append(ret, spec)
+	}
+	return ret
+}
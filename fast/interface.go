@@ -19,10 +19,14 @@ package fast
 import (
 	"fmt"
 	"go/ast"
+	"go/token"
 	r "reflect"
+	"sync"
 
 	"github.com/cosmos72/gomacro/base"
 	"github.com/cosmos72/gomacro/base/reflect"
+	etoken "github.com/cosmos72/gomacro/go/etoken"
+	"github.com/cosmos72/gomacro/go/typeutil"
 	xr "github.com/cosmos72/gomacro/xreflect"
 )
 
@@ -35,7 +39,14 @@ func (c *Comp) TypeInterface(node *ast.InterfaceType) xr.Type {
 	if node.Methods == nil || len(node.Methods.List) == 0 {
 		return c.TypeOfInterface()
 	}
-	types, names := c.TypeFields(node.Methods)
+	fields := node.Methods
+	if GENERICS_V3_GO() {
+		fields = interfaceMethodsAndEmbedded(fields)
+		if len(fields.List) == 0 {
+			return c.TypeOfInterface()
+		}
+	}
+	types, names := c.TypeFields(fields)
 
 	// parser returns embedded interfaces as unnamed fields
 	var methodnames []string
@@ -46,6 +57,10 @@ func (c *Comp) TypeInterface(node *ast.InterfaceType) xr.Type {
 			methodtypes = append(methodtypes, typ)
 		} else {
 			if typ.Kind() != r.Interface {
+				if GENERICS_V3_GO() {
+					// type element, only meaningful in type constraints. see Comp.typeConstraint()
+					continue
+				}
 				c.Errorf("embedded interface is not an interface: %v", typ)
 			}
 			embeddedtypes = append(embeddedtypes, typ)
@@ -56,6 +71,26 @@ func (c *Comp) TypeInterface(node *ast.InterfaceType) xr.Type {
 	return universe.InterfaceOf(pkg, methodnames, methodtypes, embeddedtypes)
 }
 
+// remove unions and ~T type elements from interface fields:
+// they are only meaningful in type constraints, see Comp.typeConstraint()
+func interfaceMethodsAndEmbedded(fields *ast.FieldList) *ast.FieldList {
+	list := make([]*ast.Field, 0, len(fields.List))
+	for _, field := range fields.List {
+		switch typ := field.Type.(type) {
+		case *ast.BinaryExpr:
+			if typ.Op == token.OR {
+				continue
+			}
+		case *ast.UnaryExpr:
+			if typ.Op == etoken.TILDE {
+				continue
+			}
+		}
+		list = append(list, field)
+	}
+	return &ast.FieldList{Opening: fields.Opening, List: list, Closing: fields.Closing}
+}
+
 // InterfaceProxy returns the proxy struct that implements a compiled interface
 func (c *Comp) InterfaceProxy(t xr.Type) r.Type {
 	ret := c.interf2proxy[t.ReflectType()]
@@ -223,6 +258,88 @@ func (g *CompGlobals) extractor(tin xr.Type) func(xr.Value) (xr.Value, xr.Type)
 	}
 }
 
+// interfaceAsserter executes at runtime the type assertions to a non-empty interface type,
+// and the corresponding cases in type switches.
+// It supports both compiled and interpreted types stored in interfaces,
+// and both compiled and interpreted interfaces as target,
+// by checking the method set of the dynamic type
+type interfaceAsserter struct {
+	c     *Comp
+	tout  xr.Type
+	rtout r.Type
+	lock  sync.Mutex   // protects conv
+	conv  typeutil.Map // map dynamic types.Type -> func(xr.Value) xr.Value converting to tout
+}
+
+func (c *Comp) interfaceAsserter(tout xr.Type) *interfaceAsserter {
+	return &interfaceAsserter{c: c, tout: tout, rtout: tout.ReflectType()}
+}
+
+// assert returns v converted to tout and true, if the dynamic type of v implements tout.
+// Otherwise returns v and false.
+// t is the dynamic type of v, if known: it is returned by extractors
+// for values wrapped in a proxy or emulated interface
+func (a *interfaceAsserter) assert(v xr.Value, t xr.Type) (xr.Value, bool) {
+	if v.Kind() == r.Interface {
+		v = v.Elem()
+	}
+	if !v.IsValid() || v == None {
+		// nil does not implement any interface
+		return v, false
+	}
+	if t == nil && a.rtout.Kind() == r.Interface && v.Type().Implements(a.rtout) {
+		// compiled type that implements compiled interface
+		return v.Convert(a.rtout), true
+	}
+	conv := a.converter(v.Type(), t)
+	if conv == nil {
+		return v, false
+	}
+	return conv(v), true
+}
+
+// return the function that converts values with dynamic type t to a.tout,
+// or nil if t does not implement a.tout
+func (a *interfaceAsserter) converter(rt r.Type, t xr.Type) func(xr.Value) xr.Value {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if t == nil {
+		// interpreted named types have a named reflect.Type, that is mapped back to the xr.Type
+		t = a.c.Universe.FromReflectType(rt)
+	}
+	gtype := t.GoType()
+	if conv := a.conv.At(gtype); conv != nil {
+		return conv.(func(xr.Value) xr.Value)
+	}
+	conv := a.makeConverter(t)
+	if conv != nil {
+		// do not cache failures: methods declared later on t may implement tout
+		a.conv.Set(gtype, conv)
+	}
+	return conv
+}
+
+func (a *interfaceAsserter) makeConverter(t xr.Type) func(xr.Value) xr.Value {
+	tout, rtout := a.tout, a.rtout
+	if t.Kind() == r.Interface || !t.Implements(tout) {
+		return nil
+	}
+	// need the compiler at run-time :(
+	switch {
+	case xr.IsEmulatedInterface(tout):
+		return a.c.converterToEmulatedInterface(t, tout)
+	case t.ReflectType().Implements(rtout):
+		return func(v xr.Value) xr.Value {
+			return v.Convert(rtout)
+		}
+	case a.c.interf2proxy[rtout] != nil:
+		return a.c.converterToProxy(t, tout)
+	default:
+		// compiled interface without a proxy: interpreted types cannot implement it
+		return nil
+	}
+}
+
 // return the error "\n\treason: t does not implement tinterf: missing method <method>"
 func interfaceMissingMethod(t, tinterf xr.Type) string {
 	var s string
@@ -23,6 +23,8 @@ import (
 	"io"
 	"os"
 	r "reflect"
+	"sync"
+	"sync/atomic"
 
 	"github.com/cosmos72/gomacro/base"
 	"github.com/cosmos72/gomacro/base/paths"
@@ -44,6 +46,7 @@ func New() *Interp {
 	top.env.UsedByClosure = true // do not free this *Env
 	file := NewInnerInterp(top, "main", "main")
 	file.env.UsedByClosure = true // do not free this *Env
+	file.enableSession()
 	return file
 }
 
@@ -84,6 +87,10 @@ func newTopInterp(path string) *Interp {
 			Run:   run,
 		},
 	}
+	cg.topEnv = ir.env
+	// packages without compiled wrappers can be interpreted from source
+	g.Importer.SourceImport = true
+
 	// tell xreflect about our packages "fast" and "main"
 	universe.CachePackage(types.NewPackage("fast", "fast"))
 	universe.CachePackage(types.NewPackage("main", "main"))
@@ -145,6 +152,16 @@ func (ir *Interp) SetDebugger(debugger Debugger) {
 	ir.env.Run.Debugger = debugger
 }
 
+// DebugInterp returns an Interp that compiles and executes code in the scope of env,
+// or nil if env was not compiled with OptDebugger.
+// Used by debuggers to evaluate expressions in any frame of the call stack
+func (env *Env) DebugInterp() *Interp {
+	if env == nil || env.DebugComp == nil {
+		return nil
+	}
+	return &Interp{env.DebugComp, env}
+}
+
 func (ir *Interp) Interrupt(os.Signal) {
 	ir.env.Run.interrupt()
 }
@@ -252,13 +269,49 @@ func (ir *Interp) ValueOf(name string) (value xr.Value) {
 
 // ===================== Eval(), EvalFile(), EvalReader() ============================
 
-// combined Parse + Compile + RunExpr1
+// evalLock serializes Eval, Eval1, ParseEvalPrint and Reload of interpreters
+// sharing the same CompGlobals, as Cmd.Watch calls Reload in another goroutine.
+// The goroutine holding it can acquire it again, for example when
+// interpreted code or a breakpoint condition calls Eval.
+// Other goroutines cannot: a goroutine started by interpreted code
+// that calls Eval deadlocks if the Eval holding the lock waits for it
+type evalLock struct {
+	mu    sync.Mutex
+	owner uintptr // goroutine holding mu. accessed atomically
+}
+
+// lock acquires the lock, unless the current goroutine already holds it.
+// Returns a function that releases it
+func (l *evalLock) lock() func() {
+	goid := gls.GoID()
+	if atomic.LoadUintptr(&l.owner) == goid {
+		return func() {}
+	}
+	l.mu.Lock()
+	atomic.StoreUintptr(&l.owner, goid)
+	return func() {
+		atomic.StoreUintptr(&l.owner, 0)
+		l.mu.Unlock()
+	}
+}
+
+// combined Parse + Compile + RunExpr1.
+// See Eval for concurrent calls
 func (ir *Interp) Eval1(src string) (xr.Value, xr.Type) {
+	defer ir.Comp.evalLock.lock()()
+	defer ir.commitInput()
 	return ir.RunExpr1(ir.Compile(src))
 }
 
-// combined Parse + Compile + RunExpr
+// combined Parse + Compile + RunExpr.
+//
+// Concurrent calls to Eval, Eval1, ParseEvalPrint and Reload are serialized:
+// they wait until the Eval in progress, if any, completes.
+// Thus a goroutine started by interpreted code must not call them
+// while the Eval that started it waits for the goroutine: it would deadlock
 func (ir *Interp) Eval(src string) ([]xr.Value, []xr.Type) {
+	defer ir.Comp.evalLock.lock()()
+	defer ir.commitInput()
 	return ir.RunExpr(ir.Compile(src))
 }
 
@@ -0,0 +1,316 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * limit.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package fast
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cosmos72/gomacro/base"
+	"github.com/cosmos72/gomacro/gls"
+	xr "github.com/cosmos72/gomacro/xreflect"
+)
+
+// Limits bounds the execution of Interp.EvalContext and Interp.RunExprContext.
+// Zero values mean unlimited
+type Limits struct {
+	// maximum number of statements to execute, including the ones
+	// executed by goroutines started by interpreted code.
+	// Statements are counted in small batches, thus the limit is approximate
+	MaxSteps int64
+	// maximum wall-clock time
+	Timeout time.Duration
+}
+
+// LimitKind identifies which limit stopped an evaluation
+type LimitKind int
+
+const (
+	LimitContext LimitKind = iota + 1 // the context was canceled or its deadline expired
+	LimitTimeout                      // Limits.Timeout elapsed
+	LimitSteps                        // Limits.MaxSteps statements were executed
+)
+
+func (k LimitKind) String() string {
+	switch k {
+	case LimitContext:
+		return "context"
+	case LimitTimeout:
+		return "timeout"
+	case LimitSteps:
+		return "steps"
+	default:
+		return fmt.Sprintf("LimitKind(%d)", int(k))
+	}
+}
+
+// LimitError is returned by Interp.EvalContext and Interp.RunExprContext
+// when execution is stopped by a limit
+type LimitError struct {
+	Kind    LimitKind
+	Limits  Limits
+	Elapsed time.Duration // wall-clock time between the start of evaluation and the stop
+	Err     error         // context.Canceled or context.DeadlineExceeded, or nil for LimitSteps
+}
+
+func (e *LimitError) Error() string {
+	switch e.Kind {
+	case LimitTimeout:
+		return fmt.Sprintf("evaluation stopped: time limit %v exceeded", e.Limits.Timeout)
+	case LimitSteps:
+		return fmt.Sprintf("evaluation stopped: step limit %d exceeded", e.Limits.MaxSteps)
+	default:
+		return fmt.Sprintf("evaluation stopped: %v", e.Err)
+	}
+}
+
+// Unwrap returns the context error, allowing errors.Is(err, context.Canceled)
+// and errors.Is(err, context.DeadlineExceeded)
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+// evalLimit tracks a single evaluation started by Interp.runContext,
+// and the goroutines it starts
+type evalLimit struct {
+	g         *IrGlobals
+	limits    Limits
+	start     time.Time
+	stepsLeft int64 // accessed atomically
+	stopped   int32 // accessed atomically
+	live      int32 // # of goroutines executing this evaluation. accessed atomically
+	done      chan struct{}
+	mu        sync.Mutex
+	err       *LimitError
+}
+
+// steps taken at once by a goroutine from evalLimit.stepsLeft
+const stepChunk = 1024
+
+// steps given to goroutines without a step limit
+const unlimitedSteps = 1 << 16
+
+func newEvalLimit(g *IrGlobals, limits Limits) *evalLimit {
+	return &evalLimit{
+		g:         g,
+		limits:    limits,
+		start:     time.Now(),
+		stepsLeft: limits.MaxSteps,
+		done:      make(chan struct{}),
+	}
+}
+
+// SetLimits sets the limits enforced by EvalContext and RunExprContext
+func (ir *Interp) SetLimits(limits Limits) {
+	ir.env.Run.IrGlobals.limits = limits
+}
+
+// Limits returns the limits enforced by EvalContext and RunExprContext
+func (ir *Interp) Limits() Limits {
+	return ir.env.Run.IrGlobals.limits
+}
+
+// EvalContext parses, compiles and executes src, as Eval does,
+// stopping execution if ctx is done or if a limit set by SetLimits is exceeded.
+// Goroutines started by src are stopped too.
+// Compile errors and panics are returned as errors,
+// while stopped executions return a *LimitError.
+//
+// Blocking operations, as channel receives or time.Sleep(),
+// are stopped only after they return
+func (ir *Interp) EvalContext(ctx context.Context, src string) (vals []xr.Value, types []xr.Type, err error) {
+	err = ir.runContext(ctx, func() {
+		vals, types = ir.Eval(src)
+	})
+	return vals, types, err
+}
+
+// RunExprContext executes e, as RunExpr does,
+// stopping execution if ctx is done or if a limit set by SetLimits is exceeded.
+// See EvalContext for details
+func (ir *Interp) RunExprContext(ctx context.Context, e *Expr) (vals []xr.Value, types []xr.Type, err error) {
+	err = ir.runContext(ctx, func() {
+		vals, types = ir.RunExpr(e)
+	})
+	return vals, types, err
+}
+
+func (ir *Interp) runContext(ctx context.Context, fun func()) (err error) {
+	main := ir.env.Run
+	l := newEvalLimit(main.IrGlobals, main.IrGlobals.limits)
+	if err := ctx.Err(); err != nil {
+		return &LimitError{Kind: LimitContext, Limits: l.limits, Err: err}
+	}
+	defer func() {
+		rec := recover()
+		l.exit()
+		if rec == nil {
+			return
+		} else if lerr := l.error(); lerr != nil {
+			err = lerr
+		} else if e, ok := rec.(error); ok {
+			err = e
+		} else {
+			err = errors.New(fmt.Sprint(rec))
+		}
+	}()
+	// top-level code is executed by ir.env.Run,
+	// while functions are executed by the Run of current goroutine
+	defer main.setLimit(l)()
+	if goid := gls.GoID(); main.goid != goid {
+		defer main.getRun4Goid(goid).setLimit(l)()
+	}
+	l.enter()
+	l.watch(ctx)
+	fun()
+	return nil
+}
+
+// setLimit sets the evalLimit of run.
+// Returns a function that restores the previous evalLimit
+func (run *Run) setLimit(l *evalLimit) func() {
+	saveLimit, saveSteps := run.limit, run.steps
+	run.limit, run.steps = l, 0
+	run.updateSteps()
+	return func() {
+		run.limit, run.steps = saveLimit, saveSteps
+		run.updateSteps()
+		if l.isStopped() {
+			// discard the pending signals
+			run.Signals.Sync = base.SigNone
+			run.Signals.Async = base.SigNone
+		}
+	}
+}
+
+// watch starts a goroutine that stops the evaluation
+// when ctx is done or when the time limit expires
+func (l *evalLimit) watch(ctx context.Context) {
+	var timer *time.Timer
+	var timeout <-chan time.Time
+	if l.limits.Timeout > 0 {
+		timer = time.NewTimer(l.limits.Timeout)
+		timeout = timer.C
+	}
+	cancel := ctx.Done()
+	if cancel == nil && timeout == nil {
+		return
+	}
+	go func() {
+		select {
+		case <-cancel:
+			l.stop(LimitContext, ctx.Err())
+		case <-timeout:
+			l.stop(LimitTimeout, context.DeadlineExceeded)
+		case <-l.done:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+}
+
+// enter is called when a goroutine starts executing the evaluation
+func (l *evalLimit) enter() {
+	atomic.AddInt32(&l.live, 1)
+}
+
+// exit is called when a goroutine finishes executing the evaluation
+func (l *evalLimit) exit() {
+	if atomic.AddInt32(&l.live, -1) == 0 {
+		close(l.done)
+	}
+}
+
+// goexit is deferred by goroutines started by interpreted code:
+// it silently terminates them if the evaluation was stopped
+func (l *evalLimit) goexit() {
+	defer l.exit()
+	if l.isStopped() {
+		if rec := recover(); rec != nil && rec != base.SigInterrupt {
+			panic(rec)
+		}
+	}
+}
+
+func (l *evalLimit) isStopped() bool {
+	return l != nil && atomic.LoadInt32(&l.stopped) != 0
+}
+
+func (l *evalLimit) error() *LimitError {
+	l.mu.Lock()
+	err := l.err
+	l.mu.Unlock()
+	return err
+}
+
+// stop records the limit that was hit, then interrupts
+// all the goroutines executing the evaluation
+func (l *evalLimit) stop(kind LimitKind, err error) {
+	l.mu.Lock()
+	if l.err == nil {
+		l.err = &LimitError{Kind: kind, Limits: l.limits, Elapsed: time.Since(l.start), Err: err}
+		atomic.StoreInt32(&l.stopped, 1)
+	}
+	l.mu.Unlock()
+
+	g := l.g
+	g.lock.Lock()
+	for _, run := range g.gls {
+		if run.limit == l {
+			run.Signals.Async = base.SigInterrupt
+		}
+	}
+	g.lock.Unlock()
+}
+
+// updateSteps enables counting the statements executed by run
+// only if it has a step limit or the profiler is active
+func (run *Run) updateSteps() {
+	l := run.limit
+	run.ExecFlags.SetSteps((l != nil && l.limits.MaxSteps > 0) || run.IrGlobals.loadProfiler() != nil)
+}
+
+// refillSteps is called when run.steps becomes negative:
+// takes more steps from the step limit, or stops the evaluation if exhausted
+func (run *Run) refillSteps() {
+	run.updateSteps() // the profiler may have been stopped meanwhile
+	steps := unlimitedSteps
+	if p := run.IrGlobals.loadProfiler(); p != nil {
+		p.sample(run)
+		steps = profileSteps
+	}
+	l := run.limit
+	if l == nil || l.limits.MaxSteps <= 0 || l.isStopped() {
+		run.steps = steps
+		return
+	}
+	left := atomic.AddInt64(&l.stepsLeft, -stepChunk) + stepChunk
+	if left <= 0 {
+		l.stop(LimitSteps, nil)
+		run.Signals.Async = base.SigInterrupt
+		run.steps = unlimitedSteps
+		return
+	}
+	if left > stepChunk {
+		left = stepChunk
+	}
+	run.steps += int(left)
+}
@@ -127,6 +127,10 @@ Recurse:
 		}
 		out.Set(i, child)
 	}
+	if ret, ok := in.(ReturnStmt); ok && c.coverage != nil && ret.X.Return.IsValid() {
+		// ReturnStmt.New() discards the position of "return": coverage needs it
+		c.coverage.setReturnPos(out.(ReturnStmt).X, ret.X.Return)
+	}
 	if debug {
 		c.Debugf("MacroExpandCodewalk: qq = %d, expanded to %v", quasiquoteDepth, out)
 	}
@@ -0,0 +1,191 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * policy.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package fast
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Policy restricts the packages and the package-level symbols
+// that interpreted code can use. The zero value allows everything.
+//
+// Packages are specified by import path, as "os" or "net/http".
+// A path ending in "/..." matches the package and all its subpackages,
+// as "golang.org/x/..."
+//
+// Symbols are specified as import path, dot, symbol name,
+// as "os.Getenv" or "net/http.Get". They include functions, variables,
+// constants and types, but not methods or struct fields:
+// a denylist is only as strong as the packages it leaves reachable,
+// in particular "reflect" and "unsafe".
+//
+// A policy that restricts packages or symbols also forbids passing an explicit
+// interpreter to Eval, EvalKeepUntyped, EvalType, MacroExpand, MacroExpand1,
+// MacroExpandCodeWalk and Parse, which would otherwise evaluate code
+// in an interpreter with a different policy
+type Policy struct {
+	// if not nil, only these packages can be imported
+	AllowPackages []string
+	// these packages cannot be imported
+	DenyPackages []string
+	// these symbols can be used, even if their package is denied or not allowed.
+	// The other symbols of the same packages cannot be used
+	AllowSymbols []string
+	// these symbols cannot be used
+	DenySymbols []string
+	// if true, packages not compiled into the interpreter cannot be imported
+	// by compiling them as plugins: they can only be interpreted from source
+	NoPlugin bool
+}
+
+// compiled Policy
+type policy struct {
+	Policy
+	allowSymbols map[string]map[string]bool // path -> name -> true
+	denySymbols  map[string]map[string]bool // path -> name -> true
+}
+
+// SetPolicy sets the policy enforced when compiling code.
+// Already compiled code is not affected.
+// Returns an error, and keeps the current policy, if p contains malformed symbols
+func (ir *Interp) SetPolicy(p Policy) error {
+	pol, err := newPolicy(p)
+	if err != nil {
+		return err
+	}
+	g := ir.Comp.CompGlobals
+	g.policy = pol
+	g.Importer.NoPlugin = p.NoPlugin
+	return nil
+}
+
+// Policy returns the policy set by SetPolicy
+func (ir *Interp) Policy() Policy {
+	if p := ir.Comp.CompGlobals.policy; p != nil {
+		return p.Policy
+	}
+	return Policy{}
+}
+
+func newPolicy(p Policy) (*policy, error) {
+	allow, err := splitSymbols(p.AllowSymbols)
+	if err != nil {
+		return nil, err
+	}
+	deny, err := splitSymbols(p.DenySymbols)
+	if err != nil {
+		return nil, err
+	}
+	return &policy{
+		Policy:       p,
+		allowSymbols: allow,
+		denySymbols:  deny,
+	}, nil
+}
+
+func splitSymbols(qualnames []string) (map[string]map[string]bool, error) {
+	if len(qualnames) == 0 {
+		return nil, nil
+	}
+	m := make(map[string]map[string]bool)
+	for _, qualname := range qualnames {
+		// the last '/' separates directories, the next '.' separates the symbol name
+		slash := strings.LastIndexByte(qualname, '/')
+		dot := strings.IndexByte(qualname[slash+1:], '.')
+		if dot <= 0 {
+			return nil, fmt.Errorf("invalid policy symbol %q, expecting import path, dot, symbol name as \"os.Getenv\"", qualname)
+		}
+		path, name := qualname[:slash+1+dot], qualname[slash+2+dot:]
+		if len(name) == 0 || strings.IndexByte(name, '.') >= 0 {
+			return nil, fmt.Errorf("invalid policy symbol %q, expecting import path, dot, symbol name as \"os.Getenv\"", qualname)
+		}
+		if m[path] == nil {
+			m[path] = make(map[string]bool)
+		}
+		m[path][name] = true
+	}
+	return m, nil
+}
+
+// matchPath returns true if path matches one of the patterns
+func matchPath(path string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if pattern == path {
+			return true
+		} else if prefix := strings.TrimSuffix(pattern, "/..."); prefix != pattern &&
+			(path == prefix || strings.HasPrefix(path, prefix+"/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// restricted returns true if the policy forbids something
+func (p *policy) restricted() bool {
+	return p != nil && (p.AllowPackages != nil || len(p.DenyPackages) != 0 ||
+		len(p.allowSymbols) != 0 || len(p.denySymbols) != 0)
+}
+
+// allowPackage returns true if all the symbols of package path can be used
+func (p *policy) allowPackage(path string) bool {
+	return !matchPath(path, p.DenyPackages) &&
+		(p.AllowPackages == nil || matchPath(path, p.AllowPackages))
+}
+
+// allowImport returns true if package path can be imported
+func (p *policy) allowImport(path string) bool {
+	return p == nil || p.allowSymbols[path] != nil || p.allowPackage(path)
+}
+
+// allowSymbol returns true if symbol 'name' of package path can be used
+func (p *policy) allowSymbol(path, name string) bool {
+	if p == nil {
+		return true
+	} else if p.denySymbols[path][name] {
+		return false
+	} else if names := p.allowSymbols[path]; names != nil {
+		return names[name]
+	}
+	return p.allowPackage(path)
+}
+
+// checkImport fails if the policy forbids importing package path
+func (c *Comp) checkImport(path string) error {
+	if !c.policy.allowImport(path) {
+		return c.MakeRuntimeError("import %q forbidden by policy", path)
+	}
+	return nil
+}
+
+// checkSymbol fails if the policy forbids using imp.name
+func (c *Comp) checkSymbol(imp *Import, name string) {
+	if !c.policy.allowSymbol(imp.Path, name) {
+		c.Errorf("use of %s.%s forbidden by policy", imp.Name, name)
+	}
+}
+
+// checkEnvFunc fails if the policy forbids passing an explicit interpreter
+// to an env function as Eval
+func (c *Comp) checkEnvFunc(fun *Expr) {
+	if c.policy.restricted() {
+		name := "function"
+		if fun.Sym != nil {
+			name = fun.Sym.Name
+		}
+		c.Errorf("passing an explicit interpreter to %s() forbidden by policy", name)
+	}
+}
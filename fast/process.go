@@ -0,0 +1,457 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * process.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package fast
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	r "reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cosmos72/gomacro/base"
+	"github.com/cosmos72/gomacro/imports"
+)
+
+// Process contains the process state seen by interpreted code.
+// Zero values mean the state of the real process
+type Process struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+	Args   []string
+	Env    []string // "key=value" pairs, as returned by os.Environ()
+	Dir    string   // working directory
+}
+
+// ExitError is the panic raised by os.Exit() and log.Fatal() in interpreted code
+// after Interp.SetProcess was called. Interpreted code cannot recover() it
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// ExitStatus returns the exit code passed to os.Exit()
+func (e *ExitError) ExitStatus() int {
+	return e.Code
+}
+
+// process is the state created by Interp.SetProcess
+type process struct {
+	stdin, stdout, stderr *os.File  // seen by interpreted code
+	outw, errw            io.Writer // where the output of interpreted code ends up
+	args                  []string
+	env                   map[string]string // nil if not virtualized
+	dir                   string            // empty if not virtualized
+	logger                *log.Logger
+	mu                    sync.Mutex
+	pipes                 []*os.File     // to close in Interp.CloseProcess
+	copying               sync.WaitGroup // goroutines copying from pipes
+}
+
+// os functions whose arguments at the specified indexes are file names
+var osPathArgs = map[string][]int{
+	"Chmod": {0}, "Chown": {0}, "Chtimes": {0}, "Create": {0}, "CreateTemp": {0},
+	"DirFS": {0}, "Lchown": {0}, "Link": {0, 1}, "Lstat": {0}, "Mkdir": {0},
+	"MkdirAll": {0}, "MkdirTemp": {0}, "Open": {0}, "OpenFile": {0}, "ReadDir": {0},
+	"ReadFile": {0}, "Readlink": {0}, "Remove": {0}, "RemoveAll": {0}, "Rename": {0, 1},
+	"Stat": {0}, "Symlink": {1}, "Truncate": {0}, "WriteFile": {0},
+}
+
+// SetProcess makes interpreted code see the standard input and output, command line
+// arguments, environment and working directory specified by p, instead of the ones
+// of the real process, and turns os.Exit() into a panic(&ExitError{...}).
+// It replaces the bindings of packages "fmt", "log" and "os" for this interpreter only,
+// thus it must be called before interpreted code imports them.
+// Compiled code, including other compiled packages, still uses the real process state.
+//
+// If Stdout or Stderr are not *os.File, interpreted code writing directly
+// to os.Stdout or os.Stderr, instead of using package fmt or log,
+// sees a pipe whose content is copied asynchronously: call CloseProcess
+// to wait until all the output is copied
+func (ir *Interp) SetProcess(p Process) error {
+	ir.CloseProcess()
+	proc := &process{args: p.Args}
+	g := ir.Comp.CompGlobals
+	err := proc.init(p)
+	if err != nil {
+		proc.close()
+		return err
+	}
+	g.process = proc
+	ir.OverrideImport("fmt", proc.fmtPackage())
+	ir.OverrideImport("log", proc.logPackage())
+	ir.OverrideImport("os", proc.osPackage())
+	return nil
+}
+
+// CloseProcess waits until the output written by interpreted code
+// to os.Stdout and os.Stderr is copied, then releases the resources
+// allocated by SetProcess. Interpreted code must not run after it
+func (ir *Interp) CloseProcess() {
+	g := ir.Comp.CompGlobals
+	if g.process != nil {
+		g.process.close()
+		g.process = nil
+	}
+}
+
+func (proc *process) init(p Process) error {
+	var err error
+	if proc.stdin, err = proc.inputFile(p.Stdin, os.Stdin); err != nil {
+		return err
+	}
+	if proc.stdout, proc.outw, err = proc.outputFile(p.Stdout, os.Stdout); err != nil {
+		return err
+	}
+	if proc.stderr, proc.errw, err = proc.outputFile(p.Stderr, os.Stderr); err != nil {
+		return err
+	}
+	if proc.args == nil {
+		proc.args = append([]string(nil), os.Args...)
+	}
+	if p.Env != nil {
+		proc.env = make(map[string]string)
+		for _, kv := range p.Env {
+			if i := strings.IndexByte(kv, '='); i > 0 {
+				proc.env[kv[:i]] = kv[i+1:]
+			}
+		}
+	}
+	if len(p.Dir) != 0 {
+		if proc.dir, err = filepath.Abs(p.Dir); err != nil {
+			return err
+		}
+	}
+	proc.logger = log.New(proc.errw, "", log.LstdFlags)
+	return nil
+}
+
+// inputFile returns the *os.File that interpreted code uses to read from 'in'
+func (proc *process) inputFile(in io.Reader, def *os.File) (*os.File, error) {
+	if in == nil {
+		return def, nil
+	} else if f, ok := in.(*os.File); ok {
+		return f, nil
+	}
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	proc.pipes = append(proc.pipes, pr)
+	go func() {
+		io.Copy(pw, in)
+		pw.Close()
+	}()
+	return pr, nil
+}
+
+// outputFile returns the *os.File that interpreted code uses to write into 'out',
+// and the io.Writer that package fmt and log must use to write into 'out'
+func (proc *process) outputFile(out io.Writer, def *os.File) (*os.File, io.Writer, error) {
+	if out == nil {
+		return def, def, nil
+	} else if f, ok := out.(*os.File); ok {
+		return f, f, nil
+	}
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	proc.pipes = append(proc.pipes, pw)
+	// the pipe content and the output of package fmt are written concurrently
+	w := &lockedWriter{w: out}
+	proc.copying.Add(1)
+	go func() {
+		defer proc.copying.Done()
+		io.Copy(w, pr)
+		pr.Close()
+	}()
+	return pw, w, nil
+}
+
+// lockedWriter serializes the calls to Write
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (lw *lockedWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	n, err := lw.w.Write(p)
+	lw.mu.Unlock()
+	return n, err
+}
+
+func (proc *process) close() {
+	for _, f := range proc.pipes {
+		f.Close()
+	}
+	proc.copying.Wait()
+}
+
+// writer replaces the pipes created by outputFile with the io.Writer they copy into
+func (proc *process) writer(w io.Writer) io.Writer {
+	if w == proc.stdout {
+		return proc.outw
+	} else if w == proc.stderr {
+		return proc.errw
+	}
+	return w
+}
+
+func (proc *process) exit(code int) {
+	panic(&ExitError{Code: code})
+}
+
+// copyPackage returns a copy of imports.Packages[path] with a private Binds map
+func copyPackage(path string) imports.Package {
+	pkg := imports.Packages[path]
+	binds := make(map[string]r.Value, len(pkg.Binds))
+	for name, bind := range pkg.Binds {
+		binds[name] = bind
+	}
+	pkg.Binds = binds
+	return pkg
+}
+
+func (proc *process) fmtPackage() imports.Package {
+	pkg := copyPackage("fmt")
+	for name, fun := range map[string]interface{}{
+		"Fprint": func(w io.Writer, a ...interface{}) (int, error) {
+			return fmt.Fprint(proc.writer(w), a...)
+		},
+		"Fprintf": func(w io.Writer, format string, a ...interface{}) (int, error) {
+			return fmt.Fprintf(proc.writer(w), format, a...)
+		},
+		"Fprintln": func(w io.Writer, a ...interface{}) (int, error) {
+			return fmt.Fprintln(proc.writer(w), a...)
+		},
+		"Print": func(a ...interface{}) (int, error) {
+			return fmt.Fprint(proc.outw, a...)
+		},
+		"Printf": func(format string, a ...interface{}) (int, error) {
+			return fmt.Fprintf(proc.outw, format, a...)
+		},
+		"Println": func(a ...interface{}) (int, error) {
+			return fmt.Fprintln(proc.outw, a...)
+		},
+		"Scan": func(a ...interface{}) (int, error) {
+			return fmt.Fscan(proc.stdin, a...)
+		},
+		"Scanf": func(format string, a ...interface{}) (int, error) {
+			return fmt.Fscanf(proc.stdin, format, a...)
+		},
+		"Scanln": func(a ...interface{}) (int, error) {
+			return fmt.Fscanln(proc.stdin, a...)
+		},
+	} {
+		pkg.Binds[name] = r.ValueOf(fun)
+	}
+	return pkg
+}
+
+func (proc *process) logPackage() imports.Package {
+	pkg := copyPackage("log")
+	l := proc.logger
+	for name, fun := range map[string]interface{}{
+		"Fatal": func(v ...interface{}) {
+			l.Output(2, fmt.Sprint(v...))
+			proc.exit(1)
+		},
+		"Fatalf": func(format string, v ...interface{}) {
+			l.Output(2, fmt.Sprintf(format, v...))
+			proc.exit(1)
+		},
+		"Fatalln": func(v ...interface{}) {
+			l.Output(2, fmt.Sprintln(v...))
+			proc.exit(1)
+		},
+		"Flags":     l.Flags,
+		"Output":    l.Output,
+		"Panic":     l.Panic,
+		"Panicf":    l.Panicf,
+		"Panicln":   l.Panicln,
+		"Prefix":    l.Prefix,
+		"Print":     l.Print,
+		"Printf":    l.Printf,
+		"Println":   l.Println,
+		"SetFlags":  l.SetFlags,
+		"SetOutput": l.SetOutput,
+		"SetPrefix": l.SetPrefix,
+		"Writer":    l.Writer,
+	} {
+		if _, ok := pkg.Binds[name]; ok {
+			pkg.Binds[name] = r.ValueOf(fun)
+		}
+	}
+	return pkg
+}
+
+func (proc *process) osPackage() imports.Package {
+	pkg := copyPackage("os")
+	binds := pkg.Binds
+	binds["Args"] = r.ValueOf(&proc.args).Elem()
+	binds["Stdin"] = r.ValueOf(&proc.stdin).Elem()
+	binds["Stdout"] = r.ValueOf(&proc.stdout).Elem()
+	binds["Stderr"] = r.ValueOf(&proc.stderr).Elem()
+	binds["Exit"] = r.ValueOf(proc.exit)
+	if proc.env != nil {
+		proc.bindEnv(binds)
+	}
+	if len(proc.dir) != 0 {
+		proc.bindDir(binds)
+	}
+	return pkg
+}
+
+func (proc *process) bindEnv(binds map[string]r.Value) {
+	lookup := func(key string) (string, bool) {
+		proc.mu.Lock()
+		value, ok := proc.env[key]
+		proc.mu.Unlock()
+		return value, ok
+	}
+	getenv := func(key string) string {
+		value, _ := lookup(key)
+		return value
+	}
+	for name, fun := range map[string]interface{}{
+		"Clearenv": func() {
+			proc.mu.Lock()
+			proc.env = make(map[string]string)
+			proc.mu.Unlock()
+		},
+		"Environ": func() []string {
+			proc.mu.Lock()
+			env := make([]string, 0, len(proc.env))
+			for key, value := range proc.env {
+				env = append(env, key+"="+value)
+			}
+			proc.mu.Unlock()
+			sort.Strings(env)
+			return env
+		},
+		"ExpandEnv": func(s string) string {
+			return os.Expand(s, getenv)
+		},
+		"Getenv":    getenv,
+		"LookupEnv": lookup,
+		"Setenv": func(key, value string) error {
+			if len(key) == 0 || strings.ContainsAny(key, "=\x00") {
+				return os.NewSyscallError("setenv", os.ErrInvalid)
+			}
+			proc.mu.Lock()
+			proc.env[key] = value
+			proc.mu.Unlock()
+			return nil
+		},
+		"Unsetenv": func(key string) error {
+			proc.mu.Lock()
+			delete(proc.env, key)
+			proc.mu.Unlock()
+			return nil
+		},
+	} {
+		binds[name] = r.ValueOf(fun)
+	}
+}
+
+func (proc *process) bindDir(binds map[string]r.Value) {
+	binds["Getwd"] = r.ValueOf(func() (string, error) {
+		proc.mu.Lock()
+		dir := proc.dir
+		proc.mu.Unlock()
+		return dir, nil
+	})
+	binds["Chdir"] = r.ValueOf(func(dir string) error {
+		dir = proc.path(dir)
+		info, err := os.Stat(dir)
+		if err == nil && !info.IsDir() {
+			err = &os.PathError{Op: "chdir", Path: dir, Err: os.ErrInvalid}
+		}
+		if err != nil {
+			return err
+		}
+		proc.mu.Lock()
+		proc.dir = dir
+		proc.mu.Unlock()
+		return nil
+	})
+	for name, indexes := range osPathArgs {
+		fun, ok := binds[name]
+		if !ok {
+			continue
+		}
+		indexes := indexes
+		binds[name] = r.MakeFunc(fun.Type(), func(args []r.Value) []r.Value {
+			for _, i := range indexes {
+				args[i] = r.ValueOf(proc.path(args[i].String()))
+			}
+			if fun.Type().IsVariadic() {
+				return fun.CallSlice(args)
+			}
+			return fun.Call(args)
+		})
+	}
+}
+
+// path resolves a relative file name against the working directory
+func (proc *process) path(name string) string {
+	if len(name) == 0 || filepath.IsAbs(name) {
+		return name
+	}
+	proc.mu.Lock()
+	dir := proc.dir
+	proc.mu.Unlock()
+	return filepath.Join(dir, name)
+}
+
+// goexitProcess is deferred by goroutines started by interpreted code
+// after Interp.SetProcess was called: if the goroutine called os.Exit(),
+// records the exit code and stops all the goroutines executing interpreted code.
+// The evaluation in progress returns the *ExitError
+func (run *Run) goexitProcess() {
+	if rec := recover(); rec != nil {
+		e, ok := rec.(*ExitError)
+		if !ok {
+			panic(rec)
+		} else if run.exit == nil {
+			// not stopped by another goroutine calling os.Exit()
+			run.IrGlobals.exitProcess(e)
+		}
+	}
+}
+
+// exitProcess interrupts all the goroutines executing interpreted code,
+// as evalLimit.stop() does. Each of them panics with the first *ExitError received
+func (g *IrGlobals) exitProcess(e *ExitError) {
+	g.lock.Lock()
+	for _, run := range g.gls {
+		if run.exit == nil {
+			run.exit = e
+		}
+		run.Signals.Async = base.SigInterrupt
+	}
+	g.lock.Unlock()
+}
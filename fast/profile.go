@@ -0,0 +1,436 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * profile.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package fast
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"go/ast"
+	"go/token"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// sampling period of the profiler
+const profilePeriod = 10 * time.Millisecond
+
+// statements executed between two checks for pending samples, while profiling
+const profileSteps = 256
+
+// profiler collects samples of the interpreted call stacks.
+// Each goroutine executing interpreted code records its own call stack
+// at the first statement it executes after each tick of the profiler
+type profiler struct {
+	tick    int64 // incremented every profilePeriod. accessed atomically
+	start   time.Time
+	done    chan struct{}
+	mu      sync.Mutex
+	samples map[string]*profileSample // key is the stack, converted to string
+	file    string                    // set by :profile start FILE
+}
+
+type profileSample struct {
+	stack []token.Pos // innermost first
+	count int64
+}
+
+// funcRange is the source range of a compiled function,
+// used to find the function name of sampled positions
+type funcRange struct {
+	pos, end token.Pos
+	outer    token.Pos // start of enclosing function, or token.NoPos
+	name     string
+}
+
+func (g *IrGlobals) loadProfiler() *profiler {
+	return (*profiler)(atomic.LoadPointer(&g.profiler))
+}
+
+// StartProfile starts sampling the call stacks of interpreted code
+// executed by all goroutines, about 100 times per second, until StopProfile.
+//
+// Only the time spent executing interpreted statements is sampled:
+// time spent inside compiled functions, or blocked, is not.
+// Goroutines started by interpreted code before StartProfile are not sampled
+func (ir *Interp) StartProfile() error {
+	g := ir.env.Run.IrGlobals
+	p := &profiler{
+		start:   time.Now(),
+		done:    make(chan struct{}),
+		samples: make(map[string]*profileSample),
+	}
+	if !atomic.CompareAndSwapPointer(&g.profiler, nil, unsafe.Pointer(p)) {
+		return errors.New("profiler already started")
+	}
+	go p.ticker()
+	ir.env.Run.updateSteps()
+	return nil
+}
+
+// StopProfile stops the profiler started by StartProfile and writes
+// the collected samples to out, in the gzip-compressed protocol buffer format
+// read by "go tool pprof": functions and lines are the interpreted ones
+func (ir *Interp) StopProfile(out io.Writer) error {
+	g := ir.env.Run.IrGlobals
+	p := (*profiler)(atomic.SwapPointer(&g.profiler, nil))
+	if p == nil {
+		return errors.New("profiler not started")
+	}
+	close(p.done)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return ir.Comp.CompGlobals.writeProfile(p, out)
+}
+
+// stopProfile stops the profiler and writes the collected samples to filepath
+func (ir *Interp) stopProfile(filepath string) error {
+	f, err := os.Create(filepath)
+	if err != nil {
+		return err
+	}
+	err = ir.StopProfile(f)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	return err
+}
+
+func (p *profiler) ticker() {
+	t := time.NewTicker(profilePeriod)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			atomic.AddInt64(&p.tick, 1)
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// sample records the call stack of run, if the profiler ticked since its last sample
+func (p *profiler) sample(run *Run) {
+	tick := atomic.LoadInt64(&p.tick)
+	if tick == run.profileTick {
+		return
+	}
+	run.profileTick = tick
+	var stack []token.Pos
+	var key []byte
+	run.callers(func(env *Env) {
+		if ip := env.IP; ip < len(env.DebugPos) && env.DebugPos[ip] != token.NoPos {
+			stack = append(stack, env.DebugPos[ip])
+			key = strconv.AppendInt(key, int64(env.DebugPos[ip]), 36)
+			key = append(key, ' ')
+		}
+	})
+	if len(stack) == 0 {
+		return
+	}
+	p.mu.Lock()
+	if s := p.samples[string(key)]; s != nil {
+		s.count++
+	} else {
+		p.samples[string(key)] = &profileSample{stack: stack, count: 1}
+	}
+	p.mu.Unlock()
+}
+
+// ============================ function names ================================
+
+// registerFunc remembers the name and source range of a function or method declaration,
+// or of a function literal if name is empty.
+// A redefined function replaces the previous one and its function literals
+func (c *Comp) registerFunc(node ast.Node, name string) {
+	g := c.CompGlobals
+	pos := node.Pos()
+	if pos == token.NoPos {
+		return
+	}
+	g.funcLock.Lock()
+	defer g.funcLock.Unlock()
+	i := g.funcIndex(pos)
+	var outer token.Pos
+	if len(name) == 0 {
+		if i < len(g.funcs) && g.funcs[i].pos == pos {
+			// function literal compiled again, keep its name
+			return
+		}
+		// function literal: name it as the Go toolchain does
+		name = c.FileComp().Path
+		if f := g.funcAt(pos); f != nil {
+			name, outer = f.name, f.pos
+		}
+		if g.funcLits == nil {
+			g.funcLits = make(map[string]int)
+		}
+		g.funcLits[name]++
+		name += ".func" + strconv.Itoa(g.funcLits[name])
+	} else {
+		name = c.FileComp().Path + "." + name
+		if old, ok := g.funcNames[name]; ok {
+			g.forgetFunc(name, old)
+		} else if g.funcNames == nil {
+			g.funcNames = make(map[string]token.Pos)
+		}
+		g.funcNames[name] = pos
+	}
+	f := funcRange{pos: pos, end: node.End(), outer: outer, name: name}
+	i = g.funcIndex(pos)
+	if i < len(g.funcs) && g.funcs[i].pos == pos {
+		g.funcs[i] = f
+		return
+	}
+	g.funcs = append(g.funcs, funcRange{})
+	copy(g.funcs[i+1:], g.funcs[i:])
+	g.funcs[i] = f
+}
+
+// forgetFunc removes the function name declared at pos, and its function literals.
+// g.funcLock must be held
+func (g *CompGlobals) forgetFunc(name string, pos token.Pos) {
+	i := g.funcIndex(pos)
+	if i == len(g.funcs) || g.funcs[i].pos != pos {
+		return
+	}
+	end := g.funcs[i].end
+	j := i + 1
+	for j < len(g.funcs) && g.funcs[j].pos < end {
+		j++
+	}
+	g.funcs = append(g.funcs[:i], g.funcs[j:]...)
+	// restart numbering function literals from .func1
+	prefix := name + ".func"
+	for outer := range g.funcLits {
+		if outer == name || (strings.HasPrefix(outer, prefix) && len(outer) > len(prefix) &&
+			outer[len(prefix)] >= '0' && outer[len(prefix)] <= '9') {
+			delete(g.funcLits, outer)
+		}
+	}
+}
+
+// methodName returns the name of a method, as "T.Method" or "(*T).Method"
+func methodName(funcdecl *ast.FuncDecl) string {
+	recv := funcdecl.Recv.List[0].Type
+	if star, ok := recv.(*ast.StarExpr); ok {
+		if ident, ok := star.X.(*ast.Ident); ok {
+			return "(*" + ident.Name + ")." + funcdecl.Name.Name
+		}
+	} else if ident, ok := recv.(*ast.Ident); ok {
+		return ident.Name + "." + funcdecl.Name.Name
+	}
+	return funcdecl.Name.Name
+}
+
+// funcIndex returns the index of the first function in g.funcs starting at or after pos
+func (g *CompGlobals) funcIndex(pos token.Pos) int {
+	return sort.Search(len(g.funcs), func(i int) bool {
+		return g.funcs[i].pos >= pos
+	})
+}
+
+// funcAt returns the innermost function containing pos, or nil.
+// g.funcLock must be held
+func (g *CompGlobals) funcAt(pos token.Pos) *funcRange {
+	// g.funcs is sorted by position, and its ranges are either nested or disjoint:
+	// start from the last function starting at or before pos, then visit its outer functions
+	i := g.funcIndex(pos+1) - 1
+	for i >= 0 {
+		f := &g.funcs[i]
+		if pos < f.end {
+			return f
+		} else if f.outer == token.NoPos {
+			break
+		}
+		outer := f.outer
+		if i = g.funcIndex(outer); i == len(g.funcs) || g.funcs[i].pos != outer || outer >= f.pos {
+			break
+		}
+	}
+	return nil
+}
+
+// ============================ pprof output ==================================
+
+// writeProfile writes the samples collected by p in pprof format
+func (g *CompGlobals) writeProfile(p *profiler, out io.Writer) error {
+	g.funcLock.Lock()
+	defer g.funcLock.Unlock()
+	w := profileWriter{
+		g:         g,
+		strings:   map[string]int64{"": 0},
+		strtable:  []string{""},
+		locations: make(map[token.Pos]uint64),
+		functions: make(map[string]uint64),
+	}
+	var prof protobuf
+	// sample_type
+	prof.message(1, w.valueType("samples", "count"))
+	prof.message(1, w.valueType("cpu", "nanoseconds"))
+	period := int64(profilePeriod)
+	for _, s := range p.samples {
+		ids := make([]uint64, len(s.stack))
+		for i, pos := range s.stack {
+			ids[i] = w.location(pos)
+		}
+		var sample protobuf
+		sample.packedUint64(1, ids)
+		sample.packedInt64(2, []int64{s.count, s.count * period})
+		prof.message(2, &sample)
+	}
+	prof.data = append(prof.data, w.locs.data...)
+	prof.data = append(prof.data, w.funcs.data...)
+	for _, str := range w.strtable {
+		prof.string(6, str)
+	}
+	prof.int64(9, p.start.UnixNano())
+	prof.int64(10, int64(time.Since(p.start)))
+	prof.message(11, w.valueType("cpu", "nanoseconds"))
+	prof.int64(12, period)
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(prof.data)
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	_, err := out.Write(buf.Bytes())
+	return err
+}
+
+type profileWriter struct {
+	g         *CompGlobals
+	strings   map[string]int64
+	strtable  []string
+	locations map[token.Pos]uint64
+	functions map[string]uint64
+	locs      protobuf // encoded Profile.location
+	funcs     protobuf // encoded Profile.function
+}
+
+func (w *profileWriter) str(s string) int64 {
+	if i, ok := w.strings[s]; ok {
+		return i
+	}
+	i := int64(len(w.strtable))
+	w.strings[s] = i
+	w.strtable = append(w.strtable, s)
+	return i
+}
+
+func (w *profileWriter) valueType(typ, unit string) *protobuf {
+	var vt protobuf
+	vt.int64(1, w.str(typ))
+	vt.int64(2, w.str(unit))
+	return &vt
+}
+
+// location returns the id of the Location for pos, creating it if needed
+func (w *profileWriter) location(pos token.Pos) uint64 {
+	if id, ok := w.locations[pos]; ok {
+		return id
+	}
+	position := w.g.Fileset.Position(pos)
+	name, start := "<toplevel>", position
+	if f := w.g.funcAt(pos); f != nil {
+		name, start = f.name, w.g.Fileset.Position(f.pos)
+	}
+	fid, ok := w.functions[name+"\x00"+position.Filename]
+	if !ok {
+		fid = uint64(len(w.functions) + 1)
+		w.functions[name+"\x00"+position.Filename] = fid
+		var fun protobuf
+		fun.uint64(1, fid)
+		fun.int64(2, w.str(name))
+		fun.int64(3, w.str(name))
+		fun.int64(4, w.str(position.Filename))
+		fun.int64(5, int64(start.Line))
+		w.funcs.message(5, &fun)
+	}
+	id := uint64(len(w.locations) + 1)
+	w.locations[pos] = id
+	var line, loc protobuf
+	line.uint64(1, fid)
+	line.int64(2, int64(position.Line))
+	loc.uint64(1, id)
+	loc.message(4, &line)
+	w.locs.message(4, &loc)
+	return id
+}
+
+// protobuf is a minimal encoder for the protocol buffer wire format
+type protobuf struct {
+	data []byte
+}
+
+func (b *protobuf) varint(x uint64) {
+	for x >= 0x80 {
+		b.data = append(b.data, byte(x)|0x80)
+		x >>= 7
+	}
+	b.data = append(b.data, byte(x))
+}
+
+func (b *protobuf) tag(field int, wiretype int) {
+	b.varint(uint64(field)<<3 | uint64(wiretype))
+}
+
+func (b *protobuf) uint64(field int, x uint64) {
+	b.tag(field, 0)
+	b.varint(x)
+}
+
+func (b *protobuf) int64(field int, x int64) {
+	b.uint64(field, uint64(x))
+}
+
+func (b *protobuf) bytes(field int, data []byte) {
+	b.tag(field, 2)
+	b.varint(uint64(len(data)))
+	b.data = append(b.data, data...)
+}
+
+func (b *protobuf) string(field int, s string) {
+	b.bytes(field, []byte(s))
+}
+
+func (b *protobuf) message(field int, msg *protobuf) {
+	b.bytes(field, msg.data)
+}
+
+func (b *protobuf) packedUint64(field int, xs []uint64) {
+	var packed protobuf
+	for _, x := range xs {
+		packed.varint(x)
+	}
+	b.bytes(field, packed.data)
+}
+
+func (b *protobuf) packedInt64(field int, xs []int64) {
+	var packed protobuf
+	for _, x := range xs {
+		packed.varint(uint64(x))
+	}
+	b.bytes(field, packed.data)
+}
@@ -24,6 +24,7 @@ import (
 	"unicode/utf8"
 	"unsafe"
 
+	"github.com/cosmos72/gomacro/base/reflect"
 	xr "github.com/cosmos72/gomacro/xreflect"
 )
 
@@ -40,7 +41,7 @@ func (c *Comp) Range(node *ast.RangeStmt, labels []string) {
 	erange := c.Expr1(node.X, nil)
 	t := erange.Type
 	if erange.Untyped() {
-		t = erange.DefaultType()
+		t = c.rangeUntypedType(node, erange)
 		erange.ConstTo(t)
 	}
 	var jump rangeJump
@@ -73,6 +74,11 @@ func (c *Comp) Range(node *ast.RangeStmt, labels []string) {
 		c.rangeMap(node, erange, &jump)
 	case xr.String:
 		c.rangeString(node, erange, &jump)
+	case xr.Int, xr.Int8, xr.Int16, xr.Int32, xr.Int64,
+		xr.Uint, xr.Uint8, xr.Uint16, xr.Uint32, xr.Uint64, xr.Uintptr:
+		c.rangeInt(node, erange, &jump)
+	case xr.Func:
+		c.rangeFunc(node, erange, &jump)
 	default:
 		c.Errorf("cannot range over %v <%v>", node.X, t)
 	}
@@ -82,6 +88,25 @@ func (c *Comp) Range(node *ast.RangeStmt, labels []string) {
 	c = c.popEnvIfFlag(&nbinds, flag)
 }
 
+// rangeUntypedType returns the type of an untyped constant range expression.
+// Go specs: if the range expression is an untyped integer constant and the iteration variable
+// is preexisting, the iteration values have the type of the iteration variable.
+// Otherwise they have the default type of the constant
+func (c *Comp) rangeUntypedType(node *ast.RangeStmt, erange *Expr) xr.Type {
+	t := erange.DefaultType()
+	if node.Tok != token.ASSIGN || node.Key == nil || !reflect.IsCategory(t.Kind(), r.Int) {
+		return t
+	}
+	if ident, ok := node.Key.(*ast.Ident); ok && ident.Name == "_" {
+		return t
+	}
+	tkey := c.Place(node.Key).Type
+	if !reflect.IsCategory(tkey.Kind(), r.Int, r.Uint) {
+		c.Errorf("cannot range over %v with iteration variable %v <%v>: not an integer", node.X, node.Key, tkey)
+	}
+	return tkey
+}
+
 func (c *Comp) rangeChan(node *ast.RangeStmt, erange *Expr, jump *rangeJump) {
 	t := erange.Type
 	telem := t.Elem()
@@ -93,6 +118,7 @@ func (c *Comp) rangeChan(node *ast.RangeStmt, erange *Expr, jump *rangeJump) {
 	placekey, _ := c.rangeVars(node, telem, nil)
 
 	jump.Start = c.Code.Len()
+	c.rangeVarPerIteration(node)
 
 	if placekey == nil {
 		c.append(func(env *Env) (Stmt, *Env) {
@@ -176,6 +202,7 @@ func (c *Comp) rangeSlice(node *ast.RangeStmt, erange *Expr, jump *rangeJump) {
 	}
 
 	jump.Start = c.Code.Len()
+	c.rangeVarPerIteration(node)
 
 	// compile comparison against range length
 	ekey := c.GetPlace(placekey)
@@ -246,6 +273,7 @@ func (c *Comp) rangeString(node *ast.RangeStmt, erange *Expr, jump *rangeJump) {
 	}
 
 	jump.Start = c.Code.Len()
+	c.rangeVarPerIteration(node)
 
 	if placekey != nil {
 		c.SetPlace(placekey, token.ASSIGN, c.Bind(bindnext))
@@ -330,6 +358,69 @@ func (c *Comp) rangeString(node *ast.RangeStmt, erange *Expr, jump *rangeJump) {
 	})
 }
 
+func (c *Comp) rangeInt(node *ast.RangeStmt, erange *Expr, jump *rangeJump) {
+	t := erange.Type
+	if node.Value != nil {
+		c.Pos = node.Value.Pos()
+		c.Errorf("range over %v <%v> permits only one iteration variable", node.X, t)
+	}
+	// save range limit in an unnamed bind
+	bindlen := c.DeclVar0("", nil, erange)
+
+	// unnamed bind, contains the iteration counter.
+	// kept separate from the iteration variable, because user code can modify the latter
+	bindcount := c.DeclVar0("", t, nil)
+
+	placekey, _ := c.rangeVars(node, t, nil)
+
+	jump.Start = c.Code.Len()
+	c.rangeVarPerIteration(node)
+
+	// compile comparison against range limit
+	ecount := c.Bind(bindcount)
+	lss := &ast.BinaryExpr{X: node.X, OpPos: node.X.Pos(), Op: token.LSS, Y: node.X} // for error messages
+	funcond := c.BinaryExpr1(lss, ecount, c.Bind(bindlen)).WithFun().(func(*Env) bool)
+	c.append(func(env *Env) (Stmt, *Env) {
+		var ip int
+		if funcond(env) {
+			ip = env.IP + 1
+		} else {
+			ip = jump.Break
+		}
+		env.IP = ip
+		return env.Code[ip], env
+	})
+	if placekey != nil {
+		c.SetPlace(placekey, token.ASSIGN, ecount)
+	}
+
+	// compile the body
+	c.Block(node.Body)
+
+	// "continue" is a jump to the increment below
+	jump.Continue = c.Code.Len()
+
+	// increment counter
+	c.Pos = node.End() - 1
+	one := c.exprUntypedLit(untypedOne.Kind, untypedOne.Val)
+	c.SetPlace(bindcount.AsVar(0, PlaceSettable).AsPlace(), token.ADD, one)
+
+	// jump back to comparison
+	c.append(func(env *Env) (Stmt, *Env) {
+		ip := jump.Start
+		env.IP = ip
+		return env.Code[ip], env
+	})
+}
+
+// rangeVarPerIteration compiles a statement that gives each iteration
+// its own key and value variables, as specified by go1.22
+func (c *Comp) rangeVarPerIteration(node *ast.RangeStmt) {
+	if node.Tok == token.DEFINE && c.Globals.LoopVarPerIteration() {
+		c.loopVarPerIteration()
+	}
+}
+
 // rangeVars compiles the key and value iteration variables in a for-range
 func (c *Comp) rangeVars(node *ast.RangeStmt, tkey xr.Type, tval xr.Type) (*Place, *Place) {
 	place := [2]*Place{nil, nil}
@@ -0,0 +1,331 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * range_func.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package fast
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+
+	"github.com/cosmos72/gomacro/base"
+	xr "github.com/cosmos72/gomacro/xreflect"
+)
+
+// compile-time information about a range-over-func loop.
+// Its body is compiled as a function literal, i.e. the "yield" function
+// passed to the iterator: it is stored in the body's FuncInfo and used
+// to compile break, continue, goto, return and defer inside the body
+type rangeFuncInfo struct {
+	Loop   *LoopInfo         // the loop labels
+	State  *Bind             // unnamed bind in the loop *Env, contains the loop *rangeFuncState
+	Exits  []*ast.BranchStmt // break, continue and goto that jump from the body to outside the loop
+	Return bool              // true if the body contains return statements
+	Defers bool              // true if the body contains defer statements
+}
+
+// runtime state of a range-over-func loop
+type rangeFuncState struct {
+	Next   int      // what to do after the iterator returns: rangeFuncBreak, rangeFuncReturn or rangeFuncExit + index of Exits
+	Done   bool     // true if the body returned false
+	Exited bool     // true if the iterator returned
+	Defers []func() // functions deferred by the body. executed when the function containing the loop returns
+}
+
+const (
+	rangeFuncBreak  = iota // continue after the loop
+	rangeFuncReturn        // return from the function containing the loop
+	rangeFuncExit          // first index of rangeFuncInfo.Exits
+)
+
+var (
+	rangeFuncContinuedAfterFalse = fmt.Errorf("runtime error: range function continued iteration after function for loop body returned false")
+	rangeFuncContinuedAfterExit  = fmt.Errorf("runtime error: range function continued iteration after whole loop exit")
+)
+
+// rangeFunc compiles a range over an iterator function,
+// i.e. func(yield func() bool), func(yield func(K) bool) or func(yield func(K, V) bool)
+func (c *Comp) rangeFunc(node *ast.RangeStmt, erange *Expr, jump *rangeJump) {
+	t := erange.Type
+	if t.NumIn() != 1 || t.NumOut() != 0 || t.IsVariadic() {
+		c.Errorf("cannot range over %v <%v>: func must be func(yield func(...) bool)", node.X, t)
+	}
+	tyield := t.In(0)
+	if tyield.Kind() != xr.Func || tyield.NumIn() > 2 || tyield.NumOut() != 1 ||
+		tyield.Out(0).Kind() != xr.Bool || tyield.IsVariadic() {
+		c.Errorf("cannot range over %v <%v>: func must be func(yield func(...) bool)", node.X, t)
+	}
+	var tkey, tval xr.Type
+	if n := tyield.NumIn(); n > 0 {
+		tkey = tyield.In(0)
+		if n > 1 {
+			tval = tyield.In(1)
+		}
+	}
+	funiter := erange.AsX1()
+
+	// unnamed bind, contains the loop *rangeFuncState
+	bindstate := c.NewBind("", VarBind, c.TypeOfInterface())
+	idxstate := bindstate.Desc.Index()
+	rf := &rangeFuncInfo{Loop: c.Loop, State: bindstate}
+
+	funyield := c.rangeFuncBody(node, rf, tyield, tkey, tval)
+
+	jump.Start = c.Code.Len()
+	jump.Continue = jump.Start
+
+	// if the loop is inside the body of another range-over-func loop,
+	// the defers must be passed to such loop
+	o, upn := c.enclosingFunc()
+	var outerstate func(*Env) *rangeFuncState
+	if o != nil && o.Func.rangeFunc != nil {
+		outerstate = rangeFuncStateAt(o.Func.rangeFunc.State, upn+o.UpCost)
+	} else if rf.Defers {
+		c.Code.WithDefers = true
+	}
+	// jump targets after the iterator returns. will be filled below
+	ips := make([]int, rangeFuncExit+len(rf.Exits))
+	hasdefers := rf.Defers
+
+	c.append(func(env *Env) (Stmt, *Env) {
+		state := &rangeFuncState{}
+		env.Vals[idxstate] = xr.ValueOf(state)
+		iter := funiter(env)
+		yield := funyield(env)
+		if hasdefers {
+			completed := false
+			defer func() {
+				if !completed {
+					// the body or the iterator panicked. run the body defers now
+					runDefers(state.Defers)
+				}
+			}()
+			iter.Call([]xr.Value{yield})
+			completed = true
+		} else {
+			iter.Call([]xr.Value{yield})
+		}
+		state.Exited = true
+
+		var ip int
+		if state.Next == rangeFuncBreak {
+			ip = jump.Break
+		} else {
+			ip = ips[state.Next]
+		}
+		env.IP = ip
+		if len(state.Defers) == 0 {
+			return env.Code[ip], env
+		} else if outerstate != nil {
+			outer := outerstate(env)
+			outer.Defers = append(outer.Defers, state.Defers...)
+			return env.Code[ip], env
+		}
+		defers := state.Defers
+		run := env.Run
+		run.InstallDefer = func() {
+			runDefers(defers)
+		}
+		run.Signals.Sync = base.SigDefer
+		return run.Interrupt, env
+	})
+
+	if rf.Return {
+		// compile "return" inside the body
+		ips[rangeFuncReturn] = c.Code.Len()
+		c.returnFromRangeFunc()
+	}
+
+	// compile break, continue and goto that jump from the body to outside the loop
+	for i, exit := range rf.Exits {
+		ips[rangeFuncExit+i] = c.Code.Len()
+		c.Pos = exit.Pos()
+		c.Branch(exit)
+	}
+}
+
+// rangeFuncBody compiles the body of a range-over-func loop as a function literal,
+// and returns a function that creates it at runtime
+func (c *Comp) rangeFuncBody(node *ast.RangeStmt, rf *rangeFuncInfo, tyield, tkey, tval xr.Type) func(*Env) xr.Value {
+	cf := NewComp(c, nil)
+
+	nin := tyield.NumIn()
+	params := make([]*Bind, nin)
+	for i := range params {
+		// unnamed param, copied below to the iteration variable if present
+		params[i] = cf.NewBind("", VarBind, tyield.In(i))
+	}
+	cf.Pos = node.Pos()
+	result := cf.DeclVar0("", c.TypeOfBool(), nil)
+
+	info := &FuncInfo{
+		Param:     params,
+		Result:    []*Bind{result},
+		Labels:    funcLabels(node.Body),
+		rangeFunc: rf,
+	}
+	cf.Func = info
+
+	idxstate := rf.State.Desc.Index()
+	// Go specs: the iterator must not call yield after it returned false, or after the loop exited
+	cf.append(func(env *Env) (Stmt, *Env) {
+		state := env.Outer.Vals[idxstate].Interface().(*rangeFuncState)
+		if state.Exited {
+			panic(rangeFuncContinuedAfterExit)
+		} else if state.Done {
+			panic(rangeFuncContinuedAfterFalse)
+		}
+		env.IP++
+		return env.Code[env.IP], env
+	})
+
+	// declare or resolve the iteration variables, and copy the parameters into them.
+	// declaring them inside the body gives each iteration its own variables
+	placekey, placeval := cf.rangeVars(node, tkey, tval)
+	if placekey != nil {
+		cf.SetPlace(placekey, token.ASSIGN, cf.Bind(params[0]))
+	}
+	if placeval != nil {
+		cf.SetPlace(placeval, token.ASSIGN, cf.Bind(params[1]))
+	}
+	if body := node.Body; body != nil && len(body.List) != 0 {
+		cf.List(body.List)
+	}
+	// reaching the end of the body continues the loop
+	cf.Pos = node.Body.End()
+	cf.rangeFuncContinue(0, cf)
+
+	// do NOT keep a reference to compile environment!
+	funcbody := cf.Code.Exec()
+
+	return cf.funcCreate(tyield, info, []I{cf.Bind(result).WithFun()}, funcbody)
+}
+
+// enclosingFunc returns the innermost *Comp that compiles a function body, if any,
+// and the number of *Env to exit at runtime to reach its *Env
+func (c *Comp) enclosingFunc() (*Comp, int) {
+	upn := 0
+	for o := c; o != nil; o = o.Outer {
+		if o.Func != nil {
+			return o, upn
+		}
+		upn += o.UpCost // count how many Env:s we must exit at runtime
+	}
+	return nil, upn
+}
+
+// rangeFuncBranch compiles a break, continue or goto inside the body of a range-over-func loop.
+// o is the *Comp of the body, and upn the number of *Env to exit at runtime to reach its *Env
+func (c *Comp) rangeFuncBranch(upn int, o *Comp, node *ast.BranchStmt) {
+	rf := o.Func.rangeFunc
+	label := ""
+	if node.Label != nil {
+		label = node.Label.Name
+	}
+	thisloop := node.Tok != token.GOTO && (len(label) == 0 || rf.Loop.HasLabel(label))
+	if thisloop && node.Tok == token.CONTINUE {
+		c.rangeFuncContinue(upn, o)
+		return
+	} else if thisloop && node.Tok == token.BREAK {
+		c.rangeFuncStop(upn, o, rangeFuncBreak)
+		return
+	}
+	// jump to outside the loop: it will be compiled after the iterator returns
+	next := -1
+	for i, exit := range rf.Exits {
+		if exit.Tok == node.Tok && exit.Label.Name == label {
+			next = rangeFuncExit + i
+			break
+		}
+	}
+	if next < 0 {
+		next = rangeFuncExit + len(rf.Exits)
+		rf.Exits = append(rf.Exits, node)
+	}
+	c.rangeFuncStop(upn, o, next)
+}
+
+// rangeFuncContinue compiles a return true from the body of a range-over-func loop
+func (c *Comp) rangeFuncContinue(upn int, o *Comp) {
+	result := o.Func.Result[0]
+	c.SetVar(result.AsVar(upn, PlaceSettable), token.ASSIGN, c.exprValue(c.TypeOfBool(), true))
+	c.append(stmtReturn)
+}
+
+// rangeFuncStop compiles a return false from the body of a range-over-func loop,
+// after storing in the loop state what to do after the iterator returns
+func (c *Comp) rangeFuncStop(upn int, o *Comp, next int) {
+	result := o.Func.Result[0]
+	c.SetVar(result.AsVar(upn, PlaceSettable), token.ASSIGN, c.exprValue(c.TypeOfBool(), false))
+	getstate := rangeFuncStateAt(o.Func.rangeFunc.State, upn+o.UpCost)
+	c.append(func(env *Env) (Stmt, *Env) {
+		state := getstate(env)
+		state.Next = next
+		state.Done = true
+		return stmtReturn(env)
+	})
+}
+
+// returnFromRangeFuncBody compiles a return from the body of a range-over-func loop,
+// that also returns from the function containing the loop. The results are already stored
+func (c *Comp) returnFromRangeFuncBody(body *Comp, bodyupn int) {
+	body.Func.rangeFunc.Return = true
+	c.rangeFuncStop(bodyupn, body, rangeFuncReturn)
+}
+
+// returnFromRangeFunc compiles a return after the iterator of a range-over-func loop returned,
+// because its body executed a "return". The results are already stored
+func (c *Comp) returnFromRangeFunc() {
+	o, upn := c.enclosingFunc()
+	if o != nil && o.Func.rangeFunc != nil {
+		// propagate the return to the outer range-over-func loop
+		c.returnFromRangeFuncBody(o, upn)
+	} else {
+		c.append(stmtReturn)
+	}
+}
+
+// rangeFuncDefer compiles a defer inside the body of a range-over-func loop:
+// the deferred call is stored in the loop state, and executed when the function containing the loop returns
+func (c *Comp) rangeFuncDefer(upn int, o *Comp, makedefer func(*Env) func()) {
+	rf := o.Func.rangeFunc
+	rf.Defers = true
+	getstate := rangeFuncStateAt(rf.State, upn+o.UpCost)
+	c.append(func(env *Env) (Stmt, *Env) {
+		state := getstate(env)
+		state.Defers = append(state.Defers, makedefer(env))
+		env.IP++
+		return env.Code[env.IP], env
+	})
+}
+
+// rangeFuncStateAt returns a function that retrieves at runtime the state of a range-over-func loop
+// stored in bind, from an *Env that is upn levels inside the bind's *Env
+func rangeFuncStateAt(bind *Bind, upn int) func(*Env) *rangeFuncState {
+	idx := bind.Desc.Index()
+	return func(env *Env) *rangeFuncState {
+		for i := 0; i < upn; i++ {
+			env = env.Outer
+		}
+		return env.Vals[idx].Interface().(*rangeFuncState)
+	}
+}
+
+// runDefers executes the given functions in reverse order, as Go defer does
+func runDefers(defers []func()) {
+	for _, fun := range defers {
+		defer fun()
+	}
+}
@@ -58,6 +58,7 @@ func (c *Comp) rangeMap(node *ast.RangeStmt, erange *Expr, jump *rangeJump) {
 	}
 
 	jump.Start = c.Code.Len()
+	c.rangeVarPerIteration(node)
 
 	// "continue" is a jump to the statement below
 	jump.Continue = c.Code.Len()
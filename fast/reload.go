@@ -0,0 +1,188 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * reload.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package fast
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"io/ioutil"
+	"strings"
+
+	"github.com/cosmos72/gomacro/base/dep"
+)
+
+// Reload re-reads a file evaluated previously, and compiles its top-level
+// declarations that changed since the previous Reload of the same file:
+//
+// functions and methods are recompiled. If their signature did not change,
+// existing callers, function values and goroutines execute the new body at their next call.
+// If a function signature changed, its callers are recompiled too.
+// New imports, constants, types and variables are declared.
+// Existing variables keep their current value.
+// Top-level statements are not executed again.
+//
+// The first Reload of a file recompiles all its functions and methods,
+// because it does not know which ones changed.
+// Declarations that cannot be reloaded - changed types and constants,
+// and methods whose signature changed - are reported in the returned error
+// and keep their current definition. Declarations removed from the file are kept too.
+//
+// Reload can be called by a goroutine different from the one calling Eval:
+// it waits until the Eval in progress, if any, completes.
+// For the same reason, goroutines started by interpreted code
+// must not call Reload while the Eval that started them waits for them.
+//
+// Returns the names of the recompiled and new declarations
+func (ir *Interp) Reload(filepath string) (reloaded []string, err error) {
+	src, err := ioutil.ReadFile(filepath)
+	if err != nil {
+		return nil, err
+	}
+	c := ir.Comp
+	g := c.CompGlobals
+	defer g.evalLock.lock()()
+	saveFilepath, saveLine := g.Filepath, g.Line
+	defer func() {
+		g.Filepath, g.Line = saveFilepath, saveLine
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("%v", rec)
+		}
+	}()
+	defer ir.commitInput()
+
+	g.Filepath, g.Line = filepath, 0
+	nodes := c.ParseBytes(src)
+	form, _ := c.MacroExpandCodewalk(anyToAst(nodes, "Reload"))
+
+	sorter := dep.NewSorter()
+	sorter.LoadAst(form)
+
+	old := g.reloads[filepath]
+	current := make(map[string]string)
+	var errs []string
+	for _, decl := range sorter.All() {
+		if decl.Kind == dep.TypeFwd {
+			// needed only by new types
+			if c.Types[decl.Name] == nil {
+				ir.reloadDecl(decl)
+			}
+			continue
+		}
+		key, src := c.reloadKey(decl)
+		if len(key) == 0 {
+			continue
+		}
+		prev, known := old[key]
+		if known && prev == src {
+			current[key] = src
+			continue
+		}
+		compiled, err := ir.reloadDecl1(decl, old != nil)
+		if err != nil {
+			pos := g.Fileset.Position(decl.Pos)
+			errs = append(errs, fmt.Sprintf("%v: %v", pos, err))
+			if known {
+				// report it again at next Reload
+				current[key] = prev
+			}
+			continue
+		}
+		current[key] = src
+		if compiled {
+			reloaded = append(reloaded, decl.Name)
+		}
+	}
+	// recompile the callers of functions whose signature changed
+	for _, e := range c.recompileDependents() {
+		ir.RunExpr(e)
+	}
+	if g.reloads == nil {
+		g.reloads = make(map[string]map[string]string)
+	}
+	g.reloads[filepath] = current
+	if len(errs) != 0 {
+		err = errors.New(strings.Join(errs, "\n"))
+	}
+	return reloaded, err
+}
+
+// reloadKey returns the key and the source code of a top-level declaration,
+// or an empty key if it must not be reloaded
+func (c *Comp) reloadKey(decl *dep.Decl) (key string, src string) {
+	node := decl.Node
+	switch decl.Kind {
+	case dep.Const, dep.Var:
+		if decl.Extra != nil {
+			node = decl.Extra.Spec()
+		}
+	case dep.Func, dep.Import, dep.Macro, dep.Method, dep.Type, dep.VarMulti:
+	default:
+		// package clause, statements and expressions
+		return "", ""
+	}
+	if node == nil {
+		// second and later variables in VarMulti
+		return "", ""
+	}
+	return decl.Kind.String() + " " + decl.Name, c.Sprintf("%v", genDecl(node))
+}
+
+// reloadDecl1 compiles a changed or new top-level declaration, if it can be reloaded.
+// changed is false if it's the first Reload of the file
+func (ir *Interp) reloadDecl1(decl *dep.Decl, changed bool) (compiled bool, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			compiled, err = false, fmt.Errorf("%v", rec)
+		}
+	}()
+	c := ir.Comp
+	name := decl.Name
+	switch decl.Kind {
+	case dep.Import:
+		spec := decl.Node.(*ast.ImportSpec)
+		// sessionKeep checks that the package is imported with the same name
+		if c.importOf(spec.Path) != nil && c.sessionKeep("", spec) {
+			return false, nil
+		}
+	case dep.Const:
+		if c.bindClass(name) == ConstBind {
+			if changed {
+				return false, fmt.Errorf("cannot reload const %s: constants are inlined in compiled code, restart to change them", name)
+			}
+			return false, nil
+		}
+	case dep.Var, dep.VarMulti:
+		if class := c.bindClass(name); class == VarBind || class == IntBind {
+			// keep its current value
+			return false, nil
+		}
+	case dep.Type:
+		if c.Types[name] != nil {
+			if changed {
+				return false, fmt.Errorf("cannot reload type %s: existing values would become invalid, restart to change it", name)
+			}
+			return false, nil
+		}
+	}
+	ir.reloadDecl(decl)
+	return true, nil
+}
+
+// reloadDecl compiles and executes a top-level declaration
+func (ir *Interp) reloadDecl(decl *dep.Decl) {
+	ir.RunExpr(ir.Comp.compileDecl(decl))
+}
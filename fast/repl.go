@@ -188,6 +188,11 @@ func (ir *Interp) prepareEnv(minValDelta int, minIntDelta int) *Env {
 	env := ir.env
 	// usually we know at Env creation how many slots are needed in c.Env.Binds
 	// but here we are modifying an existing Env...
+	// Goroutines started by interpreted code may be accessing it:
+	// use all the capacity of env.Vals and env.Ints, so that they are replaced
+	// only when it is exhausted, and never resliced.
+	// Replacing them is still unsafe: goroutines running meanwhile may keep
+	// using the old slices, and their writes after the copy are lost
 	if minValDelta < 0 {
 		minValDelta = 0
 	}
@@ -205,13 +210,10 @@ func (ir *Interp) prepareEnv(minValDelta int, minIntDelta int) *Env {
 		if capacity-cap(env.Vals) < minValDelta {
 			capacity = cap(env.Vals) + minValDelta
 		}
-		binds := make([]xr.Value, min, capacity)
+		binds := make([]xr.Value, capacity)
 		copy(binds, env.Vals)
 		env.Vals = binds
 	}
-	if len(env.Vals) < min {
-		env.Vals = env.Vals[0:min:cap(env.Vals)]
-	}
 	// c.Debugf("prepareEnv() after:  c.BindNum = %v, minDelta = %v, len(env.Binds) = %v, cap(env.Binds) = %v, env = %p", c.BindNum, minDelta, len(env.Binds), cap(env.Binds), env)
 
 	capacity, min = cap(env.Ints), c.IntBindNum
@@ -226,13 +228,10 @@ func (ir *Interp) prepareEnv(minValDelta int, minIntDelta int) *Env {
 		if capacity-cap(env.Ints) < minIntDelta {
 			capacity = cap(env.Ints) + minIntDelta
 		}
-		binds := make([]uint64, min, capacity)
+		binds := make([]uint64, capacity)
 		copy(binds, env.Ints)
 		env.Ints = binds
 	}
-	if len(env.Ints) < min {
-		env.Ints = env.Ints[0:min:cap(env.Ints)] // does not reallocate
-	}
 	if env.IntAddressTaken {
 		c.IntBindMax = cap(env.Ints)
 	}
@@ -242,6 +241,7 @@ func (ir *Interp) prepareEnv(minValDelta int, minIntDelta int) *Env {
 	// in case we received a SigInterrupt in the meantime
 	g.Signals.Sync = base.SigNone
 	g.Signals.Async = base.SigNone
+	g.exit = nil
 	if g.Options&base.OptDebugger != 0 {
 		// for debugger
 		env.DebugComp = c
@@ -314,9 +314,11 @@ func (ir *Interp) ParseEvalPrint(src string) (callAgain bool) {
 	if len(src) == 0 || len(strings.TrimSpace(src)) == 0 {
 		return true // no input => no form
 	}
+	defer ir.Comp.evalLock.lock()()
 
 	t1, trap, duration := ir.beforeEval()
 	defer ir.afterEval(src, &callAgain, &trap, t1, duration)
+	defer ir.commitInput()
 
 	src, opt := ir.Cmd(src)
 
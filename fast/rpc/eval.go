@@ -0,0 +1,249 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * eval.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package rpc
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/cosmos72/gomacro/ast2"
+	"github.com/cosmos72/gomacro/base"
+	"github.com/cosmos72/gomacro/fast"
+	"github.com/cosmos72/gomacro/go/scanner"
+	xr "github.com/cosmos72/gomacro/xreflect"
+)
+
+// capture collects the output written while executing a request
+type capture struct {
+	stdout, stderr       bytes.Buffer
+	outPipe, errPipe     *os.File
+	savedOut, savedErr   *os.File
+	savedGOut, savedGErr io.Writer
+	done                 chan struct{}
+}
+
+// redirect os.Stdout, os.Stderr and the interpreter output to pipes,
+// in order to capture also the output of compiled functions as fmt.Println()
+func startCapture(g *base.Globals) *capture {
+	c := &capture{done: make(chan struct{}, 2)}
+	c.savedOut, c.savedErr = os.Stdout, os.Stderr
+	c.savedGOut, c.savedGErr = g.Stdout, g.Stderr
+	c.outPipe = c.pipe(&c.stdout)
+	c.errPipe = c.pipe(&c.stderr)
+	if c.outPipe == nil || c.errPipe == nil {
+		// cannot create pipes: capture only the interpreter output
+		c.close()
+		g.Stdout, g.Stderr = &c.stdout, &c.stderr
+		return c
+	}
+	os.Stdout, os.Stderr = c.outPipe, c.errPipe
+	g.Stdout, g.Stderr = c.outPipe, c.errPipe
+	return c
+}
+
+func (c *capture) pipe(buf *bytes.Buffer) *os.File {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil
+	}
+	go func() {
+		io.Copy(buf, r)
+		r.Close()
+		c.done <- struct{}{}
+	}()
+	return w
+}
+
+func (c *capture) close() {
+	for _, w := range [...]*os.File{c.outPipe, c.errPipe} {
+		if w != nil {
+			w.Close()
+			<-c.done
+		}
+	}
+	c.outPipe, c.errPipe = nil, nil
+}
+
+// stop capturing, and return the captured output.
+// later output goes to the current os.Stdout and os.Stderr
+func (c *capture) stop(g *base.Globals) Output {
+	os.Stdout, os.Stderr = c.savedOut, c.savedErr
+	if g.Stdout == c.outPipe || g.Stdout == &c.stdout {
+		g.Stdout = c.savedGOut
+	}
+	if g.Stderr == c.errPipe || g.Stderr == &c.stderr {
+		g.Stderr = c.savedGErr
+	}
+	c.close()
+	return Output{Stdout: c.stdout.String(), Stderr: c.stderr.String()}
+}
+
+// ------------------------ eval -------------------------------------
+
+func (s *Server) eval(params *EvalParams) (result EvalResult, err *Error) {
+	ir := s.Interp()
+	g := &ir.Comp.Globals
+	saveFilepath, saveLine := g.Filepath, g.Line
+	g.Filepath = params.File
+	if params.Line > 0 {
+		g.Line = params.Line - 1
+	} else {
+		g.Line = 0
+	}
+	capture := startCapture(g)
+	defer func() {
+		rec := recover()
+		g.Filepath, g.Line = saveFilepath, saveLine
+		output := capture.stop(g)
+		if rec != nil {
+			err = evalError(rec, output)
+		} else {
+			result.Output = output
+		}
+	}()
+	result.Values = evalValues(ir, params.Code)
+	return result, nil
+}
+
+// execute src, including REPL commands as :env
+func evalValues(ir *fast.Interp, src string) []Value {
+	src, opt := ir.Cmd(src)
+	if len(strings.TrimSpace(src)) == 0 || opt&base.CmdOptQuit != 0 {
+		return []Value{}
+	}
+	vals, types := ir.RunExpr(ir.Compile(src))
+	g := &ir.Comp.Globals
+	result := make([]Value, len(vals))
+	for i, val := range vals {
+		result[i].Value = g.Sprintf("%v", val.ReflectValue())
+		if i < len(types) && types[i] != nil {
+			result[i].Type = types[i].String()
+		} else if val.IsValid() {
+			result[i].Type = val.Type().String()
+		}
+	}
+	return result
+}
+
+// convert a panic to a JSON-RPC error
+func evalError(rec interface{}, output Output) *Error {
+	data := &EvalErrorData{Output: output}
+	if rec == base.SigInterrupt {
+		data.Errors = []Diagnostic{{Message: "interrupted"}}
+		return &Error{Code: CodeInterrupted, Message: "interrupted", Data: data}
+	}
+	switch rec := rec.(type) {
+	case scanner.ErrorList:
+		for _, e := range rec {
+			data.Errors = append(data.Errors, diagnostic(e.Msg, e.Pos))
+		}
+	case *scanner.Error:
+		data.Errors = []Diagnostic{diagnostic(rec.Msg, rec.Pos)}
+	case interface {
+		error
+		Position() token.Position
+	}:
+		data.Errors = []Diagnostic{diagnostic(rec.Error(), rec.Position())}
+	default:
+		data.Errors = []Diagnostic{{Message: fmt.Sprint(rec)}}
+	}
+	if len(data.Errors) == 0 {
+		data.Errors = []Diagnostic{{Message: fmt.Sprint(rec)}}
+	}
+	return &Error{Code: CodeEvalError, Message: data.Errors[0].Message, Data: data}
+}
+
+func diagnostic(msg string, pos token.Position) Diagnostic {
+	if !pos.IsValid() {
+		return Diagnostic{Message: msg}
+	}
+	// remove position prefix from msg, it is returned separately
+	msg = strings.TrimPrefix(msg, pos.String()+": ")
+	return Diagnostic{Message: msg, File: pos.Filename, Line: pos.Line, Column: pos.Column}
+}
+
+// ------------------------ complete ---------------------------------
+
+func (s *Server) complete(params *CompleteParams) CompleteResult {
+	ir := s.Interp()
+	code, pos := params.Code, params.Pos
+	if pos < 0 || pos > len(code) {
+		pos = len(code)
+	}
+	head, matches, _ := ir.CompleteWords(code, pos)
+	if matches == nil {
+		matches = []string{}
+	}
+	return CompleteResult{Matches: matches, Start: len(head), End: pos}
+}
+
+// ------------------------ inspect ----------------------------------
+
+// inspect the value and type of an expression, or a type.
+// expressions are evaluated, including their side effects
+func (s *Server) inspect(params *InspectParams) (result InspectResult, err *Error) {
+	ir := s.Interp()
+	g := &ir.Comp.Globals
+	capture := startCapture(g)
+	defer func() {
+		rec := recover()
+		output := capture.stop(g)
+		if rec != nil {
+			err = evalError(rec, output)
+		} else {
+			result.Output = output
+		}
+	}()
+	c := ir.Comp
+	form := c.Parse(params.Code)
+	if form == nil {
+		return result, &Error{Code: CodeInvalidParams, Message: "inspect: empty code"}
+	}
+	if _, ok := form.(ast2.AstWithSlice); ok && form.Size() == 1 {
+		form = form.Get(0)
+	}
+	expr, t := c.Expr1OrType(ast2.ToExpr(form))
+	if expr != nil {
+		var val xr.Value
+		val, t = ir.RunExpr1(expr)
+		if t != nil && t.Kind() == xr.Interface && val.IsValid() && !val.IsNil() {
+			// show the concrete type
+			val = val.Elem()
+			t = ir.TypeOf(val.Interface())
+		}
+		result.Value = g.Sprintf("%v", val.ReflectValue())
+	}
+	if t == nil {
+		result.Type, result.Kind = "nil", "invalid"
+		return result, nil
+	}
+	result.Type = t.String()
+	result.Kind = t.Kind().String()
+	if t.Kind() == xr.Struct {
+		for i, n := 0, t.NumField(); i < n; i++ {
+			field := t.Field(i)
+			result.Fields = append(result.Fields, Field{Name: field.Name, Type: field.Type.String()})
+		}
+	}
+	for i, n := 0, t.NumMethod(); i < n; i++ {
+		result.Methods = append(result.Methods, t.Method(i).Name)
+	}
+	return result, nil
+}
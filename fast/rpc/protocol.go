@@ -0,0 +1,157 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * protocol.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package rpc
+
+import (
+	"encoding/json"
+)
+
+// JSON-RPC 2.0 messages, see https://www.jsonrpc.org/specification
+// Each message is a single line of JSON text, terminated by '\n'
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"` // missing for notifications
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+type notification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+// error codes defined by JSON-RPC 2.0
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+)
+
+// error codes specific to gomacro
+const (
+	CodeEvalError   = -32000 // parse, compile or runtime error in evaluated code
+	CodeInterrupted = -32001 // evaluation interrupted by an "interrupt" request
+)
+
+// Error is a JSON-RPC error.
+// For CodeEvalError and CodeInterrupted, Data contains an *EvalErrorData
+type Error struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func (err *Error) Error() string {
+	return err.Message
+}
+
+// EvalErrorData contains the details of a failed "eval"
+type EvalErrorData struct {
+	Output
+	Errors []Diagnostic `json:"errors"`
+}
+
+// Diagnostic is a single error, with its position if known
+type Diagnostic struct {
+	Message string `json:"message"`
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`   // 1-based
+	Column  int    `json:"column,omitempty"` // 1-based, in bytes
+}
+
+// Output is the output captured while executing a request
+type Output struct {
+	Stdout string `json:"stdout"`
+	Stderr string `json:"stderr"`
+}
+
+// ------------------------ eval -------------------------------------
+
+type EvalParams struct {
+	Code string `json:"code"`
+	File string `json:"file,omitempty"` // file name reported in error positions
+	Line int    `json:"line,omitempty"` // line number of the first line of Code. default: 1
+}
+
+type EvalResult struct {
+	Output
+	Values []Value `json:"values"`
+}
+
+// Value is a value computed by "eval"
+type Value struct {
+	Value string `json:"value"`
+	Type  string `json:"type"`
+}
+
+// ------------------------ complete ---------------------------------
+
+type CompleteParams struct {
+	Code string `json:"code"`
+	Pos  int    `json:"pos"` // cursor position in Code, in bytes
+}
+
+// CompleteResult lists the strings that can replace Code[Start:End]
+type CompleteResult struct {
+	Matches []string `json:"matches"`
+	Start   int      `json:"start"`
+	End     int      `json:"end"`
+}
+
+// ------------------------ inspect ----------------------------------
+
+type InspectParams struct {
+	Code string `json:"code"` // an expression or a type
+}
+
+type InspectResult struct {
+	Output
+	Value   string   `json:"value,omitempty"` // omitted for types
+	Type    string   `json:"type"`
+	Kind    string   `json:"kind"`
+	Fields  []Field  `json:"fields,omitempty"`
+	Methods []string `json:"methods,omitempty"`
+}
+
+type Field struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// ------------------------ interrupt --------------------------------
+
+type InterruptResult struct {
+	Interrupted bool `json:"interrupted"` // false if nothing was executing
+}
+
+// ------------------------ output notification ----------------------
+
+// OutputParams is the parameter of "output" notifications,
+// sent for output produced outside "eval" requests, as by goroutines
+type OutputParams struct {
+	Stream string `json:"stream"` // "stdout" or "stderr"
+	Text   string `json:"text"`
+}
@@ -0,0 +1,377 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * server.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+// Package rpc implements a JSON-RPC 2.0 server for the gomacro fast interpreter,
+// allowing editors and other tools to drive a persistent interpreter session.
+//
+// Supported methods are "eval", "complete", "inspect", "interrupt" and "reset".
+// Requests are executed in order, except "interrupt" that is executed immediately
+package rpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/cosmos72/gomacro/base"
+	"github.com/cosmos72/gomacro/fast"
+)
+
+// Server is a JSON-RPC 2.0 server.
+// It reads requests from a client and executes them on a fast.Interp
+type Server struct {
+	// NewInterp is invoked by "reset" requests to create a new interpreter.
+	// The default is fast.New
+	NewInterp func() *fast.Interp
+
+	in  *bufio.Reader
+	out io.Writer
+
+	wlock sync.Mutex // protects out
+
+	lock    sync.Mutex // protects the fields below
+	interp  *fast.Interp
+	queue   []*request    // requests to execute in the goroutine running Serve()
+	wake    chan struct{} // signaled when queue becomes non-empty
+	running bool          // true while executing a request
+}
+
+// NewServer creates a JSON-RPC server that reads requests from in and writes responses to out
+func NewServer(ir *fast.Interp, in io.Reader, out io.Writer) *Server {
+	s := &Server{
+		NewInterp: fast.New,
+		in:        bufio.NewReader(in),
+		out:       out,
+		wake:      make(chan struct{}, 1),
+	}
+	s.setInterp(ir)
+	return s
+}
+
+// ServeStdio runs a JSON-RPC server on standard input and standard output.
+// While it runs, os.Stdin is empty, and output written to os.Stdout and os.Stderr
+// outside requests, as by goroutines, is sent to the client as "output" notifications
+func ServeStdio(ir *fast.Interp, newInterp func() *fast.Interp) error {
+	s := NewServer(ir, os.Stdin, os.Stdout)
+	if newInterp != nil {
+		s.NewInterp = newInterp
+	}
+	restore, err := s.redirectStdio()
+	if err != nil {
+		return err
+	}
+	defer restore()
+	return s.Serve()
+}
+
+// ListenAndServe accepts connections on Unix domain socket path and runs a JSON-RPC server on each of them, one at a time.
+// The interpreter, and thus its declarations, are preserved across connections
+func ListenAndServe(ir *fast.Interp, path string, newInterp func() *fast.Interp) error {
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		// remove stale socket left by a previous server
+		os.Remove(path)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	g := &ir.Comp.Globals
+	stderr := g.Stderr
+	for {
+		g.Fprintf(stderr, "// JSON-RPC server listening on %v\n", path)
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		s := NewServer(ir, conn, conn)
+		if newInterp != nil {
+			s.NewInterp = newInterp
+		}
+		err = s.Serve()
+		conn.Close()
+		if err != nil {
+			g.Fprintf(stderr, "// JSON-RPC connection closed: %v\n", err)
+		}
+		ir = s.Interp() // preserve the effect of "reset" requests
+	}
+}
+
+// Interp returns the interpreter currently used by the server.
+// It changes after each "reset" request
+func (s *Server) Interp() *fast.Interp {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.interp
+}
+
+func (s *Server) setInterp(ir *fast.Interp) {
+	g := &ir.Comp.Globals
+	// results are returned to the client with their type: do not print them,
+	// and convert untyped constants to their default type.
+	// interrupts must stop execution, not enter the debugger.
+	// panics are converted to errors by the server
+	g.Options &^= base.OptShowPrompt | base.OptShowEval | base.OptShowEvalType | base.OptKeepUntyped |
+		base.OptCtrlCEnterDebugger | base.OptTrapPanic
+	s.lock.Lock()
+	s.interp = ir
+	s.lock.Unlock()
+}
+
+// Serve reads and executes requests until the client closes the connection.
+// It must be invoked by the goroutine that created the interpreter,
+// because it uses such goroutine to execute interpreted code
+func (s *Server) Serve() error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.readRequests()
+	}()
+	for {
+		select {
+		case <-s.wake:
+			s.executeQueue()
+		case err := <-errc:
+			// execute requests received before the client closed the connection
+			s.executeQueue()
+			return err
+		}
+	}
+}
+
+func (s *Server) executeQueue() {
+	for {
+		s.lock.Lock()
+		if len(s.queue) == 0 {
+			s.lock.Unlock()
+			return
+		}
+		req := s.queue[0]
+		s.queue = s.queue[1:]
+		s.running = true
+		s.lock.Unlock()
+
+		result, err := s.execute(req)
+
+		s.lock.Lock()
+		s.running = false
+		s.lock.Unlock()
+
+		s.respond(req, result, err)
+	}
+}
+
+// read requests. queue them for execution, except "interrupt"
+func (s *Server) readRequests() error {
+	for {
+		line, err := s.in.ReadBytes('\n')
+		if len(line) != 0 {
+			s.receive(line)
+		}
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) receive(line []byte) {
+	if len(trimSpace(line)) == 0 {
+		return
+	}
+	var req request
+	if err := json.Unmarshal(line, &req); err != nil {
+		s.respond(&request{ID: json.RawMessage("null")}, nil,
+			&Error{Code: CodeParseError, Message: err.Error()})
+		return
+	}
+	if req.JSONRPC != "2.0" || len(req.Method) == 0 {
+		s.respond(&req, nil, &Error{Code: CodeInvalidRequest, Message: "invalid JSON-RPC 2.0 request"})
+		return
+	}
+	if req.Method == "interrupt" {
+		s.respond(&req, s.interrupt(), nil)
+		return
+	}
+	s.lock.Lock()
+	s.queue = append(s.queue, &req)
+	s.lock.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func trimSpace(line []byte) []byte {
+	for len(line) != 0 {
+		switch line[len(line)-1] {
+		case ' ', '\t', '\r', '\n':
+			line = line[:len(line)-1]
+			continue
+		}
+		break
+	}
+	return line
+}
+
+// execute a request in the goroutine running Serve()
+func (s *Server) execute(req *request) (interface{}, *Error) {
+	switch req.Method {
+	case "eval":
+		var params EvalParams
+		if err := unmarshal(req, &params); err != nil {
+			return nil, err
+		}
+		return s.eval(&params)
+	case "complete":
+		var params CompleteParams
+		if err := unmarshal(req, &params); err != nil {
+			return nil, err
+		}
+		return s.complete(&params), nil
+	case "inspect":
+		var params InspectParams
+		if err := unmarshal(req, &params); err != nil {
+			return nil, err
+		}
+		return s.inspect(&params)
+	case "reset":
+		s.setInterp(s.NewInterp())
+		return struct{}{}, nil
+	}
+	return nil, &Error{Code: CodeMethodNotFound, Message: fmt.Sprintf("method not found: %q", req.Method)}
+}
+
+func unmarshal(req *request, params interface{}) *Error {
+	if len(req.Params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(req.Params, params); err != nil {
+		return &Error{Code: CodeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// interrupt the request being executed, if any
+func (s *Server) interrupt() InterruptResult {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.running {
+		return InterruptResult{false}
+	}
+	s.interp.Interrupt(os.Interrupt)
+	return InterruptResult{true}
+}
+
+// send the response to req. notifications, i.e. requests without id, have no response
+func (s *Server) respond(req *request, result interface{}, err *Error) {
+	if len(req.ID) == 0 {
+		return
+	}
+	resp := response{JSONRPC: "2.0", ID: req.ID}
+	if err != nil {
+		resp.Error = err
+	} else {
+		if result == nil {
+			result = struct{}{}
+		}
+		resp.Result = result
+	}
+	s.write(&resp)
+}
+
+// send a notification
+func (s *Server) notify(method string, params interface{}) {
+	s.write(&notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (s *Server) write(msg interface{}) {
+	buf, err := json.Marshal(msg)
+	if err != nil {
+		buf, _ = json.Marshal(&response{
+			JSONRPC: "2.0",
+			ID:      json.RawMessage("null"),
+			Error:   &Error{Code: CodeEvalError, Message: err.Error()},
+		})
+	}
+	buf = append(buf, '\n')
+	s.wlock.Lock()
+	defer s.wlock.Unlock()
+	s.out.Write(buf)
+}
+
+// outputWriter converts writes to "output" notifications
+type outputWriter struct {
+	s      *Server
+	stream string
+}
+
+func (w outputWriter) Write(p []byte) (int, error) {
+	w.s.notify("output", OutputParams{Stream: w.stream, Text: string(p)})
+	return len(p), nil
+}
+
+// redirect os.Stdin to an empty file, and os.Stdout and os.Stderr to "output" notifications.
+// return a function that restores them
+func (s *Server) redirectStdio() (restore func(), err error) {
+	stdin, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	w.Close()
+	var wg sync.WaitGroup
+	redirect := func(file **os.File, stream string) (func(), error) {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return nil, err
+		}
+		saved := *file
+		*file = w
+		wg.Add(1)
+		go func() {
+			io.Copy(outputWriter{s, stream}, r)
+			r.Close()
+			wg.Done()
+		}()
+		return func() {
+			*file = saved
+			w.Close()
+		}, nil
+	}
+	restoreStdout, err := redirect(&os.Stdout, "stdout")
+	if err != nil {
+		stdin.Close()
+		return nil, err
+	}
+	restoreStderr, err := redirect(&os.Stderr, "stderr")
+	if err != nil {
+		restoreStdout()
+		stdin.Close()
+		return nil, err
+	}
+	savedStdin := os.Stdin
+	os.Stdin = stdin
+	return func() {
+		os.Stdin = savedStdin
+		stdin.Close()
+		restoreStderr()
+		restoreStdout()
+		wg.Wait()
+	}, nil
+}
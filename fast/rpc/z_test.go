@@ -0,0 +1,209 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * z_test.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package rpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/cosmos72/gomacro/fast"
+)
+
+// a scripted JSON-RPC client
+type client struct {
+	t     *testing.T
+	out   io.WriteCloser
+	id    int
+	resps chan *clientResponse
+	early map[int]*clientResponse // responses received while waiting for other ones
+}
+
+type clientResponse struct {
+	ID     int             `json:"id"`
+	Method string          `json:"method"`
+	Result json.RawMessage `json:"result"`
+	Error  *Error          `json:"error"`
+}
+
+func newClient(t *testing.T) *client {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	go func() {
+		// Serve() must run in the goroutine that created the interpreter
+		s := NewServer(fast.New(), serverIn, serverOut)
+		s.Serve()
+		serverOut.Close()
+	}()
+	c := &client{t: t, out: clientOut, resps: make(chan *clientResponse, 100), early: make(map[int]*clientResponse)}
+	go func() {
+		defer close(c.resps)
+		in := bufio.NewReader(clientIn)
+		for {
+			line, err := in.ReadBytes('\n')
+			if err != nil {
+				return
+			}
+			var resp clientResponse
+			if json.Unmarshal(line, &resp) == nil && len(resp.Method) == 0 {
+				c.resps <- &resp
+			}
+		}
+	}()
+	return c
+}
+
+func (c *client) send(method string, params interface{}) int {
+	c.id++
+	buf, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0", "id": c.id, "method": method, "params": params,
+	})
+	c.out.Write(append(buf, '\n'))
+	return c.id
+}
+
+// wait for the response to request id, and decode its result into result
+func (c *client) wait(id int, result interface{}) *Error {
+	resp := c.early[id]
+	delete(c.early, id)
+	timeout := time.After(10 * time.Second)
+	for resp == nil {
+		select {
+		case r, ok := <-c.resps:
+			if !ok {
+				c.t.Fatalf("connection closed while waiting for response %d", id)
+			}
+			if r.ID == id {
+				resp = r
+			} else {
+				c.early[r.ID] = r
+			}
+		case <-timeout:
+			c.t.Fatalf("timeout while waiting for response %d", id)
+		}
+	}
+	if resp.Error == nil && result != nil {
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			c.t.Fatalf("invalid result for request %d: %v", id, err)
+		}
+	}
+	return resp.Error
+}
+
+func (c *client) call(method string, params interface{}, result interface{}) *Error {
+	return c.wait(c.send(method, params), result)
+}
+
+func (c *client) close() {
+	c.out.Close()
+}
+
+func TestEval(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+
+	var res EvalResult
+	err := c.call("eval", EvalParams{Code: "import \"fmt\"\nx := 6 * 7\nfmt.Println(\"hello\", x)\nx"}, &res)
+	if err != nil {
+		t.Fatalf("eval failed: %v", err)
+	}
+	if res.Stdout != "hello 42\n" {
+		t.Errorf("expecting stdout %q, found %q", "hello 42\n", res.Stdout)
+	}
+	expected := []Value{{"42", "int"}}
+	if len(res.Values) != len(expected) || res.Values[0] != expected[0] {
+		t.Errorf("expecting values %v, found %v", expected, res.Values)
+	}
+
+	err = c.call("eval", EvalParams{Code: "x +\n  y", File: "cell.go", Line: 10}, nil)
+	if err == nil || err.Code != CodeEvalError {
+		t.Fatalf("expecting eval error, found %v", err)
+	}
+	var data EvalErrorData
+	buf, _ := json.Marshal(err.Data)
+	json.Unmarshal(buf, &data)
+	expectedErr := Diagnostic{Message: "undefined identifier: y", File: "cell.go", Line: 11, Column: 3}
+	if len(data.Errors) != 1 || data.Errors[0] != expectedErr {
+		t.Errorf("expecting errors %v, found %v", []Diagnostic{expectedErr}, data.Errors)
+	}
+
+	err = c.call("eval", EvalParams{Code: "panic(\"boom\")"}, nil)
+	if err == nil || err.Code != CodeEvalError || err.Message != "boom" {
+		t.Errorf("expecting eval error \"boom\", found %v", err)
+	}
+}
+
+func TestCompleteInspect(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+
+	if err := c.call("eval", EvalParams{Code: "import \"fmt\"; type Pair struct { A, B int }; func (p Pair) Sum() int { return p.A + p.B }"}, nil); err != nil {
+		t.Fatalf("eval failed: %v", err)
+	}
+	var comp CompleteResult
+	if err := c.call("complete", CompleteParams{Code: "x := fmt.Sprin", Pos: 14}, &comp); err != nil {
+		t.Fatalf("complete failed: %v", err)
+	}
+	if comp.Start != 9 || comp.End != 14 || len(comp.Matches) != 3 || comp.Matches[0] != "Sprint" {
+		t.Errorf("unexpected completion result %+v", comp)
+	}
+
+	var insp InspectResult
+	if err := c.call("inspect", InspectParams{Code: "Pair{1, 2}"}, &insp); err != nil {
+		t.Fatalf("inspect failed: %v", err)
+	}
+	if insp.Value != "{A:1 B:2}" || insp.Type != "main.Pair" || insp.Kind != "struct" ||
+		len(insp.Fields) != 2 || insp.Fields[1] != (Field{"B", "int"}) ||
+		len(insp.Methods) != 1 || insp.Methods[0] != "Sum" {
+		t.Errorf("unexpected inspect result %+v", insp)
+	}
+}
+
+func TestInterruptReset(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+
+	if err := c.call("eval", EvalParams{Code: "x := 1"}, nil); err != nil {
+		t.Fatalf("eval failed: %v", err)
+	}
+	var intr InterruptResult
+	if err := c.call("interrupt", nil, &intr); err != nil || intr.Interrupted {
+		t.Errorf("expecting nothing to interrupt, found %v %+v", err, intr)
+	}
+	id := c.send("eval", EvalParams{Code: "for { x++ }"})
+	for !intr.Interrupted {
+		time.Sleep(10 * time.Millisecond)
+		if err := c.call("interrupt", nil, &intr); err != nil {
+			t.Fatalf("interrupt failed: %v", err)
+		}
+	}
+	if err := c.wait(id, nil); err == nil || err.Code != CodeInterrupted {
+		t.Fatalf("expecting interrupted eval, found %v", err)
+	}
+
+	var res EvalResult
+	if err := c.call("eval", EvalParams{Code: "x > 1"}, &res); err != nil || len(res.Values) != 1 || res.Values[0].Value != "true" {
+		t.Errorf("expecting x > 1 after interrupt, found %v %+v", err, res)
+	}
+	if err := c.call("reset", nil, nil); err != nil {
+		t.Fatalf("reset failed: %v", err)
+	}
+	if err := c.call("eval", EvalParams{Code: "x"}, nil); err == nil || err.Code != CodeEvalError {
+		t.Errorf("expecting x to be undefined after reset, found %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * run_package.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package fast
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cosmos72/gomacro/base/genimport"
+)
+
+// RunPackage interprets the package in directory dir, as "go run" does:
+// it loads all the *.go files that match current build constraints, excluding tests,
+// sorts their declarations with dep.Sorter, executes their init() functions
+// in file order and finally main() if the package is "main".
+//
+// Errors while loading or compiling the package are returned.
+// Panics raised by the interpreted code are propagated to the caller,
+// and calls to os.Exit() terminate the process as in compiled Go
+func (ir *Interp) RunPackage(dir string) error {
+	src, err := genimport.LoadSourceDir(dir, dir)
+	if err != nil {
+		return err
+	}
+	// types declared by the package are named main.T as in compiled Go, not DIR.T
+	src.Path = src.Name
+	inner, err := ir.LoadPackage(src)
+	if err != nil {
+		return err
+	}
+	if src.Name != "main" {
+		return nil
+	}
+	var main func()
+	if v := inner.ValueOf("main"); v.IsValid() {
+		main, _ = v.Interface().(func())
+	}
+	if main == nil {
+		return fmt.Errorf("%s: function main is undeclared in the main package", dir)
+	}
+	main()
+	return nil
+}
+
+// LoadPackage interprets the source files of a package in a new inner Interp:
+// executes its package-level declarations and its init() functions in file order,
+// then makes it available to interpreted code that imports src.Path.
+// Returns the new Interp.
+//
+// Errors while compiling the package are returned.
+// Panics raised by the interpreted code are propagated to the caller
+func (ir *Interp) LoadPackage(src *genimport.SourcePackage) (*Interp, error) {
+	inner, expr, inits, err := ir.compilePackage(src)
+	if err != nil {
+		return nil, err
+	}
+	inner.runSource(expr, inits)
+	ir.Comp.CompGlobals.KnownImports[src.Path] = inner.asImport()
+	return inner, nil
+}
+
+// compilePackage is as Comp.compileSource, and returns compile errors instead of panicking
+func (ir *Interp) compilePackage(src *genimport.SourcePackage) (inner *Interp, expr *Expr, inits []string, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			switch rec := rec.(type) {
+			case error:
+				err = rec
+			default:
+				err = errors.New(fmt.Sprint(rec))
+			}
+		}
+	}()
+	inner, expr, inits = ir.Comp.compileSource(src)
+	return inner, expr, inits, nil
+}
@@ -37,6 +37,7 @@ func (c *Comp) SelectorExpr(node *ast.SelectorExpr) *Expr {
 	if t.Kind() == r.Ptr && t.ReflectType() == rtypeOfPtrImport && e.Const() {
 		// access symbol from imported package, for example fmt.Printf
 		imp := e.Value.(*Import)
+		c.checkSymbol(imp, name)
 		return imp.selector(name, &c.Stringer)
 	}
 	if GENERICS_V2_CTI() && e.Untyped() {
@@ -0,0 +1,678 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * session.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package fast
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"math"
+	r "reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	. "github.com/cosmos72/gomacro/ast2"
+	"github.com/cosmos72/gomacro/base/dep"
+	"github.com/cosmos72/gomacro/base/untyped"
+	xr "github.com/cosmos72/gomacro/xreflect"
+)
+
+// session records the top-level declarations compiled in a package,
+// to save them with Interp.SaveSession and to undo them with Interp.Undo
+type session struct {
+	decls map[string]ast.Node // key -> *ast.ImportSpec, *ast.TypeSpec, *ast.ValueSpec or *ast.FuncDecl
+	order []string            // keys in declaration order
+	// Binds and Types at the end of previous input. used to compute undo steps
+	knownBinds map[string]*Bind
+	knownTypes map[string]xr.Type
+	pending    *undoStep   // changes in current input
+	history    []*undoStep // changes in previous inputs
+	env        *EnvBinds   // values of Binds
+	// functions redefined with a different signature in current input
+	changedFuncs []string
+}
+
+func newSession(c *Comp, env *EnvBinds) *session {
+	s := &session{decls: make(map[string]ast.Node), env: env}
+	s.reset(c)
+	return s
+}
+
+// record remembers a top-level declaration, replacing any previous one with the same name
+func (s *session) record(decl *dep.Decl) {
+	var key string
+	node := decl.Node
+	switch decl.Kind {
+	case dep.Import:
+		spec := node.(*ast.ImportSpec)
+		key = "import " + spec.Path.Value
+		if spec.Name != nil {
+			key = "import " + spec.Name.Name + " " + spec.Path.Value
+		}
+	case dep.Const, dep.Var, dep.VarMulti:
+		if decl.Extra != nil {
+			node = decl.Extra.Spec()
+		}
+		key = decl.Name
+	case dep.Type, dep.Func, dep.Method:
+		key = decl.Name
+	default:
+		return
+	}
+	s.set(key, node)
+}
+
+// set replaces the declaration with given key. node == nil means remove it
+func (s *session) set(key string, node ast.Node) {
+	if p := s.pending; p != nil {
+		if _, ok := p.decls[key]; !ok {
+			p.decls[key] = s.decls[key]
+		}
+	}
+	if _, ok := s.decls[key]; ok {
+		for i, k := range s.order {
+			if k == key {
+				s.order = append(s.order[:i], s.order[i+1:]...)
+				break
+			}
+		}
+	}
+	if node == nil {
+		delete(s.decls, key)
+	} else {
+		s.decls[key] = node
+		s.order = append(s.order, key)
+	}
+}
+
+// pendingDecl returns true if the declaration with given key was changed by current input
+func (s *session) pendingDecl(key string) bool {
+	if p := s.pending; p != nil {
+		_, ok := p.decls[key]
+		return ok
+	}
+	return false
+}
+
+// recordDecl remembers a top-level declaration, if c records them
+func (c *Comp) recordDecl(decl *dep.Decl) {
+	if c.session != nil {
+		c.session.record(decl)
+	}
+}
+
+// enableSession starts recording the top-level declarations compiled by ir.Comp
+func (ir *Interp) enableSession() {
+	if c := ir.Comp; c.session == nil {
+		c.session = newSession(c, &ir.env.EnvBinds)
+	}
+}
+
+// ============================ save ==========================================
+
+// SaveSession writes to file the current package, its imports, declarations
+// and the values of its variables. See WriteSession for details
+func (ir *Interp) SaveSession(filename string) error {
+	var buf bytes.Buffer
+	if err := ir.WriteSession(&buf); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, buf.Bytes(), 0644)
+}
+
+// WriteSession writes to out the Go source code that recreates the current package:
+// its imports, types, functions, methods, macros and constants,
+// and its variables with their current values, sorted by dependencies.
+//
+// Only the values of booleans, numbers, strings and of arrays, slices, maps,
+// structs and interfaces containing them are saved: other variables
+// are saved with their zero value, and a warning is printed for each of them
+func (ir *Interp) WriteSession(out io.Writer) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("error saving session: %v", rec)
+		}
+	}()
+	c := ir.Comp
+	s := c.session
+	if s == nil {
+		s = newSession(c, nil)
+	}
+	var nodes []ast.Node
+	for _, key := range s.order {
+		if node := s.decls[key]; c.sessionKeep(key, node) {
+			nodes = append(nodes, genDecl(node))
+		}
+	}
+	nodes = append(nodes, ir.sessionValues()...)
+
+	sorter := dep.NewSorter()
+	sorter.LoadNodes(nodes)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// gomacro session\n\npackage %q\n\n", c.Path)
+	for _, decl := range sorter.All() {
+		if decl.Kind == dep.TypeFwd {
+			continue
+		}
+		node := genDecl(decl.Node)
+		if spec, ok := node.(*ast.ValueSpec); ok {
+			tok := token.VAR
+			if decl.Kind == dep.Const {
+				tok = token.CONST
+			}
+			node = &ast.GenDecl{Tok: tok, Specs: []ast.Spec{spec}}
+		}
+		str := c.Sprintf("%v", node)
+		if isMacroDecl(node) {
+			// the printer omits the empty receiver that marks macros
+			str = "macro " + strings.TrimPrefix(str, "func ")
+		}
+		fmt.Fprintf(&buf, "%s\n\n", str)
+	}
+	_, err = out.Write(buf.Bytes())
+	return err
+}
+
+// isMacroDecl returns true if node declares a macro
+func isMacroDecl(node ast.Node) bool {
+	decl, ok := node.(*ast.FuncDecl)
+	return ok && decl.Recv != nil && len(decl.Recv.List) == 0
+}
+
+// genDecl wraps naked import and type specs, as returned by dep.Sorter, into *ast.GenDecl
+func genDecl(node ast.Node) ast.Node {
+	switch spec := node.(type) {
+	case *ast.ImportSpec:
+		return &ast.GenDecl{Tok: token.IMPORT, Specs: []ast.Spec{spec}}
+	case *ast.TypeSpec:
+		return &ast.GenDecl{Tok: token.TYPE, Specs: []ast.Spec{spec}}
+	}
+	return node
+}
+
+// sessionKeep returns true if the recorded declaration 'node'
+// was not shadowed by later declarations.
+// Constants and variables are saved from their current value instead
+func (c *Comp) sessionKeep(key string, node ast.Node) bool {
+	switch node := node.(type) {
+	case *ast.ImportSpec:
+		if node.Name != nil && (node.Name.Name == "." || node.Name.Name == "_") {
+			return true
+		}
+		name := strings.TrimPrefix(key, "import ")
+		if node.Name == nil {
+			if imp := c.importOf(node.Path); imp != nil {
+				name = imp.Name
+			}
+		} else {
+			name = node.Name.Name
+		}
+		bind := c.Binds[name]
+		if bind == nil {
+			return false
+		}
+		imp, ok := bind.Value.(*Import)
+		return ok && strconv.Quote(imp.Path) == node.Path.Value
+	case *ast.TypeSpec:
+		return c.Types[key] != nil || c.bindClass(key) == GenericTypeBind
+	case *ast.FuncDecl:
+		if node.Recv != nil && len(node.Recv.List) == 1 {
+			// method: keep it if its receiver type still exists
+			tname := key[:strings.IndexByte(key, '.')]
+			return c.Types[tname] != nil || c.bindClass(tname) == GenericTypeBind
+		}
+		switch c.bindClass(key) {
+		case FuncBind, GenericFuncBind:
+			return true
+		case ConstBind:
+			_, ok := c.Binds[key].Value.(Macro)
+			return ok
+		}
+	}
+	return false
+}
+
+func (c *Comp) importOf(path *ast.BasicLit) *Import {
+	str, err := strconv.Unquote(path.Value)
+	if err != nil {
+		return nil
+	}
+	return c.KnownImports[str]
+}
+
+// bindClass returns the class of c.Binds[name], or ^BindClass(0) if not found
+func (c *Comp) bindClass(name string) BindClass {
+	if bind := c.Binds[name]; bind != nil {
+		return bind.Desc.Class()
+	}
+	return ^BindClass(0)
+}
+
+// sessionValues returns the declarations of constants and variables
+// in the current package, with their current values
+func (ir *Interp) sessionValues() []ast.Node {
+	c := ir.Comp
+	names := make([]string, 0, len(c.Binds))
+	for name := range c.Binds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	s := c.session
+	var src bytes.Buffer
+	for _, name := range names {
+		bind := c.Binds[name]
+		switch class := bind.Desc.Class(); class {
+		case ConstBind:
+			switch bind.Value.(type) {
+			case *Import, Macro, *GenericFunc, *GenericType:
+				continue
+			}
+			if lit, ok := c.constLiteral(bind); ok {
+				fmt.Fprintf(&src, "const %s%s\n", name, lit)
+			} else {
+				c.Warnf("session: constant %s of type %v not saved", name, bind.Type)
+			}
+		case VarBind, IntBind:
+			tstr, ok := c.typeString(bind.Type)
+			if !ok {
+				c.Warnf("session: variable %s of type %v not saved", name, bind.Type)
+				continue
+			}
+			v := ir.ValueOf(name)
+			if !v.IsValid() || v.IsZero() {
+				fmt.Fprintf(&src, "var %s %s\n", name, tstr)
+			} else if lit, ok := c.valueLiteral(v, bind.Type, false); ok {
+				fmt.Fprintf(&src, "var %s %s = %s\n", name, tstr, lit)
+			} else {
+				c.Warnf("session: value of variable %s of type %v not saved", name, bind.Type)
+				fmt.Fprintf(&src, "var %s %s\n", name, tstr)
+			}
+		case FuncBind, GenericFuncBind:
+			if s == nil || s.decls[name] == nil {
+				c.Warnf("session: source of function %s not available, not saved", name)
+			}
+		}
+	}
+	for name := range c.Types {
+		if s == nil || s.decls[name] == nil {
+			c.Warnf("session: source of type %s not available, not saved", name)
+		}
+	}
+	return c.ParseBytes(src.Bytes())
+}
+
+// constLiteral returns " = VALUE" or " TYPE = VALUE" for constant 'bind'
+func (c *Comp) constLiteral(bind *Bind) (string, bool) {
+	if lit, ok := bind.Value.(UntypedLit); ok {
+		str, ok := untypedLiteral(lit)
+		return " = " + str, ok
+	}
+	tstr, ok := c.typeString(bind.Type)
+	if !ok {
+		return "", false
+	}
+	str, ok := c.valueLiteral(bind.ConstValue(), bind.Type, false)
+	return " " + tstr + " = " + str, ok
+}
+
+// untypedLiteral converts an untyped constant to Go source code, without loss of precision
+func untypedLiteral(lit UntypedLit) (string, bool) {
+	val := lit.Val
+	switch lit.Kind {
+	case untyped.Bool:
+		return strconv.FormatBool(constant.BoolVal(val)), true
+	case untyped.Rune:
+		if n, ok := constant.Int64Val(val); ok && n >= 0 && n <= math.MaxInt32 {
+			return strconv.QuoteRune(rune(n)), true
+		}
+		return "", false
+	case untyped.Int:
+		return val.ExactString(), true
+	case untyped.Float:
+		return floatLiteral(val.ExactString()), true
+	case untyped.Complex:
+		re := floatLiteral(constant.Real(val).ExactString())
+		im := floatLiteral(constant.Imag(val).ExactString())
+		return fmt.Sprintf("complex(%s, %s)", re, im), true
+	case untyped.String:
+		return strconv.Quote(constant.StringVal(val)), true
+	}
+	return "", false
+}
+
+// floatLiteral converts constant.Value.ExactString() of an untyped float constant to Go source code.
+// Fractions "N/D" become "N.0/D", which is an exact untyped float constant
+func floatLiteral(str string) string {
+	if i := strings.IndexByte(str, '/'); i >= 0 {
+		return "(" + str[:i] + ".0" + str[i:] + ")"
+	} else if !strings.ContainsAny(str, ".eEpP") {
+		return str + ".0"
+	}
+	return str
+}
+
+// typeString converts t to Go source code that can be compiled in the current package
+func (c *Comp) typeString(t xr.Type) (string, bool) {
+	if t == nil {
+		return "", false
+	}
+	if name := t.Name(); len(name) != 0 {
+		if strings.ContainsAny(name, "#[") {
+			// instantiated generic type
+			return "", false
+		}
+		pkgpath := t.PkgPath()
+		if len(pkgpath) == 0 || pkgpath == c.FileComp().Path {
+			return name, true
+		}
+		for alias, bind := range c.FileComp().Binds {
+			if imp, ok := bind.Value.(*Import); ok && bind.Desc.Class() == ConstBind && imp.Path == pkgpath {
+				return alias + "." + name, true
+			}
+		}
+		return "", false
+	}
+	switch t.Kind() {
+	case xr.Array:
+		elem, ok := c.typeString(t.Elem())
+		return fmt.Sprintf("[%d]%s", t.Len(), elem), ok
+	case xr.Chan:
+		elem, ok := c.typeString(t.Elem())
+		switch t.ChanDir() {
+		case r.RecvDir:
+			return "<-chan " + elem, ok
+		case r.SendDir:
+			return "chan<- " + elem, ok
+		}
+		if strings.HasPrefix(elem, "<-") {
+			elem = "(" + elem + ")"
+		}
+		return "chan " + elem, ok
+	case xr.Func:
+		return c.funcTypeString(t)
+	case xr.Interface:
+		if t.NumMethod() == 0 {
+			return "interface{}", true
+		}
+	case xr.Map:
+		key, ok1 := c.typeString(t.Key())
+		elem, ok2 := c.typeString(t.Elem())
+		return "map[" + key + "]" + elem, ok1 && ok2
+	case xr.Ptr:
+		elem, ok := c.typeString(t.Elem())
+		return "*" + elem, ok
+	case xr.Slice:
+		elem, ok := c.typeString(t.Elem())
+		return "[]" + elem, ok
+	case xr.Struct:
+		var buf bytes.Buffer
+		buf.WriteString("struct {")
+		for i, n := 0, t.NumField(); i < n; i++ {
+			field := t.Field(i)
+			ftype, ok := c.typeString(field.Type)
+			if !ok {
+				return "", false
+			}
+			if i != 0 {
+				buf.WriteString(";")
+			}
+			if field.Anonymous {
+				fmt.Fprintf(&buf, " %s", ftype)
+			} else {
+				fmt.Fprintf(&buf, " %s %s", field.Name, ftype)
+			}
+			if len(field.Tag) != 0 {
+				fmt.Fprintf(&buf, " %s", strconv.Quote(string(field.Tag)))
+			}
+		}
+		buf.WriteString(" }")
+		return buf.String(), true
+	}
+	return "", false
+}
+
+func (c *Comp) funcTypeString(t xr.Type) (string, bool) {
+	var buf bytes.Buffer
+	buf.WriteString("func(")
+	for i, n := 0, t.NumIn(); i < n; i++ {
+		if i != 0 {
+			buf.WriteString(", ")
+		}
+		arg := t.In(i)
+		if i == n-1 && t.IsVariadic() {
+			buf.WriteString("...")
+			arg = arg.Elem()
+		}
+		str, ok := c.typeString(arg)
+		if !ok {
+			return "", false
+		}
+		buf.WriteString(str)
+	}
+	buf.WriteString(")")
+	n := t.NumOut()
+	if n > 0 {
+		buf.WriteString(" (")
+		for i := 0; i < n; i++ {
+			if i != 0 {
+				buf.WriteString(", ")
+			}
+			str, ok := c.typeString(t.Out(i))
+			if !ok {
+				return "", false
+			}
+			buf.WriteString(str)
+		}
+		buf.WriteString(")")
+	}
+	return buf.String(), true
+}
+
+// valueLiteral converts v to Go source code.
+// If typed is true, basic values are converted to their type t, as needed inside interfaces
+func (c *Comp) valueLiteral(v xr.Value, t xr.Type, typed bool) (string, bool) {
+	var str string
+	switch v.Kind() {
+	case xr.Bool:
+		str = strconv.FormatBool(v.Bool())
+	case xr.Int, xr.Int8, xr.Int16, xr.Int32, xr.Int64:
+		str = strconv.FormatInt(v.Int(), 10)
+	case xr.Uint, xr.Uint8, xr.Uint16, xr.Uint32, xr.Uint64, xr.Uintptr:
+		str = strconv.FormatUint(v.Uint(), 10)
+	case xr.Float32, xr.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return "", false
+		}
+		str = strconv.FormatFloat(f, 'g', -1, t.Bits())
+	case xr.Complex64, xr.Complex128:
+		z := v.Complex()
+		if cmplxIsNaNOrInf(z) {
+			return "", false
+		}
+		bits := t.Bits() / 2
+		str = fmt.Sprintf("complex(%s, %s)",
+			strconv.FormatFloat(real(z), 'g', -1, bits), strconv.FormatFloat(imag(z), 'g', -1, bits))
+	case xr.String:
+		str = strconv.Quote(v.String())
+	case xr.Array, xr.Slice:
+		if v.Kind() == xr.Slice && v.IsNil() {
+			return "nil", true
+		}
+		elems := make([]string, v.Len())
+		for i := range elems {
+			elem, ok := c.valueLiteral(v.Index(i), t.Elem(), false)
+			if !ok {
+				return "", false
+			}
+			elems[i] = elem
+		}
+		return c.compositeLiteral(t, elems)
+	case xr.Map:
+		if v.IsNil() {
+			return "nil", true
+		}
+		elems := make([]string, 0, v.Len())
+		for _, key := range v.MapKeys() {
+			kstr, ok1 := c.valueLiteral(key, t.Key(), false)
+			vstr, ok2 := c.valueLiteral(v.MapIndex(key), t.Elem(), false)
+			if !ok1 || !ok2 {
+				return "", false
+			}
+			elems = append(elems, kstr+": "+vstr)
+		}
+		sort.Strings(elems)
+		return c.compositeLiteral(t, elems)
+	case xr.Struct:
+		var elems []string
+		for i, n := 0, t.NumField(); i < n; i++ {
+			field := t.Field(i)
+			fv := v.Field(i)
+			if fv.IsZero() {
+				continue
+			}
+			if !ast.IsExported(field.Name) && t.Named() && t.PkgPath() != c.FileComp().Path {
+				// cannot set unexported fields of other packages
+				return "", false
+			}
+			fstr, ok := c.valueLiteral(fv, field.Type, false)
+			if !ok {
+				return "", false
+			}
+			name := field.Name
+			if field.Anonymous {
+				name = field.Type.Name()
+			}
+			elems = append(elems, name+": "+fstr)
+		}
+		return c.compositeLiteral(t, elems)
+	case xr.Interface:
+		if v.IsNil() {
+			return "nil", true
+		}
+		elem := v.Elem()
+		return c.valueLiteral(elem, c.Universe.FromReflectType(elem.Type()), true)
+	case xr.Chan, xr.Func, xr.Ptr, xr.UnsafePointer:
+		if v.IsNil() {
+			return "nil", true
+		}
+		return "", false
+	default:
+		return "", false
+	}
+	if typed {
+		tstr, ok := c.typeString(t)
+		if !ok {
+			return "", false
+		}
+		str = tstr + "(" + str + ")"
+	}
+	return str, true
+}
+
+func (c *Comp) compositeLiteral(t xr.Type, elems []string) (string, bool) {
+	tstr, ok := c.typeString(t)
+	if !ok {
+		return "", false
+	}
+	return tstr + "{" + strings.Join(elems, ", ") + "}", true
+}
+
+func cmplxIsNaNOrInf(z complex128) bool {
+	re, im := real(z), imag(z)
+	return math.IsNaN(re) || math.IsInf(re, 0) || math.IsNaN(im) || math.IsInf(im, 0)
+}
+
+// ============================ load ==========================================
+
+// LoadSession reads a session written by SaveSession or WriteSession
+// and executes it in the current interpreter
+func (ir *Interp) LoadSession(filename string) error {
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	g := ir.Comp.CompGlobals
+	saveFilepath, saveLine := g.Filepath, g.Line
+	g.Filepath, g.Line = filename, 0
+	defer func() {
+		g.Filepath, g.Line = saveFilepath, saveLine
+	}()
+	return ir.ReadSession(bytes.NewReader(src))
+}
+
+// ReadSession reads a session written by WriteSession
+// and executes it in the current interpreter
+func (ir *Interp) ReadSession(in io.Reader) (err error) {
+	src, err := ioutil.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	defer ir.commitInput()
+	defer func() {
+		if rec := recover(); rec != nil {
+			if e, ok := rec.(error); ok {
+				err = e
+			} else {
+				err = errors.New(fmt.Sprint(rec))
+			}
+		}
+	}()
+	// the package clause selects the package to load the session into
+	lines := bytes.Split(src, []byte("\n"))
+	for i, line := range lines {
+		line = bytes.TrimSpace(line)
+		if bytes.HasPrefix(line, []byte("package ")) {
+			ir.cmdPackage(string(line[len("package "):]), 0)
+			lines[i] = nil
+			break
+		} else if len(line) != 0 && !bytes.HasPrefix(line, []byte("//")) {
+			break
+		}
+	}
+	nodes := ir.Comp.ParseBytes(bytes.Join(lines, []byte("\n")))
+
+	// imports and macros must be compiled before macroexpanding the other declarations
+	var first, rest []ast.Node
+	for _, node := range nodes {
+		switch decl := node.(type) {
+		case *ast.GenDecl:
+			if decl.Tok == token.IMPORT {
+				first = append(first, node)
+				continue
+			}
+		case *ast.FuncDecl:
+			if isMacroDecl(decl) {
+				first = append(first, node)
+				continue
+			}
+		}
+		rest = append(rest, node)
+	}
+	c := ir.Comp
+	ir.RunExpr(c.Compile(NodeSlice{X: first}))
+	form, _ := c.MacroExpandCodewalk(NodeSlice{X: rest})
+	ir.RunExpr(c.Compile(form))
+	return nil
+}
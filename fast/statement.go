@@ -21,6 +21,7 @@ import (
 	"go/token"
 	r "reflect"
 	"sort"
+	"strings"
 
 	"github.com/cosmos72/gomacro/base"
 	"github.com/cosmos72/gomacro/base/output"
@@ -47,6 +48,13 @@ func (c *Comp) Stmt(in ast.Stmt) {
 	for {
 		if in != nil {
 			c.Pos = in.Pos()
+			if block := c.coverNext; block != nil {
+				if _, ok := in.(*ast.LabeledStmt); !ok {
+					// count executions after the labels, which can be jumped to
+					c.append(c.coverCounter(block))
+					c.coverNext = nil
+				}
+			}
 			if isBreakpoint(in) {
 				c.append(c.breakpoint())
 				break
@@ -86,14 +94,7 @@ func (c *Comp) Stmt(in ast.Stmt) {
 		case *ast.LabeledStmt:
 			label := node.Label.Name
 			labels = append(labels, label)
-			ip := c.Code.Len()
-			if c.Labels == nil {
-				c.Labels = map[string]*int{label: &ip}
-			} else if addr := c.Labels[label]; addr != nil {
-				*addr = ip
-			} else {
-				c.Labels[label] = &ip
-			}
+			c.defineLabel(label)
 			in = node.Stmt
 			continue
 		case *ast.RangeStmt:
@@ -185,9 +186,8 @@ func (c *Comp) List(list []ast.Stmt) {
 
 	c2, locals := c.pushEnvIfLocalBinds(&nbinds, list...)
 
-	for _, node := range list {
-		c2.Stmt(node)
-	}
+	c2.declLabels(list)
+	c2.stmtList(list)
 
 	c2.popEnvIfLocalBinds(locals, &nbinds, list...)
 
@@ -218,7 +218,14 @@ func (c *Comp) Break(node *ast.BranchStmt) {
 	}
 	upn := 0
 	// do not cross function boundaries
-	for o := c; o != nil && o.Func == nil; o = o.Outer {
+	for o := c; o != nil; o = o.Outer {
+		if o.Func != nil {
+			if o.Func.rangeFunc == nil {
+				break
+			}
+			c.rangeFuncBranch(upn, o, node)
+			return
+		}
 		if o.Loop != nil && o.Loop.Break != nil {
 			if len(label) == 0 || o.Loop.HasLabel(label) {
 				// only keep a reference to the jump target, NOT TO THE WHOLE *Comp!
@@ -243,7 +250,14 @@ func (c *Comp) Continue(node *ast.BranchStmt) {
 	}
 	upn := 0
 	// do not cross function boundaries
-	for o := c; o != nil && o.Func == nil; o = o.Outer {
+	for o := c; o != nil; o = o.Outer {
+		if o.Func != nil {
+			if o.Func.rangeFunc == nil {
+				break
+			}
+			c.rangeFuncBranch(upn, o, node)
+			return
+		}
 		if o.Loop != nil && o.Loop.Continue != nil {
 			if len(label) == 0 || o.Loop.HasLabel(label) {
 				// only keep a reference to the jump target, NOT TO THE WHOLE *Comp!
@@ -267,43 +281,155 @@ func (c *Comp) Goto(node *ast.BranchStmt) {
 	}
 	label := node.Label.Name
 	upn := 0
+	var o *Comp
 	// do not cross function boundaries
-	for o := c; o != nil && o.Func == nil; o = o.Outer {
+	for o = c; o != nil; o = o.Outer {
 		if ip := o.Labels[label]; ip != nil {
+			if *ip < 0 {
+				// forward goto: label is declared but not compiled yet.
+				// remember enough information to check for jumps over variable declarations
+				o.Gotos[label] = append(o.Gotos[label], forwardGoto{
+					Pos:        node.Pos(),
+					BindNum:    o.BindNum,
+					IntBindNum: o.IntBindNum,
+				})
+			}
 			// only keep a reference to the jump target, NOT TO THE WHOLE *Comp!
 			c.jumpOut(upn, ip)
 			return
 		}
+		if o.Func != nil {
+			if o.Func.rangeFunc != nil {
+				// jump from the body of a range-over-func loop to outside the loop
+				c.rangeFuncBranch(upn, o, node)
+				return
+			}
+			break
+		}
 		upn += o.UpCost // count how many Env:s we must exit at runtime
 	}
+	if o != nil && o.Func.Labels[label] {
+		c.Errorf("goto %v jumps into block", label)
+	}
 	c.Errorf("goto label not found: %v", label)
 }
 
+// declLabels forward-declares the labels in a statement list.
+// Needed to compile forward gotos, i.e. gotos that jump to a label not yet compiled
+func (c *Comp) declLabels(list []ast.Stmt) {
+	for _, node := range list {
+		labeled, ok := node.(*ast.LabeledStmt)
+		for ok {
+			label := labeled.Label.Name
+			if c.Labels == nil {
+				c.Labels = make(map[string]*int)
+			} else if _, ok := c.Labels[label]; ok {
+				c.Errorf("label %v already defined", label)
+			}
+			ip := -1 // not compiled yet
+			c.Labels[label] = &ip
+			labeled, ok = labeled.Stmt.(*ast.LabeledStmt)
+		}
+	}
+	if c.Labels != nil && c.Gotos == nil {
+		c.Gotos = make(map[string][]forwardGoto)
+	}
+}
+
+// defineLabel sets the jump target of a label to the next statement,
+// and checks the forward gotos that jump to it
+func (c *Comp) defineLabel(label string) {
+	ip := c.Code.Len()
+	if c.Labels == nil {
+		c.Labels = map[string]*int{label: &ip}
+	} else if addr := c.Labels[label]; addr != nil {
+		*addr = ip
+	} else {
+		c.Labels[label] = &ip
+	}
+	for _, g := range c.Gotos[label] {
+		c.checkForwardGoto(label, g)
+	}
+	delete(c.Gotos, label)
+}
+
+// checkForwardGoto checks that a forward goto does not jump over variable declarations.
+// Go specs: "Executing the "goto" statement must not cause any variables to come into scope
+// that were not already in scope at the point of the goto."
+func (c *Comp) checkForwardGoto(label string, g forwardGoto) {
+	var names []string
+	for name, bind := range c.Binds {
+		switch bind.Desc.Class() {
+		case VarBind, FuncBind:
+			if bind.Desc.Index() >= g.BindNum {
+				names = append(names, name)
+			}
+		case IntBind:
+			if bind.Desc.Index() >= g.IntBindNum {
+				names = append(names, name)
+			}
+		}
+	}
+	if len(names) != 0 {
+		sort.Strings(names)
+		c.ErrorAt(g.Pos, "goto %v jumps over variable declaration: %v", label, strings.Join(names, ", "))
+	}
+}
+
+// funcLabels returns all the labels declared in a function body,
+// including the ones in nested blocks but excluding the ones in function literals.
+// Used to produce accurate error messages for goto
+func funcLabels(body *ast.BlockStmt) map[string]bool {
+	var labels map[string]bool
+	if body == nil {
+		return labels
+	}
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.LabeledStmt:
+			if labels == nil {
+				labels = make(map[string]bool)
+			}
+			labels[node.Label.Name] = true
+		}
+		return true
+	})
+	return labels
+}
+
 // Defer compiles a "defer" statement
 func (c *Comp) Defer(node *ast.DeferStmt) {
 	call := c.prepareCall(node.Call, nil)
 	fun := call.Fun.AsX1()
 	argfuns := call.MakeArgfunsX1()
 	ellipsis := call.Ellipsis
-	c.Append(func(env *Env) (Stmt, *Env) {
-		// Go specs: arguments of a defer call are evaluated immediately.
-		// the call itself is executed when the function containing defer returns,
-		// either normally or with a panic
-		f := fun(env)
-		if f.CanSet() {
-			f = f.Convert(f.Type()) // make a copy
-		}
-		args := make([]xr.Value, len(argfuns))
-		for i, argfun := range argfuns {
-			v := argfun(env)
-			if v.CanSet() {
-				v = v.Convert(v.Type()) // make a copy
+	if o, upn := c.enclosingFunc(); o != nil && o.Func.rangeFunc != nil {
+		// defer inside the body of a range-over-func loop:
+		// executed when the function containing the loop returns
+		c.Pos = node.Pos()
+		c.rangeFuncDefer(upn, o, func(env *Env) func() {
+			f, args := deferArgs(env, fun, argfuns)
+			if ellipsis {
+				return func() {
+					f.CallSlice(args)
+				}
 			}
-			args[i] = v
-		}
+			return func() {
+				f.Call(args)
+			}
+		})
+		return
+	}
+	c.Append(func(env *Env) (Stmt, *Env) {
+		f, args := deferArgs(env, fun, argfuns)
 		env.IP++
 		run := env.Run
-		if ellipsis {
+		if direct, ok := deferDirect(f, args); ok {
+			run.InstallDefer = direct
+			run.DeferDirect = true
+		} else if ellipsis {
 			run.InstallDefer = func() {
 				f.CallSlice(args)
 			}
@@ -318,6 +444,37 @@ func (c *Comp) Defer(node *ast.DeferStmt) {
 	c.Code.WithDefers = true
 }
 
+// deferArgs evaluates the function and arguments of a defer call.
+// Go specs: arguments of a defer call are evaluated immediately.
+// the call itself is executed when the function containing defer returns,
+// either normally or with a panic
+func deferArgs(env *Env, fun func(*Env) xr.Value, argfuns []func(*Env) xr.Value) (xr.Value, []xr.Value) {
+	f := fun(env)
+	if f.CanSet() {
+		f = f.Convert(f.Type()) // make a copy
+	}
+	args := make([]xr.Value, len(argfuns))
+	for i, argfun := range argfuns {
+		v := argfun(env)
+		if v.CanSet() {
+			v = v.Convert(v.Type()) // make a copy
+		}
+		args[i] = v
+	}
+	return f, args
+}
+
+// deferDirect returns f as a func() if it's a function without arguments.
+// Such functions are deferred directly, without wrapping them in a closure,
+// so that compiled functions can invoke recover() by themselves
+func deferDirect(f xr.Value, args []xr.Value) (func(), bool) {
+	if len(args) != 0 || f.IsNil() {
+		return nil, false
+	}
+	fun, ok := f.Interface().(func())
+	return fun, ok
+}
+
 // jumpOut compiles a break or continue statement
 // ip is a pointer because the jump target may not be known yet... it will be filled later
 func (c *Comp) jumpOut(upn int, ip *int) {
@@ -332,6 +489,7 @@ func (c *Comp) jumpOut(upn int, ip *int) {
 	case 1:
 		stmt = func(env *Env) (Stmt, *Env) {
 			env = env.Outer
+			env.Run.CurrEnv = env
 			ip := *ip
 			env.IP = ip
 			return env.Code[ip], env
@@ -339,6 +497,7 @@ func (c *Comp) jumpOut(upn int, ip *int) {
 	case 2:
 		stmt = func(env *Env) (Stmt, *Env) {
 			env = env.Outer.Outer
+			env.Run.CurrEnv = env
 			ip := *ip
 			env.IP = ip
 			return env.Code[ip], env
@@ -349,6 +508,7 @@ func (c *Comp) jumpOut(upn int, ip *int) {
 			for i := 3; i < upn; i++ {
 				env = env.Outer
 			}
+			env.Run.CurrEnv = env
 			ip := *ip
 			env.IP = ip
 			return env.Code[ip], env
@@ -403,11 +563,22 @@ func (c *Comp) For(node *ast.ForStmt, labels []string) {
 	}
 	// compile the body
 	c.Block(node.Body)
+	// go1.22: each iteration has its own loop variables,
+	// copied from the previous iteration before executing the post statement
+	pervar := initLocals && c.Globals.LoopVarPerIteration()
+	if pervar {
+		jump.Post = c.Code.Len()
+		c.loopVarPerIteration()
+	}
 	// compile the post
 	if node.Post == nil {
-		jump.Post = jump.Cond // no post statement. "continue" jumps to the condition
+		if !pervar {
+			jump.Post = jump.Cond // no post statement. "continue" jumps to the condition
+		}
 	} else {
-		jump.Post = c.Code.Len()
+		if !pervar {
+			jump.Post = c.Code.Len()
+		}
 		if containLocalBinds(node.Post) {
 			c.Errorf("invalid for: cannot declare new variables in post statement: %v", node.Post)
 		}
@@ -431,6 +602,23 @@ func (c *Comp) For(node *ast.ForStmt, labels []string) {
 	c = c.popEnvIfLocalBinds(initLocals, &initBinds, node.Init)
 }
 
+// loopVarPerIteration compiles a statement that gives a new copy
+// of the variables declared in c to the next iteration of a loop
+func (c *Comp) loopVarPerIteration() {
+	var vals []int
+	for _, bind := range c.Binds {
+		if index := bind.Desc.Index(); index != NoIndex && bind.Desc.Class() == VarBind {
+			vals = append(vals, index)
+		}
+	}
+	sort.Ints(vals)
+	c.append(func(env *Env) (Stmt, *Env) {
+		env = env.copyLoopVars(vals)
+		env.IP++
+		return env.Code[env.IP], env
+	})
+}
+
 // Go compiles a "go" statement i.e. a goroutine
 func (c *Comp) Go(node *ast.GoStmt) {
 	// we must create a new ThreadGlobals with a new Pool.
@@ -449,6 +637,8 @@ func (c *Comp) Go(node *ast.GoStmt) {
 		// keep a reference to c2 only if needed
 		debugC = c2
 	}
+	// os.Exit() panics only if a virtual process is installed
+	process := c.CompGlobals.process != nil
 
 	stmt := func(env *Env) (Stmt, *Env) {
 		tg := env.Run
@@ -472,6 +662,22 @@ func (c *Comp) Go(node *ast.GoStmt) {
 			env2.Run = tg2
 			tg2.glsStore()
 			defer tg2.glsDel()
+			if process {
+				defer tg2.goexitProcess()
+			}
+			if l := tg2.limit; l != nil {
+				l.enter()
+				defer l.goexit()
+			}
+			if h := tg2.loadHooks(); h != nil {
+				goid := tg2.goid
+				if h.GoStart != nil {
+					h.GoStart(goid)
+				}
+				if h.GoEnd != nil {
+					defer h.GoEnd(goid)
+				}
+			}
 
 			funv.Call(argv)
 		}()
@@ -541,8 +747,10 @@ func (c *Comp) If(node *ast.IfStmt) {
 		xelse := node.Else
 		_, ok1 := xelse.(*ast.BlockStmt)
 		_, ok2 := xelse.(*ast.IfStmt)
-		if ok1 || ok2 {
+		if ok1 {
 			c.Stmt(xelse)
+		} else if ok2 {
+			c.stmtList([]ast.Stmt{xelse})
 		} else {
 			c.Block(&ast.BlockStmt{List: []ast.Stmt{xelse}})
 		}
@@ -573,12 +781,18 @@ func (c *Comp) IncDec(node *ast.IncDecStmt) {
 // Return compiles a "return" statement
 func (c *Comp) Return(node *ast.ReturnStmt) {
 	var cinfo *FuncInfo
-	var upn int
-	var cf *Comp
+	var upn, bodyupn int
+	var cf, body *Comp
 	for cf = c; cf != nil; cf = cf.Outer {
 		if cf.Func != nil {
-			cinfo = cf.Func
-			break
+			if cf.Func.rangeFunc == nil {
+				cinfo = cf.Func
+				break
+			} else if body == nil {
+				// return from the body of a range-over-func loop:
+				// also return from the function containing the loop
+				body, bodyupn = cf, upn
+			}
 		}
 		upn += cf.UpCost // count how many Env:s we must exit at runtime
 	}
@@ -597,7 +811,7 @@ func (c *Comp) Return(node *ast.ReturnStmt) {
 		if n == 0 {
 			c.Errorf("return: expecting %d expressions, found %d: %v", n, len(resultExprs), node)
 		}
-		c.returnMultiValues(node, resultBinds, upn, resultExprs)
+		c.returnMultiValues(node, resultBinds, upn, resultExprs, body, bodyupn)
 		return
 	case 0:
 		if !cinfo.NamedResults {
@@ -616,11 +830,17 @@ func (c *Comp) Return(node *ast.ReturnStmt) {
 		c.Pos = resultExprs[i].Pos()
 		c.SetVar(resultBinds[i].AsVar(upn, PlaceSettable), token.ASSIGN, exprs[i])
 	}
-	c.Append(stmtReturn, node.Pos())
+	c.Pos = node.Pos()
+	if body != nil {
+		c.returnFromRangeFuncBody(body, bodyupn)
+	} else {
+		c.append(stmtReturn)
+	}
 }
 
-// returnMultiValues compiles a "return foo()" statement where foo() returns multiple values
-func (c *Comp) returnMultiValues(node *ast.ReturnStmt, resultBinds []*Bind, upn int, exprs []ast.Expr) {
+// returnMultiValues compiles a "return foo()" statement where foo() returns multiple values.
+// body is non-nil if the statement is inside the body of a range-over-func loop
+func (c *Comp) returnMultiValues(node *ast.ReturnStmt, resultBinds []*Bind, upn int, exprs []ast.Expr, body *Comp, bodyupn int) {
 	n := len(resultBinds)
 	e := c.ExprsMultipleValues(exprs, n)[0]
 	fun := e.AsXV(COptDefaults)
@@ -633,6 +853,19 @@ func (c *Comp) returnMultiValues(node *ast.ReturnStmt, resultBinds []*Bind, upn
 		}
 		assigns[i] = c.varSetValue(resultBinds[i].AsVar(upn, PlaceSettable))
 	}
+	if body != nil {
+		c.Append(func(env *Env) (Stmt, *Env) {
+			_, vals := fun(env)
+			for i, assign := range assigns {
+				assign(env, vals[i])
+			}
+			env.IP++
+			return env.Code[env.IP], env
+		}, node.Pos())
+		c.Pos = node.Pos()
+		c.returnFromRangeFuncBody(body, bodyupn)
+		return
+	}
 	c.Append(func(env *Env) (Stmt, *Env) {
 		// no risk in evaluating fun() first: return binds are plain variables, not places with side effects
 		_, vals := fun(env)
@@ -662,6 +895,13 @@ func containLocalBinds(list ...ast.Stmt) bool {
 		output.Errorf("internal error: containLocalBinds() invoked on empty statement list")
 	}
 	for _, node := range list {
+		for {
+			labeled, ok := node.(*ast.LabeledStmt)
+			if !ok {
+				break
+			}
+			node = labeled.Stmt
+		}
 		switch node := node.(type) {
 		case *ast.AssignStmt:
 			if node.Tok == token.DEFINE {
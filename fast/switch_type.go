@@ -303,52 +303,14 @@ func (c *Comp) typeswitchCase(node *ast.CaseClause, varname string, bind *Bind,
 				env.IP = ip
 				return env.Code[ip], env
 			}
-		} else if t.Kind() == r.Interface && xr.IsEmulatedInterface(t) {
-			// case emulated_interface:
-			stmt = func(env *Env) (Stmt, *Env) {
-				v := env.Vals[idx]
-				// Debugf("typeswitchCase: comparing %v <%v> against interface type %v", v, Type(v), rtype)
-				ip := iend
-				if v.IsValid() {
-					// rtype may be an interpreted type:
-					// extract the concrete xr.Type and use it
-					xtv := env.Vals[idx+1]
-					if xtv.IsValid() && !xtv.IsNil() {
-						xt := xtv.Interface().(xr.Type)
-						if xt.Implements(t) {
-							ip = env.IP + 1
-							// need the compiler at run-time :(
-							conv := c.converterToEmulatedInterface(xt, t)
-							env.Vals[idx] = conv(v)
-						}
-					}
-				}
-				env.IP = ip
-				return env.Code[ip], env
-			}
 		} else if t.Kind() == r.Interface {
 			// case interface:
+			asserter := c.interfaceAsserter(t)
 			stmt = func(env *Env) (Stmt, *Env) {
-				v := env.Vals[idx]
-				// Debugf("typeswitchCase: comparing %v <%v> against interface type %v", v, Type(v), rtype)
 				ip := iend
-				if v.IsValid() {
-					if v.Type().Implements(rtype) {
-						ip = env.IP + 1
-					} else {
-						// rtype may be an interpreted type:
-						// extract the concrete xr.Type and use it
-						xtv := env.Vals[idx+1]
-						if xtv.IsValid() && !xtv.IsNil() {
-							xt := xtv.Interface().(xr.Type)
-							if xt.Implements(t) {
-								ip = env.IP + 1
-								// need the compiler at run-time :(
-								conv := c.converterToProxy(xt, t)
-								env.Vals[idx] = conv(v)
-							}
-						}
-					}
+				if v, ok := asserter.assert(env.Vals[idx], typeswitchConcreteType(env, idx)); ok {
+					ip = env.IP + 1
+					env.Vals[idx] = v
 				}
 				env.IP = ip
 				return env.Code[ip], env
@@ -377,25 +339,35 @@ func (c *Comp) typeswitchCase(node *ast.CaseClause, varname string, bind *Bind,
 			}
 		}
 	default:
+		asserters := make([]*interfaceAsserter, len(ts))
+		for i, t := range ts {
+			if t != nil && t.Kind() == r.Interface {
+				asserters[i] = c.interfaceAsserter(t)
+			}
+		}
 		stmt = func(env *Env) (Stmt, *Env) {
 			v := env.Vals[idx]
+			xt := typeswitchConcreteType(env, idx)
 			var vt r.Type
 			if v.IsValid() {
 				vt = v.Type()
 			}
 			// Debugf("typeswitchCase: comparing %v <%v> against types %v", v, vt, rtypes)
 			ip := iend
-			for _, rtype := range rtypes {
+			for i, t := range ts {
 				switch {
-				case vt == rtype:
-				case rtype != nil:
-					if rtype.Kind() != r.Interface || !vt.Implements(rtype) {
+				case t == nil:
+					if v.IsValid() {
 						continue
 					}
-				default: // rtype == nil
-					if v.IsValid() {
+				case asserters[i] != nil:
+					// the variable declared by a case with multiple types has the type of the tag:
+					// no need to convert v
+					if _, ok := asserters[i].assert(v, xt); !ok {
 						continue
 					}
+				case vt != rtypes[i] || (xt != nil && !xt.IdenticalTo(t)):
+					continue
 				}
 				// Debugf("typeswitchCase: v <%v> matches type %v", v, vt, rtype)
 				ip = env.IP + 1
@@ -416,6 +388,15 @@ func (c *Comp) typeswitchCase(node *ast.CaseClause, varname string, bind *Bind,
 	iend = c.Code.Len()
 }
 
+// return the concrete xr.Type saved by typeswitchTag, or nil if not known
+func typeswitchConcreteType(env *Env, idx int) xr.Type {
+	xtv := env.Vals[idx+1]
+	if xtv.IsValid() && !xtv.IsNil() {
+		return xtv.Interface().(xr.Type)
+	}
+	return nil
+}
+
 // typeswitchDefault compiles the default case in a type-switch.
 func (c *Comp) typeswitchDefault(node *ast.CaseClause, varname string, bind *Bind) {
 	var iend int
@@ -450,9 +431,8 @@ func (c *Comp) typeswitchBody(list []ast.Stmt, varname string, t xr.Type, bind *
 		// cannot simply use sym as varname initializer: it returns the wrong type
 		c2.typeswitchVar(varname, t, sym)
 	}
-	for _, stmt := range list {
-		c2.Stmt(stmt)
-	}
+	c2.declLabels(list)
+	c2.stmtList(list)
 	c2.jumpOut(c2.UpCost, c.Loop.Break)
 	c2.popEnvIfLocalBinds(locals2, &nbinds, list1...)
 }
@@ -0,0 +1,243 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * trace.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package fast
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cosmos72/gomacro/gls"
+	xr "github.com/cosmos72/gomacro/xreflect"
+)
+
+// TraceEvent describes a call to a traced function, or its end.
+// It is the format of JSON lines written to the output set by SetTraceOutput
+type TraceEvent struct {
+	Event   string        `json:"event"` // "call", "return" or "panic"
+	Func    string        `json:"func"`
+	Depth   int           `json:"depth"` // Env.CallDepth of the caller
+	Args    []string      `json:"args,omitempty"`
+	Results []string      `json:"results,omitempty"`
+	Panic   string        `json:"panic,omitempty"`
+	Elapsed time.Duration `json:"elapsed,omitempty"` // nanoseconds, only for "return" and "panic"
+}
+
+// tracer contains the functions traced by Interp.Trace
+type tracer struct {
+	mu    sync.Mutex
+	out   io.Writer // JSON lines output. if nil, use Globals.Fprintf
+	file  io.Closer // set by :trace -json FILE
+	funcs map[string]*tracedFunc
+}
+
+type tracedFunc struct {
+	binds   *EnvBinds
+	index   int
+	orig    xr.Value
+	wrapper xr.Value
+}
+
+func (g *CompGlobals) getTracer() *tracer {
+	if g.tracer == nil {
+		g.tracer = &tracer{funcs: make(map[string]*tracedFunc)}
+	}
+	return g.tracer
+}
+
+// Trace logs each call to the function name, with its arguments, results,
+// panics and elapsed time. name can be a function of the current package,
+// or PKG.NAME for a function of an imported package, either interpreted or compiled:
+// in the latter case, only calls compiled after Trace are logged
+func (ir *Interp) Trace(name string) error {
+	binds, bind, err := ir.traceTarget(name)
+	if err != nil {
+		return err
+	}
+	t := ir.Comp.getTracer()
+	index := bind.Desc.Index()
+	if f := t.funcs[name]; f != nil && f.binds == binds && f.index == index && binds.Vals[index] == f.wrapper {
+		return nil // already traced
+	}
+	orig := binds.Vals[index]
+	if !orig.IsValid() || orig.IsNil() {
+		return fmt.Errorf("cannot trace %s: function has no value", name)
+	}
+	f := &tracedFunc{binds: binds, index: index, orig: orig}
+	f.wrapper = xr.MakeFunc(bind.Type, ir.Comp.CompGlobals.traceWrapper(name, bind.Type, orig))
+	binds.Vals[index] = f.wrapper
+	t.funcs[name] = f
+	return nil
+}
+
+// Untrace stops logging calls to the function name traced with Trace.
+// If name is empty, stops logging all traced functions
+func (ir *Interp) Untrace(name string) error {
+	t := ir.Comp.tracer
+	if len(name) == 0 {
+		if t != nil {
+			for name := range t.funcs {
+				t.untrace(name)
+			}
+		}
+		return nil
+	} else if t == nil || t.funcs[name] == nil {
+		return fmt.Errorf("cannot untrace %s: not traced", name)
+	}
+	t.untrace(name)
+	return nil
+}
+
+func (t *tracer) untrace(name string) {
+	f := t.funcs[name]
+	// do not restore functions redefined after Trace
+	if f.binds.Vals[f.index] == f.wrapper {
+		f.binds.Vals[f.index] = f.orig
+	}
+	delete(t.funcs, name)
+}
+
+// Traced returns the sorted names of functions traced with Trace
+func (ir *Interp) Traced() []string {
+	t := ir.Comp.tracer
+	if t == nil {
+		return nil
+	}
+	var list []string
+	for name := range t.funcs {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// SetTraceOutput sets where to log calls to traced functions:
+// if out is nil, they are shown with Globals.Fprintf.
+// Otherwise they are written to out as JSON lines, one TraceEvent per line
+func (ir *Interp) SetTraceOutput(out io.Writer) {
+	t := ir.Comp.getTracer()
+	t.mu.Lock()
+	if t.file != nil {
+		t.file.Close()
+		t.file = nil
+	}
+	t.out = out
+	t.mu.Unlock()
+}
+
+// traceTarget returns the binds and the bind of function name
+func (ir *Interp) traceTarget(name string) (*EnvBinds, *Bind, error) {
+	c := ir.Comp
+	var binds *EnvBinds
+	var bind *Bind
+	if dot := strings.IndexByte(name, '.'); dot >= 0 {
+		pkg, fname := name[:dot], name[dot+1:]
+		sym := c.TryResolve(pkg)
+		var imp *Import
+		if sym != nil && sym.Desc.Class() == ConstBind {
+			imp, _ = sym.Value.(*Import)
+		}
+		if imp == nil {
+			return nil, nil, fmt.Errorf("cannot trace %s: %s is not an imported package", name, pkg)
+		}
+		binds, bind = imp.EnvBinds, imp.Binds[fname]
+	} else {
+		binds, bind = &ir.PrepareEnv().EnvBinds, c.Binds[name]
+	}
+	if bind == nil {
+		return nil, nil, fmt.Errorf("cannot trace %s: not declared", name)
+	} else if bind.Desc.Class() != FuncBind || bind.Type.Kind() != xr.Func {
+		return nil, nil, fmt.Errorf("cannot trace %s: not a function", name)
+	} else if index := bind.Desc.Index(); index < 0 || index >= len(binds.Vals) {
+		return nil, nil, fmt.Errorf("cannot trace %s: function has no value", name)
+	}
+	return binds, bind, nil
+}
+
+// traceWrapper returns a function that logs the calls to orig
+func (g *CompGlobals) traceWrapper(name string, t xr.Type, orig xr.Value) func([]xr.Value) []xr.Value {
+	variadic := t.IsVariadic()
+	return func(args []xr.Value) (rets []xr.Value) {
+		depth := 0
+		if run := g.glsGet(gls.GoID()); run != nil && run.CurrEnv != nil {
+			depth = run.CurrEnv.CallDepth
+		}
+		strargs := g.traceStrings(args)
+		g.traceEvent(&TraceEvent{Event: "call", Func: name, Depth: depth, Args: strargs})
+		start := time.Now()
+		panicking := true
+		defer func() {
+			if !panicking {
+				return
+			}
+			rec := recover()
+			g.traceEvent(&TraceEvent{Event: "panic", Func: name, Depth: depth, Args: strargs,
+				Panic: fmt.Sprint(rec), Elapsed: time.Since(start)})
+			panic(rec)
+		}()
+		if variadic {
+			rets = orig.CallSlice(args)
+		} else {
+			rets = orig.Call(args)
+		}
+		panicking = false
+		g.traceEvent(&TraceEvent{Event: "return", Func: name, Depth: depth, Args: strargs,
+			Results: g.traceStrings(rets), Elapsed: time.Since(start)})
+		return rets
+	}
+}
+
+func (g *CompGlobals) traceStrings(vals []xr.Value) []string {
+	strs := make([]string, len(vals))
+	for i, v := range vals {
+		if v.IsValid() && v.Kind() == xr.String {
+			strs[i] = strconv.Quote(v.String())
+		} else {
+			strs[i] = g.Sprintf("%v", v.ReflectValue())
+		}
+	}
+	return strs
+}
+
+func (g *CompGlobals) traceEvent(e *TraceEvent) {
+	t := g.tracer
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.out != nil {
+		if line, err := json.Marshal(e); err == nil {
+			t.out.Write(append(line, '\n'))
+		}
+		return
+	}
+	indent := strings.Repeat("  ", e.Depth)
+	call := e.Func + "(" + strings.Join(e.Args, ", ") + ")"
+	switch e.Event {
+	case "call":
+		g.Fprintf(g.Stdout, "// trace: %s%s\n", indent, call)
+	case "return":
+		if len(e.Results) != 0 {
+			call += " = " + strings.Join(e.Results, ", ")
+		}
+		g.Fprintf(g.Stdout, "// trace: %s%s [%v]\n", indent, call, e.Elapsed)
+	case "panic":
+		g.Fprintf(g.Stdout, "// trace: %s%s panic: %s [%v]\n", indent, call, e.Panic, e.Elapsed)
+	}
+}
@@ -35,7 +35,7 @@ func (c *Comp) DeclType(spec ast.Spec) {
 	if !ok {
 		c.Errorf("unexpected type declaration, expecting *ast.TypeSpec, found: %v // %T", spec, spec)
 	}
-	if GENERICS_V1_CXX() || GENERICS_V2_CTI() {
+	if GENERICS_ENABLED() {
 		if lit, _ := node.Type.(*ast.CompositeLit); lit != nil {
 			c.DeclGenericType(node)
 			return
@@ -65,6 +65,7 @@ func (c *Comp) DeclType(spec ast.Spec) {
 	u := c.Type(node.Type)
 	if t != nil { // t == nil means name == "_", discard the result of type declaration
 		c.SetUnderlyingType(t, u)
+		c.setTypeConstraint(t, node.Type)
 	}
 	panicking = false
 }
@@ -217,7 +218,7 @@ func (c *Comp) compileType2(node ast.Expr, allowEllipsis bool) (t xr.Type, ellip
 	case *ast.Ident:
 		t = c.ResolveType(node.Name)
 	case *ast.IndexExpr:
-		if GENERICS_V1_CXX() || GENERICS_V2_CTI() {
+		if GENERICS_ENABLED() {
 			t = c.GenericType(node)
 		} else {
 			c.Errorf("unimplemented type: %v <%v>", node, r.TypeOf(node))
@@ -252,6 +253,7 @@ func (c *Comp) compileType2(node ast.Expr, allowEllipsis bool) (t xr.Type, ellip
 			c.Errorf("not a package: %q in %v <%v>", name, node, r.TypeOf(node))
 		}
 		name = node.Sel.Name
+		c.checkSymbol(imp, name)
 		t, ok = imp.Types[name]
 		if !ok || t == nil {
 			c.Errorf("not a type: %v <%v>", node, r.TypeOf(node))
@@ -492,36 +494,16 @@ func (c *Comp) TypeAssert2(node *ast.TypeAssertExpr) *Expr {
 			}
 			break
 		}
-		if tin.Implements(tout) {
-			// type assertion to interface.
-			// expression type implements such interface, can only fail if value is nil
-			ret = func(env *Env) (xr.Value, []xr.Value) {
-				v, _ := extractor(fun(env))
-				// nil is not a valid tout, check for it.
-				// IsNil() can be invoked only on nillable types...
-				if reflect.IsNillableKind(v.Kind()) && (!v.IsValid() || v.IsNil()) {
-					return fail[0], fail
-				}
-				v = convert(v, rtout)
-				return v, []xr.Value{v, True}
-			}
-			break
-		}
-		// type assertion to interface
-		// must check at runtime whether concrete type implements asserted interface
+		// type assertion to interface.
+		// must check at runtime whether concrete type implements asserted interface,
+		// even if tin.Implements(tout): an interpreted type stored in tin
+		// cannot be converted to a compiled interface with reflect.Value.Convert
+		asserter := c.interfaceAsserter(tout)
 		ret = func(env *Env) (xr.Value, []xr.Value) {
-			v, t := extractor(fun(env))
-			// nil is not a valid tout, check for it.
-			// IsNil() can be invoked only on nillable types...
-			if reflect.IsNillableKind(v.Kind()) && (!v.IsValid() || v.IsNil()) {
+			v, ok := asserter.assert(extractor(fun(env)))
+			if !ok {
 				return fail[0], fail
 			}
-			rt := rtypeof(v, t)
-			if (rt != rtout && !rt.Implements(rtout)) ||
-				(t != nil && !t.IdenticalTo(tout) && !t.Implements(tout)) {
-				return fail[0], fail
-			}
-			v = convert(v, rtout)
 			return v, []xr.Value{v, True}
 		}
 
@@ -693,34 +675,24 @@ func (c *Comp) TypeAssert1(node *ast.TypeAssertExpr) *Expr {
 				}
 				return convert(v, rtout)
 			}
-		} else if tin.Implements(tout) {
-			// type assertion to interface.
-			// expression type implements such interface, can only fail if value is nil
-			ret = func(env *Env) xr.Value {
-				v, _ := extractor(fun(env))
-				// nil is not a valid tout, check for it.
-				// IsNil() can be invoked only on nillable types...
-				if reflect.IsNillableKind(v.Kind()) && (!v.IsValid() || v.IsNil()) {
-					typeassertpanic(nil, nil, tin, tout)
-				}
-				return convert(v, rtout)
-			}
 		} else {
 			// type assertion to interface.
-			// must check at runtime whether concrete type implements asserted interface
+			// must check at runtime whether concrete type implements asserted interface,
+			// even if tin.Implements(tout): see TypeAssert2 above
+			asserter := c.interfaceAsserter(tout)
 			ret = func(env *Env) xr.Value {
 				v, t := extractor(fun(env))
-				// nil is not a valid tout, check for it.
-				// IsNil() can be invoked only on nillable types...
-				if reflect.IsNillableKind(v.Kind()) && (!v.IsValid() || v.IsNil()) {
-					typeassertpanic(nil, nil, tin, tout)
-				}
-				rt := rtypeof(v, t)
-				if (rt != rtout && !rt.AssignableTo(rtout) && !rt.Implements(rtout)) ||
-					(t != nil && !t.AssignableTo(tout) && !t.Implements(tout)) {
-					typeassertpanic(rt, t, tin, tout)
+				ret, ok := asserter.assert(v, t)
+				if !ok {
+					if v.Kind() == r.Interface {
+						v = v.Elem()
+					}
+					if !v.IsValid() || v == None {
+						typeassertpanic(nil, nil, tin, tout)
+					}
+					typeassertpanic(rtypeof(v, t), t, tin, tout)
 				}
-				return convert(v, rtout)
+				return ret
 			}
 		}
 	default:
@@ -0,0 +1,364 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * undo.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package fast
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	r "reflect"
+	"sort"
+	"strings"
+
+	"github.com/cosmos72/gomacro/base"
+	"github.com/cosmos72/gomacro/base/dep"
+	xr "github.com/cosmos72/gomacro/xreflect"
+)
+
+// undoStep records the binds, types and declarations
+// changed by a top-level input, with their previous value. nil means absent
+type undoStep struct {
+	binds  map[string]*Bind
+	types  map[string]xr.Type
+	decls  map[string]ast.Node
+	values map[*Bind]savedValue // values of redefined binds, whose index was reused
+}
+
+type savedValue struct {
+	val  xr.Value
+	ints [2]uint64
+	body *funcBody // for functions: the body executed by val
+}
+
+func newUndoStep() *undoStep {
+	return &undoStep{
+		binds:  make(map[string]*Bind),
+		types:  make(map[string]xr.Type),
+		decls:  make(map[string]ast.Node),
+		values: make(map[*Bind]savedValue),
+	}
+}
+
+func (step *undoStep) empty() bool {
+	return len(step.binds) == 0 && len(step.types) == 0 && len(step.decls) == 0 && len(step.values) == 0
+}
+
+func (step *undoStep) setBind(name string, old *Bind) {
+	if _, ok := step.binds[name]; !ok {
+		step.binds[name] = old
+	}
+}
+
+func (step *undoStep) setType(name string, old xr.Type) {
+	if _, ok := step.types[name]; !ok {
+		step.types[name] = old
+	}
+}
+
+// intSlots returns the number of Env.Ints slots used by bind
+func intSlots(bind *Bind) int {
+	if bind.Type.Kind() == r.Complex128 {
+		return 2
+	}
+	return 1
+}
+
+// saveValue saves the current value of bind, because a redefinition
+// is going to reuse its index
+func (s *session) saveValue(bind *Bind) {
+	p, env := s.pending, s.env
+	if p == nil || env == nil {
+		return
+	} else if _, ok := p.values[bind]; ok {
+		return
+	}
+	var saved savedValue
+	index := bind.Desc.Index()
+	switch bind.Desc.Class() {
+	case FuncBind, VarBind:
+		if index < 0 || index >= len(env.Vals) || !env.Vals[index].IsValid() {
+			return
+		}
+		// copy the value: variables are modified in place
+		v := env.Vals[index]
+		saved.val = xr.NewR(v.Type()).Elem()
+		saved.val.Set(v)
+		if bind.cell != nil {
+			// a compatible redefinition replaces the body, not the function value
+			saved.body = bind.cell.load()
+		}
+	case IntBind:
+		n := intSlots(bind)
+		if index < 0 || index+n > len(env.Ints) {
+			return
+		}
+		copy(saved.ints[:n], env.Ints[index:])
+	default:
+		return
+	}
+	p.values[bind] = saved
+}
+
+// restoreValue restores the value of bind saved by saveValue
+func (s *session) restoreValue(bind *Bind, saved savedValue) {
+	env := s.env
+	index := bind.Desc.Index()
+	switch bind.Desc.Class() {
+	case FuncBind, VarBind:
+		if index < len(env.Vals) {
+			env.Vals[index] = saved.val
+		}
+		if bind.cell != nil && saved.body != nil {
+			bind.cell.store(saved.body)
+		}
+	case IntBind:
+		if n := intSlots(bind); index+n <= len(env.Ints) {
+			copy(env.Ints[index:index+n], saved.ints[:n])
+		}
+	}
+}
+
+// reset discards the undo history and takes c.Binds and c.Types as the known state
+func (s *session) reset(c *Comp) {
+	s.knownBinds = copyBinds(c.Binds)
+	s.knownTypes = copyTypes(c.Types)
+	s.pending = newUndoStep()
+	s.history = nil
+}
+
+// commit compares c.Binds and c.Types with the known state,
+// and appends the differences to the undo history
+func (s *session) commit(c *Comp) {
+	step := s.pending
+	for name, bind := range c.Binds {
+		if old := s.knownBinds[name]; old != bind {
+			step.setBind(name, old)
+		}
+	}
+	for name, old := range s.knownBinds {
+		if _, ok := c.Binds[name]; !ok {
+			step.setBind(name, old)
+		}
+	}
+	for name, t := range c.Types {
+		if old := s.knownTypes[name]; old == nil || !old.IdenticalTo(t) {
+			step.setType(name, old)
+		}
+	}
+	for name, old := range s.knownTypes {
+		if c.Types[name] == nil {
+			step.setType(name, old)
+		}
+	}
+	if !step.empty() {
+		s.history = append(s.history, step)
+		s.pending = newUndoStep()
+		s.knownBinds = copyBinds(c.Binds)
+		s.knownTypes = copyTypes(c.Types)
+	}
+}
+
+func copyBinds(binds map[string]*Bind) map[string]*Bind {
+	ret := make(map[string]*Bind, len(binds))
+	for name, bind := range binds {
+		ret[name] = bind
+	}
+	return ret
+}
+
+func copyTypes(types map[string]xr.Type) map[string]xr.Type {
+	ret := make(map[string]xr.Type, len(types))
+	for name, t := range types {
+		ret[name] = t
+	}
+	return ret
+}
+
+// commitInput records the declarations made by the current top-level input, for Undo
+func (ir *Interp) commitInput() {
+	if c := ir.Comp; c.session != nil {
+		c.session.commit(c)
+	}
+}
+
+// Undo reverts the declarations made by the last n top-level inputs
+// in the current package: declarations are removed, and redefined ones are restored.
+// Other effects, as assignments to variables or output, are not reverted,
+// and inputs that declared nothing are not counted.
+//
+// Returns the number of reverted inputs, and the names of the remaining
+// declarations that use reverted ones: they keep using the reverted
+// definitions until they are redefined
+func (ir *Interp) Undo(n int) (undone int, dependents []string) {
+	c := ir.Comp
+	s := c.session
+	if s == nil {
+		return 0, nil
+	}
+	s.commit(c)
+	s.pending = nil // do not record the changes made by undo
+	var changed []string
+	for ; undone < n && len(s.history) != 0; undone++ {
+		last := len(s.history) - 1
+		changed = append(changed, c.undo(s.history[last])...)
+		s.history = s.history[:last]
+	}
+	s.pending = newUndoStep()
+	s.knownBinds = copyBinds(c.Binds)
+	s.knownTypes = copyTypes(c.Types)
+	return undone, c.dependents(changed)
+}
+
+// undo reverts step. Returns the names of changed binds and types
+func (c *Comp) undo(step *undoStep) []string {
+	var changed []string
+	for name, bind := range step.binds {
+		if bind == nil {
+			delete(c.Binds, name)
+		} else {
+			if c.Binds == nil {
+				c.Binds = make(map[string]*Bind)
+			}
+			c.Binds[name] = bind
+		}
+		changed = append(changed, name)
+	}
+	for bind, saved := range step.values {
+		if c.Binds[bind.Name] == bind {
+			c.session.restoreValue(bind, saved)
+		}
+	}
+	for name, t := range step.types {
+		if t == nil {
+			delete(c.Types, name)
+		} else {
+			if c.Types == nil {
+				c.Types = make(map[string]xr.Type)
+			}
+			c.Types[name] = t
+		}
+		changed = append(changed, name)
+	}
+	for key, node := range step.decls {
+		if decl, ok := c.session.decls[key].(*ast.FuncDecl); ok && decl.Recv != nil && len(decl.Recv.List) != 0 {
+			if _, ok := step.types[key[:strings.IndexByte(key, '.')]]; !ok {
+				c.Warnf("cannot undo method %s: methods cannot be removed from existing types", key)
+			}
+		}
+		c.session.set(key, node)
+	}
+	return changed
+}
+
+// Forget removes the declaration of name from the current package,
+// together with its methods if name is a type.
+//
+// Returns the names of the remaining declarations that use name:
+// they keep using the removed declaration until they are redefined.
+// Forget can be reverted with Undo
+func (ir *Interp) Forget(name string) (dependents []string, err error) {
+	c := ir.Comp
+	_, isBind := c.Binds[name]
+	_, isType := c.Types[name]
+	if !isBind && !isType {
+		return nil, fmt.Errorf("cannot forget %s: not declared in package %q", name, c.Path)
+	}
+	s := c.session
+	if s != nil {
+		s.commit(c)
+	}
+	delete(c.Binds, name)
+	delete(c.Types, name)
+	if s != nil {
+		for key := range s.decls {
+			if key == name || strings.HasPrefix(key, name+".") {
+				s.set(key, nil)
+			}
+		}
+		s.commit(c)
+	}
+	return c.dependents([]string{name}), nil
+}
+
+// dependents returns the sorted names of recorded declarations
+// that still exist, are not in names, and use one of names
+func (c *Comp) dependents(names []string) []string {
+	s := c.session
+	if s == nil || len(names) == 0 {
+		return nil
+	}
+	set := make(map[string]bool)
+	for _, name := range names {
+		set[name] = true
+	}
+	var list []string
+	for _, key := range s.order {
+		if set[key] || !c.declared(key) {
+			continue
+		}
+		if dot := strings.IndexByte(key, '.'); dot >= 0 && set[key[:dot]] {
+			// method of a changed type
+			continue
+		}
+		node := s.decls[key]
+		if spec, ok := node.(*ast.ValueSpec); ok {
+			node = &ast.GenDecl{Tok: token.VAR, Specs: []ast.Spec{spec}}
+		}
+		for _, name := range dep.NewScope(nil).Node(genDecl(node)) {
+			if set[name] {
+				list = append(list, key)
+				break
+			}
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
+// declared returns true if the recorded declaration 'key' still exists
+func (c *Comp) declared(key string) bool {
+	if strings.HasPrefix(key, "import ") {
+		return false
+	} else if dot := strings.IndexByte(key, '.'); dot >= 0 {
+		// method
+		tname := key[:dot]
+		return c.Types[tname] != nil || c.bindClass(tname) == GenericTypeBind
+	}
+	return c.Binds[key] != nil || c.Types[key] != nil
+}
+
+// Reset discards all the declarations and imports of the interpreter,
+// and the packages created with ChangePackage, then switches to package main.
+// Already loaded packages, including compiled plugins, can be imported again
+// without loading them. Options, policy, process and limits are preserved
+func (ir *Interp) Reset() {
+	c := ir.Comp
+	g := c.CompGlobals
+	for path, imp := range g.KnownImports {
+		if imp.session != nil {
+			// created with ChangePackage
+			delete(g.KnownImports, path)
+		}
+	}
+	top := &Interp{c.TopComp(), ir.env.Top()}
+	*ir = *NewInnerInterp(top, "main", "main")
+	ir.env.UsedByClosure = true // do not free this *Env
+	if g.Options&base.OptDebugger != 0 {
+		ir.env.DebugComp = ir.Comp
+	}
+	ir.env.Run.Globals.PackagePath = "main"
+	ir.enableSession()
+}
@@ -0,0 +1,192 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * unsafe.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package fast
+
+import (
+	"go/ast"
+	r "reflect"
+	"unsafe"
+
+	"github.com/cosmos72/gomacro/base"
+	xr "github.com/cosmos72/gomacro/xreflect"
+)
+
+// add the builtin functions Alignof, Offsetof and Sizeof to imported package "unsafe"
+func (imp *Import) loadUnsafeBuiltins(g *CompGlobals) {
+	t := g.TypeOfBuiltin()
+	o := &g.Output
+	for name, builtin := range map[string]Builtin{
+		"Alignof":  {compileAlignof, 1, 1},
+		"Offsetof": {compileOffsetof, 1, 1},
+		"Sizeof":   {compileSizeof, 1, 1},
+	} {
+		bind := imp.CompBinds.NewBind(o, name, ConstBind, t)
+		bind.Value = builtin
+	}
+}
+
+// checkUnsafe panics if the interpreter option OptUnsafe is not set
+func (c *Comp) checkUnsafe(what string, node ast.Node) {
+	if c.Globals.Options&base.OptUnsafe == 0 {
+		c.Errorf("cannot use %s: package unsafe is disabled, enable it with %coptions Unsafe: %v",
+			what, c.Globals.ReplCmdChar, node)
+	}
+}
+
+// --- Alignof(), Sizeof() ---
+
+func compileAlignof(c *Comp, sym Symbol, node *ast.CallExpr) *Call {
+	t := c.unsafeArgType(sym, node)
+	return c.unsafeConstCall(sym, uintptr(t.Align()))
+}
+
+func compileSizeof(c *Comp, sym Symbol, node *ast.CallExpr) *Call {
+	t := c.unsafeArgType(sym, node)
+	return c.unsafeConstCall(sym, t.Size())
+}
+
+// return the type of the argument of unsafe.Alignof() or unsafe.Sizeof()
+func (c *Comp) unsafeArgType(sym Symbol, node *ast.CallExpr) xr.Type {
+	c.checkUnsafe("unsafe."+sym.Name, node)
+	// argument is not evaluated, we only need its type
+	arg := c.expr1(node.Args[0], nil)
+	if arg.Untyped() {
+		arg.ConstTo(arg.DefaultType())
+	}
+	if arg.Type == nil {
+		c.Errorf("use of untyped nil in unsafe.%s(): %v", sym.Name, node)
+	}
+	return arg.Type.Resolve()
+}
+
+// --- Offsetof() ---
+
+func compileOffsetof(c *Comp, sym Symbol, node *ast.CallExpr) *Call {
+	c.checkUnsafe("unsafe."+sym.Name, node)
+	arg := node.Args[0]
+	for {
+		if paren, ok := arg.(*ast.ParenExpr); ok {
+			arg = paren.X
+		} else {
+			break
+		}
+	}
+	sel, ok := arg.(*ast.SelectorExpr)
+	if !ok {
+		c.Errorf("invalid expression %v: argument is not a selector expression", node)
+	}
+	// the struct is not evaluated, we only need its type
+	t := c.expr1(sel.X, nil).Type
+	if t != nil && t.Kind() == r.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != r.Struct {
+		c.Errorf("invalid expression %v: %v is not a struct or pointer to struct", node, sel.X)
+	}
+	name := sel.Sel.Name
+	field, count := t.FieldByName(name, c.FileComp().Path)
+	if count == 0 {
+		c.Errorf("invalid expression %v: %v has no field %v", node, t, name)
+	} else if count > 1 {
+		c.Errorf("invalid expression %v: ambiguous selector %v", node, sel)
+	}
+	// field.Offset would also follow embedded pointers: walk the fields manually
+	var offset uintptr
+	for _, index := range field.Index {
+		if t.Kind() == r.Ptr {
+			c.Errorf("invalid expression %v: field %v is embedded via a pointer in %v", node, name, sel.X)
+		}
+		f := t.Field(index)
+		offset += f.Offset
+		t = f.Type
+	}
+	return c.unsafeConstCall(sym, offset)
+}
+
+// return a constant call to unsafe.Alignof(), unsafe.Offsetof() or unsafe.Sizeof().
+// Go specs: "Calls to Alignof, Offsetof, and Sizeof are compile-time constant expressions of type uintptr"
+func (c *Comp) unsafeConstCall(sym Symbol, val uintptr) *Call {
+	touts := []xr.Type{c.TypeOfUintptr()}
+	tfun := c.Universe.FuncOf(nil, touts, false)
+	sym.Type = tfun
+	fun := exprLit(Lit{Type: tfun, Value: val}, &sym)
+	return &Call{Fun: fun, Args: nil, OutTypes: touts, Const: true}
+}
+
+// --- conversions from/to unsafe.Pointer ---
+
+// isUnsafeConversion returns true if converting from tin to tout
+// needs package unsafe, i.e. if it converts between unsafe.Pointer
+// and a pointer or an uintptr
+func isUnsafeConversion(tin, tout xr.Type) bool {
+	if tin == nil || tout == nil {
+		return false
+	}
+	kin, kout := tin.Kind(), tout.Kind()
+	switch {
+	case kin == r.UnsafePointer:
+		return kout == r.Ptr || kout == r.Uintptr
+	case kout == r.UnsafePointer:
+		return kin == r.Ptr || kin == r.Uintptr
+	default:
+		return false
+	}
+}
+
+// convertUnsafe compiles a conversion between unsafe.Pointer and a pointer or an uintptr
+func (c *Comp) convertUnsafe(e *Expr, t xr.Type, nodeOpt ast.Expr) *Expr {
+	c.checkUnsafe("conversion from "+e.Type.String()+" to "+t.String(), nodeOpt)
+	rtype := t.ReflectType()
+	if rtype.Kind() != t.Kind() || e.Type.ReflectType().Kind() != e.Type.Kind() {
+		c.Errorf("unimplemented conversion from <%v> to <%v> with reflect.Type <%v> to <%v>",
+			e.Type, t, e.Type.ReflectType(), rtype)
+	}
+	if e.Const() {
+		val := unsafeConvert(xr.ValueOf(e.Value), rtype).Interface()
+		return c.exprValue(t, val)
+	}
+	fun := e.AsX1()
+	var ret I
+	if t.Kind() == xr.Uintptr {
+		ret = func(env *Env) uintptr {
+			val := unsafeConvert(fun(env), rtype)
+			return uintptr(val.Uint())
+		}
+	} else {
+		ret = func(env *Env) xr.Value {
+			return unsafeConvert(fun(env), rtype)
+		}
+	}
+	return exprFun(t, ret)
+}
+
+// unsafeConvert reinterprets the bits of v as a value of type rtout.
+// v and rtout must have the same size: they are pointers, unsafe.Pointer or uintptr
+func unsafeConvert(v xr.Value, rtout r.Type) xr.Value {
+	rv := v.ReflectValue()
+	if rv.Kind() == r.Interface {
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		// untyped nil
+		return xr.ZeroR(rtout)
+	}
+	addr := r.New(rv.Type())
+	addr.Elem().Set(rv)
+	ret := r.New(rtout).Elem()
+	ret.Set(r.NewAt(rtout, unsafe.Pointer(addr.Pointer())).Elem())
+	return xr.MakeValue(ret)
+}
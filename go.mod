@@ -5,5 +5,6 @@ go 1.13
 require (
 	github.com/mattn/go-runewidth v0.0.13
 	github.com/peterh/liner v1.2.1
+	golang.org/x/mod v0.5.1
 	golang.org/x/tools v0.1.8
 )
@@ -12,6 +12,8 @@ const (
 	GENERICS_V1_CXX
 	// enables generics "contracts are interfaces"
 	GENERICS_V2_CTI
+	// enables standard Go 1.18+ type parameters, i.e. func Map[T, U any](...)
+	GENERICS_V3_GO
 )
 
 // can be changed at runtime. useful to enable them by in gomacro,
@@ -25,3 +27,7 @@ func (g Generics) V1_CXX() bool {
 func (g Generics) V2_CTI() bool {
 	return g == GENERICS_V2_CTI
 }
+
+func (g Generics) V3_GO() bool {
+	return g == GENERICS_V3_GO
+}
@@ -24,6 +24,7 @@ const (
 	TYPECASE
 	TEMPLATE // template
 	HASH     // #
+	TILDE    // ~ in type constraints, only used by GENERICS_V3_GO
 
 	// the following are never used by go/scanner
 	// they are returned by ast2/Ast.Op() for corresponding AST nodes
@@ -62,6 +63,7 @@ func init() {
 	}
 	tokens[TEMPLATE] = "template"
 	tokens[HASH] = "#"
+	tokens[TILDE] = "~"
 }
 
 // Lookup maps a identifier to its keyword token.
@@ -33,11 +33,21 @@ func GENERICS_V2_CTI() bool {
 	return etoken.GENERICS.V2_CTI()
 }
 
+// enable standard Go 1.18+ type parameters?
+func GENERICS_V3_GO() bool {
+	return etoken.GENERICS.V3_GO()
+}
+
 // do generics use Foo#[T1,T2...] syntax?
 func _GENERICS_HASH() bool {
 	return GENERICS_V1_CXX() || GENERICS_V2_CTI()
 }
 
+// can &ast.IndexExpr be a generic instantiation, either Foo#[T1,T2...] or Foo[T1,T2...] ?
+func _GENERICS_INDEX() bool {
+	return _GENERICS_HASH() || GENERICS_V3_GO()
+}
+
 /*
  * used by GENERICS_V1_CXX and GENERICS_V2_CTI:
  *    parse prefix#[T1,T2...]
@@ -171,3 +181,231 @@ func genericFuncDecl(params *ast.CompositeLit, decl *ast.FuncDecl) *ast.FuncDecl
 	recv.List = list
 	return decl
 }
+
+/*
+ * used by GENERICS_V3_GO:
+ *    parse [T1, T2 C2, T3 ~int | ~string]
+ *    as &ast.CompositeLit{Type: nil, Elts: [&KeyValueExpr{T1,C2}, &KeyValueExpr{T2,C2}, &KeyValueExpr{T3,C3}] }
+ *    i.e. the same representation used by GENERICS_V2_CTI for Foo#[T1:C1,T2:C2...]
+ *
+ * the opening '[' and, if not nil, the first parameter name have already been consumed.
+ */
+func (p *parser) parseTypeParams(lbrack token.Pos, first *ast.Ident) *ast.CompositeLit {
+	if p.trace {
+		defer un(trace(p, "TypeParams"))
+	}
+	var list []ast.Expr
+	var names []*ast.Ident
+	if first != nil {
+		names = append(names, first)
+	}
+	for p.tok != token.RBRACK && p.tok != token.EOF {
+		if first == nil {
+			names = append(names, p.parseIdent())
+		}
+		first = nil
+		for p.tok == token.COMMA {
+			p.next()
+			names = append(names, p.parseIdent())
+		}
+		constraint := p.parseConstraint()
+		for _, name := range names {
+			list = append(list, &ast.KeyValueExpr{Key: name, Colon: name.End(), Value: constraint})
+		}
+		names = names[0:0]
+		if !p.atComma("type parameter list", token.RBRACK) {
+			break
+		}
+		p.next()
+	}
+	rbrack := p.expect(token.RBRACK)
+	if len(list) == 0 {
+		p.error(rbrack, "empty type parameter list")
+	}
+	return &ast.CompositeLit{
+		Lbrace: lbrack,
+		Elts:   list,
+		Rbrace: rbrack,
+	}
+}
+
+// parse a type constraint: either a type, a ~type, or an union term1 | term2 ...
+// unions are represented as &ast.BinaryExpr{Op: token.OR}
+// and ~type is represented as &ast.UnaryExpr{Op: etoken.TILDE}
+func (p *parser) parseConstraint() ast.Expr {
+	if p.trace {
+		defer un(trace(p, "Constraint"))
+	}
+	x := p.parseConstraintTerm()
+	for p.tok == token.OR {
+		pos := p.pos
+		p.next()
+		y := p.parseConstraintTerm()
+		x = &ast.BinaryExpr{X: x, OpPos: pos, Op: token.OR, Y: y}
+	}
+	return x
+}
+
+func (p *parser) parseConstraintTerm() ast.Expr {
+	if p.tok == etoken.TILDE {
+		pos := p.pos
+		p.next()
+		return &ast.UnaryExpr{OpPos: pos, Op: etoken.TILDE, X: p.parseType()}
+	}
+	return p.parseType()
+}
+
+// parse the type arguments [T1, T2...] following a generic type name x.
+// a single argument is stored directly in ast.IndexExpr.Index,
+// while multiple arguments are stored as &ast.CompositeLit{Elts: [T1, T2...]}
+func (p *parser) parseTypeArgs(x ast.Expr) ast.Expr {
+	if p.trace {
+		defer un(trace(p, "TypeArgs"))
+	}
+	lbrack := p.expect(token.LBRACK)
+	p.exprLev++
+	var list []ast.Expr
+	for p.tok != token.RBRACK && p.tok != token.EOF {
+		list = append(list, p.parseType())
+		if !p.atComma("type argument list", token.RBRACK) {
+			break
+		}
+		p.next()
+	}
+	p.exprLev--
+	rbrack := p.expect(token.RBRACK)
+	return packIndexExpr(x, lbrack, list, rbrack)
+}
+
+func packIndexExpr(x ast.Expr, lbrack token.Pos, list []ast.Expr, rbrack token.Pos) *ast.IndexExpr {
+	var index ast.Expr
+	switch len(list) {
+	case 0:
+		index = &ast.BadExpr{From: lbrack + 1, To: rbrack}
+	case 1:
+		index = list[0]
+	default:
+		index = &ast.CompositeLit{Lbrace: lbrack, Elts: list, Rbrace: rbrack}
+	}
+	return &ast.IndexExpr{X: x, Lbrack: lbrack, Index: index, Rbrack: rbrack}
+}
+
+// used by GENERICS_V3_GO to disambiguate, inside struct fields and function parameters,
+// between 'name [N]Elem' or 'name []Elem' and generic type instantiation 'Foo[T1, T2...]'
+//
+// returns (name, array type) in the first case, and (nil, instantiated type) in the second case.
+func (p *parser) parseArrayFieldOrTypeInstance(x *ast.Ident) (*ast.Ident, ast.Expr) {
+	if p.trace {
+		defer un(trace(p, "ArrayFieldOrTypeInstance"))
+	}
+	lbrack := p.expect(token.LBRACK)
+	var args []ast.Expr
+	if p.tok == token.ELLIPSIS {
+		// name [...]Elem is not valid, but accept it for better error messages
+		args = append(args, &ast.Ellipsis{Ellipsis: p.pos})
+		p.next()
+	} else if p.tok != token.RBRACK {
+		p.exprLev++
+		args = append(args, p.parseRhsOrType())
+		for p.tok == token.COMMA {
+			p.next()
+			if p.tok == token.RBRACK {
+				break
+			}
+			args = append(args, p.parseType())
+		}
+		p.exprLev--
+	}
+	rbrack := p.expect(token.RBRACK)
+	if len(args) == 0 {
+		// name []Elem
+		elem := p.parseType()
+		return x, &ast.ArrayType{Lbrack: lbrack, Elt: elem}
+	}
+	if len(args) == 1 {
+		if elem := p.tryType(); elem != nil {
+			// name [N]Elem
+			return x, &ast.ArrayType{Lbrack: lbrack, Len: args[0], Elt: elem}
+		}
+	}
+	// Foo[T1, T2...]
+	p.resolve(x)
+	return nil, packIndexExpr(x, lbrack, args, rbrack)
+}
+
+// used by GENERICS_V3_GO: after 'type Name [' decide whether
+// we are parsing an array type or a type parameter list.
+// sets spec.Type to the array type, or returns the type parameters
+func (p *parser) parseArrayTypeOrTypeParams(spec *ast.TypeSpec) *ast.CompositeLit {
+	lbrack := p.expect(token.LBRACK)
+	if p.tok == token.IDENT {
+		ident := p.parseIdent()
+		switch p.tok {
+		case token.IDENT, token.COMMA, etoken.TILDE, token.LBRACK,
+			token.INTERFACE, token.FUNC, token.MAP, token.CHAN, token.STRUCT, token.ARROW:
+			return p.parseTypeParams(lbrack, ident)
+		}
+		// array length that starts with an identifier, as 'type Foo [N+1]Elem'.
+		// As in Go, the ambiguous 'type Foo [P *C]Elem' is an array type
+		p.resolve(ident)
+		p.exprLev++
+		x := p.parsePrimaryExprSuffix(ident, false)
+		x = p.parseBinaryExprSuffix(x, false, token.LowestPrec+1)
+		p.exprLev--
+		p.expect(token.RBRACK)
+		spec.Type = &ast.ArrayType{Lbrack: lbrack, Len: x, Elt: p.parseType()}
+		return nil
+	}
+	// array or slice type
+	var len ast.Expr
+	p.exprLev++
+	if p.tok == token.ELLIPSIS {
+		len = &ast.Ellipsis{Ellipsis: p.pos}
+		p.next()
+	} else if p.tok != token.RBRACK {
+		len = p.parseRhs()
+	}
+	p.exprLev--
+	p.expect(token.RBRACK)
+	spec.Type = &ast.ArrayType{Lbrack: lbrack, Len: len, Elt: p.parseType()}
+	return nil
+}
+
+// used by GENERICS_V3_GO: parse an interface element.
+// It can be a method, an embedded interface, or a type constraint
+// as ~int | ~string
+func (p *parser) parseInterfaceElem(scope *ast.Scope) *ast.Field {
+	if p.trace {
+		defer un(trace(p, "InterfaceElem"))
+	}
+	doc := p.leadComment
+	if p.tok == token.IDENT {
+		x := p.parseTypeName()
+		if ident, ok := x.(*ast.Ident); ok && p.tok == token.LPAREN {
+			// method
+			idents := []*ast.Ident{ident}
+			scope := ast.NewScope(nil) // method scope
+			params, results := p.parseSignature(scope)
+			typ := &ast.FuncType{Func: token.NoPos, Params: params, Results: results}
+			p.expectSemi() // call before accessing p.linecomment
+			spec := &ast.Field{Doc: doc, Names: idents, Type: typ, Comment: p.lineComment}
+			p.declare(spec, nil, scope, ast.Fun, idents...)
+			return spec
+		}
+		if p.tok == token.LBRACK {
+			x = p.parseTypeArgs(x)
+		}
+		p.resolve(x)
+		for p.tok == token.OR {
+			pos := p.pos
+			p.next()
+			y := p.parseConstraintTerm()
+			x = &ast.BinaryExpr{X: x, OpPos: pos, Op: token.OR, Y: y}
+		}
+		p.expectSemi() // call before accessing p.linecomment
+		return &ast.Field{Doc: doc, Type: x, Comment: p.lineComment}
+	}
+	x := p.parseConstraint()
+	p.expectSemi() // call before accessing p.linecomment
+	return &ast.Field{Doc: doc, Type: x, Comment: p.lineComment}
+}
@@ -744,15 +744,20 @@ func (p *parser) parseFieldDecl(scope *ast.Scope) *ast.Field {
 	// 1st FieldDecl
 	// A type name used as an anonymous field looks like a field identifier.
 	var list []ast.Expr
+	var typ ast.Expr
 	for {
-		list = append(list, p.parseVarType(false))
-		if p.tok != token.COMMA {
+		var x ast.Expr
+		x, typ = p.parseVarTypeOrArrayField(false)
+		list = append(list, x)
+		if typ != nil || p.tok != token.COMMA {
 			break
 		}
 		p.next()
 	}
 
-	typ := p.tryVarType(false)
+	if typ == nil {
+		typ = p.tryVarType(false)
+	}
 
 	// analyze case
 	var idents []*ast.Ident
@@ -842,6 +847,29 @@ func (p *parser) tryVarType(isParam bool) ast.Expr {
 	return p.tryIdentOrType()
 }
 
+// patch: if GENERICS_V3_GO, an identifier followed by '[' is either
+// a generic type instantiation Foo[T1,T2...] or a name followed by an array type.
+// In the latter case, return both the name and the array type.
+//
+// If the first result is an identifier, it is not resolved.
+func (p *parser) parseVarTypeOrArrayField(isParam bool) (ast.Expr, ast.Expr) {
+	if GENERICS_V3_GO() && p.tok == token.IDENT {
+		x := p.parseTypeName()
+		if ident, ok := x.(*ast.Ident); ok && p.tok == token.LBRACK {
+			name, typ := p.parseArrayFieldOrTypeInstance(ident)
+			if name != nil {
+				return name, typ
+			}
+			return typ, nil
+		} else if p.tok == token.LBRACK {
+			// pkg.Foo[T1,T2...]
+			return p.parseTypeArgs(x), nil
+		}
+		return x, nil
+	}
+	return p.parseVarType(isParam), nil
+}
+
 // If the result is an identifier, it is not resolved.
 func (p *parser) parseVarType(isParam bool) ast.Expr {
 	typ := p.tryVarType(isParam)
@@ -862,9 +890,12 @@ func (p *parser) parseParameterList(scope *ast.Scope, ellipsisOk bool) (params [
 	// 1st ParameterDecl
 	// A list of identifiers looks like a list of type names.
 	var list []ast.Expr
+	var typ ast.Expr
 	for {
-		list = append(list, p.parseVarType(ellipsisOk))
-		if p.tok != token.COMMA {
+		var x ast.Expr
+		x, typ = p.parseVarTypeOrArrayField(ellipsisOk)
+		list = append(list, x)
+		if typ != nil || p.tok != token.COMMA {
 			break
 		}
 		p.next()
@@ -874,7 +905,10 @@ func (p *parser) parseParameterList(scope *ast.Scope, ellipsisOk bool) (params [
 	}
 
 	// analyze case
-	if typ := p.tryVarType(ellipsisOk); typ != nil {
+	if typ == nil {
+		typ = p.tryVarType(ellipsisOk)
+	}
+	if typ != nil {
 		// IdentifierList Type
 		idents := p.makeIdentList(list)
 		field := &ast.Field{Names: idents, Type: typ}
@@ -1048,8 +1082,14 @@ func (p *parser) parseInterfaceType() *ast.InterfaceType {
 	lbrace := p.expect(token.LBRACE)
 	scope := ast.NewScope(nil) // interface scope
 	var list []*ast.Field
-	for p.tok == token.IDENT || (GENERICS_V2_CTI() && p.tok == token.FUNC) {
-		list = append(list, p.parseMethodSpec(scope))
+	if GENERICS_V3_GO() {
+		for p.tok != token.RBRACE && p.tok != token.EOF {
+			list = append(list, p.parseInterfaceElem(scope))
+		}
+	} else {
+		for p.tok == token.IDENT || (GENERICS_V2_CTI() && p.tok == token.FUNC) {
+			list = append(list, p.parseMethodSpec(scope))
+		}
 	}
 	rbrace := p.expect(token.RBRACE)
 
@@ -1110,6 +1150,9 @@ func (p *parser) tryIdentOrType() ast.Expr {
 		if _GENERICS_HASH() && p.tok == etoken.HASH {
 			// parse Foo#[T1,T2...]
 			return p.parseHash(ident)
+		} else if GENERICS_V3_GO() && p.tok == token.LBRACK {
+			// parse Foo[T1,T2...]
+			return p.parseTypeArgs(ident)
 		}
 		return ident
 	case token.LBRACK:
@@ -1318,7 +1361,7 @@ func (p *parser) parseIndexOrSlice(x ast.Expr) ast.Expr {
 	var index0 ast.Expr
 	if p.tok != token.COLON {
 		index0 = p.parseRhsOrType()
-		if _GENERICS_HASH() && p.tok == token.COMMA {
+		if _GENERICS_INDEX() && p.tok == token.COMMA {
 			// parse [A, B...] used in generics
 			var list = []ast.Expr{index0}
 			for p.tok == token.COMMA {
@@ -1519,8 +1562,8 @@ func isTypeName(x ast.Expr) bool {
 	case *ast.BadExpr:
 	case *ast.Ident:
 	case *ast.IndexExpr:
-		// generic type, for example Pair#[T1,T2]
-		return _GENERICS_HASH()
+		// generic type, for example Pair#[T1,T2] or Pair[T1,T2]
+		return _GENERICS_INDEX()
 	case *ast.SelectorExpr:
 		_, isIdent := t.X.(*ast.Ident)
 		return isIdent
@@ -1536,8 +1579,8 @@ func isLiteralType(x ast.Expr) bool {
 	case *ast.BadExpr:
 	case *ast.Ident:
 	case *ast.IndexExpr:
-		// generic type, for example Pair#[T1,T2]
-		return _GENERICS_HASH()
+		// generic type, for example Pair#[T1,T2] or Pair[T1,T2]
+		return _GENERICS_INDEX()
 	case *ast.SelectorExpr:
 		_, isIdent := t.X.(*ast.Ident)
 		return isIdent
@@ -1595,6 +1638,13 @@ func (p *parser) parsePrimaryExpr(lhs bool) ast.Expr {
 	}
 
 	x := p.parseOperand(lhs)
+	return p.parsePrimaryExprSuffix(x, lhs)
+}
+
+// patch: parse selectors, index or slice expressions, calls and composite literals
+// following an already parsed operand x.
+// If lhs is set and the result is an identifier, it is not resolved.
+func (p *parser) parsePrimaryExprSuffix(x ast.Expr, lhs bool) ast.Expr {
 L:
 	for {
 		switch p.tok {
@@ -1728,6 +1778,11 @@ func (p *parser) parseBinaryExpr(lhs bool, prec1 int) ast.Expr {
 	}
 
 	x := p.parseUnaryExpr(lhs)
+	return p.parseBinaryExprSuffix(x, lhs, prec1)
+}
+
+// patch: parse binary operators following an already parsed operand x.
+func (p *parser) parseBinaryExprSuffix(x ast.Expr, lhs bool, prec1 int) ast.Expr {
 	for {
 		op, oprec := p.tokPrec()
 		if oprec < prec1 {
@@ -2495,13 +2550,20 @@ func (p *parser) parseTypeSpec(doc *ast.CommentGroup, _ token.Token, _ int) ast.
 	if GENERICS_V2_CTI() && p.tok == etoken.HASH {
 		p.next()
 		params = p.parseGenericParams()
+	} else if GENERICS_V3_GO() && p.tok == token.LBRACK {
+		// generics v3 parameters also appear after the type name,
+		// i.e. `type Map[K comparable, V any] struct { ... }`
+		// but we must distinguish them from `type Array [N]int`
+		params = p.parseArrayTypeOrTypeParams(spec)
 	}
 
-	if p.tok == token.ASSIGN {
-		spec.Assign = p.pos
-		p.next()
+	if spec.Type == nil {
+		if p.tok == token.ASSIGN {
+			spec.Assign = p.pos
+			p.next()
+		}
+		spec.Type = p.parseType()
 	}
-	spec.Type = p.parseType()
 	if params != nil {
 		params.Type = spec.Type
 		spec.Type = params
@@ -2588,6 +2650,11 @@ func (p *parser) parseFuncOrMacroDecl(tok token.Token) *ast.FuncDecl {
 	if tok != etoken.MACRO && GENERICS_V2_CTI() && p.tok == etoken.HASH {
 		p.next()
 		c = p.parseGenericParams()
+	} else if tok != etoken.MACRO && GENERICS_V3_GO() && p.tok == token.LBRACK {
+		// patch: generic v3 type params
+		lbrack := p.pos
+		p.next()
+		c = p.parseTypeParams(lbrack, nil)
 	}
 	params, results := p.parseSignature(scope)
 
@@ -272,12 +272,42 @@ func (p *printer) exprList(prev0 token.Pos, list []ast.Expr, depth int, mode exp
 
 // print the infix #[T1,T2...]
 func (p *printer) genericInfix(c *ast.CompositeLit) {
-	p.print(etoken.HASH, token.LBRACK)
 	params, _ := splitGenericArgs(c)
+	if etoken.GENERICS.V3_GO() {
+		p.typeParams(params)
+		return
+	}
+	p.print(etoken.HASH, token.LBRACK)
 	p.exprList(c.Lbrace, params, 1, 0, c.Rbrace)
 	p.print(token.RBRACK)
 }
 
+// print the Go 1.18+ type parameters [T1, T2 C2, T3 C3]
+// stored as &ast.KeyValueExpr{Key: T, Value: C}
+func (p *printer) typeParams(params []ast.Expr) {
+	p.print(token.LBRACK)
+	for i, param := range params {
+		if i != 0 {
+			p.print(token.COMMA, blank)
+		}
+		kv, ok := param.(*ast.KeyValueExpr)
+		if !ok {
+			p.expr(param)
+			continue
+		}
+		p.expr(kv.Key)
+		// omit the constraint if it's the same as the next parameter's one
+		if i+1 < len(params) {
+			if next, ok := params[i+1].(*ast.KeyValueExpr); ok && next.Value == kv.Value {
+				continue
+			}
+		}
+		p.print(blank)
+		p.expr(kv.Value)
+	}
+	p.print(token.RBRACK)
+}
+
 // print the prefix template[T1,T2...] for[Foo#[T1],Bar#[T2],...]
 func (p *printer) templatePrefix(c *ast.CompositeLit) {
 	p.print(etoken.TEMPLATE, token.LBRACK)
@@ -871,7 +901,11 @@ func (p *printer) expr1(expr ast.Expr, prec1, depth int) {
 		p.expr1(x.X, token.HighestPrec, 1)
 		if c, ok := x.Index.(*ast.CompositeLit); ok && c.Type == nil {
 			// Pair#[A,B] is parsed as &ast.IndexExpr{X: Pair, Index: &ast.CompositeLit{Elts: [A,B]}}
-			p.print(etoken.HASH, x.Lbrack, token.LBRACK)
+			// and Pair[A,B] too, if etoken.GENERICS.V3_GO()
+			if !etoken.GENERICS.V3_GO() {
+				p.print(etoken.HASH)
+			}
+			p.print(x.Lbrack, token.LBRACK)
 			p.exprList(c.Lbrace, c.Elts, depth+1, 0, c.Rbrace)
 			p.print(x.Rbrack, token.RBRACK)
 			break
@@ -1575,7 +1609,7 @@ func (p *printer) spec(spec ast.Spec, n int, doIndent bool) {
 			typ = c.Type
 		}
 		p.expr(s.Name)
-		if etoken.GENERICS.V2_CTI() && c != nil {
+		if (etoken.GENERICS.V2_CTI() || etoken.GENERICS.V3_GO()) && c != nil {
 			p.genericInfix(c)
 		}
 		if n == 1 {
@@ -1789,7 +1823,7 @@ func (p *printer) funcDecl(d *ast.FuncDecl) {
 		p.receiver(d.Recv) // method: print receiver
 	}
 	p.expr(d.Name)
-	if c != nil && etoken.GENERICS.V2_CTI() {
+	if c != nil && (etoken.GENERICS.V2_CTI() || etoken.GENERICS.V3_GO()) {
 		// generic function or generic method
 		p.genericInfix(c)
 	}
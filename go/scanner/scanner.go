@@ -963,9 +963,15 @@ scanAgain:
 					tok = etoken.UNQUOTE
 				}
 			default:
+				offs, rdOffs, ch := s.offset, s.rdOffset, s.ch
 				lit = s.scanIdentifier()
 				tok = etoken.LookupSpecial(lit)
-				if tok == token.ILLEGAL {
+				if tok == token.ILLEGAL && etoken.GENERICS.V3_GO() {
+					// patch: ~T in type constraints. rewind and return only the '~'
+					s.offset, s.rdOffset, s.ch = offs, rdOffs, ch
+					lit = ""
+					tok = etoken.TILDE
+				} else if tok == token.ILLEGAL {
 					s.error(s.file.Offset(pos), fmt.Sprintf("expecting macro-related keyword after '%c', found '%c%s'", s.macroChar, s.macroChar, lit))
 					insertSemi = s.insertSemi // preserve insertSemi info
 				}
@@ -9,13 +9,11 @@ package types
 import (
 	"fmt"
 	"go/types"
-
-	"golang.org/x/tools/go/types/typeutil"
 )
 
 type Converter struct {
 	pkg          map[string]*Package
-	cache        typeutil.Map
+	cache        map[types.Type]Type
 	toaddmethods map[*Named]*types.Named
 	tocomplete   []*Interface
 }
@@ -51,11 +49,17 @@ func (c *Converter) Package(g *types.Package) *Package {
 	if g == nil {
 		return nil
 	}
-	c.cache = typeutil.Map{}
+	c.cache = make(map[types.Type]Type)
 	p := c.mkpackage(g)
 	scope := g.Scope()
 	for _, name := range scope.Names() {
-		obj := c.object(scope.Lookup(name))
+		gobj := scope.Lookup(name)
+		if isGeneric(gobj) {
+			// generic functions and types cannot be represented
+			// in github.com/cosmos72/gomacro/go/types
+			continue
+		}
+		obj := c.object(gobj)
 		if obj != nil {
 			p.scope.Insert(obj)
 		}
@@ -119,7 +123,9 @@ func (c *Converter) typ(g types.Type) Type {
 	if g == nil {
 		return nil
 	}
-	t, _ := c.cache.At(g).(Type)
+	// Go >= 1.22 may return *types.Alias, for example for 'any'
+	g = unalias(g)
+	t := c.cache[g]
 	if t != nil {
 		return t
 	}
@@ -151,7 +157,7 @@ func (c *Converter) typ(g types.Type) Type {
 	default:
 		panic(fmt.Errorf("Converter.Type(): unsupported types.Type: %T", g))
 	}
-	c.cache.Set(g, t)
+	c.cache[g] = t
 	if debugConverter {
 		fmt.Print("scanned type ", t, " has ", t.NumMethods(), " methods")
 		if u := t.Underlying(); u != nil {
@@ -197,7 +203,7 @@ func (c *Converter) mknamed(g *types.Named) *Named {
 	}
 	t := NewNamed(typename, nil, nil)
 	// cache t early, in case it's part of a cycle in a recursive type
-	c.cache.Set(g, t)
+	c.cache[g] = t
 	if debugConverter {
 		fmt.Println("scanning underlying type of", typename.Name())
 	}
@@ -0,0 +1,16 @@
+// Copyright 2024 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.22
+
+package types
+
+import (
+	"go/types"
+)
+
+// remove *types.Alias wrappers, i.e. return the actual type
+func unalias(g types.Type) types.Type {
+	return types.Unalias(g)
+}
@@ -0,0 +1,25 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.18
+
+package types
+
+import (
+	"go/types"
+)
+
+// return true if g is a generic function or a generic named type.
+// They are not representable in github.com/cosmos72/gomacro/go/types
+func isGeneric(g types.Object) bool {
+	switch g := g.(type) {
+	case *types.Func:
+		sig, _ := g.Type().(*types.Signature)
+		return sig != nil && (sig.TypeParams().Len() != 0 || sig.RecvTypeParams().Len() != 0)
+	case *types.TypeName:
+		named, _ := g.Type().(*types.Named)
+		return named != nil && named.TypeParams().Len() != 0
+	}
+	return false
+}
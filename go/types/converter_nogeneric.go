@@ -0,0 +1,16 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !go1.18
+
+package types
+
+import (
+	"go/types"
+)
+
+// Go < 1.18 has no generics
+func isGeneric(g types.Object) bool {
+	return false
+}
@@ -28,7 +28,9 @@ func main() {
 	cmd := cmd.New()
 
 	err := cmd.Main(args)
-	if err != nil {
+	if status, ok := err.(interface{ ExitStatus() int }); ok {
+		os.Exit(status.ExitStatus())
+	} else if err != nil {
 		o := &cmd.Interp.Comp.Output
 		o.Fprintf(o.Stderr, "%s\n", err)
 		os.Exit(1)
@@ -156,6 +156,9 @@ func (t Type) UnsafeForceReflectType(rtype r.Type) {
 
 // Size returns the number of bytes needed to store
 // a value of the given type; it is analogous to unsafe.Sizeof.
+// For interpreted types, Size, Align and FieldAlign describe the memory layout
+// actually used by the interpreter: it is the same as compiled code,
+// except for emulated interfaces and recursive types - see ReflectType
 func (t Type) Size() uintptr {
 	return t(z{}).Size()
 }
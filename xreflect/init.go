@@ -96,7 +96,7 @@ func (v *Universe) makeForward() Type {
 }
 
 func NewUniverse() *Universe {
-	v := &Universe{}
+	v := &Universe{RuntimeNamedTypes: runtimeTypesSupported}
 	v.BasicTypes = v.makeBasicTypes()
 	v.addBasicTypesMethodsCTI()
 	v.TypeOfForward = v.makeForward()
@@ -126,11 +126,11 @@ func (t *xtype) Bits() int {
 // Align returns the alignment in bytes of a value of
 // this type when allocated in memory.
 func (t *xtype) Align() int {
-	return t.rtype.Align()
+	return t.layoutType().Align()
 }
 
 // FieldAlign returns the alignment in bytes of a value of
 // this type when used as a field in a struct.
 func (t *xtype) FieldAlign() int {
-	return t.rtype.FieldAlign()
+	return t.layoutType().FieldAlign()
 }
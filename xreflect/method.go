@@ -17,7 +17,6 @@
 package xreflect
 
 import (
-	"fmt"
 	"go/ast"
 	r "reflect"
 
@@ -317,7 +316,6 @@ func MissingMethod(t, tinterf Type) *Method {
 			if t.Kind() != r.Interface {
 				tfunc = removeReceiver(tfunc)
 			}
-			fmt.Printf("MissingMethod: comparing %v against expected interface method %v\n", tfunc, mtdinterf)
 			if mtdinterf.Type.IdenticalTo(tfunc) && matchReceiverType(xt, xtinterf) {
 				continue
 			}
@@ -76,6 +76,9 @@ func (t *xtype) SetUnderlying(underlying Type) {
 		xerrorf(t, "SetUnderlying of unnamed type %v", t)
 	}
 	v := t.universe
+	// synthesize a genuine named reflect.Type only for types created by the interpreter,
+	// not for compiled types: their reflect.Type is set by the caller
+	synthesize := v.RuntimeNamedTypes && (t.rtype == rTypeOfForward || isRuntimeNamed(t.rtype))
 	if t.kind != r.Invalid || gtype.Underlying() != v.TypeOfForward.GoType() || t.rtype != v.TypeOfForward.ReflectType() {
 		// redefined type. try really hard to support it.
 		v.InvalidateCache()
@@ -87,6 +90,12 @@ func (t *xtype) SetUnderlying(underlying Type) {
 	gtype.SetUnderlying(gunderlying)
 	// debugf("SetUnderlying: updated <%v> reflect Type from <%v> to <%v> (%v)", gtype, t.rtype, underlying.ReflectType(), t.option)
 	t.rtype = underlying.ReflectType()
+	if synthesize {
+		if named := v.runtimeNamed(t, xunderlying); named != nil {
+			t.rtype = named
+			v.cacheType(named, wrap(t))
+		}
+	}
 	if t.kind == r.Interface {
 		// propagate methodvalue from underlying interface to named type
 		t.methodvalue = xunderlying.methodvalue
@@ -0,0 +1,200 @@
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * rtype.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package xreflect
+
+import (
+	r "reflect"
+
+	"github.com/cosmos72/gomacro/go/types"
+)
+
+// RuntimeNamedTypesSupported returns true if named reflect.Types
+// can be created at runtime with the current Go toolchain
+func RuntimeNamedTypesSupported() bool {
+	return runtimeTypesSupported
+}
+
+// runtimeNamed creates a genuine named reflect.Type for the named type t, whose underlying type is u.
+// Self-references in u, as in type List struct { Elem int; Rest *List }, are resolved if possible.
+// Returns nil if creating named types at runtime is not supported for t
+func (v *Universe) runtimeNamed(t *xtype, u *xtype) r.Type {
+	kind := t.kind
+	if !runtimeNamedKindSupported(kind) {
+		return nil
+	}
+	urtype := u.rtype
+	named := newRuntimeNamed(kind, t.String(), t.PkgPath())
+	if u.option != OptDefault || urtype.Kind() != kind {
+		b := rtypeBuilder{
+			v:     v,
+			self:  t.gtype,
+			named: named,
+		}
+		if rtype, _, ok := b.build(u.gtype.Underlying()); ok {
+			urtype = rtype
+			b.commit()
+			t.option = OptDefault
+		}
+	}
+	if urtype.Kind() != kind {
+		// the underlying reflect.Type is approximated, e.g. by xreflect.Forward
+		return nil
+	}
+	setRuntimeNamedUnderlying(named, urtype)
+	return named
+}
+
+// rtypeBuilder creates the reflect.Type of types that reference a named type being defined
+type rtypeBuilder struct {
+	v     *Universe
+	self  types.Type // the named type being defined
+	named r.Type     // its reflect.Type, whose contents are not set yet
+	built []builtType
+}
+
+type builtType struct {
+	gtype types.Type
+	rtype r.Type
+}
+
+// build the reflect.Type of gtype. also return true if it contains b.self by value, not by reference
+func (b *rtypeBuilder) build(gtype types.Type) (rtype r.Type, byValue bool, ok bool) {
+	switch g := gtype.(type) {
+	case *types.Named:
+		if g == b.self {
+			return b.named, true, true
+		}
+		rtype, ok = b.cached(g)
+		return rtype, false, ok
+	case *types.Basic, *types.Interface:
+		rtype, ok = b.cached(g)
+		return rtype, false, ok
+	case *types.Pointer:
+		if rtype, _, ok = b.build(g.Elem()); ok {
+			rtype = r.PtrTo(rtype)
+		}
+	case *types.Slice:
+		if rtype, _, ok = b.build(g.Elem()); ok {
+			rtype = r.SliceOf(rtype)
+		}
+	case *types.Chan:
+		if rtype, _, ok = b.build(g.Elem()); ok {
+			rtype = r.ChanOf(gdirTodir(g.Dir()), rtype)
+		}
+	case *types.Array:
+		// r.ArrayOf() needs the size of its element
+		if rtype, byValue, ok = b.build(g.Elem()); ok && !byValue {
+			rtype = r.ArrayOf(int(g.Len()), rtype)
+		} else {
+			ok = false
+		}
+	case *types.Map:
+		// r.MapOf() needs the size of key and element
+		var key r.Type
+		var keyByValue bool
+		if key, keyByValue, ok = b.build(g.Key()); ok && !keyByValue {
+			if rtype, byValue, ok = b.build(g.Elem()); ok && !byValue {
+				rtype = r.MapOf(key, rtype)
+			} else {
+				ok = false
+			}
+		} else {
+			ok = false
+		}
+	case *types.Signature:
+		rtype, ok = b.buildFunc(g)
+	case *types.Struct:
+		rtype, ok = b.buildStruct(g)
+	default:
+		return nil, false, false
+	}
+	if ok {
+		b.built = append(b.built, builtType{gtype, rtype})
+	}
+	return rtype, false, ok
+}
+
+// return the reflect.Type of an already complete type
+func (b *rtypeBuilder) cached(gtype types.Type) (r.Type, bool) {
+	t, _ := b.v.Types.gmap.At(gtype).(Type)
+	if t == nil {
+		return nil, false
+	}
+	xt := unwrap(t)
+	if xt.rtype == rTypeOfForward || xt.option != OptDefault {
+		return nil, false
+	}
+	return xt.rtype, true
+}
+
+func (b *rtypeBuilder) buildTuple(tuple *types.Tuple) ([]r.Type, bool) {
+	n := tuple.Len()
+	rtypes := make([]r.Type, n)
+	for i := 0; i < n; i++ {
+		rtype, _, ok := b.build(tuple.At(i).Type())
+		if !ok {
+			return nil, false
+		}
+		rtypes[i] = rtype
+	}
+	return rtypes, true
+}
+
+func (b *rtypeBuilder) buildFunc(g *types.Signature) (r.Type, bool) {
+	if g.Recv() != nil {
+		return nil, false
+	}
+	in, ok := b.buildTuple(g.Params())
+	if !ok {
+		return nil, false
+	}
+	out, ok := b.buildTuple(g.Results())
+	if !ok {
+		return nil, false
+	}
+	return r.FuncOf(in, out, g.Variadic()), true
+}
+
+// create the same reflect.Type as Universe.StructOf()
+func (b *rtypeBuilder) buildStruct(g *types.Struct) (r.Type, bool) {
+	n := g.NumFields()
+	rfields := make([]r.StructField, n)
+	for i := 0; i < n; i++ {
+		field := g.Field(i)
+		rtype, byValue, ok := b.build(field.Type())
+		if !ok || byValue {
+			// a struct cannot contain itself
+			return nil, false
+		}
+		rfields[i] = r.StructField{
+			Name: toExportedFieldName(field.Name(), nil, field.Anonymous()),
+			Type: rtype,
+			Tag:  r.StructTag(g.Tag(i)),
+		}
+	}
+	return r.StructOf(rfields), true
+}
+
+// update the cached types that were approximated while the named type was incomplete
+func (b *rtypeBuilder) commit() {
+	for _, built := range b.built {
+		if t, _ := b.v.Types.gmap.At(built.gtype).(Type); t != nil {
+			xt := unwrap(t)
+			xt.rtype = built.rtype
+			xt.option = OptDefault
+		}
+	}
+}
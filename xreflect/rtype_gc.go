@@ -0,0 +1,295 @@
+// +build gc,go1.21
+
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * rtype_gc.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package xreflect
+
+import (
+	r "reflect"
+	"sync"
+	"unsafe"
+)
+
+// the following types mirror the runtime type descriptors of Go toolchain gc,
+// see $GOROOT/src/internal/abi/type.go
+// Their layout is verified at startup by checkRuntimeTypes()
+
+type abiType struct {
+	size       uintptr
+	ptrBytes   uintptr
+	hash       uint32
+	tflag      uint8
+	align      uint8
+	fieldAlign uint8
+	kind       uint8
+	equal      func(unsafe.Pointer, unsafe.Pointer) bool
+	gcData     *byte
+	str        int32
+	ptrToThis  int32
+}
+
+type uncommonType struct {
+	pkgPath int32
+	mcount  uint16
+	xcount  uint16
+	moff    uint32
+	_       uint32
+}
+
+type abiPtrType struct {
+	abiType
+	elem *abiType
+}
+
+type abiChanType struct {
+	abiType
+	elem *abiType
+	dir  int
+}
+
+type abiArrayType struct {
+	abiType
+	elem  *abiType
+	slice *abiType
+	len   uintptr
+}
+
+type abiStructField struct {
+	name   *byte
+	typ    *abiType
+	offset uintptr
+}
+
+type abiStructType struct {
+	abiType
+	pkgPath *byte
+	fields  []abiStructField
+}
+
+// a named type is followed by its uncommonType
+type (
+	namedBasic struct {
+		abiType
+		u uncommonType
+	}
+	namedPtr struct {
+		abiPtrType
+		u uncommonType
+	}
+	namedChan struct {
+		abiChanType
+		u uncommonType
+	}
+	namedArray struct {
+		abiArrayType
+		u uncommonType
+	}
+	namedStruct struct {
+		abiStructType
+		u uncommonType
+	}
+)
+
+const (
+	tflagUncommon  = 1 << 0
+	tflagExtraStar = 1 << 1
+	tflagNamed     = 1 << 2
+)
+
+// addReflectOff registers ptr in the runtime reflection lookup map,
+// and returns an offset that the runtime resolves to ptr.
+// Implemented in the runtime package, which exports it to reflect
+//
+//go:linkname addReflectOff reflect.addReflectOff
+func addReflectOff(ptr unsafe.Pointer) int32
+
+// keep alive the names and types created at runtime
+var runtimeTypes struct {
+	lock  sync.Mutex
+	names [][]byte
+	types map[r.Type]struct{}
+}
+
+var runtimeTypesSupported = checkRuntimeTypes()
+
+// return the runtime type descriptor of t
+func abiTypeOf(t r.Type) *abiType {
+	return (*abiType)((*[2]unsafe.Pointer)(unsafe.Pointer(&t))[1])
+}
+
+// return a reflect.Type for runtime type descriptor p
+func reflectTypeOf(p *abiType) r.Type {
+	t := rTypeOfInterface // any reflect.Type: we only need its itab
+	(*[2]unsafe.Pointer)(unsafe.Pointer(&t))[1] = unsafe.Pointer(p)
+	return t
+}
+
+// encode str in the format of internal/abi.Name, and return its offset
+func nameOff(str string) int32 {
+	var buf [10]byte
+	n := 0
+	for l := len(str); ; l >>= 7 {
+		if l < 0x80 {
+			buf[n] = byte(l)
+			n++
+			break
+		}
+		buf[n] = byte(l&0x7f | 0x80)
+		n++
+	}
+	bytes := make([]byte, 1+n+len(str))
+	copy(bytes[1:], buf[:n])
+	copy(bytes[1+n:], str)
+
+	runtimeTypes.lock.Lock()
+	runtimeTypes.names = append(runtimeTypes.names, bytes)
+	runtimeTypes.lock.Unlock()
+	return addReflectOff(unsafe.Pointer(&bytes[0]))
+}
+
+// FNV-1 hash, as used by package reflect
+func fnv1(x uint32, str string) uint32 {
+	for i := 0; i < len(str); i++ {
+		x = x*16777619 ^ uint32(str[i])
+	}
+	return x
+}
+
+func runtimeNamedKindSupported(kind r.Kind) bool {
+	switch kind {
+	case r.Bool, r.Int, r.Int8, r.Int16, r.Int32, r.Int64,
+		r.Uint, r.Uint8, r.Uint16, r.Uint32, r.Uint64, r.Uintptr,
+		r.Float32, r.Float64, r.Complex64, r.Complex128, r.String,
+		r.UnsafePointer, r.Array, r.Chan, r.Ptr, r.Slice, r.Struct:
+		return runtimeTypesSupported
+	}
+	return false
+}
+
+// newRuntimeNamed allocates a new named reflect.Type with given kind.
+// str is the type's string representation, as "main.Pair"
+// Its String(), Name() and PkgPath() are already valid,
+// and it can be used as element of PtrTo(), SliceOf(), ChanOf() and FuncOf()
+// but the rest of its contents must be set by calling setRuntimeNamedUnderlying()
+func newRuntimeNamed(kind r.Kind, str string, pkgpath string) r.Type {
+	var t *abiType
+	var u *uncommonType
+	switch kind {
+	case r.Array:
+		p := new(namedArray)
+		t, u = &p.abiType, &p.u
+	case r.Chan:
+		p := new(namedChan)
+		t, u = &p.abiType, &p.u
+	case r.Ptr, r.Slice:
+		// SliceType has the same layout as PtrType
+		p := new(namedPtr)
+		t, u = &p.abiType, &p.u
+	case r.Struct:
+		p := new(namedStruct)
+		t, u = &p.abiType, &p.u
+	default:
+		p := new(namedBasic)
+		t, u = &p.abiType, &p.u
+	}
+	t.kind = uint8(kind)
+	t.tflag = tflagUncommon | tflagNamed
+	t.align, t.fieldAlign = 1, 1
+	t.hash = fnv1(fnv1(2166136261, pkgpath), str)
+	t.str = nameOff(str)
+	if len(pkgpath) != 0 {
+		u.pkgPath = nameOff(pkgpath)
+	}
+	u.moff = uint32(unsafe.Sizeof(*u))
+
+	rtype := reflectTypeOf(t)
+	runtimeTypes.lock.Lock()
+	if runtimeTypes.types == nil {
+		runtimeTypes.types = make(map[r.Type]struct{})
+	}
+	runtimeTypes.types[rtype] = struct{}{}
+	runtimeTypes.lock.Unlock()
+	return rtype
+}
+
+// return true if rtype was created by newRuntimeNamed()
+func isRuntimeNamed(rtype r.Type) bool {
+	runtimeTypes.lock.Lock()
+	_, ok := runtimeTypes.types[rtype]
+	runtimeTypes.lock.Unlock()
+	return ok
+}
+
+// copy the contents of underlying into named, preserving named's identity:
+// string, hash, flags and package path
+func setRuntimeNamedUnderlying(named r.Type, underlying r.Type) {
+	t := abiTypeOf(named)
+	src := abiTypeOf(underlying)
+	hash, str, tflag := t.hash, t.str, t.tflag
+	switch underlying.Kind() {
+	case r.Array:
+		*(*abiArrayType)(unsafe.Pointer(t)) = *(*abiArrayType)(unsafe.Pointer(src))
+	case r.Chan:
+		*(*abiChanType)(unsafe.Pointer(t)) = *(*abiChanType)(unsafe.Pointer(src))
+	case r.Ptr, r.Slice:
+		*(*abiPtrType)(unsafe.Pointer(t)) = *(*abiPtrType)(unsafe.Pointer(src))
+	case r.Struct:
+		*(*abiStructType)(unsafe.Pointer(t)) = *(*abiStructType)(unsafe.Pointer(src))
+	default:
+		*t = *src
+	}
+	t.hash, t.str = hash, str
+	t.tflag = src.tflag&^(tflagUncommon|tflagExtraStar|tflagNamed) | tflag&(tflagUncommon|tflagNamed)
+	t.ptrToThis = 0
+}
+
+type runtimeTypeProbe struct {
+	A int
+	B *runtimeTypeProbe
+}
+
+// verify that the runtime type descriptors have the expected layout,
+// by inspecting a compiled named type and by creating a named type at runtime
+func checkRuntimeTypes() (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	compiled := r.TypeOf(runtimeTypeProbe{})
+	t := abiTypeOf(compiled)
+	if t.size != compiled.Size() || r.Kind(t.kind&31) != r.Struct ||
+		t.tflag&(tflagUncommon|tflagNamed) != tflagUncommon|tflagNamed ||
+		(*abiStructType)(unsafe.Pointer(t)).fields[1].offset != compiled.Field(1).Offset ||
+		reflectTypeOf(t) != compiled {
+		return false
+	}
+	underlying := r.StructOf([]r.StructField{
+		{Name: "A", Type: r.TypeOf(int(0))},
+		{Name: "B", Type: r.TypeOf((*int)(nil))},
+	})
+	named := newRuntimeNamed(r.Struct, "xreflect.probe", "github.com/cosmos72/gomacro/xreflect")
+	setRuntimeNamedUnderlying(named, underlying)
+	v := r.New(named).Elem()
+	v.Field(0).SetInt(7)
+	return named.Name() == "probe" && named.String() == "xreflect.probe" &&
+		named.PkgPath() == "github.com/cosmos72/gomacro/xreflect" &&
+		named.Kind() == r.Struct && named.Size() == underlying.Size() &&
+		named.NumField() == 2 && named.Field(1).Type == underlying.Field(1).Type &&
+		named.NumMethod() == 0 && r.PtrTo(named).Elem() == named &&
+		v.Convert(underlying).Field(0).Int() == 7 &&
+		named.AssignableTo(underlying) && !named.AssignableTo(r.TypeOf(runtimeTypeProbe{}))
+}
@@ -0,0 +1,3 @@
+// +build gc,go1.21
+
+// empty file: it allows declaring functions without body in rtype_gc.go
@@ -0,0 +1,42 @@
+// +build !gc !go1.21
+
+/*
+ * gomacro - A Go interpreter with Lisp-like macros
+ *
+ * Copyright (C) 2018-2019 Massimiliano Ghilardi
+ *
+ *     This Source Code Form is subject to the terms of the Mozilla Public
+ *     License, v. 2.0. If a copy of the MPL was not distributed with this
+ *     file, You can obtain one at http://mozilla.org/MPL/2.0/.
+ *
+ *
+ * rtype_other.go
+ *
+ *  Created on Oct 17, 2026
+ */
+
+package xreflect
+
+import (
+	r "reflect"
+)
+
+// creating named types at runtime is not supported by this Go toolchain:
+// named types are emulated by their underlying type
+
+const runtimeTypesSupported = false
+
+func runtimeNamedKindSupported(kind r.Kind) bool {
+	return false
+}
+
+func newRuntimeNamed(kind r.Kind, str string, pkgpath string) r.Type {
+	return nil
+}
+
+func isRuntimeNamed(rtype r.Type) bool {
+	return false
+}
+
+func setRuntimeNamedUnderlying(named r.Type, underlying r.Type) {
+}
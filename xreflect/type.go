@@ -259,7 +259,16 @@ func (t *xtype) PkgPath() string {
 // Size returns the number of bytes needed to store
 // a value of the given type; it is analogous to unsafe.Sizeof.
 func (t *xtype) Size() uintptr {
-	return t.rtype.Size()
+	return t.layoutType().Size()
+}
+
+// layoutType returns the reflect.Type describing the memory layout of t.
+// If t.rtype is Forward i.e. approximated due to recursive types, resolve it
+func (t *xtype) layoutType() r.Type {
+	if t.rtype == rTypeOfForward {
+		return resolveFwdR(t)
+	}
+	return t.rtype
 }
 
 // String returns a string representation of a type.
@@ -47,7 +47,11 @@ type Universe struct {
 	mutex           sync.Mutex
 	debugmutex      int
 	ThreadSafe      bool
-	cache           struct {
+	// if true, named types created by the interpreter have a genuine named reflect.Type
+	// instead of the reflect.Type of their underlying type.
+	// Default is RuntimeNamedTypesSupported()
+	RuntimeNamedTypes bool
+	cache             struct {
 		method bool
 		field  bool
 	}
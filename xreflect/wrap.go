@@ -42,6 +42,12 @@ func MakeFunc(t Type, fn func([]Value) []Value) Value {
 	return Value{r.MakeFunc(rtype, rfn)}
 }
 
+// MakeFuncR is as MakeFunc, but fn is invoked directly by reflect:
+// it can call recover() when the created function is invoked as defer
+func MakeFuncR(t Type, fn func([]r.Value) []r.Value) Value {
+	return Value{r.MakeFunc(resolveFwdR(unwrap(t)), fn)}
+}
+
 func MakeMap(t Type) Value {
 	return Value{r.MakeMap(resolveFwdR(unwrap(t)))}
 }
@@ -66,7 +72,11 @@ func NewR(rt r.Type) Value {
 }
 
 func New(t Type) Value {
-	t = t.resolve()
+	if xt := unwrap(t); xt == nil || xt.option != OptDefault {
+		// resolve forward references. Skipped for other types, because resolve()
+		// accesses the Universe that the compiler may be modifying in another goroutine
+		t = t.resolve()
+	}
 	rv := r.New(t.ReflectType())
 	fillForward(rv.Elem(), t)
 	return Value{rv}
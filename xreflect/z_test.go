@@ -17,6 +17,8 @@
 package xreflect
 
 import (
+	"encoding/json"
+	"fmt"
 	"go/token"
 	"io"
 	"os"
@@ -80,6 +82,21 @@ func istypeof(t *testing.T, actual interface{}, expected interface{}) {
 	is(t, r.TypeOf(actual), r.TypeOf(expected))
 }
 
+// check the reflect.Type of a named type created at runtime
+func isreflectnamed(t *testing.T, typ Type, underlying r.Type) {
+	rtype := typ.ReflectType()
+	if !typ.Universe().RuntimeNamedTypes {
+		is(t, rtype, underlying)
+		return
+	}
+	is(t, rtype.Name(), typ.Name())
+	is(t, rtype.PkgPath(), typ.PkgPath())
+	is(t, rtype.String(), typ.String())
+	is(t, rtype.Kind(), underlying.Kind())
+	is(t, rtype.Size(), underlying.Size())
+	istrue(t, rtype.ConvertibleTo(underlying))
+}
+
 func TestCanonical(t *testing.T) {
 	type testCase struct {
 		expected, actual r.Type
@@ -219,7 +236,7 @@ func TestIntMethod(t *testing.T) {
 	rtype := r.TypeOf(int(0))
 	is(t, typ.Kind(), r.Int)
 	is(t, typ.Name(), "MyInt")
-	is(t, typ.ReflectType(), rtype)
+	isreflectnamed(t, typ, rtype)
 	if etoken.GENERICS.V2_CTI() {
 		is(t, typ.NumMethod(), 16)
 		is(t, typ.NumAllMethod(), 32)
@@ -303,7 +320,7 @@ func TestSelfReference(t *testing.T) {
 	is(t, typ.Kind(), r.Struct)
 	is(t, typ.Name(), "List")
 	istypeof(t, typ.GoType(), (*types.Named)(nil))
-	is(t, typ.ReflectType(), rtype)
+	isreflectnamed(t, typ, rtype)
 	is(t, typ.NumAllMethod(), rtype.NumMethod())
 	is(t, typ1.ReflectType(), rTypeOfForward)         // Rest is actually xreflect.Incomplete
 	isidenticalgotype(t, typ1.GoType(), typ.GoType()) // but it must pretend to be a main.List
@@ -346,7 +363,7 @@ func TestEmbedded(t *testing.T) {
 	}{})
 	is(t, etyp.Kind(), r.Struct)
 	is(t, etyp.Name(), "Box")
-	is(t, etyp.ReflectType(), ertype)
+	isreflectnamed(t, etyp, ertype)
 	istypeof(t, etyp.GoType(), (*types.Named)(nil))
 	istypeof(t, etyp.GoType().Underlying(), (*types.Struct)(nil))
 
@@ -742,6 +759,124 @@ func TestInterfaceIoReadWriter(t *testing.T) {
 	is(t, trw.IdenticalTo(rw), false)
 }
 
+// test named types both with genuine named reflect.Types created at runtime,
+// and with the emulation that uses the reflect.Type of their underlying type
+func TestRuntimeNamed(t *testing.T) {
+	for _, synthesize := range []bool{false, true} {
+		if synthesize && !RuntimeNamedTypesSupported() {
+			t.Log("creating named reflect.Types at runtime is not supported, testing only emulation")
+			continue
+		}
+		name := "emulated"
+		if synthesize {
+			name = "synthesized"
+		}
+		t.Run(name, func(t *testing.T) {
+			v := NewUniverse()
+			v.RuntimeNamedTypes = synthesize
+			testRuntimeNamedBasic(t, v)
+			testRuntimeNamedStruct(t, v)
+			testRuntimeNamedList(t, v)
+			testRuntimeNamedTree(t, v)
+			testRuntimeNamedMap(t, v)
+		})
+	}
+}
+
+func testRuntimeNamedBasic(t *testing.T, v *Universe) {
+	typ := v.NamedOf("Celsius", "main")
+	typ.SetUnderlying(v.BasicTypes[r.Float64])
+	rtype := typ.ReflectType()
+	val := r.ValueOf(36.5).Convert(rtype)
+	is(t, rtype.Kind(), r.Float64)
+	is(t, val.Float(), 36.5)
+	if v.RuntimeNamedTypes {
+		is(t, rtype.Name(), "Celsius")
+		is(t, rtype.PkgPath(), "main")
+		is(t, fmt.Sprintf("%T", val.Interface()), "main.Celsius")
+		istrue(t, v.FromReflectType(rtype).IdenticalTo(typ))
+	} else {
+		is(t, rtype, r.TypeOf(float64(0)))
+	}
+}
+
+func testRuntimeNamedStruct(t *testing.T, v *Universe) {
+	typ := v.NamedOf("Pair", "main")
+	typ.SetUnderlying(v.StructOf([]StructField{
+		StructField{Name: "A", Type: v.BasicTypes[r.Int]},
+		StructField{Name: "B", Type: v.BasicTypes[r.String], Tag: `json:"b"`},
+	}))
+	rtype := typ.ReflectType()
+	val := r.New(rtype).Elem()
+	val.Field(0).SetInt(1)
+	val.Field(1).SetString("x")
+	buf, err := json.Marshal(val.Interface())
+	is(t, err, nil)
+	is(t, string(buf), `{"A":1,"b":"x"}`)
+	istrue(t, rtype.ConvertibleTo(r.TypeOf(struct {
+		A int
+		B string `json:"b"`
+	}{})))
+	if v.RuntimeNamedTypes {
+		is(t, rtype.Name(), "Pair")
+		is(t, rtype.String(), "main.Pair")
+		is(t, fmt.Sprintf("%T", val.Interface()), "main.Pair")
+	} else {
+		is(t, rtype.Name(), "")
+		is(t, rtype.String(), `struct { A int; B string "json:\"b\"" }`)
+	}
+}
+
+// type List struct { First int; Rest *List }
+func testRuntimeNamedList(t *testing.T, v *Universe) {
+	typ := v.NamedOf("List", "main")
+	typ.SetUnderlying(v.StructOf([]StructField{
+		StructField{Name: "First", Type: v.BasicTypes[r.Int]},
+		StructField{Name: "Rest", Type: v.PtrTo(typ)},
+	}))
+	rtype := typ.ReflectType()
+	rest := typ.Field(1).Type
+	is(t, rest.String(), "*main.List")
+	if !v.RuntimeNamedTypes {
+		is(t, rtype.Field(1).Type, rTypeOfForward)
+		return
+	}
+	is(t, rtype.Field(1).Type, r.PtrTo(rtype))
+	is(t, rest.ReflectType(), r.PtrTo(rtype))
+	head := r.New(rtype)
+	head.Elem().Field(1).Set(r.New(rtype))
+	head.Elem().Field(1).Elem().Field(0).SetInt(2)
+	is(t, fmt.Sprintf("%T %v", head.Interface(), head.Elem().Field(1).Elem().Interface()), "*main.List {2 <nil>}")
+}
+
+// type Tree struct { Value int; Children []Tree }
+func testRuntimeNamedTree(t *testing.T, v *Universe) {
+	typ := v.NamedOf("Tree", "main")
+	typ.SetUnderlying(v.StructOf([]StructField{
+		StructField{Name: "Value", Type: v.BasicTypes[r.Int]},
+		StructField{Name: "Children", Type: v.SliceOf(typ)},
+	}))
+	rtype := typ.ReflectType()
+	if !v.RuntimeNamedTypes {
+		is(t, rtype.Field(1).Type, rTypeOfForward)
+		return
+	}
+	is(t, rtype.Field(1).Type, r.SliceOf(rtype))
+	is(t, typ.Field(1).Type.ReflectType(), r.SliceOf(rtype))
+	tree := r.New(rtype).Elem()
+	tree.Field(1).Set(r.MakeSlice(r.SliceOf(rtype), 1, 1))
+	tree.Field(1).Index(0).Field(0).SetInt(3)
+	is(t, fmt.Sprintf("%v", tree.Interface()), "{0 [{3 []}]}")
+}
+
+// named maps cannot be created at runtime: they are always emulated
+func testRuntimeNamedMap(t *testing.T, v *Universe) {
+	typ := v.NamedOf("Env", "main")
+	typ.SetUnderlying(v.MapOf(v.BasicTypes[r.String], v.BasicTypes[r.Int]))
+	is(t, typ.Kind(), r.Map)
+	is(t, typ.ReflectType(), r.TypeOf(map[string]int{}))
+}
+
 func inspect(label string, t types.Type) {
 	debugf("%s:\t%v", label, t)
 	switch t := t.(type) {